@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultTokenTTL is how long locally issued tokens stay valid unless LISTY_JWT_TTL says otherwise
+const DefaultTokenTTL = 30 * 24 * time.Hour
+
+// tokenIssuer is the iss claim of locally issued tokens
+const tokenIssuer = "listy"
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, unsigned by a known secret or expired
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrNotAUser is returned for valid tokens that do not identify a signed-in user,
+	// such as the Supabase anon and service_role keys
+	ErrNotAUser = errors.New("token does not identify a user")
+	// ErrDisabled is returned when issuing a token without LISTY_JWT_SECRET
+	ErrDisabled = errors.New("local sign-in is not configured (set LISTY_JWT_SECRET)")
+)
+
+// Claims are the JWT claims the API reads. Supabase GoTrue access tokens
+// carry the user's UUID in sub and "authenticated" in role.
+type Claims struct {
+	Subject   string `json:"sub,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+var (
+	// signingKey signs locally issued tokens (LISTY_JWT_SECRET)
+	signingKey []byte
+	// verifyKeys are every secret a bearer token may be signed with
+	verifyKeys [][]byte
+	// tokenTTL is the lifetime of locally issued tokens
+	tokenTTL = DefaultTokenTTL
+)
+
+// now is replaced in tests to pin the clock
+var now = time.Now
+
+// Init configures authentication from LISTY_JWT_SECRET, SUPABASE_JWT_SECRET
+// and LISTY_JWT_TTL. Without either secret it fails, unless
+// LISTY_AUTH_DISABLED=1 asks for every request to act as the anonymous
+// owner. Call it after the environment has been loaded.
+func Init() error {
+	Configure(os.Getenv("LISTY_JWT_SECRET"), os.Getenv("SUPABASE_JWT_SECRET"))
+	if !Enabled() && os.Getenv("LISTY_AUTH_DISABLED") != "1" {
+		return errors.New("no JWT secret set (set LISTY_JWT_SECRET or SUPABASE_JWT_SECRET, or LISTY_AUTH_DISABLED=1 to run without authentication)")
+	}
+
+	if ttl := os.Getenv("LISTY_JWT_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid LISTY_JWT_TTL %q (expected a duration such as 720h)", ttl)
+		}
+		tokenTTL = d
+	}
+	return nil
+}
+
+// Configure sets the secret used to issue local tokens and the Supabase
+// project's JWT secret. Either may be empty; with both empty authentication
+// is disabled and every request acts as the anonymous owner.
+func Configure(localSecret, supabaseSecret string) {
+	signingKey, verifyKeys = nil, nil
+	if localSecret != "" {
+		signingKey = []byte(localSecret)
+		verifyKeys = append(verifyKeys, signingKey)
+	}
+	if supabaseSecret != "" {
+		verifyKeys = append(verifyKeys, []byte(supabaseSecret))
+	}
+}
+
+// Enabled reports whether requests must carry a bearer token
+func Enabled() bool {
+	return len(verifyKeys) > 0
+}
+
+// CanIssueTokens reports whether LISTY_JWT_SECRET is set, so password sign-in is available
+func CanIssueTokens() bool {
+	return signingKey != nil
+}
+
+// IssueToken signs a token for the user, returning it with its expiry
+func IssueToken(userId, email string) (string, time.Time, error) {
+	if signingKey == nil {
+		return "", time.Time{}, ErrDisabled
+	}
+
+	issued := now()
+	expires := issued.Add(tokenTTL)
+	token, err := sign(Claims{
+		Subject:   userId,
+		Email:     email,
+		Role:      "authenticated",
+		Issuer:    tokenIssuer,
+		IssuedAt:  issued.Unix(),
+		ExpiresAt: expires.Unix(),
+	}, signingKey)
+	return token, expires, err
+}
+
+// VerifyToken checks a token's HS256 signature against the configured
+// secrets and its expiry, and that it identifies a user
+func VerifyToken(token string) (*Claims, error) {
+	header, payload, signature, ok := splitToken(token)
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(header, &head); err != nil || head.Alg != "HS256" {
+		return nil, fmt.Errorf("%w: unsupported signing algorithm", ErrInvalidToken)
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !verifySignature(header+"."+payload, mac) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+
+	var claims Claims
+	if err := decodeSegment(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidToken)
+	}
+	if claims.ExpiresAt != 0 && now().Unix() >= claims.ExpiresAt {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if claims.Subject == "" || (claims.Role != "" && claims.Role != "authenticated") {
+		return nil, ErrNotAUser
+	}
+	return &claims, nil
+}
+
+// sign encodes claims as an HS256 JWT
+func sign(claims Claims, key []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("error encoding token claims: %v", err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) +
+		"." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(hmacSHA256(key, unsigned)), nil
+}
+
+// verifySignature reports whether mac signs unsigned with any configured secret
+func verifySignature(unsigned string, mac []byte) bool {
+	for _, key := range verifyKeys {
+		if hmac.Equal(mac, hmacSHA256(key, unsigned)) {
+			return true
+		}
+	}
+	return false
+}
+
+func hmacSHA256(key []byte, message string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(message))
+	return h.Sum(nil)
+}
+
+// splitToken splits a compact JWT into its three segments
+func splitToken(token string) (header, payload, signature string, ok bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", "", "", false
+	}
+	return parts[0], parts[1], parts[2], true
+}
+
+// decodeSegment decodes a base64url JSON segment into v
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
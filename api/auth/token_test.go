@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useSecrets configures authentication for the duration of a test
+func useSecrets(t *testing.T, localSecret, supabaseSecret string) {
+	Configure(localSecret, supabaseSecret)
+	t.Cleanup(func() { Configure("", "") })
+}
+
+func TestIssueAndVerifyToken(t *testing.T) {
+	useSecrets(t, "local-secret", "supabase-secret")
+
+	token, expires, err := IssueToken("user-1", "ada@example.com")
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	if d := time.Until(expires); d < DefaultTokenTTL-time.Minute || d > DefaultTokenTTL {
+		t.Errorf("IssueToken() expires in %v, want about %v", d, DefaultTokenTTL)
+	}
+
+	claims, err := VerifyToken(token)
+	if err != nil {
+		t.Fatalf("VerifyToken() error = %v", err)
+	}
+	if claims.Subject != "user-1" || claims.Email != "ada@example.com" {
+		t.Errorf("VerifyToken() claims = %+v", claims)
+	}
+}
+
+func TestVerifyToken_SupabaseTokens(t *testing.T) {
+	useSecrets(t, "", "supabase-secret")
+
+	// Shaped like a GoTrue access token, signed with the project's JWT secret
+	user, _ := sign(Claims{Subject: "8d0a6b43-3a52-4a8e-9f0b-2a5b7b1c9e11", Role: "authenticated", ExpiresAt: time.Now().Add(time.Hour).Unix()}, []byte("supabase-secret"))
+	if _, err := VerifyToken(user); err != nil {
+		t.Errorf("VerifyToken(user token) error = %v", err)
+	}
+
+	anon, _ := sign(Claims{Role: "anon"}, []byte("supabase-secret"))
+	if _, err := VerifyToken(anon); !errors.Is(err, ErrNotAUser) {
+		t.Errorf("VerifyToken(anon key) error = %v, want ErrNotAUser", err)
+	}
+
+	if _, _, err := IssueToken("user-1", ""); !errors.Is(err, ErrDisabled) {
+		t.Errorf("IssueToken() without LISTY_JWT_SECRET error = %v, want ErrDisabled", err)
+	}
+}
+
+func TestVerifyToken_Rejects(t *testing.T) {
+	useSecrets(t, "local-secret", "")
+
+	valid, _, err := IssueToken("user-1", "")
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	header, payload, _, _ := splitToken(valid)
+	otherSecret, _ := sign(Claims{Subject: "user-1"}, []byte("guessed"))
+	expired, _ := sign(Claims{Subject: "user-1", ExpiresAt: time.Now().Add(-time.Minute).Unix()}, []byte("local-secret"))
+
+	tests := map[string]string{
+		"empty":        "",
+		"not a jwt":    "abc.def",
+		"other secret": otherSecret,
+		"expired":      expired,
+		"unsigned":     strings.Replace(header, "J", "K", 1) + "." + payload + ".",
+		"alg none":     "eyJhbGciOiJub25lIn0." + payload + ".",
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := VerifyToken(token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("VerifyToken() error = %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+}
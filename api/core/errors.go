@@ -0,0 +1,30 @@
+package core
+
+import "errors"
+
+// Kinds of error. The sentinel errors of the API's packages are each of one
+// kind, so callers can tell a missing todo from a missing list or a bad
+// request from a failing store with errors.Is, and the API reports each kind
+// under its own status and code. The services package exports them.
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrValidation = errors.New("invalid request")
+	ErrConflict   = errors.New("conflict")
+	ErrUpstreamAI = errors.New("AI service failed")
+)
+
+// kindError is an error with its own message that is of a kind
+type kindError struct {
+	message string
+	kind    error
+}
+
+func (e *kindError) Error() string { return e.message }
+
+func (e *kindError) Unwrap() error { return e.kind }
+
+// NewError returns a sentinel error with message that errors.Is matches
+// against kind, e.g. NewError(ErrNotFound, "list not found")
+func NewError(kind error, message string) error {
+	return &kindError{message: message, kind: kind}
+}
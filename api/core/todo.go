@@ -0,0 +1,109 @@
+// Package core holds the todo model and the domain logic shared by the API,
+// the listy CLI and the todolist binary at the repository root. It lives in
+// the API module so the API still builds on its own (e.g. in its Docker
+// image); the root module imports it through a replace directive.
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Todo represents a todo item
+type Todo struct {
+	Id        int        `json:"id"`
+	Item      string     `json:"item"`
+	Done      bool       `json:"done"`
+	ListId    *string    `json:"list_id,omitempty"` // NULL means main list, otherwise it's a list identifier
+	CreatedAt time.Time  `json:"created_at"`
+	Version   int        `json:"version"`              // Incremented by every change; also sent as the ETag
+	OwnerId   string     `json:"owner_id,omitempty"`   // User the todo belongs to; empty when authentication is disabled
+	DueAt     *time.Time `json:"due_at,omitempty"`     // RFC 3339 timestamp with offset
+	RemindAt  *time.Time `json:"remind_at,omitempty"`  // When to remind about the todo, if ever
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // Set while the todo is in the trash
+
+	Priority      string `json:"priority,omitempty"`       // "high", "medium", "low" or empty
+	EstimatedTime string `json:"estimated_time,omitempty"` // Free text, e.g. "15 minutes"
+	Category      string `json:"category,omitempty"`
+
+	ParentId     *int `json:"parent_id,omitempty"` // Set on subtasks; NULL for top-level todos
+	AutoComplete bool `json:"auto_complete"`       // Mark done automatically once every subtask is done
+
+	// iCalendar RRULE such as "FREQ=WEEKLY;BYDAY=MO", optionally after a
+	// DTSTART line. Completing the todo adds its next occurrence, which
+	// takes the rule over; reopening it takes the rule back while that
+	// occurrence is still pending.
+	Recurrence string `json:"recurrence,omitempty"`
+	PreviousId *int   `json:"previous_id,omitempty"` // Set on an occurrence to the todo whose completion added it
+
+	Tags []string `json:"tags,omitempty"` // Lower case without the #, sorted
+
+	// Filled in by the services layer for responses; never stored
+	Subtasks *SubtaskProgress `json:"subtasks,omitempty"`
+	Children []Todo           `json:"children,omitempty"` // Only with ?include=children
+	Next     *Todo            `json:"next,omitempty"`     // The occurrence added by completing a recurring todo
+}
+
+// SubtaskProgress rolls up a todo's direct subtasks, e.g. 3 of 5 done
+type SubtaskProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// UpdateItem sets the todo's text; empty text leaves it unchanged
+func (i *Todo) UpdateItem(Uname string) {
+	if Uname != "" {
+		i.Item = Uname
+	}
+}
+
+func (i *Todo) MarkComplete() {
+	i.Done = true
+}
+
+func (i *Todo) MarkIncomplete() {
+	i.Done = false
+}
+
+func (i *Todo) ToggleDone() {
+	i.Done = !(i.Done)
+}
+
+// String formats a todo as {id item done}, followed by whichever of its
+// subtask progress, priority, estimate, category, tags, due date,
+// recurrence and deletion time are set
+func (t Todo) String() string {
+	s := fmt.Sprintf("{%d %s %v}", t.Id, t.Item, t.Done)
+	if t.Subtasks != nil {
+		s += fmt.Sprintf(" (%d/%d done)", t.Subtasks.Done, t.Subtasks.Total)
+	}
+	if t.Priority != "" {
+		s += " [" + t.Priority + "]"
+	}
+	if t.EstimatedTime != "" {
+		s += " ~" + t.EstimatedTime
+	}
+	if t.Category != "" {
+		s += " #" + t.Category
+	}
+	if len(t.Tags) > 0 {
+		s += " tagged #" + strings.Join(t.Tags, " #")
+	}
+	if t.DueAt != nil {
+		s += " due " + t.DueAt.Local().Format("Mon Jan 2 2006 15:04")
+	}
+	if t.Recurrence != "" {
+		s += " repeats " + DescribeRecurrence(t.Recurrence)
+	}
+	if t.DeletedAt != nil {
+		s += " deleted " + t.DeletedAt.Local().Format("Mon Jan 2 2006 15:04")
+	}
+	return s
+}
+
+// DescribeRecurrence shortens a rule for display by leaving out its DTSTART line
+func DescribeRecurrence(rule string) string {
+	lines := strings.Split(rule, "\n")
+	return strings.TrimPrefix(strings.TrimSpace(lines[len(lines)-1]), "RRULE:")
+}
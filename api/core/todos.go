@@ -0,0 +1,127 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+)
+
+// AddTodos appends a pending todo with the next ID and advances nextID
+func AddTodos(todos []Todo, NextItem string, nextID *int) []Todo {
+	newTodo := Todo{
+		Id:   *nextID,
+		Item: NextItem,
+		Done: false,
+	}
+	todos = append(todos, newTodo)
+	*nextID++
+	return todos
+}
+
+// SortById sorts todos by ID in place
+func SortById(todos []Todo) {
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].Id < todos[j].Id
+	})
+}
+
+// PendingTodos returns the todos not yet done, in their original order
+func PendingTodos(todos []Todo) []Todo {
+	return filterTodos(todos, false)
+}
+
+// CompletedTodos returns the done todos, in their original order
+func CompletedTodos(todos []Todo) []Todo {
+	return filterTodos(todos, true)
+}
+
+func filterTodos(todos []Todo, done bool) []Todo {
+	matched := []Todo{}
+	for _, todo := range todos {
+		if todo.Done == done {
+			matched = append(matched, todo)
+		}
+	}
+	return matched
+}
+
+// FindTodos returns the index of the first todo with exactly this text and
+// a pointer into todos, or -1 and nil
+func FindTodos(todos []Todo, ItemName string) (int, *Todo) {
+	for i, todo := range todos {
+		if todo.Item == ItemName {
+			return i, &todos[i]
+		}
+	}
+	return -1, nil
+}
+
+// FindTodosById returns the index of the todo with Id and a pointer into
+// todos, or -1 and nil
+func FindTodosById(todos []Todo, Id int) (int, *Todo) {
+	for i, todo := range todos {
+		if todo.Id == Id {
+			return i, &todos[i]
+		}
+	}
+	return -1, nil
+}
+
+// RemoveTodos removes the todo with Id, reusing the backing array
+func RemoveTodos(todos []Todo, Id int) ([]Todo, error) {
+	i, _ := FindTodosById(todos, Id)
+	if i == -1 {
+		return todos, fmt.Errorf("todo with Id %d %w", Id, ErrNotFound)
+	}
+	todos = append(todos[:i], todos[i+1:]...)
+	return todos, nil
+}
+
+func MarkCompleteByID(todos []Todo, Id int) error {
+	_, todo := FindTodosById(todos, Id)
+	if todo == nil {
+		return fmt.Errorf("todo with Id %d %w", Id, ErrNotFound)
+	}
+	todo.MarkComplete()
+	return nil
+}
+
+func MarkIncompleteByID(todos []Todo, Id int) error {
+	_, todo := FindTodosById(todos, Id)
+	if todo == nil {
+		return fmt.Errorf("todo with Id %d %w", Id, ErrNotFound)
+	}
+	todo.MarkIncomplete()
+	return nil
+}
+
+func ToggleDoneByID(todos []Todo, Id int) error {
+	_, todo := FindTodosById(todos, Id)
+	if todo == nil {
+		return fmt.Errorf("todo with Id %d %w", Id, ErrNotFound)
+	}
+	todo.ToggleDone()
+	return nil
+}
+
+func UpdateItemByID(todos []Todo, Id int, Newname string) error {
+	_, todo := FindTodosById(todos, Id)
+	if todo == nil {
+		return fmt.Errorf("todo with Id %d %w", Id, ErrNotFound)
+	}
+	todo.UpdateItem(Newname)
+	return nil
+}
+
+// GetNextID calculates the next ID based on existing todos
+func GetNextID(todos []Todo) int {
+	if len(todos) == 0 {
+		return 1
+	}
+	maxID := 0
+	for _, todo := range todos {
+		if todo.Id > maxID {
+			maxID = todo.Id
+		}
+	}
+	return maxID + 1
+}
@@ -0,0 +1,407 @@
+package database
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"sync"
+
+	"listy-api/models"
+)
+
+// MemoryStore is a TodoStore that keeps todos in process memory.
+// It is intended for local development and tests.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	todos   map[int]models.Todo
+	lists   map[string]models.List
+	members map[memberKey]models.ListMember
+	users   map[string]models.User          // Keyed by lower-case email
+	tokens  map[string]models.CalendarToken // Keyed by user ID
+	lastID  int
+
+	activity []models.Activity // In insertion order, so also by ID
+}
+
+// memberKey identifies a user's membership of a list
+type memberKey struct {
+	listId, userId string
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		todos:   make(map[int]models.Todo),
+		lists:   make(map[string]models.List),
+		members: make(map[memberKey]models.ListMember),
+		users:   make(map[string]models.User),
+		tokens:  make(map[string]models.CalendarToken),
+	}
+}
+
+// InsertTodo adds a todo to the store under the next unused ID
+func (s *MemoryStore) InsertTodo(todo models.Todo) (*models.Todo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	todo.Id = s.lastID
+	todo.Version = 1
+	s.todos[todo.Id] = copyTodo(todo)
+	todo = copyTodo(todo)
+	return &todo, nil
+}
+
+// UpdateTodo replaces the todo with the given ID if its version still matches
+func (s *MemoryStore) UpdateTodo(id int, todo models.Todo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.todos[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Version != todo.Version {
+		return ErrVersionConflict
+	}
+	todo.Version++
+	s.todos[id] = copyTodo(todo)
+	return nil
+}
+
+// DeleteTodo removes the todo with the given ID
+func (s *MemoryStore) DeleteTodo(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.todos[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.todos, id)
+	return nil
+}
+
+// QueryTodos returns copies of the todos matching q
+func (s *MemoryStore) QueryTodos(q TodoQuery) ([]models.Todo, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	todos := make([]models.Todo, 0, len(s.todos))
+	for _, todo := range s.todos {
+		todos = append(todos, copyTodo(todo))
+	}
+	return q.apply(todos), nil
+}
+
+// CountTags counts the todos matching q per tag
+func (s *MemoryStore) CountTags(q TodoQuery) ([]models.Tag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var todos []models.Todo
+	for _, todo := range s.todos {
+		if q.Matches(todo) {
+			todos = append(todos, todo)
+		}
+	}
+	return countTags(todos), nil
+}
+
+// GetTodo returns a copy of the todo with the given ID
+func (s *MemoryStore) GetTodo(id int) (*models.Todo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	todo, ok := s.todos[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	todo = copyTodo(todo)
+	return &todo, nil
+}
+
+// copyTodo copies a todo with its own tags, so the todos the store holds
+// and those it hands out never share a backing array
+func copyTodo(todo models.Todo) models.Todo {
+	todo.Tags = slices.Clone(todo.Tags)
+	return todo
+}
+
+// InsertActivity appends an entry to the audit log under the next ID
+func (s *MemoryStore) InsertActivity(activity models.Activity) (*models.Activity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	activity.Id = len(s.activity) + 1
+	s.activity = append(s.activity, activity)
+	return &activity, nil
+}
+
+// QueryActivity returns copies of the audit log entries matching q
+func (s *MemoryStore) QueryActivity(q ActivityQuery) ([]models.Activity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []models.Activity{}
+	for _, activity := range s.activity {
+		if q.Limit > 0 && len(result) == q.Limit {
+			break
+		}
+		if q.Matches(activity) {
+			result = append(result, activity)
+		}
+	}
+	return result, nil
+}
+
+// InsertList adds a list unless its ID is already taken
+func (s *MemoryStore) InsertList(list models.List) (*models.List, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lists[list.Id]; ok {
+		return nil, ErrListExists
+	}
+	list.PendingCount, list.DoneCount = 0, 0
+	s.lists[list.Id] = list
+	return &list, nil
+}
+
+// UpdateList replaces the list with the same ID
+func (s *MemoryStore) UpdateList(list models.List) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lists[list.Id]; !ok {
+		return ErrListNotFound
+	}
+	s.lists[list.Id] = list
+	return nil
+}
+
+// DeleteList removes a list with its members and moves its todos to the main list
+func (s *MemoryStore) DeleteList(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.lists[id]; !ok {
+		return ErrListNotFound
+	}
+	for todoID, todo := range s.todos {
+		if todo.ListId == nil || *todo.ListId != id {
+			continue
+		}
+		todo.ListId = nil
+		todo.Version++
+		s.todos[todoID] = todo
+	}
+	for key := range s.members {
+		if key.listId == id {
+			delete(s.members, key)
+		}
+	}
+	delete(s.lists, id)
+	return nil
+}
+
+// GetList returns a copy of the list with the given ID
+func (s *MemoryStore) GetList(id string) (*models.List, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list, ok := s.lists[id]
+	if !ok {
+		return nil, ErrListNotFound
+	}
+	s.countTodos(&list)
+	return &list, nil
+}
+
+// QueryLists returns copies of the lists matching q, ordered by position
+func (s *MemoryStore) QueryLists(q ListQuery) ([]models.List, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	lists := []models.List{}
+	for _, list := range s.lists {
+		if list.OwnerId != q.OwnerId && !slices.Contains(q.SharedIds, list.Id) {
+			continue
+		}
+		if list.Archived && !q.IncludeArchived {
+			continue
+		}
+		s.countTodos(&list)
+		lists = append(lists, list)
+	}
+	sortLists(lists)
+	return lists, nil
+}
+
+// InsertMember adds a member unless the user already has a role in the list
+func (s *MemoryStore) InsertMember(member models.ListMember) (*models.ListMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memberKey{member.ListId, member.UserId}
+	if _, ok := s.members[key]; ok {
+		return nil, ErrMemberExists
+	}
+	s.members[key] = member
+	return &member, nil
+}
+
+// UpdateMember replaces the member's role
+func (s *MemoryStore) UpdateMember(member models.ListMember) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memberKey{member.ListId, member.UserId}
+	existing, ok := s.members[key]
+	if !ok {
+		return ErrMemberNotFound
+	}
+	existing.Role = member.Role
+	s.members[key] = existing
+	return nil
+}
+
+// DeleteMember removes the user from the list
+func (s *MemoryStore) DeleteMember(listId, userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := memberKey{listId, userId}
+	if _, ok := s.members[key]; !ok {
+		return ErrMemberNotFound
+	}
+	delete(s.members, key)
+	return nil
+}
+
+// GetMember returns a copy of the user's membership of the list
+func (s *MemoryStore) GetMember(listId, userId string) (*models.ListMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	member, ok := s.members[memberKey{listId, userId}]
+	if !ok {
+		return nil, ErrMemberNotFound
+	}
+	return &member, nil
+}
+
+// QueryMembers returns copies of the list's members
+func (s *MemoryStore) QueryMembers(listId string) ([]models.ListMember, error) {
+	return s.queryMembers(func(key memberKey) bool { return key.listId == listId }), nil
+}
+
+// QueryMemberships returns copies of the user's memberships
+func (s *MemoryStore) QueryMemberships(userId string) ([]models.ListMember, error) {
+	return s.queryMembers(func(key memberKey) bool { return key.userId == userId }), nil
+}
+
+// queryMembers returns the members whose key matches, oldest first
+func (s *MemoryStore) queryMembers(match func(memberKey) bool) []models.ListMember {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	members := []models.ListMember{}
+	for key, member := range s.members {
+		if match(key) {
+			members = append(members, member)
+		}
+	}
+	slices.SortFunc(members, func(a, b models.ListMember) int {
+		return cmp.Or(
+			a.CreatedAt.Compare(b.CreatedAt),
+			strings.Compare(a.ListId, b.ListId),
+			strings.Compare(a.UserId, b.UserId),
+		)
+	})
+	return members
+}
+
+// InsertUser adds a user unless the email is already registered
+func (s *MemoryStore) InsertUser(user models.User) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user.Email = strings.ToLower(user.Email)
+	if _, ok := s.users[user.Email]; ok {
+		return nil, ErrUserExists
+	}
+	s.users[user.Email] = user
+	return &user, nil
+}
+
+// GetUserByEmail returns a copy of the user registered with the email
+func (s *MemoryStore) GetUserByEmail(email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[strings.ToLower(email)]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
+// SetCalendarToken stores the user's calendar token, replacing any earlier one
+func (s *MemoryStore) SetCalendarToken(token models.CalendarToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token.UserId] = token
+	return nil
+}
+
+// DeleteCalendarToken removes the user's calendar token, if any
+func (s *MemoryStore) DeleteCalendarToken(userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, userId)
+	return nil
+}
+
+// GetCalendarToken returns a copy of the calendar token with the given hash
+func (s *MemoryStore) GetCalendarToken(tokenHash string) (*models.CalendarToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, token := range s.tokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrCalendarTokenNotFound
+}
+
+// countTodos fills in the list's todo counts; the caller must hold s.mu
+func (s *MemoryStore) countTodos(list *models.List) {
+	list.PendingCount, list.DoneCount = 0, 0
+	for _, todo := range s.todos {
+		if todo.ListId == nil || *todo.ListId != list.Id || todo.DeletedAt != nil {
+			continue
+		}
+		if todo.Done {
+			list.DoneCount++
+		} else {
+			list.PendingCount++
+		}
+	}
+}
+
+// sortLists orders lists by position, then ID, the order every store returns them in
+func sortLists(lists []models.List) {
+	slices.SortFunc(lists, func(a, b models.List) int {
+		if c := cmp.Compare(a.Position, b.Position); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+}
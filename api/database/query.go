@@ -0,0 +1,295 @@
+package database
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"listy-api/models"
+)
+
+// TodoQuery describes which todos a store should return and in what order.
+// The zero value matches every todo, ordered by ID.
+type TodoQuery struct {
+	// When set, only todos of this owner are returned; an empty owner
+	// selects todos without one (owner_id IS NULL)
+	Owner *string
+	// With Owner, todos in these lists are returned too, whoever owns them
+	SharedLists []string
+
+	Done *bool // Only todos with this done state
+
+	// When FilterList is set, only todos in ListId are returned;
+	// a nil ListId selects the main list (list_id IS NULL)
+	FilterList bool
+	ListId     *string
+
+	Search string // Case-insensitive substring match on item
+
+	DueAfter  *time.Time // Only todos due at or after this instant
+	DueBefore *time.Time // Only todos due strictly before this instant
+
+	Priority string // Only todos with this priority
+	Category string // Only todos in this category, compared case-insensitively
+
+	TopLevel  bool  // Only todos without a parent
+	ParentIds []int // Only subtasks of these todos
+
+	PreviousId *int // Only the occurrences added by completing this todo
+
+	// Only todos with every one of these tags, or with any of them when
+	// AnyTag is set; the tags must be distinct
+	Tags   []string
+	AnyTag bool
+
+	// Todos in the trash are left out unless Trashed is set, which selects
+	// only them; DeletedBefore then narrows it to those deleted before the instant
+	Trashed       bool
+	DeletedBefore *time.Time
+
+	Sort []SortField // Applied in order; ID ascending is always the final tie-breaker
+
+	Limit  int // Maximum number of todos to return; 0 means no limit
+	Offset int // Number of matching todos to skip
+}
+
+// ListQuery describes which lists QueryLists returns, ordered by position then ID
+type ListQuery struct {
+	OwnerId         string   // Lists of this owner; empty selects lists without one
+	SharedIds       []string // Plus these lists, whoever owns them
+	IncludeArchived bool
+}
+
+// ActivityQuery describes which audit log entries QueryActivity returns, oldest first
+type ActivityQuery struct {
+	TodoId int // Only entries about this todo; 0 selects every todo
+
+	// Like TodoQuery's: entries for todos of this owner, or in these lists
+	Owner       *string
+	SharedLists []string
+
+	Since *time.Time // Only entries recorded strictly after this instant
+	Limit int        // Maximum number of entries to return; 0 means no limit
+}
+
+// Matches reports whether an entry satisfies the query's filters
+func (q ActivityQuery) Matches(activity models.Activity) bool {
+	if q.TodoId != 0 && activity.TodoId != q.TodoId {
+		return false
+	}
+	if q.Owner != nil && activity.OwnerId != *q.Owner && (activity.ListId == nil || !slices.Contains(q.SharedLists, *activity.ListId)) {
+		return false
+	}
+	if q.Since != nil && !activity.At.After(*q.Since) {
+		return false
+	}
+	return true
+}
+
+// SortField orders query results by a single column
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// todoComparators holds the sortable columns and how to compare them in memory.
+// Keys double as the whitelist of column names the SQL backends may order by.
+var todoComparators = map[string]func(a, b models.Todo) int{
+	"id":         func(a, b models.Todo) int { return cmp.Compare(a.Id, b.Id) },
+	"item":       func(a, b models.Todo) int { return strings.Compare(a.Item, b.Item) },
+	"done":       func(a, b models.Todo) int { return compareBool(a.Done, b.Done) },
+	"list_id":    func(a, b models.Todo) int { return strings.Compare(deref(a.ListId), deref(b.ListId)) },
+	"created_at": func(a, b models.Todo) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"due_at":     func(a, b models.Todo) int { return deref(a.DueAt).Compare(deref(b.DueAt)) },
+	"remind_at":  func(a, b models.Todo) int { return deref(a.RemindAt).Compare(deref(b.RemindAt)) },
+	"category":   func(a, b models.Todo) int { return strings.Compare(a.Category, b.Category) },
+	"deleted_at": func(a, b models.Todo) int { return deref(a.DeletedAt).Compare(deref(b.DeletedAt)) },
+}
+
+// todoNullable reports, for nullable sort columns, whether a todo's value is NULL.
+// NULLs sort last in both directions, matching PostgREST's default.
+var todoNullable = map[string]func(todo models.Todo) bool{
+	"list_id":    func(todo models.Todo) bool { return todo.ListId == nil },
+	"due_at":     func(todo models.Todo) bool { return todo.DueAt == nil },
+	"remind_at":  func(todo models.Todo) bool { return todo.RemindAt == nil },
+	"category":   func(todo models.Todo) bool { return todo.Category == "" },
+	"deleted_at": func(todo models.Todo) bool { return todo.DeletedAt == nil },
+}
+
+// IsSortField reports whether field can be used in a SortField
+func IsSortField(field string) bool {
+	_, ok := todoComparators[field]
+	return ok
+}
+
+// Validate checks that every sort field is supported and the window is sane
+func (q TodoQuery) Validate() error {
+	if q.Limit < 0 || q.Offset < 0 {
+		return fmt.Errorf("limit and offset must not be negative")
+	}
+	for _, s := range q.Sort {
+		if !IsSortField(s.Field) {
+			return fmt.Errorf("unsupported sort field %q", s.Field)
+		}
+	}
+	return nil
+}
+
+// orderBy returns the sort fields with the ID tie-breaker appended
+func (q TodoQuery) orderBy() []SortField {
+	order := make([]SortField, 0, len(q.Sort)+1)
+	for _, s := range q.Sort {
+		if s.Field == "id" {
+			return append(order, s)
+		}
+		order = append(order, s)
+	}
+	return append(order, SortField{Field: "id"})
+}
+
+// Matches reports whether todo satisfies the query's filters
+func (q TodoQuery) Matches(todo models.Todo) bool {
+	if q.Owner != nil && todo.OwnerId != *q.Owner && (todo.ListId == nil || !slices.Contains(q.SharedLists, *todo.ListId)) {
+		return false
+	}
+	if q.Done != nil && todo.Done != *q.Done {
+		return false
+	}
+	if q.FilterList {
+		if q.ListId == nil {
+			if todo.ListId != nil {
+				return false
+			}
+		} else if todo.ListId == nil || *todo.ListId != *q.ListId {
+			return false
+		}
+	}
+	if q.Search != "" && !strings.Contains(strings.ToLower(todo.Item), strings.ToLower(q.Search)) {
+		return false
+	}
+	if q.DueAfter != nil && (todo.DueAt == nil || todo.DueAt.Before(*q.DueAfter)) {
+		return false
+	}
+	if q.DueBefore != nil && (todo.DueAt == nil || !todo.DueAt.Before(*q.DueBefore)) {
+		return false
+	}
+	if q.Priority != "" && todo.Priority != q.Priority {
+		return false
+	}
+	if q.Category != "" && !strings.EqualFold(todo.Category, q.Category) {
+		return false
+	}
+	if q.TopLevel && todo.ParentId != nil {
+		return false
+	}
+	if q.ParentIds != nil && (todo.ParentId == nil || !slices.Contains(q.ParentIds, *todo.ParentId)) {
+		return false
+	}
+	if q.PreviousId != nil && (todo.PreviousId == nil || *todo.PreviousId != *q.PreviousId) {
+		return false
+	}
+	if len(q.Tags) > 0 {
+		matched := 0
+		for _, tag := range q.Tags {
+			if slices.Contains(todo.Tags, tag) {
+				matched++
+			}
+		}
+		if matched == 0 || (!q.AnyTag && matched < len(q.Tags)) {
+			return false
+		}
+	}
+	if (todo.DeletedAt != nil) != q.Trashed {
+		return false
+	}
+	if q.DeletedBefore != nil && (todo.DeletedAt == nil || !todo.DeletedAt.Before(*q.DeletedBefore)) {
+		return false
+	}
+	return true
+}
+
+// apply filters and sorts todos in memory according to the query
+func (q TodoQuery) apply(todos []models.Todo) []models.Todo {
+	result := make([]models.Todo, 0, len(todos))
+	for _, todo := range todos {
+		if q.Matches(todo) {
+			result = append(result, todo)
+		}
+	}
+
+	order := q.orderBy()
+	slices.SortFunc(result, func(a, b models.Todo) int {
+		for _, s := range order {
+			if isNull, ok := todoNullable[s.Field]; ok {
+				if c := compareBool(isNull(a), isNull(b)); c != 0 {
+					return c
+				}
+			}
+			c := todoComparators[s.Field](a, b)
+			if s.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	if q.Offset >= len(result) {
+		return []models.Todo{}
+	}
+	result = result[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(result) {
+		result = result[:q.Limit]
+	}
+	return result
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// deref returns the value behind p, or the zero value when p is nil
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// countTags counts pending and done todos per tag, ordered by name
+func countTags(todos []models.Todo) []models.Tag {
+	counts := map[string]*models.Tag{}
+	for _, todo := range todos {
+		for _, name := range todo.Tags {
+			tag, ok := counts[name]
+			if !ok {
+				tag = &models.Tag{Name: name}
+				counts[name] = tag
+			}
+			if todo.Done {
+				tag.DoneCount++
+			} else {
+				tag.PendingCount++
+			}
+		}
+	}
+
+	tags := make([]models.Tag, 0, len(counts))
+	for _, tag := range counts {
+		tags = append(tags, *tag)
+	}
+	slices.SortFunc(tags, func(a, b models.Tag) int { return strings.Compare(a.Name, b.Name) })
+	return tags
+}
@@ -0,0 +1,1035 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"listy-api/models"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteMigrations are applied in order; PRAGMA user_version tracks how many have run
+var sqliteMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS todos (
+		id      INTEGER PRIMARY KEY,
+		item    TEXT    NOT NULL,
+		done    INTEGER NOT NULL DEFAULT 0,
+		list_id TEXT
+	)`,
+	// AUTOINCREMENT guarantees IDs of deleted todos are never handed out again
+	`CREATE TABLE todos_new (
+		id      INTEGER PRIMARY KEY AUTOINCREMENT,
+		item    TEXT    NOT NULL,
+		done    INTEGER NOT NULL DEFAULT 0,
+		list_id TEXT
+	);
+	INSERT INTO todos_new (id, item, done, list_id) SELECT id, item, done, list_id FROM todos;
+	DROP TABLE todos;
+	ALTER TABLE todos_new RENAME TO todos`,
+	`ALTER TABLE todos ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+	UPDATE todos SET created_at = strftime('%Y-%m-%dT%H:%M:%S.000000000Z', 'now')`,
+	`ALTER TABLE todos ADD COLUMN due_at TEXT;
+	ALTER TABLE todos ADD COLUMN remind_at TEXT;
+	CREATE INDEX todos_due_at ON todos (due_at)`,
+	`ALTER TABLE todos ADD COLUMN priority TEXT;
+	ALTER TABLE todos ADD COLUMN estimated_time TEXT;
+	ALTER TABLE todos ADD COLUMN category TEXT`,
+	// Lists used to exist only as todo list_id values; backfill a record for each
+	`CREATE TABLE lists (
+		id         TEXT    PRIMARY KEY,
+		name       TEXT    NOT NULL,
+		color      TEXT,
+		position   INTEGER NOT NULL DEFAULT 0,
+		archived   INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT    NOT NULL DEFAULT ''
+	);
+	INSERT INTO lists (id, name, created_at)
+		SELECT DISTINCT list_id, list_id, strftime('%Y-%m-%dT%H:%M:%S.000000000Z', 'now')
+		FROM todos WHERE list_id IS NOT NULL AND list_id != '';
+	CREATE INDEX todos_list_id ON todos (list_id)`,
+	`ALTER TABLE todos ADD COLUMN parent_id INTEGER;
+	ALTER TABLE todos ADD COLUMN auto_complete INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX todos_parent_id ON todos (parent_id)`,
+	// Existing todos and lists keep a NULL owner and stay with the anonymous user
+	`ALTER TABLE todos ADD COLUMN owner_id TEXT;
+	ALTER TABLE lists ADD COLUMN owner_id TEXT;
+	CREATE INDEX todos_owner_id ON todos (owner_id);
+	CREATE TABLE users (
+		id            TEXT PRIMARY KEY,
+		email         TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		created_at    TEXT NOT NULL
+	)`,
+	`CREATE TABLE list_members (
+		list_id    TEXT NOT NULL,
+		user_id    TEXT NOT NULL,
+		email      TEXT,
+		role       TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		PRIMARY KEY (list_id, user_id)
+	);
+	CREATE INDEX list_members_user_id ON list_members (user_id)`,
+	`ALTER TABLE todos ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE todos ADD COLUMN deleted_at TEXT;
+	CREATE INDEX todos_deleted_at ON todos (deleted_at)`,
+	`CREATE TABLE activity (
+		id       INTEGER PRIMARY KEY AUTOINCREMENT,
+		todo_id  INTEGER NOT NULL,
+		actor_id TEXT,
+		action   TEXT NOT NULL,
+		changes  TEXT,
+		at       TEXT NOT NULL,
+		owner_id TEXT,
+		list_id  TEXT
+	);
+	CREATE INDEX activity_todo_id ON activity (todo_id);
+	CREATE INDEX activity_at ON activity (at)`,
+	`ALTER TABLE todos ADD COLUMN recurrence TEXT`,
+	`CREATE TABLE todo_tags (
+		todo_id INTEGER NOT NULL,
+		tag     TEXT NOT NULL,
+		PRIMARY KEY (todo_id, tag)
+	);
+	CREATE INDEX todo_tags_tag ON todo_tags (tag);
+	CREATE TRIGGER todo_tags_delete AFTER DELETE ON todos BEGIN
+		DELETE FROM todo_tags WHERE todo_id = OLD.id;
+	END`,
+	`CREATE TABLE calendar_tokens (
+		user_id    TEXT PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_at TEXT NOT NULL
+	)`,
+	`ALTER TABLE todos ADD COLUMN previous_id INTEGER;
+	CREATE INDEX todos_previous_id ON todos (previous_id)`,
+}
+
+// sqliteTimeFormat is fixed-width UTC so stored timestamps sort correctly as text
+const sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// sqliteTodoColumns are the writable todo columns, in the order returned by sqliteTodoValues
+var sqliteTodoColumns = []string{
+	"item", "done", "list_id", "created_at", "due_at", "remind_at",
+	"priority", "estimated_time", "category",
+	"parent_id", "auto_complete", "owner_id", "deleted_at", "recurrence", "previous_id",
+}
+
+// sqliteSelectTodos selects the columns scanned by scanTodo
+var sqliteSelectTodos = "SELECT id, version, " + strings.Join(sqliteTodoColumns, ", ") + " FROM todos"
+
+// sqliteTodoValues returns the values for sqliteTodoColumns
+func sqliteTodoValues(todo models.Todo) []any {
+	return []any{
+		todo.Item, todo.Done, todo.ListId, formatSQLiteTime(todo.CreatedAt),
+		formatSQLiteNullTime(todo.DueAt), formatSQLiteNullTime(todo.RemindAt),
+		sqliteNullString(todo.Priority), sqliteNullString(todo.EstimatedTime), sqliteNullString(todo.Category),
+		todo.ParentId, todo.AutoComplete, sqliteNullString(todo.OwnerId), formatSQLiteNullTime(todo.DeletedAt),
+		sqliteNullString(todo.Recurrence), todo.PreviousId,
+	}
+}
+
+// SQLiteStore is a TodoStore backed by an embedded SQLite database file
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the SQLite database at path and migrates its schema
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %v", err)
+	}
+	// SQLite allows a single writer; serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	store := &SQLiteStore{db: db}
+	if err := store.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Close closes the underlying database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("error reading SQLite schema version: %v", err)
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("error starting SQLite migration %d: %v", i+1, err)
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error applying SQLite migration %d: %v", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error updating SQLite schema version: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error committing SQLite migration %d: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+// InsertTodo inserts a single todo and its tags into SQLite, letting SQLite allocate the ID
+func (s *SQLiteStore) InsertTodo(todo models.Todo) (*models.Todo, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error inserting todo to SQLite: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO todos ("+strings.Join(sqliteTodoColumns, ", ")+") VALUES ("+sqlitePlaceholders(len(sqliteTodoColumns))+")",
+		sqliteTodoValues(todo)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting todo to SQLite: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("error reading inserted todo ID: %v", err)
+	}
+	todo.Id = int(id)
+	todo.Version = 1
+
+	if err := replaceSQLiteTags(tx, todo.Id, todo.Tags); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error inserting todo to SQLite: %v", err)
+	}
+	return &todo, nil
+}
+
+// UpdateTodo updates a single todo and its tags in SQLite by ID if its version still matches
+func (s *SQLiteStore) UpdateTodo(id int, todo models.Todo) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error updating todo in SQLite: %v", err)
+	}
+	defer tx.Rollback()
+
+	set := strings.Join(sqliteTodoColumns, " = ?, ") + " = ?, version = version + 1"
+	result, err := tx.Exec(
+		"UPDATE todos SET "+set+" WHERE id = ? AND version = ?",
+		append(sqliteTodoValues(todo), id, todo.Version)...,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating todo in SQLite: %v", err)
+	}
+
+	if err := checkAffected(result, ErrNotFound); err != nil {
+		// The todo exists but another update got there first
+		var exists bool
+		if errors.Is(err, ErrNotFound) && tx.QueryRow("SELECT 1 FROM todos WHERE id = ?", id).Scan(&exists) == nil {
+			return ErrVersionConflict
+		}
+		return err
+	}
+	if err := replaceSQLiteTags(tx, id, todo.Tags); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error updating todo in SQLite: %v", err)
+	}
+	return nil
+}
+
+// DeleteTodo deletes a single todo from SQLite by ID; a trigger deletes its tags
+func (s *SQLiteStore) DeleteTodo(id int) error {
+	result, err := s.db.Exec("DELETE FROM todos WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("error deleting todo from SQLite: %v", err)
+	}
+
+	return checkAffected(result, ErrNotFound)
+}
+
+// QueryTodos loads the todos matching q from SQLite
+func (s *SQLiteStore) QueryTodos(q TodoQuery) ([]models.Todo, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	where, args := sqliteWhere(q)
+	query := sqliteSelectTodos + where + sqliteOrderBy(q)
+	if q.Limit > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit, q.Offset)
+	} else if q.Offset > 0 {
+		query += " LIMIT -1 OFFSET ?"
+		args = append(args, q.Offset)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading todos from SQLite: %v", err)
+	}
+	defer rows.Close()
+
+	todos := []models.Todo{}
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, *todo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading todos from SQLite: %v", err)
+	}
+	rows.Close()
+
+	if err := s.loadTags(todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// CountTags counts the todos matching q per tag in SQLite
+func (s *SQLiteStore) CountTags(q TodoQuery) ([]models.Tag, error) {
+	where, args := sqliteWhere(q)
+	rows, err := s.db.Query(
+		"SELECT tag, SUM(NOT done), SUM(done) FROM todo_tags JOIN todos ON todos.id = todo_tags.todo_id"+where+" GROUP BY tag ORDER BY tag",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error counting tags in SQLite: %v", err)
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var tag models.Tag
+		if err := rows.Scan(&tag.Name, &tag.PendingCount, &tag.DoneCount); err != nil {
+			return nil, fmt.Errorf("error counting tags in SQLite: %v", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error counting tags in SQLite: %v", err)
+	}
+	return tags, nil
+}
+
+// GetTodo loads a single todo and its tags from SQLite by ID
+func (s *SQLiteStore) GetTodo(id int) (*models.Todo, error) {
+	row := s.db.QueryRow(sqliteSelectTodos+" WHERE id = ?", id)
+	todo, err := scanTodo(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	todos := []models.Todo{*todo}
+	if err := s.loadTags(todos); err != nil {
+		return nil, err
+	}
+	return &todos[0], nil
+}
+
+// loadTags fills in the tags of the given todos, in name order
+func (s *SQLiteStore) loadTags(todos []models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	index := make(map[int]int, len(todos))
+	ids := make([]any, len(todos))
+	for i, todo := range todos {
+		index[todo.Id] = i
+		ids[i] = todo.Id
+	}
+
+	rows, err := s.db.Query("SELECT todo_id, tag FROM todo_tags WHERE todo_id IN ("+sqlitePlaceholders(len(ids))+") ORDER BY tag", ids...)
+	if err != nil {
+		return fmt.Errorf("error loading tags from SQLite: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return fmt.Errorf("error loading tags from SQLite: %v", err)
+		}
+		todos[index[id]].Tags = append(todos[index[id]].Tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error loading tags from SQLite: %v", err)
+	}
+	return nil
+}
+
+// replaceSQLiteTags stores tags as the only tags of a todo
+func replaceSQLiteTags(tx *sql.Tx, id int, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM todo_tags WHERE todo_id = ?", id); err != nil {
+		return fmt.Errorf("error saving tags to SQLite: %v", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT INTO todo_tags (todo_id, tag) VALUES (?, ?)", id, tag); err != nil {
+			return fmt.Errorf("error saving tags to SQLite: %v", err)
+		}
+	}
+	return nil
+}
+
+// InsertActivity appends an entry to the audit log in SQLite
+func (s *SQLiteStore) InsertActivity(activity models.Activity) (*models.Activity, error) {
+	var changes any
+	if len(activity.Changes) > 0 {
+		data, err := json.Marshal(activity.Changes)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding activity changes: %v", err)
+		}
+		changes = string(data)
+	}
+	result, err := s.db.Exec(
+		"INSERT INTO activity (todo_id, actor_id, action, changes, at, owner_id, list_id) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		activity.TodoId, sqliteNullString(activity.ActorId), activity.Action, changes,
+		formatSQLiteTime(activity.At), sqliteNullString(activity.OwnerId), activity.ListId,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting activity to SQLite: %v", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("error reading inserted activity ID: %v", err)
+	}
+	activity.Id = int(id)
+	return &activity, nil
+}
+
+// QueryActivity loads the audit log entries matching q from SQLite
+func (s *SQLiteStore) QueryActivity(q ActivityQuery) ([]models.Activity, error) {
+	var conds []string
+	var args []any
+	if q.TodoId != 0 {
+		conds = append(conds, "todo_id = ?")
+		args = append(args, q.TodoId)
+	}
+	if q.Owner != nil {
+		if len(q.SharedLists) == 0 {
+			conds = append(conds, "owner_id IS ?")
+		} else {
+			conds = append(conds, "(owner_id IS ? OR list_id IN ("+sqlitePlaceholders(len(q.SharedLists))+"))")
+		}
+		args = append(args, sqliteNullString(*q.Owner))
+		for _, id := range q.SharedLists {
+			args = append(args, id)
+		}
+	}
+	if q.Since != nil {
+		conds = append(conds, "at > ?")
+		args = append(args, formatSQLiteTime(*q.Since))
+	}
+
+	query := "SELECT id, todo_id, actor_id, action, changes, at, owner_id, list_id FROM activity"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY id"
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading activity from SQLite: %v", err)
+	}
+	defer rows.Close()
+
+	entries := []models.Activity{}
+	for rows.Next() {
+		var activity models.Activity
+		var actorId, changes, ownerId, listId sql.NullString
+		var at string
+		if err := rows.Scan(&activity.Id, &activity.TodoId, &actorId, &activity.Action, &changes, &at, &ownerId, &listId); err != nil {
+			return nil, fmt.Errorf("error parsing activity: %v", err)
+		}
+		activity.ActorId, activity.OwnerId = actorId.String, ownerId.String
+		if listId.Valid {
+			activity.ListId = &listId.String
+		}
+		if changes.Valid {
+			if err := json.Unmarshal([]byte(changes.String), &activity.Changes); err != nil {
+				return nil, fmt.Errorf("error parsing activity %d changes: %v", activity.Id, err)
+			}
+		}
+		if activity.At, err = parseSQLiteTime(at); err != nil {
+			return nil, fmt.Errorf("error parsing activity %d at: %v", activity.Id, err)
+		}
+		entries = append(entries, activity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading activity from SQLite: %v", err)
+	}
+
+	return entries, nil
+}
+
+// sqliteSelectLists selects the columns scanned by scanList, with todo counts
+const sqliteSelectLists = `SELECT l.id, l.name, l.color, l.position, l.archived, l.created_at, l.owner_id,
+	COUNT(t.id) FILTER (WHERE t.done = 0), COUNT(t.id) FILTER (WHERE t.done = 1)
+	FROM lists l LEFT JOIN todos t ON t.list_id = l.id AND t.deleted_at IS NULL`
+
+// InsertList inserts a list into SQLite unless its ID is already taken
+func (s *SQLiteStore) InsertList(list models.List) (*models.List, error) {
+	result, err := s.db.Exec(
+		`INSERT INTO lists (id, name, color, position, archived, created_at, owner_id) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING`,
+		list.Id, list.Name, sqliteNullString(list.Color), list.Position, list.Archived, formatSQLiteTime(list.CreatedAt),
+		sqliteNullString(list.OwnerId),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting list to SQLite: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("error checking inserted list: %v", err)
+	} else if n == 0 {
+		return nil, ErrListExists
+	}
+
+	list.PendingCount, list.DoneCount = 0, 0
+	return &list, nil
+}
+
+// UpdateList updates a list in SQLite by ID
+func (s *SQLiteStore) UpdateList(list models.List) error {
+	result, err := s.db.Exec(
+		"UPDATE lists SET name = ?, color = ?, position = ?, archived = ? WHERE id = ?",
+		list.Name, sqliteNullString(list.Color), list.Position, list.Archived, list.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating list in SQLite: %v", err)
+	}
+
+	return checkAffected(result, ErrListNotFound)
+}
+
+// DeleteList deletes a list and its members from SQLite, moving its todos
+// to the main list in the same transaction
+func (s *SQLiteStore) DeleteList(id string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error deleting list from SQLite: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM lists WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("error deleting list from SQLite: %v", err)
+	}
+	if err := checkAffected(result, ErrListNotFound); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE todos SET list_id = NULL, version = version + 1 WHERE list_id = ?", id); err != nil {
+		return fmt.Errorf("error deleting list from SQLite: %v", err)
+	}
+	if _, err := tx.Exec("DELETE FROM list_members WHERE list_id = ?", id); err != nil {
+		return fmt.Errorf("error deleting list from SQLite: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error deleting list from SQLite: %v", err)
+	}
+	return nil
+}
+
+// GetList loads a single list, with its todo counts, from SQLite
+func (s *SQLiteStore) GetList(id string) (*models.List, error) {
+	list, err := scanList(s.db.QueryRow(sqliteSelectLists+" WHERE l.id = ? GROUP BY l.id", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrListNotFound
+	}
+	return list, err
+}
+
+// QueryLists loads the lists matching q, with their todo counts, from SQLite ordered by position
+func (s *SQLiteStore) QueryLists(q ListQuery) ([]models.List, error) {
+	query := sqliteSelectLists + " WHERE (l.owner_id IS ?"
+	args := []any{sqliteNullString(q.OwnerId)}
+	if len(q.SharedIds) > 0 {
+		query += " OR l.id IN (" + sqlitePlaceholders(len(q.SharedIds)) + ")"
+		for _, id := range q.SharedIds {
+			args = append(args, id)
+		}
+	}
+	query += ")"
+	if !q.IncludeArchived {
+		query += " AND l.archived = 0"
+	}
+	rows, err := s.db.Query(query+" GROUP BY l.id ORDER BY l.position, l.id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading lists from SQLite: %v", err)
+	}
+	defer rows.Close()
+
+	lists := []models.List{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, *list)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading lists from SQLite: %v", err)
+	}
+
+	return lists, nil
+}
+
+// sqliteSelectMembers selects the columns scanned by scanMember
+const sqliteSelectMembers = "SELECT list_id, user_id, email, role, created_at FROM list_members"
+
+// InsertMember inserts a member into SQLite unless the user already has a role in the list
+func (s *SQLiteStore) InsertMember(member models.ListMember) (*models.ListMember, error) {
+	result, err := s.db.Exec(
+		`INSERT INTO list_members (list_id, user_id, email, role, created_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (list_id, user_id) DO NOTHING`,
+		member.ListId, member.UserId, sqliteNullString(member.Email), member.Role, formatSQLiteTime(member.CreatedAt),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting member to SQLite: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("error checking inserted member: %v", err)
+	} else if n == 0 {
+		return nil, ErrMemberExists
+	}
+
+	return &member, nil
+}
+
+// UpdateMember changes a member's role in SQLite
+func (s *SQLiteStore) UpdateMember(member models.ListMember) error {
+	result, err := s.db.Exec(
+		"UPDATE list_members SET role = ? WHERE list_id = ? AND user_id = ?",
+		member.Role, member.ListId, member.UserId,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating member in SQLite: %v", err)
+	}
+
+	return checkAffected(result, ErrMemberNotFound)
+}
+
+// DeleteMember removes a member from a list in SQLite
+func (s *SQLiteStore) DeleteMember(listId, userId string) error {
+	result, err := s.db.Exec("DELETE FROM list_members WHERE list_id = ? AND user_id = ?", listId, userId)
+	if err != nil {
+		return fmt.Errorf("error deleting member from SQLite: %v", err)
+	}
+
+	return checkAffected(result, ErrMemberNotFound)
+}
+
+// GetMember loads a user's membership of a list from SQLite
+func (s *SQLiteStore) GetMember(listId, userId string) (*models.ListMember, error) {
+	member, err := scanMember(s.db.QueryRow(sqliteSelectMembers+" WHERE list_id = ? AND user_id = ?", listId, userId))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMemberNotFound
+	}
+	return member, err
+}
+
+// QueryMembers loads the members of a list from SQLite
+func (s *SQLiteStore) QueryMembers(listId string) ([]models.ListMember, error) {
+	return s.queryMembers("list_id", listId)
+}
+
+// QueryMemberships loads the user's memberships from SQLite
+func (s *SQLiteStore) QueryMemberships(userId string) ([]models.ListMember, error) {
+	return s.queryMembers("user_id", userId)
+}
+
+// queryMembers loads the members whose column equals value, oldest first
+func (s *SQLiteStore) queryMembers(column, value string) ([]models.ListMember, error) {
+	rows, err := s.db.Query(sqliteSelectMembers+" WHERE "+column+" = ? ORDER BY created_at, list_id, user_id", value)
+	if err != nil {
+		return nil, fmt.Errorf("error loading members from SQLite: %v", err)
+	}
+	defer rows.Close()
+
+	members := []models.ListMember{}
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, *member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading members from SQLite: %v", err)
+	}
+
+	return members, nil
+}
+
+// InsertUser inserts a user into SQLite unless the email is already registered
+func (s *SQLiteStore) InsertUser(user models.User) (*models.User, error) {
+	user.Email = strings.ToLower(user.Email)
+	result, err := s.db.Exec(
+		`INSERT INTO users (id, email, password_hash, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (email) DO NOTHING`,
+		user.Id, user.Email, user.PasswordHash, formatSQLiteTime(user.CreatedAt),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting user to SQLite: %v", err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("error checking inserted user: %v", err)
+	} else if n == 0 {
+		return nil, ErrUserExists
+	}
+
+	return &user, nil
+}
+
+// GetUserByEmail loads a user from SQLite by email
+func (s *SQLiteStore) GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	var createdAt string
+	err := s.db.QueryRow(
+		"SELECT id, email, password_hash, created_at FROM users WHERE email = ?", strings.ToLower(email),
+	).Scan(&user.Id, &user.Email, &user.PasswordHash, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading user from SQLite: %v", err)
+	}
+	if user.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+		return nil, fmt.Errorf("error parsing user %q created_at: %v", user.Id, err)
+	}
+
+	return &user, nil
+}
+
+// SetCalendarToken stores the user's calendar token in SQLite, replacing any earlier one
+func (s *SQLiteStore) SetCalendarToken(token models.CalendarToken) error {
+	_, err := s.db.Exec(
+		`INSERT INTO calendar_tokens (user_id, token_hash, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at`,
+		token.UserId, token.TokenHash, formatSQLiteTime(token.CreatedAt),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving calendar token to SQLite: %v", err)
+	}
+	return nil
+}
+
+// DeleteCalendarToken removes the user's calendar token from SQLite, if any
+func (s *SQLiteStore) DeleteCalendarToken(userId string) error {
+	if _, err := s.db.Exec("DELETE FROM calendar_tokens WHERE user_id = ?", userId); err != nil {
+		return fmt.Errorf("error deleting calendar token from SQLite: %v", err)
+	}
+	return nil
+}
+
+// GetCalendarToken loads a calendar token from SQLite by its hash
+func (s *SQLiteStore) GetCalendarToken(tokenHash string) (*models.CalendarToken, error) {
+	token := models.CalendarToken{TokenHash: tokenHash}
+	var createdAt string
+	err := s.db.QueryRow(
+		"SELECT user_id, created_at FROM calendar_tokens WHERE token_hash = ?", tokenHash,
+	).Scan(&token.UserId, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCalendarTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading calendar token from SQLite: %v", err)
+	}
+	if token.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+		return nil, fmt.Errorf("error parsing calendar token created_at: %v", err)
+	}
+	return &token, nil
+}
+
+// sqliteWhere builds the WHERE clause and arguments for q's filters
+func sqliteWhere(q TodoQuery) (string, []any) {
+	var conds []string
+	var args []any
+
+	if q.Owner != nil {
+		if len(q.SharedLists) == 0 {
+			conds = append(conds, "owner_id IS ?")
+		} else {
+			conds = append(conds, "(owner_id IS ? OR list_id IN ("+sqlitePlaceholders(len(q.SharedLists))+"))")
+		}
+		args = append(args, sqliteNullString(*q.Owner))
+		for _, id := range q.SharedLists {
+			args = append(args, id)
+		}
+	}
+	if q.Done != nil {
+		conds = append(conds, "done = ?")
+		args = append(args, *q.Done)
+	}
+	if q.FilterList {
+		if q.ListId == nil {
+			conds = append(conds, "list_id IS NULL")
+		} else {
+			conds = append(conds, "list_id = ?")
+			args = append(args, *q.ListId)
+		}
+	}
+	if q.Search != "" {
+		conds = append(conds, "instr(lower(item), lower(?)) > 0")
+		args = append(args, q.Search)
+	}
+	if q.DueAfter != nil {
+		conds = append(conds, "due_at >= ?")
+		args = append(args, formatSQLiteTime(*q.DueAfter))
+	}
+	if q.DueBefore != nil {
+		conds = append(conds, "due_at < ?")
+		args = append(args, formatSQLiteTime(*q.DueBefore))
+	}
+	if q.Priority != "" {
+		conds = append(conds, "priority = ?")
+		args = append(args, q.Priority)
+	}
+	if q.Category != "" {
+		conds = append(conds, "lower(category) = lower(?)")
+		args = append(args, q.Category)
+	}
+	if len(q.Tags) > 0 {
+		tags := "SELECT todo_id FROM todo_tags WHERE tag IN (" + sqlitePlaceholders(len(q.Tags)) + ")"
+		if !q.AnyTag {
+			tags += " GROUP BY todo_id HAVING COUNT(*) = ?"
+		}
+		conds = append(conds, "id IN ("+tags+")")
+		for _, tag := range q.Tags {
+			args = append(args, tag)
+		}
+		if !q.AnyTag {
+			args = append(args, len(q.Tags))
+		}
+	}
+	if q.TopLevel {
+		conds = append(conds, "parent_id IS NULL")
+	}
+	if q.ParentIds != nil {
+		if len(q.ParentIds) == 0 {
+			conds = append(conds, "0")
+		} else {
+			conds = append(conds, "parent_id IN ("+sqlitePlaceholders(len(q.ParentIds))+")")
+			for _, id := range q.ParentIds {
+				args = append(args, id)
+			}
+		}
+	}
+	if q.PreviousId != nil {
+		conds = append(conds, "previous_id = ?")
+		args = append(args, *q.PreviousId)
+	}
+	if q.Trashed {
+		conds = append(conds, "deleted_at IS NOT NULL")
+	} else {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if q.DeletedBefore != nil {
+		conds = append(conds, "deleted_at < ?")
+		args = append(args, formatSQLiteTime(*q.DeletedBefore))
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// sqliteOrderBy builds the ORDER BY clause for q; field names are validated by the caller
+func sqliteOrderBy(q TodoQuery) string {
+	var terms []string
+	for _, s := range q.orderBy() {
+		if _, nullable := todoNullable[s.Field]; nullable {
+			terms = append(terms, s.Field+" IS NULL")
+		}
+		term := s.Field
+		if s.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+	}
+	return " ORDER BY " + strings.Join(terms, ", ")
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTodo reads a row selected with sqliteSelectTodos
+func scanTodo(row rowScanner) (*models.Todo, error) {
+	var todo models.Todo
+	var listId sql.NullString
+	var createdAt string
+	var dueAt, remindAt sql.NullString
+	var priority, estimatedTime, category sql.NullString
+	var parentId, previousId sql.NullInt64
+	var ownerId, deletedAt, recurrence sql.NullString
+	if err := row.Scan(
+		&todo.Id, &todo.Version, &todo.Item, &todo.Done, &listId, &createdAt, &dueAt, &remindAt,
+		&priority, &estimatedTime, &category, &parentId, &todo.AutoComplete, &ownerId, &deletedAt,
+		&recurrence, &previousId,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error parsing todo: %v", err)
+	}
+	if listId.Valid {
+		todo.ListId = &listId.String
+	}
+	todo.Priority, todo.EstimatedTime, todo.Category = priority.String, estimatedTime.String, category.String
+	todo.OwnerId, todo.Recurrence = ownerId.String, recurrence.String
+	if parentId.Valid {
+		id := int(parentId.Int64)
+		todo.ParentId = &id
+	}
+	if previousId.Valid {
+		id := int(previousId.Int64)
+		todo.PreviousId = &id
+	}
+
+	var err error
+	if todo.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+		return nil, fmt.Errorf("error parsing todo %d created_at: %v", todo.Id, err)
+	}
+	if todo.DueAt, err = parseSQLiteNullTime(dueAt); err != nil {
+		return nil, fmt.Errorf("error parsing todo %d due_at: %v", todo.Id, err)
+	}
+	if todo.RemindAt, err = parseSQLiteNullTime(remindAt); err != nil {
+		return nil, fmt.Errorf("error parsing todo %d remind_at: %v", todo.Id, err)
+	}
+	if todo.DeletedAt, err = parseSQLiteNullTime(deletedAt); err != nil {
+		return nil, fmt.Errorf("error parsing todo %d deleted_at: %v", todo.Id, err)
+	}
+
+	return &todo, nil
+}
+
+// scanList reads a row selected with sqliteSelectLists
+func scanList(row rowScanner) (*models.List, error) {
+	var list models.List
+	var color, ownerId sql.NullString
+	var createdAt string
+	if err := row.Scan(
+		&list.Id, &list.Name, &color, &list.Position, &list.Archived, &createdAt, &ownerId,
+		&list.PendingCount, &list.DoneCount,
+	); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error parsing list: %v", err)
+	}
+	list.Color, list.OwnerId = color.String, ownerId.String
+
+	var err error
+	if list.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+		return nil, fmt.Errorf("error parsing list %q created_at: %v", list.Id, err)
+	}
+
+	return &list, nil
+}
+
+// scanMember reads a row selected with sqliteSelectMembers
+func scanMember(row rowScanner) (*models.ListMember, error) {
+	var member models.ListMember
+	var email sql.NullString
+	var createdAt string
+	if err := row.Scan(&member.ListId, &member.UserId, &email, &member.Role, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error parsing member: %v", err)
+	}
+	member.Email = email.String
+
+	var err error
+	if member.CreatedAt, err = parseSQLiteTime(createdAt); err != nil {
+		return nil, fmt.Errorf("error parsing member %q of list %q created_at: %v", member.UserId, member.ListId, err)
+	}
+
+	return &member, nil
+}
+
+// sqlitePlaceholders returns n comma-separated ? placeholders
+func sqlitePlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// sqliteNullString stores an optional text column, using NULL when s is empty
+func sqliteNullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// formatSQLiteTime stores t as fixed-width UTC text; the zero time is stored as ""
+func formatSQLiteTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// formatSQLiteNullTime stores an optional time, using NULL when t is nil
+func formatSQLiteNullTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return formatSQLiteTime(*t)
+}
+
+// parseSQLiteNullTime reverses formatSQLiteNullTime
+func parseSQLiteNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := parseSQLiteTime(s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// parseSQLiteTime reverses formatSQLiteTime
+func parseSQLiteTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(sqliteTimeFormat, s)
+}
+
+func checkAffected(result sql.Result, notFound error) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error reading SQLite result: %v", err)
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,346 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"listy-api/core"
+	"listy-api/models"
+
+	"github.com/joho/godotenv"
+)
+
+// ErrNotFound is returned by a TodoStore when no todo matches the given ID
+var ErrNotFound = core.NewError(core.ErrNotFound, "todo not found")
+
+// ErrVersionConflict is returned by UpdateTodo when the todo changed since it was read
+var ErrVersionConflict = core.NewError(core.ErrConflict, "todo version conflict")
+
+// ErrListNotFound is returned by a ListStore when no list matches the given ID
+var ErrListNotFound = core.NewError(core.ErrNotFound, "list not found")
+
+// ErrListExists is returned by InsertList when the list ID is already taken
+var ErrListExists = core.NewError(core.ErrConflict, "list already exists")
+
+// ErrUserNotFound is returned by a UserStore when no user has the given email
+var ErrUserNotFound = core.NewError(core.ErrNotFound, "user not found")
+
+// ErrUserExists is returned by InsertUser when the email is already registered
+var ErrUserExists = core.NewError(core.ErrConflict, "user already exists")
+
+// ErrCalendarTokenNotFound is returned by GetCalendarToken when no user has the token
+var ErrCalendarTokenNotFound = core.NewError(core.ErrNotFound, "calendar token not found")
+
+// ErrMemberNotFound is returned by a MemberStore when the user is not a member of the list
+var ErrMemberNotFound = core.NewError(core.ErrNotFound, "member not found")
+
+// ErrMemberExists is returned by InsertMember when the user is already a member of the list
+var ErrMemberExists = core.NewError(core.ErrConflict, "member already exists")
+
+// TodoStore is the persistence layer used by the services package.
+// InsertTodo ignores todo.Id and todo.Version and returns the stored todo
+// with the ID the store allocated and version 1; allocation must be safe
+// under concurrent inserts. UpdateTodo only succeeds while the stored version
+// still equals todo.Version, storing the todo as the next version; otherwise
+// it returns ErrVersionConflict. Todos are stored and loaded with their
+// tags; CountTags counts the pending and done todos matching q per tag,
+// ordered by name.
+type TodoStore interface {
+	InsertTodo(todo models.Todo) (*models.Todo, error)
+	UpdateTodo(id int, todo models.Todo) error
+	DeleteTodo(id int) error
+	GetTodo(id int) (*models.Todo, error)
+	QueryTodos(q TodoQuery) ([]models.Todo, error)
+	CountTags(q TodoQuery) ([]models.Tag, error)
+	ActivityStore
+	ListStore
+	MemberStore
+	UserStore
+	CalendarTokenStore
+}
+
+// ActivityStore keeps the audit log of changes to todos. Entries are never
+// changed or removed; InsertActivity allocates increasing IDs and
+// QueryActivity returns entries in the order they were recorded.
+type ActivityStore interface {
+	InsertActivity(activity models.Activity) (*models.Activity, error)
+	QueryActivity(q ActivityQuery) ([]models.Activity, error)
+}
+
+// ListStore persists lists. GetList and QueryLists fill in each list's
+// pending and done counts. DeleteList removes a list with its members and
+// moves its todos to the main list.
+type ListStore interface {
+	InsertList(list models.List) (*models.List, error)
+	UpdateList(list models.List) error
+	DeleteList(id string) error
+	GetList(id string) (*models.List, error)
+	QueryLists(q ListQuery) ([]models.List, error)
+}
+
+// MemberStore persists who lists are shared with; a user has at most one
+// role per list. QueryMembers and QueryMemberships order by when the member
+// was added.
+type MemberStore interface {
+	InsertMember(member models.ListMember) (*models.ListMember, error)
+	UpdateMember(member models.ListMember) error
+	DeleteMember(listId, userId string) error
+	GetMember(listId, userId string) (*models.ListMember, error)
+	QueryMembers(listId string) ([]models.ListMember, error)
+	QueryMemberships(userId string) ([]models.ListMember, error)
+}
+
+// UserStore persists the accounts that sign in with a password.
+// Emails are stored and looked up in lower case.
+type UserStore interface {
+	InsertUser(user models.User) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+}
+
+// CalendarTokenStore keeps the hash of each user's calendar feed token.
+// SetCalendarToken replaces the user's earlier token, if any.
+type CalendarTokenStore interface {
+	SetCalendarToken(token models.CalendarToken) error
+	DeleteCalendarToken(userId string) error
+	GetCalendarToken(tokenHash string) (*models.CalendarToken, error)
+}
+
+// Store is the active TodoStore, set by InitStore
+var Store TodoStore
+
+// InitStore loads the environment and initializes the store selected by LISTY_STORE.
+// Supported values are "supabase" (default), "memory" and "sqlite".
+func InitStore() error {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		// Try loading from parent directory
+		err = godotenv.Load("../.env")
+		if err != nil {
+			log.Println("Warning: .env file not found, using environment variables")
+		}
+	}
+
+	switch backend := os.Getenv("LISTY_STORE"); backend {
+	case "", "supabase":
+		store, err := NewSupabaseStore()
+		if err != nil {
+			return err
+		}
+		Store = store
+	case "memory":
+		Store = NewMemoryStore()
+	case "sqlite":
+		path := os.Getenv("LISTY_SQLITE_PATH")
+		if path == "" {
+			path = "listy.db"
+		}
+		store, err := NewSQLiteStore(path)
+		if err != nil {
+			return err
+		}
+		Store = store
+	default:
+		return fmt.Errorf("unknown LISTY_STORE %q (expected supabase, memory or sqlite)", backend)
+	}
+
+	return nil
+}
+
+// InsertTodo inserts a single todo into the active store and returns it with its new ID
+func InsertTodo(todo models.Todo) (*models.Todo, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.InsertTodo(todo)
+}
+
+// UpdateTodo updates a single todo in the active store by ID
+func UpdateTodo(id int, todo models.Todo) error {
+	if Store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return Store.UpdateTodo(id, todo)
+}
+
+// DeleteTodo deletes a single todo from the active store by ID
+func DeleteTodo(id int) error {
+	if Store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return Store.DeleteTodo(id)
+}
+
+// LoadTodos loads all todos from the active store, ordered by ID
+func LoadTodos() ([]models.Todo, error) {
+	return QueryTodos(TodoQuery{})
+}
+
+// QueryTodos loads the todos matching q from the active store
+func QueryTodos(q TodoQuery) ([]models.Todo, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.QueryTodos(q)
+}
+
+// GetTodo loads a single todo from the active store by ID
+func GetTodo(id int) (*models.Todo, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.GetTodo(id)
+}
+
+// CountTags counts the todos matching q per tag in the active store
+func CountTags(q TodoQuery) ([]models.Tag, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.CountTags(q)
+}
+
+// InsertActivity appends an entry to the audit log of the active store
+func InsertActivity(activity models.Activity) (*models.Activity, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.InsertActivity(activity)
+}
+
+// QueryActivity loads the audit log entries matching q from the active store
+func QueryActivity(q ActivityQuery) ([]models.Activity, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.QueryActivity(q)
+}
+
+// InsertList inserts a list into the active store
+func InsertList(list models.List) (*models.List, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.InsertList(list)
+}
+
+// UpdateList updates a list in the active store
+func UpdateList(list models.List) error {
+	if Store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return Store.UpdateList(list)
+}
+
+// DeleteList deletes a list from the active store, moving its todos to the main list
+func DeleteList(id string) error {
+	if Store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return Store.DeleteList(id)
+}
+
+// GetList loads a single list, with its todo counts, from the active store
+func GetList(id string) (*models.List, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.GetList(id)
+}
+
+// QueryLists loads the lists matching q, with their todo counts, from the active store
+func QueryLists(q ListQuery) ([]models.List, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.QueryLists(q)
+}
+
+// InsertMember adds a member to a list in the active store
+func InsertMember(member models.ListMember) (*models.ListMember, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.InsertMember(member)
+}
+
+// UpdateMember changes a member's role in the active store
+func UpdateMember(member models.ListMember) error {
+	if Store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return Store.UpdateMember(member)
+}
+
+// DeleteMember removes a member from a list in the active store
+func DeleteMember(listId, userId string) error {
+	if Store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return Store.DeleteMember(listId, userId)
+}
+
+// GetMember loads a user's membership of a list from the active store
+func GetMember(listId, userId string) (*models.ListMember, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.GetMember(listId, userId)
+}
+
+// QueryMembers loads the members of a list from the active store
+func QueryMembers(listId string) ([]models.ListMember, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.QueryMembers(listId)
+}
+
+// QueryMemberships loads every list membership of a user from the active store
+func QueryMemberships(userId string) ([]models.ListMember, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.QueryMemberships(userId)
+}
+
+// InsertUser inserts a user into the active store
+func InsertUser(user models.User) (*models.User, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.InsertUser(user)
+}
+
+// GetUserByEmail loads a user from the active store by email
+func GetUserByEmail(email string) (*models.User, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.GetUserByEmail(email)
+}
+
+// SetCalendarToken stores a user's calendar token in the active store, replacing any earlier one
+func SetCalendarToken(token models.CalendarToken) error {
+	if Store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return Store.SetCalendarToken(token)
+}
+
+// DeleteCalendarToken removes a user's calendar token from the active store
+func DeleteCalendarToken(userId string) error {
+	if Store == nil {
+		return fmt.Errorf("store not initialized")
+	}
+	return Store.DeleteCalendarToken(userId)
+}
+
+// GetCalendarToken loads a calendar token from the active store by its hash
+func GetCalendarToken(tokenHash string) (*models.CalendarToken, error) {
+	if Store == nil {
+		return nil, fmt.Errorf("store not initialized")
+	}
+	return Store.GetCalendarToken(tokenHash)
+}
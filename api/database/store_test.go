@@ -0,0 +1,805 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"listy-api/models"
+)
+
+// storeFactories returns every offline TodoStore implementation under test
+func storeFactories(t *testing.T) map[string]func() TodoStore {
+	return map[string]func() TodoStore{
+		"memory": func() TodoStore { return NewMemoryStore() },
+		"sqlite": func() TodoStore {
+			store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "listy.db"))
+			if err != nil {
+				t.Fatalf("NewSQLiteStore() error = %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store
+		},
+	}
+}
+
+func TestTodoStore_CRUD(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			work := "work"
+
+			first, err := store.InsertTodo(models.Todo{Id: 99, Item: "First"})
+			if err != nil {
+				t.Fatalf("InsertTodo() error = %v", err)
+			}
+			if first.Id != 1 {
+				t.Errorf("InsertTodo() id = %d, want 1 (caller-supplied IDs are ignored)", first.Id)
+			}
+			if _, err := store.InsertTodo(models.Todo{Item: "Second", ListId: &work}); err != nil {
+				t.Fatalf("InsertTodo() error = %v", err)
+			}
+
+			todos, err := store.QueryTodos(TodoQuery{})
+			if err != nil {
+				t.Fatalf("QueryTodos() error = %v", err)
+			}
+			if len(todos) != 2 || todos[0].Id != 1 || todos[1].Id != 2 {
+				t.Fatalf("QueryTodos() = %+v, want IDs [1 2]", todos)
+			}
+			if todos[1].ListId == nil || *todos[1].ListId != "work" {
+				t.Errorf("QueryTodos() list_id = %v, want work", todos[1].ListId)
+			}
+
+			due := time.Date(2026, time.March, 6, 17, 30, 0, 0, time.FixedZone("CET", 3600))
+			edited := models.Todo{
+				Id: 1, Version: first.Version, Item: "First (edited)", Done: true, DueAt: &due,
+				Priority: "high", EstimatedTime: "30 minutes", Category: "admin",
+				AutoComplete: true, Recurrence: "FREQ=WEEKLY;BYDAY=MO",
+			}
+			if err := store.UpdateTodo(1, edited); err != nil {
+				t.Fatalf("UpdateTodo() error = %v", err)
+			}
+			todo, err := store.GetTodo(1)
+			if err != nil {
+				t.Fatalf("GetTodo() error = %v", err)
+			}
+			if todo.Item != "First (edited)" || !todo.Done || todo.Version != 2 {
+				t.Errorf("GetTodo() = %+v, want edited, done and version 2", todo)
+			}
+			if todo.DueAt == nil || !todo.DueAt.Equal(due) {
+				t.Errorf("GetTodo() due_at = %v, want %v", todo.DueAt, due)
+			}
+			if todo.RemindAt != nil {
+				t.Errorf("GetTodo() remind_at = %v, want nil", todo.RemindAt)
+			}
+			if todo.Priority != "high" || todo.EstimatedTime != "30 minutes" || todo.Category != "admin" || !todo.AutoComplete {
+				t.Errorf("GetTodo() = %+v, want priority, estimate, category and auto_complete kept", todo)
+			}
+			if todo.Recurrence != "FREQ=WEEKLY;BYDAY=MO" {
+				t.Errorf("GetTodo() recurrence = %q, want FREQ=WEEKLY;BYDAY=MO", todo.Recurrence)
+			}
+
+			if err := store.DeleteTodo(1); err != nil {
+				t.Fatalf("DeleteTodo() error = %v", err)
+			}
+			if _, err := store.GetTodo(1); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetTodo() after delete error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestTodoStore_MissingTodo(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+
+			if _, err := store.GetTodo(42); !errors.Is(err, ErrNotFound) {
+				t.Errorf("GetTodo() error = %v, want ErrNotFound", err)
+			}
+			if err := store.UpdateTodo(42, models.Todo{Id: 42}); !errors.Is(err, ErrNotFound) {
+				t.Errorf("UpdateTodo() error = %v, want ErrNotFound", err)
+			}
+			if err := store.DeleteTodo(42); !errors.Is(err, ErrNotFound) {
+				t.Errorf("DeleteTodo() error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestTodoStore_VersionConflict(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			todo, err := store.InsertTodo(models.Todo{Item: "Shared", Version: 7})
+			if err != nil {
+				t.Fatalf("InsertTodo() error = %v", err)
+			}
+			if todo.Version != 1 {
+				t.Fatalf("InsertTodo() version = %d, want 1", todo.Version)
+			}
+
+			// Two clients read version 1; only the first write wins
+			first, second := *todo, *todo
+			first.Done = true
+			second.Item = "Shared (renamed)"
+			if err := store.UpdateTodo(todo.Id, first); err != nil {
+				t.Fatalf("UpdateTodo() error = %v", err)
+			}
+			if err := store.UpdateTodo(todo.Id, second); !errors.Is(err, ErrVersionConflict) {
+				t.Fatalf("UpdateTodo() with a stale version error = %v, want ErrVersionConflict", err)
+			}
+
+			stored, err := store.GetTodo(todo.Id)
+			if err != nil {
+				t.Fatalf("GetTodo() error = %v", err)
+			}
+			if stored.Item != "Shared" || !stored.Done || stored.Version != 2 {
+				t.Errorf("GetTodo() = %+v, want the first update at version 2", stored)
+			}
+
+			// Moving a list's todos to the main list is a change too
+			work := "work"
+			if _, err := store.InsertList(models.List{Id: work, Name: "Work"}); err != nil {
+				t.Fatalf("InsertList() error = %v", err)
+			}
+			listed, err := store.InsertTodo(models.Todo{Item: "Listed", ListId: &work})
+			if err != nil {
+				t.Fatalf("InsertTodo() error = %v", err)
+			}
+			if err := store.DeleteList(work); err != nil {
+				t.Fatalf("DeleteList() error = %v", err)
+			}
+			if moved, err := store.GetTodo(listed.Id); err != nil || moved.Version != 2 {
+				t.Errorf("GetTodo() after DeleteList = %+v, %v, want version 2", moved, err)
+			}
+		})
+	}
+}
+
+func TestTodoStore_Trash(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			work := "work"
+			if _, err := store.InsertList(models.List{Id: work, Name: "Work"}); err != nil {
+				t.Fatalf("InsertList() error = %v", err)
+			}
+			deletedAt := time.Date(2026, time.March, 6, 9, 0, 0, 0, time.UTC)
+			for i, item := range []string{"Kept", "Deleted early", "Deleted late"} {
+				todo, err := store.InsertTodo(models.Todo{Item: item, ListId: &work})
+				if err != nil {
+					t.Fatalf("InsertTodo() error = %v", err)
+				}
+				if i == 0 {
+					continue
+				}
+				at := deletedAt.Add(time.Duration(i) * time.Hour)
+				todo.DeletedAt = &at
+				if err := store.UpdateTodo(todo.Id, *todo); err != nil {
+					t.Fatalf("UpdateTodo() error = %v", err)
+				}
+			}
+
+			items := func(q TodoQuery) []string {
+				t.Helper()
+				todos, err := store.QueryTodos(q)
+				if err != nil {
+					t.Fatalf("QueryTodos() error = %v", err)
+				}
+				var items []string
+				for _, todo := range todos {
+					items = append(items, todo.Item)
+				}
+				return items
+			}
+			if got := items(TodoQuery{}); !slices.Equal(got, []string{"Kept"}) {
+				t.Errorf("QueryTodos() = %v, want only the todo outside the trash", got)
+			}
+			trash := TodoQuery{Trashed: true, Sort: []SortField{{Field: "deleted_at", Desc: true}}}
+			if got := items(trash); !slices.Equal(got, []string{"Deleted late", "Deleted early"}) {
+				t.Errorf("QueryTodos(trashed) = %v, want the trash, latest first", got)
+			}
+			before := deletedAt.Add(90 * time.Minute)
+			trash.DeletedBefore = &before
+			if got := items(trash); !slices.Equal(got, []string{"Deleted early"}) {
+				t.Errorf("QueryTodos(deleted before) = %v, want [Deleted early]", got)
+			}
+
+			list, err := store.GetList(work)
+			if err != nil {
+				t.Fatalf("GetList() error = %v", err)
+			}
+			if list.PendingCount != 1 {
+				t.Errorf("GetList() pending count = %d, want 1 without the trash", list.PendingCount)
+			}
+
+			todos, err := store.QueryTodos(TodoQuery{Trashed: true})
+			if err != nil {
+				t.Fatalf("QueryTodos() error = %v", err)
+			}
+			if got := todos[0].DeletedAt; got == nil || !got.Equal(deletedAt.Add(time.Hour)) {
+				t.Errorf("QueryTodos() deleted_at = %v, want %v", got, deletedAt.Add(time.Hour))
+			}
+		})
+	}
+}
+
+func TestTodoStore_Tags(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			ada := "ada"
+			var ids []int
+			for _, todo := range []models.Todo{
+				{Item: "Buy milk", Tags: []string{"errands", "urgent"}, OwnerId: ada},
+				{Item: "Write report", Tags: []string{"urgent"}, Done: true, OwnerId: ada},
+				{Item: "Someone else's", Tags: []string{"secret"}, OwnerId: "bob"},
+			} {
+				inserted, err := store.InsertTodo(todo)
+				if err != nil {
+					t.Fatalf("InsertTodo() error = %v", err)
+				}
+				ids = append(ids, inserted.Id)
+			}
+
+			todo, err := store.GetTodo(ids[0])
+			if err != nil {
+				t.Fatalf("GetTodo() error = %v", err)
+			}
+			if !slices.Equal(todo.Tags, []string{"errands", "urgent"}) {
+				t.Errorf("GetTodo() tags = %v, want [errands urgent]", todo.Tags)
+			}
+
+			// Changing the tags of a todo read from the store does not change the stored todo
+			todo.Tags[0] = "changed"
+			listed, err := store.QueryTodos(TodoQuery{Owner: &ada})
+			if err != nil {
+				t.Fatalf("QueryTodos() error = %v", err)
+			}
+			listed[0].Tags[0] = "changed"
+			if stored, err := store.GetTodo(ids[0]); err != nil || !slices.Equal(stored.Tags, []string{"errands", "urgent"}) {
+				t.Errorf("GetTodo() after changing a copy = %+v, %v, want tags [errands urgent]", stored, err)
+			}
+
+			tags, err := store.CountTags(TodoQuery{Owner: &ada})
+			if err != nil {
+				t.Fatalf("CountTags() error = %v", err)
+			}
+			want := []models.Tag{{Name: "errands", PendingCount: 1}, {Name: "urgent", PendingCount: 1, DoneCount: 1}}
+			if !slices.Equal(tags, want) {
+				t.Errorf("CountTags() = %+v, want %+v", tags, want)
+			}
+
+			// Updating replaces every tag
+			todo.Tags = []string{"home"}
+			if err := store.UpdateTodo(todo.Id, *todo); err != nil {
+				t.Fatalf("UpdateTodo() error = %v", err)
+			}
+			if todo, err = store.GetTodo(ids[0]); err != nil || !slices.Equal(todo.Tags, []string{"home"}) {
+				t.Errorf("GetTodo() after UpdateTodo = %+v, %v, want tags [home]", todo, err)
+			}
+			todo.Tags = nil
+			if err := store.UpdateTodo(todo.Id, *todo); err != nil {
+				t.Fatalf("UpdateTodo() error = %v", err)
+			}
+			if todo, err = store.GetTodo(ids[0]); err != nil || len(todo.Tags) != 0 {
+				t.Errorf("GetTodo() after removing tags = %+v, %v, want no tags", todo, err)
+			}
+
+			if err := store.DeleteTodo(ids[1]); err != nil {
+				t.Fatalf("DeleteTodo() error = %v", err)
+			}
+			if tags, err := store.CountTags(TodoQuery{Owner: &ada}); err != nil || len(tags) != 0 {
+				t.Errorf("CountTags() after deleting = %+v, %v, want none", tags, err)
+			}
+		})
+	}
+}
+
+func TestActivityStore(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			work := "work"
+			at := time.Date(2026, time.March, 6, 9, 0, 0, 0, time.UTC)
+			entries := []models.Activity{
+				{TodoId: 1, ActorId: "ada", Action: models.EventCreated, OwnerId: "ada", Changes: map[string]models.FieldChange{"item": {After: "Plan"}}},
+				{TodoId: 2, ActorId: "ada", Action: models.EventCreated, OwnerId: "ada", ListId: &work},
+				{TodoId: 1, ActorId: "grace", Action: models.EventToggled, OwnerId: "ada", Changes: map[string]models.FieldChange{"done": {Before: false, After: true}}},
+				{TodoId: 3, Action: models.EventCreated, OwnerId: "grace"},
+			}
+			for i, entry := range entries {
+				entry.At = at.Add(time.Duration(i) * time.Minute)
+				inserted, err := store.InsertActivity(entry)
+				if err != nil {
+					t.Fatalf("InsertActivity() error = %v", err)
+				}
+				if inserted.Id != i+1 {
+					t.Errorf("InsertActivity() id = %d, want %d", inserted.Id, i+1)
+				}
+			}
+
+			ids := func(q ActivityQuery) []int {
+				t.Helper()
+				entries, err := store.QueryActivity(q)
+				if err != nil {
+					t.Fatalf("QueryActivity() error = %v", err)
+				}
+				var ids []int
+				for _, entry := range entries {
+					ids = append(ids, entry.Id)
+				}
+				return ids
+			}
+			ada, grace := "ada", "grace"
+			since := at.Add(time.Minute)
+			tests := []struct {
+				name string
+				q    ActivityQuery
+				want []int
+			}{
+				{"all", ActivityQuery{}, []int{1, 2, 3, 4}},
+				{"one todo", ActivityQuery{TodoId: 1}, []int{1, 3}},
+				{"owner", ActivityQuery{Owner: &ada}, []int{1, 2, 3}},
+				{"shared list", ActivityQuery{Owner: &grace, SharedLists: []string{work}}, []int{2, 4}},
+				{"since is exclusive", ActivityQuery{Since: &since}, []int{3, 4}},
+				{"limit", ActivityQuery{Owner: &ada, Limit: 2}, []int{1, 2}},
+			}
+			for _, tt := range tests {
+				if got := ids(tt.q); !slices.Equal(got, tt.want) {
+					t.Errorf("QueryActivity(%s) = %v, want %v", tt.name, got, tt.want)
+				}
+			}
+
+			history, err := store.QueryActivity(ActivityQuery{TodoId: 1})
+			if err != nil {
+				t.Fatalf("QueryActivity() error = %v", err)
+			}
+			toggled := history[1]
+			if toggled.ActorId != "grace" || toggled.Action != models.EventToggled || !toggled.At.Equal(at.Add(2*time.Minute)) {
+				t.Errorf("QueryActivity() = %+v, want grace's toggle at %v", toggled, at.Add(2*time.Minute))
+			}
+			if change := toggled.Changes["done"]; change.Before != false || change.After != true {
+				t.Errorf("QueryActivity() done change = %+v, want false -> true", change)
+			}
+		})
+	}
+}
+
+func TestSQLiteStore_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "listy.db")
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore() error = %v", err)
+	}
+	if _, err := store.InsertTodo(models.Todo{Item: "Survives restart"}); err != nil {
+		t.Fatalf("InsertTodo() error = %v", err)
+	}
+	store.Close()
+
+	reopened, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore() reopen error = %v", err)
+	}
+	defer reopened.Close()
+
+	todo, err := reopened.GetTodo(1)
+	if err != nil {
+		t.Fatalf("GetTodo() error = %v", err)
+	}
+	if todo.Item != "Survives restart" {
+		t.Errorf("GetTodo() = %q, want %q", todo.Item, "Survives restart")
+	}
+}
+
+func TestSQLiteStore_BackfillsLists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "listy.db")
+
+	// Build a database as it was before lists had their own table
+	before := slices.IndexFunc(sqliteMigrations, func(m string) bool { return strings.Contains(m, "CREATE TABLE lists") })
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	for _, migration := range sqliteMigrations[:before] {
+		if _, err := db.Exec(migration); err != nil {
+			t.Fatalf("migration error = %v", err)
+		}
+	}
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", before)); err != nil {
+		t.Fatalf("PRAGMA user_version error = %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO todos (item, list_id) VALUES ('Install Go', 'learn_go'), ('Buy milk', NULL)"); err != nil {
+		t.Fatalf("INSERT error = %v", err)
+	}
+	db.Close()
+
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore() error = %v", err)
+	}
+	defer store.Close()
+
+	lists, err := store.QueryLists(ListQuery{})
+	if err != nil {
+		t.Fatalf("QueryLists() error = %v", err)
+	}
+	if len(lists) != 1 || lists[0].Id != "learn_go" || lists[0].PendingCount != 1 {
+		t.Errorf("QueryLists() = %+v, want learn_go with one pending todo", lists)
+	}
+}
+
+func TestTodoStore_IDsNotReused(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+
+			first, _ := store.InsertTodo(models.Todo{Item: "First"})
+			second, _ := store.InsertTodo(models.Todo{Item: "Second"})
+			if err := store.DeleteTodo(second.Id); err != nil {
+				t.Fatalf("DeleteTodo() error = %v", err)
+			}
+
+			third, err := store.InsertTodo(models.Todo{Item: "Third"})
+			if err != nil {
+				t.Fatalf("InsertTodo() error = %v", err)
+			}
+			if third.Id == first.Id || third.Id == second.Id {
+				t.Errorf("InsertTodo() reused ID %d", third.Id)
+			}
+		})
+	}
+}
+
+func TestTodoStore_ConcurrentInserts(t *testing.T) {
+	const workers = 50
+
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+
+			var wg sync.WaitGroup
+			ids := make(chan int, workers)
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					todo, err := store.InsertTodo(models.Todo{Item: "Concurrent"})
+					if err != nil {
+						t.Errorf("InsertTodo() error = %v", err)
+						return
+					}
+					ids <- todo.Id
+				}()
+			}
+			wg.Wait()
+			close(ids)
+
+			seen := make(map[int]bool)
+			for id := range ids {
+				if seen[id] {
+					t.Errorf("InsertTodo() allocated duplicate ID %d", id)
+				}
+				seen[id] = true
+			}
+			if len(seen) != workers {
+				t.Errorf("got %d unique IDs, want %d", len(seen), workers)
+			}
+		})
+	}
+}
+
+func TestTodoStore_QueryTodos(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			work, home, one, two := "work", "home", 1, 2
+			ada, anonymous := "ada", ""
+			seed := []models.Todo{
+				{Item: "Buy milk", Priority: "low", Category: "Errands", Tags: []string{"errands", "urgent"}},
+				{Item: "Write report", ListId: &work, Done: true, Priority: "high", Tags: []string{"urgent", "work"}},
+				{Item: "Call plumber", ListId: &home, Priority: "high", Category: "house", OwnerId: "ada", Tags: []string{"home"}},
+				{Item: "Answer email", ListId: &work, ParentId: &two, PreviousId: &one},
+			}
+			for _, todo := range seed {
+				if _, err := store.InsertTodo(todo); err != nil {
+					t.Fatalf("InsertTodo() error = %v", err)
+				}
+			}
+
+			pending, done := false, true
+			tests := []struct {
+				name    string
+				query   TodoQuery
+				wantIDs []int
+			}{
+				{"all todos ordered by ID", TodoQuery{}, []int{1, 2, 3, 4}},
+				{"owned by a user", TodoQuery{Owner: &ada}, []int{3}},
+				{"without an owner", TodoQuery{Owner: &anonymous}, []int{1, 2, 4}},
+				{"owned or in shared lists", TodoQuery{Owner: &ada, SharedLists: []string{"work"}}, []int{2, 3, 4}},
+				{"pending only", TodoQuery{Done: &pending}, []int{1, 3, 4}},
+				{"completed only", TodoQuery{Done: &done}, []int{2}},
+				{"main list", TodoQuery{FilterList: true}, []int{1}},
+				{"named list", TodoQuery{FilterList: true, ListId: &work}, []int{2, 4}},
+				{"named list and pending", TodoQuery{FilterList: true, ListId: &work, Done: &pending}, []int{4}},
+				{"sorted by item", TodoQuery{Sort: []SortField{{Field: "item"}}}, []int{4, 1, 3, 2}},
+				{"sorted by ID descending", TodoQuery{Sort: []SortField{{Field: "id", Desc: true}}}, []int{4, 3, 2, 1}},
+				{"list_id NULLs last", TodoQuery{Sort: []SortField{{Field: "list_id", Desc: true}}}, []int{2, 4, 3, 1}},
+				{"search is case-insensitive", TodoQuery{Search: "REPORT"}, []int{2}},
+				{"search treats wildcards literally", TodoQuery{Search: "%"}, nil},
+				{"priority", TodoQuery{Priority: "high"}, []int{2, 3}},
+				{"category ignores case", TodoQuery{Category: "errands"}, []int{1}},
+				{"category NULLs last", TodoQuery{Sort: []SortField{{Field: "category"}}}, []int{1, 3, 2, 4}},
+				{"every tag", TodoQuery{Tags: []string{"urgent", "work"}}, []int{2}},
+				{"any tag", TodoQuery{Tags: []string{"home", "work"}, AnyTag: true}, []int{2, 3}},
+				{"unused tag", TodoQuery{Tags: []string{"garden"}}, nil},
+				{"top-level only", TodoQuery{TopLevel: true}, []int{1, 2, 3}},
+				{"subtasks of a parent", TodoQuery{ParentIds: []int{2, 3}}, []int{4}},
+				{"subtasks of no parents", TodoQuery{ParentIds: []int{}}, nil},
+				{"occurrences added by a todo", TodoQuery{PreviousId: &one}, []int{4}},
+				{"limit and offset", TodoQuery{Limit: 2, Offset: 1}, []int{2, 3}},
+				{"offset only", TodoQuery{Offset: 3}, []int{4}},
+				{"offset past the end", TodoQuery{Limit: 2, Offset: 10}, nil},
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					todos, err := store.QueryTodos(tt.query)
+					if err != nil {
+						t.Fatalf("QueryTodos() error = %v", err)
+					}
+					var ids []int
+					for _, todo := range todos {
+						ids = append(ids, todo.Id)
+					}
+					if !slices.Equal(ids, tt.wantIDs) {
+						t.Errorf("QueryTodos() IDs = %v, want %v", ids, tt.wantIDs)
+					}
+				})
+			}
+
+			if _, err := store.QueryTodos(TodoQuery{Sort: []SortField{{Field: "item; DROP TABLE todos"}}}); err == nil {
+				t.Error("QueryTodos() with unknown sort field should fail")
+			}
+		})
+	}
+}
+
+func TestListStore(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			work, home := "work", "home"
+
+			for _, list := range []models.List{
+				{Id: "work", Name: "Work", Color: "#4f46e5", Position: 2},
+				{Id: "home", Name: "Home", Position: 1},
+				{Id: "old", Name: "Old", Archived: true},
+				{Id: "ada_home", Name: "Home", OwnerId: "ada"},
+			} {
+				if _, err := store.InsertList(list); err != nil {
+					t.Fatalf("InsertList() error = %v", err)
+				}
+			}
+			if _, err := store.InsertList(models.List{Id: "work", Name: "Again"}); !errors.Is(err, ErrListExists) {
+				t.Errorf("InsertList() duplicate error = %v, want ErrListExists", err)
+			}
+
+			for _, todo := range []models.Todo{
+				{Item: "Write report", ListId: &work},
+				{Item: "Answer email", ListId: &work, Done: true},
+				{Item: "Call plumber", ListId: &home},
+				{Item: "Buy milk"},
+			} {
+				if _, err := store.InsertTodo(todo); err != nil {
+					t.Fatalf("InsertTodo() error = %v", err)
+				}
+			}
+
+			lists, err := store.QueryLists(ListQuery{})
+			if err != nil {
+				t.Fatalf("QueryLists() error = %v", err)
+			}
+			if len(lists) != 2 || lists[0].Id != "home" || lists[1].Id != "work" {
+				t.Fatalf("QueryLists() = %+v, want [home work] by position without archived", lists)
+			}
+			if lists[1].PendingCount != 1 || lists[1].DoneCount != 1 || lists[1].Color != "#4f46e5" {
+				t.Errorf("QueryLists() work = %+v, want 1 pending, 1 done and its colour", lists[1])
+			}
+			if all, _ := store.QueryLists(ListQuery{IncludeArchived: true}); len(all) != 3 {
+				t.Errorf("QueryLists(archived) returned %d lists, want 3", len(all))
+			}
+			if owned, _ := store.QueryLists(ListQuery{OwnerId: "ada", IncludeArchived: true}); len(owned) != 1 || owned[0].OwnerId != "ada" {
+				t.Errorf("QueryLists(ada) = %+v, want only ada_home", owned)
+			}
+			shared, _ := store.QueryLists(ListQuery{OwnerId: "ada", SharedIds: []string{"work", "old"}})
+			if len(shared) != 2 || shared[0].Id != "ada_home" || shared[1].Id != "work" {
+				t.Errorf("QueryLists(ada, shared) = %+v, want [ada_home work] without archived", shared)
+			}
+
+			renamed := lists[1]
+			renamed.Name, renamed.Archived = "Office", true
+			if err := store.UpdateList(renamed); err != nil {
+				t.Fatalf("UpdateList() error = %v", err)
+			}
+			if list, err := store.GetList("work"); err != nil || list.Name != "Office" || !list.Archived {
+				t.Errorf("GetList() = %+v, %v; want renamed and archived", list, err)
+			}
+			if err := store.UpdateList(models.List{Id: "missing", Name: "Missing"}); !errors.Is(err, ErrListNotFound) {
+				t.Errorf("UpdateList() missing error = %v, want ErrListNotFound", err)
+			}
+
+			if err := store.DeleteList("work"); err != nil {
+				t.Fatalf("DeleteList() error = %v", err)
+			}
+			if _, err := store.GetList("work"); !errors.Is(err, ErrListNotFound) {
+				t.Errorf("GetList() after delete error = %v, want ErrListNotFound", err)
+			}
+			if err := store.DeleteList("work"); !errors.Is(err, ErrListNotFound) {
+				t.Errorf("DeleteList() missing error = %v, want ErrListNotFound", err)
+			}
+
+			mainList, err := store.QueryTodos(TodoQuery{FilterList: true})
+			if err != nil {
+				t.Fatalf("QueryTodos() error = %v", err)
+			}
+			var items []string
+			for _, todo := range mainList {
+				items = append(items, todo.Item)
+			}
+			if want := []string{"Write report", "Answer email", "Buy milk"}; !slices.Equal(items, want) {
+				t.Errorf("main list = %v, want %v (work todos moved, home todos kept)", items, want)
+			}
+		})
+	}
+}
+
+func TestUserStore(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			created := time.Date(2026, time.March, 6, 9, 0, 0, 0, time.UTC)
+
+			user, err := store.InsertUser(models.User{Id: "u1", Email: "Ada@Example.com", PasswordHash: "hash", CreatedAt: created})
+			if err != nil {
+				t.Fatalf("InsertUser() error = %v", err)
+			}
+			if user.Email != "ada@example.com" {
+				t.Errorf("InsertUser() email = %q, want lower case", user.Email)
+			}
+			if _, err := store.InsertUser(models.User{Id: "u2", Email: "ADA@example.com", PasswordHash: "other"}); !errors.Is(err, ErrUserExists) {
+				t.Errorf("InsertUser() duplicate error = %v, want ErrUserExists", err)
+			}
+
+			found, err := store.GetUserByEmail("ada@EXAMPLE.com")
+			if err != nil {
+				t.Fatalf("GetUserByEmail() error = %v", err)
+			}
+			if found.Id != "u1" || found.PasswordHash != "hash" || !found.CreatedAt.Equal(created) {
+				t.Errorf("GetUserByEmail() = %+v", found)
+			}
+			if _, err := store.GetUserByEmail("grace@example.com"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("GetUserByEmail() missing error = %v, want ErrUserNotFound", err)
+			}
+		})
+	}
+}
+
+func TestCalendarTokenStore(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			created := time.Date(2026, time.March, 6, 9, 0, 0, 0, time.UTC)
+
+			if err := store.SetCalendarToken(models.CalendarToken{UserId: "u1", TokenHash: "first", CreatedAt: created}); err != nil {
+				t.Fatalf("SetCalendarToken() error = %v", err)
+			}
+			token, err := store.GetCalendarToken("first")
+			if err != nil {
+				t.Fatalf("GetCalendarToken() error = %v", err)
+			}
+			if token.UserId != "u1" || !token.CreatedAt.Equal(created) {
+				t.Errorf("GetCalendarToken() = %+v", token)
+			}
+
+			// A new token replaces the old one
+			if err := store.SetCalendarToken(models.CalendarToken{UserId: "u1", TokenHash: "second", CreatedAt: created.Add(time.Hour)}); err != nil {
+				t.Fatalf("SetCalendarToken() error = %v", err)
+			}
+			if _, err := store.GetCalendarToken("first"); !errors.Is(err, ErrCalendarTokenNotFound) {
+				t.Errorf("GetCalendarToken(replaced) error = %v, want ErrCalendarTokenNotFound", err)
+			}
+			if token, err := store.GetCalendarToken("second"); err != nil || token.UserId != "u1" {
+				t.Errorf("GetCalendarToken(second) = %+v, %v, want u1's token", token, err)
+			}
+
+			if err := store.DeleteCalendarToken("u1"); err != nil {
+				t.Fatalf("DeleteCalendarToken() error = %v", err)
+			}
+			if _, err := store.GetCalendarToken("second"); !errors.Is(err, ErrCalendarTokenNotFound) {
+				t.Errorf("GetCalendarToken(deleted) error = %v, want ErrCalendarTokenNotFound", err)
+			}
+			if err := store.DeleteCalendarToken("u1"); err != nil {
+				t.Errorf("DeleteCalendarToken() without a token error = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestMemberStore(t *testing.T) {
+	for name, newStore := range storeFactories(t) {
+		t.Run(name, func(t *testing.T) {
+			store := newStore()
+			added := time.Date(2026, time.March, 6, 9, 0, 0, 0, time.UTC)
+
+			for _, list := range []models.List{{Id: "work", Name: "Work", OwnerId: "ada"}, {Id: "home", Name: "Home", OwnerId: "ada"}} {
+				if _, err := store.InsertList(list); err != nil {
+					t.Fatalf("InsertList() error = %v", err)
+				}
+			}
+			for i, member := range []models.ListMember{
+				{ListId: "work", UserId: "grace", Email: "grace@example.com", Role: models.RoleEditor},
+				{ListId: "work", UserId: "linus", Role: models.RoleViewer},
+				{ListId: "home", UserId: "grace", Role: models.RoleViewer},
+			} {
+				member.CreatedAt = added.Add(time.Duration(i) * time.Minute)
+				if _, err := store.InsertMember(member); err != nil {
+					t.Fatalf("InsertMember() error = %v", err)
+				}
+			}
+			if _, err := store.InsertMember(models.ListMember{ListId: "work", UserId: "grace", Role: models.RoleViewer}); !errors.Is(err, ErrMemberExists) {
+				t.Errorf("InsertMember() duplicate error = %v, want ErrMemberExists", err)
+			}
+
+			members, err := store.QueryMembers("work")
+			if err != nil {
+				t.Fatalf("QueryMembers() error = %v", err)
+			}
+			if len(members) != 2 || members[0].UserId != "grace" || members[1].UserId != "linus" {
+				t.Fatalf("QueryMembers() = %+v, want [grace linus]", members)
+			}
+			if members[0].Email != "grace@example.com" || !members[0].CreatedAt.Equal(added) {
+				t.Errorf("QueryMembers() grace = %+v", members[0])
+			}
+			if memberships, _ := store.QueryMemberships("grace"); len(memberships) != 2 || memberships[1].ListId != "home" {
+				t.Errorf("QueryMemberships() = %+v, want work then home", memberships)
+			}
+
+			if err := store.UpdateMember(models.ListMember{ListId: "work", UserId: "linus", Role: models.RoleEditor}); err != nil {
+				t.Fatalf("UpdateMember() error = %v", err)
+			}
+			if member, err := store.GetMember("work", "linus"); err != nil || member.Role != models.RoleEditor {
+				t.Errorf("GetMember() = %+v, %v; want editor", member, err)
+			}
+			if err := store.UpdateMember(models.ListMember{ListId: "home", UserId: "linus", Role: models.RoleEditor}); !errors.Is(err, ErrMemberNotFound) {
+				t.Errorf("UpdateMember() missing error = %v, want ErrMemberNotFound", err)
+			}
+
+			if err := store.DeleteMember("work", "linus"); err != nil {
+				t.Fatalf("DeleteMember() error = %v", err)
+			}
+			if _, err := store.GetMember("work", "linus"); !errors.Is(err, ErrMemberNotFound) {
+				t.Errorf("GetMember() after delete error = %v, want ErrMemberNotFound", err)
+			}
+			if err := store.DeleteMember("work", "linus"); !errors.Is(err, ErrMemberNotFound) {
+				t.Errorf("DeleteMember() missing error = %v, want ErrMemberNotFound", err)
+			}
+
+			if err := store.DeleteList("work"); err != nil {
+				t.Fatalf("DeleteList() error = %v", err)
+			}
+			if memberships, _ := store.QueryMemberships("grace"); len(memberships) != 1 || memberships[0].ListId != "home" {
+				t.Errorf("QueryMemberships() after DeleteList = %+v, want only home", memberships)
+			}
+		})
+	}
+}
@@ -2,97 +2,117 @@ package database
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"math"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"listy-api/models"
 
-	"github.com/joho/godotenv"
+	"github.com/supabase-community/postgrest-go"
 	"github.com/supabase-community/supabase-go"
 )
 
-var Client *supabase.Client
-
-// InitSupabase initializes the Supabase client
-func InitSupabase() error {
-	// Load environment variables from .env file
-	err := godotenv.Load()
-	if err != nil {
-		// Try loading from parent directory
-		err = godotenv.Load("../.env")
-		if err != nil {
-			log.Println("Warning: .env file not found, using environment variables")
-		}
-	}
+// SupabaseStore is a TodoStore backed by the Supabase "todos" table
+type SupabaseStore struct {
+	client *supabase.Client
+}
 
+// NewSupabaseStore creates a Supabase store from SUPABASE_URL and SUPABASE_KEY
+func NewSupabaseStore() (*SupabaseStore, error) {
 	supabaseURL := os.Getenv("SUPABASE_URL")
 	supabaseKey := os.Getenv("SUPABASE_KEY")
 
 	if supabaseURL == "" || supabaseKey == "" {
-		return fmt.Errorf("SUPABASE_URL and SUPABASE_KEY must be set in environment variables or .env file")
+		return nil, fmt.Errorf("SUPABASE_URL and SUPABASE_KEY must be set in environment variables or .env file")
 	}
 
 	client, err := supabase.NewClient(supabaseURL, supabaseKey, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create Supabase client: %v", err)
+		return nil, fmt.Errorf("failed to create Supabase client: %v", err)
 	}
 
-	Client = client
-	return nil
+	return &SupabaseStore{client: client}, nil
 }
 
-// InsertTodo inserts a single todo into Supabase
-func InsertTodo(todo models.Todo) error {
-	if Client == nil {
-		return fmt.Errorf("Supabase client not initialized")
+// InsertTodo inserts a single todo into Supabase.
+// The id column is omitted so the table's identity sequence allocates it.
+func (s *SupabaseStore) InsertTodo(todo models.Todo) (*models.Todo, error) {
+	row, err := toRow(todo)
+	if err != nil {
+		return nil, err
 	}
 
-	_, _, err := Client.From("todos").Insert(todo, false, "", "", "").Execute()
+	var inserted []models.Todo
+	data, _, err := s.client.From("todos").Insert(row, false, "", "representation", "").Execute()
 	if err != nil {
-		return fmt.Errorf("error inserting todo to Supabase: %v", err)
+		return nil, fmt.Errorf("error inserting todo to Supabase: %v", err)
 	}
 
-	return nil
+	if err := json.Unmarshal(data, &inserted); err != nil {
+		return nil, fmt.Errorf("error parsing inserted todo: %v", err)
+	}
+	if len(inserted) == 0 {
+		return nil, fmt.Errorf("Supabase did not return the inserted todo")
+	}
+
+	return &inserted[0], nil
 }
 
-// UpdateTodo updates a single todo in Supabase by ID
-func UpdateTodo(id int, todo models.Todo) error {
-	if Client == nil {
-		return fmt.Errorf("Supabase client not initialized")
+// UpdateTodo updates a single todo in Supabase by ID if its version still matches
+func (s *SupabaseStore) UpdateTodo(id int, todo models.Todo) error {
+	row, err := toRow(todo)
+	if err != nil {
+		return err
 	}
+	row["version"] = todo.Version + 1
 
-	_, _, err := Client.From("todos").Update(todo, "", "").Eq("id", strconv.Itoa(id)).Execute()
+	data, _, err := s.client.From("todos").Update(row, "representation", "").
+		Eq("id", strconv.Itoa(id)).Eq("version", strconv.Itoa(todo.Version)).Execute()
 	if err != nil {
 		return fmt.Errorf("error updating todo in Supabase: %v", err)
 	}
 
-	return nil
+	err = checkReturned(data, ErrNotFound)
+	if errors.Is(err, ErrNotFound) {
+		// The todo exists but another update got there first
+		if _, getErr := s.GetTodo(id); getErr == nil {
+			return ErrVersionConflict
+		}
+	}
+	return err
 }
 
 // DeleteTodo deletes a single todo from Supabase by ID
-func DeleteTodo(id int) error {
-	if Client == nil {
-		return fmt.Errorf("Supabase client not initialized")
-	}
-
-	_, _, err := Client.From("todos").Delete("", "").Eq("id", strconv.Itoa(id)).Execute()
+func (s *SupabaseStore) DeleteTodo(id int) error {
+	data, _, err := s.client.From("todos").Delete("representation", "").Eq("id", strconv.Itoa(id)).Execute()
 	if err != nil {
 		return fmt.Errorf("error deleting todo from Supabase: %v", err)
 	}
-
-	return nil
+	return checkReturned(data, ErrNotFound)
 }
 
-// LoadTodos loads all todos from Supabase
-func LoadTodos() ([]models.Todo, error) {
-	if Client == nil {
-		return nil, fmt.Errorf("Supabase client not initialized")
+// QueryTodos loads the todos matching q from Supabase
+func (s *SupabaseStore) QueryTodos(q TodoQuery) ([]models.Todo, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	filter := s.filterTodos("*", q)
+	for _, sort := range q.orderBy() {
+		filter = filter.Order(sort.Field, &postgrest.OrderOpts{Ascending: !sort.Desc})
+	}
+	if q.Limit > 0 {
+		filter = filter.Range(q.Offset, q.Offset+q.Limit-1, "")
+	} else if q.Offset > 0 {
+		filter = filter.Range(q.Offset, math.MaxInt32, "")
 	}
 
 	var todos []models.Todo
-	data, _, err := Client.From("todos").Select("*", "", false).Execute()
+	data, _, err := filter.Execute()
 	if err != nil {
 		return nil, fmt.Errorf("error loading todos from Supabase: %v", err)
 	}
@@ -112,3 +132,569 @@ func LoadTodos() ([]models.Todo, error) {
 
 	return todos, nil
 }
+
+// CountTags counts the todos matching q per tag, loading only their tags
+// and done flags from Supabase
+func (s *SupabaseStore) CountTags(q TodoQuery) ([]models.Tag, error) {
+	var todos []models.Todo
+	data, _, err := s.filterTodos("tags,done", q).Not("tags", "eq", "{}").Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error counting tags in Supabase: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &todos); err != nil {
+			return nil, fmt.Errorf("error parsing tags: %v", err)
+		}
+	}
+	return countTags(todos), nil
+}
+
+// filterTodos selects columns of the todos matching q's filters
+func (s *SupabaseStore) filterTodos(columns string, q TodoQuery) *postgrest.FilterBuilder {
+	filter := s.client.From("todos").Select(columns, "", false)
+	if q.Owner != nil {
+		filter = filter.Or(postgrestOwnerOr(*q.Owner, "list_id", q.SharedLists), "")
+	}
+	if q.Done != nil {
+		filter = filter.Eq("done", strconv.FormatBool(*q.Done))
+	}
+	if q.FilterList {
+		if q.ListId == nil {
+			filter = filter.Is("list_id", "null")
+		} else {
+			filter = filter.Eq("list_id", *q.ListId)
+		}
+	}
+	if q.Search != "" {
+		filter = filter.Ilike("item", "*"+escapeLike(q.Search)+"*")
+	}
+	if q.DueAfter != nil {
+		filter = filter.Gte("due_at", q.DueAfter.UTC().Format(time.RFC3339Nano))
+	}
+	if q.DueBefore != nil {
+		filter = filter.Lt("due_at", q.DueBefore.UTC().Format(time.RFC3339Nano))
+	}
+	if q.Priority != "" {
+		filter = filter.Eq("priority", q.Priority)
+	}
+	if q.Category != "" {
+		filter = filter.Ilike("category", escapeLike(q.Category))
+	}
+	if len(q.Tags) > 0 {
+		if q.AnyTag {
+			filter = filter.Overlaps("tags", q.Tags)
+		} else {
+			filter = filter.Contains("tags", q.Tags)
+		}
+	}
+	if q.TopLevel {
+		filter = filter.Is("parent_id", "null")
+	}
+	if q.ParentIds != nil {
+		ids := make([]string, len(q.ParentIds))
+		for i, id := range q.ParentIds {
+			ids[i] = strconv.Itoa(id)
+		}
+		filter = filter.In("parent_id", ids)
+	}
+	if q.PreviousId != nil {
+		filter = filter.Eq("previous_id", strconv.Itoa(*q.PreviousId))
+	}
+	if q.Trashed {
+		filter = filter.Not("deleted_at", "is", "null")
+	} else {
+		filter = filter.Is("deleted_at", "null")
+	}
+	if q.DeletedBefore != nil {
+		filter = filter.Lt("deleted_at", q.DeletedBefore.UTC().Format(time.RFC3339Nano))
+	}
+	return filter
+}
+
+// GetTodo loads a single todo from Supabase by ID
+func (s *SupabaseStore) GetTodo(id int) (*models.Todo, error) {
+	var todos []models.Todo
+	data, _, err := s.client.From("todos").Select("*", "", false).Eq("id", strconv.Itoa(id)).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error loading todo from Supabase: %v", err)
+	}
+
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &todos)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing todo: %v", err)
+		}
+	}
+
+	if len(todos) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &todos[0], nil
+}
+
+// InsertActivity appends an entry to the Supabase "activity" table
+func (s *SupabaseStore) InsertActivity(activity models.Activity) (*models.Activity, error) {
+	row := map[string]interface{}{
+		"todo_id":  activity.TodoId,
+		"actor_id": nil,
+		"action":   activity.Action,
+		"changes":  activity.Changes,
+		"at":       activity.At.UTC().Format(time.RFC3339Nano),
+		"owner_id": nil,
+		"list_id":  activity.ListId,
+	}
+	if activity.ActorId != "" {
+		row["actor_id"] = activity.ActorId
+	}
+	if activity.OwnerId != "" {
+		row["owner_id"] = activity.OwnerId
+	}
+
+	var inserted []models.Activity
+	data, _, err := s.client.From("activity").Insert(row, false, "", "representation", "").Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error inserting activity to Supabase: %v", err)
+	}
+	if err := json.Unmarshal(data, &inserted); err != nil {
+		return nil, fmt.Errorf("error parsing inserted activity: %v", err)
+	}
+	if len(inserted) == 0 {
+		return nil, fmt.Errorf("Supabase did not return the inserted activity")
+	}
+	return &inserted[0], nil
+}
+
+// QueryActivity loads the audit log entries matching q from Supabase, oldest first
+func (s *SupabaseStore) QueryActivity(q ActivityQuery) ([]models.Activity, error) {
+	filter := s.client.From("activity").Select("*", "", false)
+	if q.TodoId != 0 {
+		filter = filter.Eq("todo_id", strconv.Itoa(q.TodoId))
+	}
+	if q.Owner != nil {
+		filter = filter.Or(postgrestOwnerOr(*q.Owner, "list_id", q.SharedLists), "")
+	}
+	if q.Since != nil {
+		filter = filter.Gt("at", q.Since.UTC().Format(time.RFC3339Nano))
+	}
+	filter = filter.Order("id", &postgrest.OrderOpts{Ascending: true})
+	if q.Limit > 0 {
+		filter = filter.Limit(q.Limit, "")
+	}
+	data, _, err := filter.Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error loading activity from Supabase: %v", err)
+	}
+
+	entries := []models.Activity{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("error parsing activity: %v", err)
+		}
+	}
+	return entries, nil
+}
+
+// InsertList inserts a list into the Supabase "lists" table unless its ID is already taken
+func (s *SupabaseStore) InsertList(list models.List) (*models.List, error) {
+	_, _, err := s.client.From("lists").Insert(listRow(list), false, "", "", "").Execute()
+	if isDuplicate(err) {
+		return nil, ErrListExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error inserting list to Supabase: %v", err)
+	}
+
+	list.PendingCount, list.DoneCount = 0, 0
+	return &list, nil
+}
+
+// UpdateList updates a list in Supabase by ID
+func (s *SupabaseStore) UpdateList(list models.List) error {
+	row := listRow(list)
+	delete(row, "id")
+	delete(row, "created_at")
+
+	data, _, err := s.client.From("lists").Update(row, "representation", "").Eq("id", list.Id).Execute()
+	if err != nil {
+		return fmt.Errorf("error updating list in Supabase: %v", err)
+	}
+	return checkReturned(data, ErrListNotFound)
+}
+
+// DeleteList deletes a list from Supabase, first moving its todos to the
+// main list and removing its members. PostgREST cannot wrap these
+// in one transaction, so a failure part-way leaves the todos moved but the
+// list in place.
+func (s *SupabaseStore) DeleteList(id string) error {
+	if _, err := s.GetList(id); err != nil {
+		return err
+	}
+
+	// PostgREST cannot increment in a bulk update, so move the todos one
+	// at a time, including those in the trash
+	listed, err := s.QueryTodos(TodoQuery{FilterList: true, ListId: &id})
+	if err != nil {
+		return err
+	}
+	trashed, err := s.QueryTodos(TodoQuery{FilterList: true, ListId: &id, Trashed: true})
+	if err != nil {
+		return err
+	}
+	for _, todo := range append(listed, trashed...) {
+		_, _, err := s.client.From("todos").Update(map[string]interface{}{"list_id": nil, "version": todo.Version + 1}, "", "").
+			Eq("id", strconv.Itoa(todo.Id)).Execute()
+		if err != nil {
+			return fmt.Errorf("error clearing list todos in Supabase: %v", err)
+		}
+	}
+	if _, _, err := s.client.From("list_members").Delete("", "").Eq("list_id", id).Execute(); err != nil {
+		return fmt.Errorf("error removing list members in Supabase: %v", err)
+	}
+
+	data, _, err := s.client.From("lists").Delete("representation", "").Eq("id", id).Execute()
+	if err != nil {
+		return fmt.Errorf("error deleting list from Supabase: %v", err)
+	}
+	return checkReturned(data, ErrListNotFound)
+}
+
+// GetList loads a single list, with its todo counts, from Supabase
+func (s *SupabaseStore) GetList(id string) (*models.List, error) {
+	lists, err := s.loadLists(s.client.From("lists").Select("*", "", false).Eq("id", id))
+	if err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, ErrListNotFound
+	}
+	return &lists[0], nil
+}
+
+// QueryLists loads the lists matching q, with their todo counts, from Supabase ordered by position
+func (s *SupabaseStore) QueryLists(q ListQuery) ([]models.List, error) {
+	filter := s.client.From("lists").Select("*", "", false).Or(postgrestOwnerOr(q.OwnerId, "id", q.SharedIds), "")
+	if !q.IncludeArchived {
+		filter = filter.Eq("archived", "false")
+	}
+	filter = filter.Order("position", &postgrest.OrderOpts{Ascending: true}).
+		Order("id", &postgrest.OrderOpts{Ascending: true})
+	return s.loadLists(filter)
+}
+
+// loadLists runs a lists query and fills in todo counts. PostgREST has no
+// GROUP BY, so only the list_id and done columns of listed todos are fetched
+// and tallied here; todos in the trash are not counted.
+func (s *SupabaseStore) loadLists(filter *postgrest.FilterBuilder) ([]models.List, error) {
+	data, _, err := filter.Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error loading lists from Supabase: %v", err)
+	}
+	lists := []models.List{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &lists); err != nil {
+			return nil, fmt.Errorf("error parsing lists: %v", err)
+		}
+	}
+	if len(lists) == 0 {
+		return lists, nil
+	}
+
+	ids := make([]string, len(lists))
+	for i, list := range lists {
+		ids[i] = list.Id
+	}
+	var rows []struct {
+		ListId string `json:"list_id"`
+		Done   bool   `json:"done"`
+	}
+	data, _, err = s.client.From("todos").Select("list_id,done", "", false).In("list_id", ids).
+		Is("deleted_at", "null").Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error counting list todos in Supabase: %v", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("error parsing list todos: %v", err)
+		}
+	}
+
+	index := make(map[string]int, len(lists))
+	for i, list := range lists {
+		index[list.Id] = i
+	}
+	for _, row := range rows {
+		list := &lists[index[row.ListId]]
+		if row.Done {
+			list.DoneCount++
+		} else {
+			list.PendingCount++
+		}
+	}
+
+	return lists, nil
+}
+
+// listRow converts a list to a row map without the computed count columns
+func listRow(list models.List) map[string]interface{} {
+	var color, ownerId interface{}
+	if list.Color != "" {
+		color = list.Color
+	}
+	if list.OwnerId != "" {
+		ownerId = list.OwnerId
+	}
+	return map[string]interface{}{
+		"id":         list.Id,
+		"name":       list.Name,
+		"color":      color,
+		"position":   list.Position,
+		"archived":   list.Archived,
+		"created_at": list.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"owner_id":   ownerId,
+	}
+}
+
+// InsertMember inserts a member into the Supabase "list_members" table unless
+// the user already has a role in the list
+func (s *SupabaseStore) InsertMember(member models.ListMember) (*models.ListMember, error) {
+	_, _, err := s.client.From("list_members").Insert(member, false, "", "", "").Execute()
+	if isDuplicate(err) {
+		return nil, ErrMemberExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error inserting member to Supabase: %v", err)
+	}
+
+	return &member, nil
+}
+
+// UpdateMember changes a member's role in Supabase
+func (s *SupabaseStore) UpdateMember(member models.ListMember) error {
+	data, _, err := s.client.From("list_members").
+		Update(map[string]interface{}{"role": member.Role}, "representation", "").
+		Eq("list_id", member.ListId).Eq("user_id", member.UserId).Execute()
+	if err != nil {
+		return fmt.Errorf("error updating member in Supabase: %v", err)
+	}
+	return checkReturned(data, ErrMemberNotFound)
+}
+
+// DeleteMember removes a member from a list in Supabase
+func (s *SupabaseStore) DeleteMember(listId, userId string) error {
+	data, _, err := s.client.From("list_members").Delete("representation", "").
+		Eq("list_id", listId).Eq("user_id", userId).Execute()
+	if err != nil {
+		return fmt.Errorf("error deleting member from Supabase: %v", err)
+	}
+	return checkReturned(data, ErrMemberNotFound)
+}
+
+// GetMember loads a user's membership of a list from Supabase
+func (s *SupabaseStore) GetMember(listId, userId string) (*models.ListMember, error) {
+	members, err := s.loadMembers(s.client.From("list_members").Select("*", "", false).
+		Eq("list_id", listId).Eq("user_id", userId))
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, ErrMemberNotFound
+	}
+	return &members[0], nil
+}
+
+// QueryMembers loads the members of a list from Supabase
+func (s *SupabaseStore) QueryMembers(listId string) ([]models.ListMember, error) {
+	return s.loadMembers(s.client.From("list_members").Select("*", "", false).Eq("list_id", listId))
+}
+
+// QueryMemberships loads the user's memberships from Supabase
+func (s *SupabaseStore) QueryMemberships(userId string) ([]models.ListMember, error) {
+	return s.loadMembers(s.client.From("list_members").Select("*", "", false).Eq("user_id", userId))
+}
+
+// loadMembers runs a list_members query, oldest first
+func (s *SupabaseStore) loadMembers(filter *postgrest.FilterBuilder) ([]models.ListMember, error) {
+	for _, column := range []string{"created_at", "list_id", "user_id"} {
+		filter = filter.Order(column, &postgrest.OrderOpts{Ascending: true})
+	}
+	data, _, err := filter.Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error loading members from Supabase: %v", err)
+	}
+
+	members := []models.ListMember{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &members); err != nil {
+			return nil, fmt.Errorf("error parsing members: %v", err)
+		}
+	}
+	return members, nil
+}
+
+// supabaseUser is a row of the "users" table; models.User never serializes its password hash
+type supabaseUser struct {
+	Id           string    `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// InsertUser inserts a user into the Supabase "users" table unless the email is already registered
+func (s *SupabaseStore) InsertUser(user models.User) (*models.User, error) {
+	user.Email = strings.ToLower(user.Email)
+	row := supabaseUser{Id: user.Id, Email: user.Email, PasswordHash: user.PasswordHash, CreatedAt: user.CreatedAt}
+	_, _, err := s.client.From("users").Insert(row, false, "", "", "").Execute()
+	if isDuplicate(err) {
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error inserting user to Supabase: %v", err)
+	}
+
+	return &user, nil
+}
+
+// GetUserByEmail loads a user from Supabase by email
+func (s *SupabaseStore) GetUserByEmail(email string) (*models.User, error) {
+	data, _, err := s.client.From("users").Select("*", "", false).Eq("email", strings.ToLower(email)).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error loading user from Supabase: %v", err)
+	}
+
+	var rows []supabaseUser
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("error parsing user: %v", err)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	row := rows[0]
+	return &models.User{Id: row.Id, Email: row.Email, PasswordHash: row.PasswordHash, CreatedAt: row.CreatedAt}, nil
+}
+
+// supabaseCalendarToken is a row of the "calendar_tokens" table
+type supabaseCalendarToken struct {
+	UserId    string    `json:"user_id"`
+	TokenHash string    `json:"token_hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SetCalendarToken upserts the user's calendar token into the Supabase "calendar_tokens" table
+func (s *SupabaseStore) SetCalendarToken(token models.CalendarToken) error {
+	row := supabaseCalendarToken{UserId: token.UserId, TokenHash: token.TokenHash, CreatedAt: token.CreatedAt}
+	if _, _, err := s.client.From("calendar_tokens").Insert(row, true, "user_id", "", "").Execute(); err != nil {
+		return fmt.Errorf("error saving calendar token to Supabase: %v", err)
+	}
+	return nil
+}
+
+// DeleteCalendarToken removes the user's calendar token from Supabase, if any
+func (s *SupabaseStore) DeleteCalendarToken(userId string) error {
+	if _, _, err := s.client.From("calendar_tokens").Delete("", "").Eq("user_id", userId).Execute(); err != nil {
+		return fmt.Errorf("error deleting calendar token from Supabase: %v", err)
+	}
+	return nil
+}
+
+// GetCalendarToken loads a calendar token from Supabase by its hash
+func (s *SupabaseStore) GetCalendarToken(tokenHash string) (*models.CalendarToken, error) {
+	data, _, err := s.client.From("calendar_tokens").Select("*", "", false).Eq("token_hash", tokenHash).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error loading calendar token from Supabase: %v", err)
+	}
+
+	var rows []supabaseCalendarToken
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("error parsing calendar token: %v", err)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, ErrCalendarTokenNotFound
+	}
+	return &models.CalendarToken{UserId: rows[0].UserId, TokenHash: rows[0].TokenHash, CreatedAt: rows[0].CreatedAt}, nil
+}
+
+// isDuplicate reports whether PostgREST refused a write for breaking a
+// unique constraint (SQLSTATE 23505), as when a concurrent request has just
+// inserted the same key
+func isDuplicate(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "(23505)")
+}
+
+// checkReturned maps an empty "representation" response to notFound
+func checkReturned(data []byte, notFound error) error {
+	var rows []json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return fmt.Errorf("error parsing Supabase response: %v", err)
+	}
+	if len(rows) == 0 {
+		return notFound
+	}
+	return nil
+}
+
+// supabaseNullableColumns are omitted from the todo JSON when empty but must be
+// sent as explicit nulls so updates can clear them
+var supabaseNullableColumns = []string{"list_id", "due_at", "remind_at", "priority", "estimated_time", "category", "parent_id", "owner_id", "deleted_at", "recurrence", "previous_id"}
+
+// toRow converts a todo to a row map with the id column removed and
+// every nullable column present
+func toRow(todo models.Todo) (map[string]interface{}, error) {
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding todo: %v", err)
+	}
+
+	var row map[string]interface{}
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, fmt.Errorf("error encoding todo: %v", err)
+	}
+	delete(row, "id")
+	delete(row, "version") // Defaults to 1 on insert; UpdateTodo sets the next version
+	delete(row, "subtasks")
+	delete(row, "children")
+	delete(row, "next")
+	for _, column := range supabaseNullableColumns {
+		if _, ok := row[column]; !ok {
+			row[column] = nil
+		}
+	}
+	if _, ok := row["tags"]; !ok {
+		row["tags"] = []string{} // Not null, so the tag filters match it as having none
+	}
+
+	return row, nil
+}
+
+// postgrestOwnerOr builds an or=(...) filter matching rows of ownerId, or
+// whose idColumn is one of ids. An empty owner matches owner_id IS NULL.
+func postgrestOwnerOr(ownerId, idColumn string, ids []string) string {
+	filters := []string{"owner_id.is.null"}
+	if ownerId != "" {
+		filters[0] = "owner_id.eq." + postgrestQuote(ownerId)
+	}
+	if len(ids) > 0 {
+		quoted := make([]string, len(ids))
+		for i, id := range ids {
+			quoted[i] = postgrestQuote(id)
+		}
+		filters = append(filters, idColumn+".in.("+strings.Join(quoted, ",")+")")
+	}
+	return strings.Join(filters, ",")
+}
+
+// postgrestQuote double-quotes a value so commas and parentheses in it are
+// not read as filter syntax
+func postgrestQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// escapeLike escapes LIKE wildcards so user search text matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"listy-api/models"
+)
+
+// DefaultHistory is how many recent events a broker keeps for clients resuming after a reconnect
+const DefaultHistory = 1000
+
+// subscriberBuffer is how many events may queue for a subscriber before it is dropped
+const subscriberBuffer = 64
+
+// Broker fans todo events out to subscribers within this process and keeps
+// a bounded history so reconnecting clients can resume where they left off.
+// Event IDs are "<run>-<sequence>", where run identifies the broker, so IDs
+// from before a restart are recognised as unresumable.
+type Broker struct {
+	mu      sync.Mutex
+	run     string
+	seq     int64
+	history []models.TodoEvent // Oldest first, at most size events
+	size    int
+	subs    map[*Subscription]struct{}
+}
+
+// Subscription receives events published after it was created. C is closed
+// when the subscriber is unsubscribed or falls too far behind, in which case
+// it should reconnect with the last event ID it saw.
+type Subscription struct {
+	C     <-chan models.TodoEvent
+	Start string // ID of the last event published before subscribing, "" if none
+	c     chan models.TodoEvent
+}
+
+// NewBroker creates a broker that keeps the last size events
+func NewBroker(size int) *Broker {
+	return &Broker{
+		run:  strconv.FormatInt(time.Now().UnixNano(), 36),
+		size: size,
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish records an event for the todo and delivers it to every subscriber.
+// It never blocks: subscribers whose buffer is full are dropped.
+func (b *Broker) Publish(eventType string, todo models.Todo) models.TodoEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event := models.TodoEvent{
+		Id:   b.eventId(b.seq),
+		Type: eventType,
+		Todo: &todo,
+		At:   time.Now().UTC(),
+	}
+	b.history = append(b.history, event)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.c <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe registers a subscriber. When lastEventId is set, the events
+// published after it are returned for replay; ok is false when they can no
+// longer be replayed because the ID is from another run or too old.
+func (b *Broker) Subscribe(lastEventId string) (sub *Subscription, backlog []models.TodoEvent, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan models.TodoEvent, subscriberBuffer)
+	sub = &Subscription{C: c, c: c}
+	if b.seq > 0 {
+		sub.Start = b.eventId(b.seq)
+	}
+	b.subs[sub] = struct{}{}
+
+	if lastEventId == "" {
+		return sub, nil, true
+	}
+	seq, err := b.parseEventId(lastEventId)
+	if err != nil || seq > b.seq {
+		return sub, nil, false
+	}
+	// History holds consecutive sequence numbers ending at b.seq
+	missed := int(b.seq - seq)
+	if missed > len(b.history) {
+		return sub, nil, false
+	}
+	backlog = append(backlog, b.history[len(b.history)-missed:]...)
+	return sub, backlog, true
+}
+
+// Unsubscribe stops delivering events to sub and closes its channel
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(sub)
+}
+
+// drop removes a subscriber; the caller must hold b.mu
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
+
+func (b *Broker) eventId(seq int64) string {
+	return b.run + "-" + strconv.FormatInt(seq, 10)
+}
+
+// parseEventId returns the sequence number of an ID issued by this broker
+func (b *Broker) parseEventId(id string) (int64, error) {
+	run, seq, ok := strings.Cut(id, "-")
+	if !ok || run != b.run {
+		return 0, fmt.Errorf("event %q is from another server run", id)
+	}
+	return strconv.ParseInt(seq, 10, 64)
+}
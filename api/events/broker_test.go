@@ -0,0 +1,79 @@
+package events
+
+import (
+	"slices"
+	"testing"
+
+	"listy-api/models"
+)
+
+func TestBroker_DeliversAndResumes(t *testing.T) {
+	broker := NewBroker(3)
+
+	live, _, _ := broker.Subscribe("")
+	first := broker.Publish(models.EventCreated, models.Todo{Id: 1, Item: "First"})
+	if event := <-live.C; event.Id != first.Id || event.Todo.Item != "First" {
+		t.Errorf("live subscriber got %+v, want %+v", event, first)
+	}
+
+	// History keeps 3 to 5; 2 has been evicted
+	for i := 2; i <= 5; i++ {
+		broker.Publish(models.EventUpdated, models.Todo{Id: i})
+	}
+
+	tests := []struct {
+		name        string
+		lastEventId string
+		wantTodos   []int
+		wantOK      bool
+	}{
+		{"fresh subscriber", "", nil, true},
+		{"resumes from the oldest kept event", broker.eventId(2), []int{3, 4, 5}, true},
+		{"resumes after an event in history", broker.eventId(3), []int{4, 5}, true},
+		{"up to date", broker.eventId(5), nil, true},
+		{"too old to resume", first.Id, nil, false},
+		{"from another run", "abc-3", nil, false},
+		{"from the future", broker.eventId(9), nil, false},
+		{"malformed", "nonsense", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, backlog, ok := broker.Subscribe(tt.lastEventId)
+			defer broker.Unsubscribe(sub)
+
+			if sub.Start != broker.eventId(5) {
+				t.Errorf("Subscribe(%q) start = %q, want the latest event", tt.lastEventId, sub.Start)
+			}
+			if ok != tt.wantOK {
+				t.Errorf("Subscribe(%q) ok = %v, want %v", tt.lastEventId, ok, tt.wantOK)
+			}
+			var ids []int
+			for _, event := range backlog {
+				ids = append(ids, event.Todo.Id)
+			}
+			if !slices.Equal(ids, tt.wantTodos) {
+				t.Errorf("Subscribe(%q) backlog todos = %v, want %v", tt.lastEventId, ids, tt.wantTodos)
+			}
+		})
+	}
+}
+
+func TestBroker_DropsSlowSubscribers(t *testing.T) {
+	broker := NewBroker(DefaultHistory)
+	slow, _, _ := broker.Subscribe("")
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.Publish(models.EventCreated, models.Todo{Id: i})
+	}
+
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("slow subscriber received %d events before being dropped, want %d", received, subscriberBuffer)
+	}
+
+	// Unsubscribing a dropped subscriber is a no-op
+	broker.Unsubscribe(slow)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"listy-api/models"
+	"listy-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTodoHistory handles GET /api/todos/:id/history
+func GetTodoHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+
+	history, err := services.GetTodoHistory(currentUser(c), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": history})
+}
+
+// GetActivity handles GET /api/activity?since=&limit=
+func GetActivity(c *gin.Context) {
+	var filter models.ActivityFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	activity, err := services.GetActivity(currentUser(c), filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": activity})
+}
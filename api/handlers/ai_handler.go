@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+
 	"listy-api/models"
 	"listy-api/services"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,23 +15,20 @@ import (
 func GenerateTaskBreakdown(c *gin.Context) {
 	var req models.AITaskBreakdownRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
 	// Validate goal is not empty
 	if req.Goal == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "goal is required"})
+		c.Error(fmt.Errorf("%w: goal is required", services.ErrValidation))
 		return
 	}
 
 	// Generate task breakdown using AI
 	tasks, err := services.GenerateTaskBreakdown(req.Goal)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   err.Error(),
-			"message": "Failed to generate task breakdown",
-		})
+		c.Error(fmt.Errorf("failed to generate task breakdown: %w", err))
 		return
 	}
 
@@ -47,36 +46,52 @@ func GenerateTaskBreakdown(c *gin.Context) {
 func CreateAITasks(c *gin.Context) {
 	var req models.CreateAITasksRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
 	if len(req.Tasks) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one task is required"})
+		c.Error(fmt.Errorf("%w: at least one task is required", services.ErrValidation))
 		return
 	}
 
-	// Create todos from AI tasks
-	var createdTodos []models.Todo
-	var errors []string
+	// Subtasks need an existing parent; check once rather than failing every task
+	if req.ParentId != nil {
+		if _, err := services.GetTodoByID(currentUser(c), *req.ParentId); errors.Is(err, services.ErrForbidden) {
+			c.Error(fmt.Errorf("parent_id: %w", err))
+			return
+		} else if err != nil {
+			c.Error(fmt.Errorf("%w: parent_id: %v", services.ErrInvalidParent, err))
+			return
+		}
+	}
 
-	for _, aiTask := range req.Tasks {
-		// Use clean task text only
-		taskText := aiTask.Text
+	// Create todos from AI tasks in one batch; tasks that fail are reported as warnings
+	ops := make([]models.BatchOperation, len(req.Tasks))
+	for i, aiTask := range req.Tasks {
+		todoReq := services.AITaskTodo(aiTask, req.ListId)
+		todoReq.ParentId = req.ParentId
+		ops[i] = models.BatchOperation{Op: models.BatchCreate, Todo: &todoReq}
+	}
+	outcomes, err := services.RunBatch(currentUser(c), models.BatchRequest{Operations: ops})
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-		todo, err := services.CreateTodo(taskText, req.ListId)
-		if err != nil {
-			errors = append(errors, "Failed to create task: "+aiTask.Text+" - "+err.Error())
+	var createdTodos []models.Todo
+	var warnings []string
+	for i, outcome := range outcomes {
+		if outcome.Err != nil {
+			warnings = append(warnings, "Failed to create task: "+req.Tasks[i].Text+" - "+outcome.Err.Error())
 			continue
 		}
-		createdTodos = append(createdTodos, *todo)
+		createdTodos = append(createdTodos, *outcome.Todo)
 	}
 
+	// Every task failed: report the first failure's kind, and each failure as a detail
 	if len(createdTodos) == 0 {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to create any tasks",
-			"details": errors,
-		})
+		c.Error(fmt.Errorf("failed to create any tasks: %w", outcomes[0].Err)).SetMeta(warnings)
 		return
 	}
 
@@ -86,8 +101,8 @@ func CreateAITasks(c *gin.Context) {
 		"data":    createdTodos,
 	}
 
-	if len(errors) > 0 {
-		response["warnings"] = errors
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
 	}
 
 	c.JSON(http.StatusCreated, response)
@@ -98,23 +113,20 @@ func CreateAITasks(c *gin.Context) {
 func GenerateSubtaskBreakdown(c *gin.Context) {
 	var req models.AITaskBreakdownRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
 	// Validate goal is not empty
 	if req.Goal == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "task is required"})
+		c.Error(fmt.Errorf("%w: task is required", services.ErrValidation))
 		return
 	}
 
 	// Generate subtask breakdown using AI (smart breakdown)
 	tasks, err := services.GenerateSubtaskBreakdown(req.Goal)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   err.Error(),
-			"message": "Failed to generate subtask breakdown",
-		})
+		c.Error(fmt.Errorf("failed to generate subtask breakdown: %w", err))
 		return
 	}
 
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"listy-api/auth"
+	"listy-api/models"
+	"listy-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gin context keys holding the authenticated user's ID and email
+const (
+	userIDKey    = "user_id"
+	userEmailKey = "user_email"
+)
+
+// RequireUser authenticates the bearer token and stores its user for the
+// handlers. Missing, malformed and expired tokens are rejected with 401;
+// valid tokens that do not belong to a user (the Supabase anon key) with 403.
+// When authentication is disabled every request acts as the anonymous owner.
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Enabled() {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			abortUnauthorized(c, fmt.Errorf("%w: missing bearer token", auth.ErrInvalidToken))
+			return
+		}
+
+		claims, err := auth.VerifyToken(token)
+		if errors.Is(err, auth.ErrNotAUser) {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			abortUnauthorized(c, err)
+			return
+		}
+
+		c.Set(userIDKey, claims.Subject)
+		c.Set(userEmailKey, claims.Email)
+		c.Next()
+	}
+}
+
+// abortUnauthorized records err for a 401 with a Bearer challenge
+func abortUnauthorized(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `Bearer realm="listy"`)
+	c.Error(err)
+	c.Abort()
+}
+
+// currentUser returns the authenticated user's ID, or "" when authentication is disabled
+func currentUser(c *gin.Context) string {
+	return c.GetString(userIDKey)
+}
+
+// Register handles POST /api/auth/register
+func Register(c *gin.Context) {
+	var req models.Credentials
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	resp, err := services.Register(req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": resp})
+}
+
+// Login handles POST /api/auth/login
+func Login(c *gin.Context) {
+	var req models.Credentials
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	resp, err := services.Login(req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": resp})
+}
+
+// Me handles GET /api/auth/me, returning the user the token belongs to
+func Me(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"id":           currentUser(c),
+		"email":        c.GetString(userEmailKey),
+		"auth_enabled": auth.Enabled(),
+	}})
+}
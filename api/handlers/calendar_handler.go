@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"listy-api/auth"
+	"listy-api/models"
+	"listy-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireCalendarUser authenticates calendar feed requests. Calendar apps
+// cannot send a bearer token, so a calendar token in ?token= is accepted as
+// well; without one the request needs a bearer token as usual.
+func RequireCalendarUser() gin.HandlerFunc {
+	requireUser := RequireUser()
+	return func(c *gin.Context) {
+		token := c.Query("token")
+		if !auth.Enabled() || token == "" {
+			requireUser(c)
+			return
+		}
+
+		user, err := services.CalendarUser(token)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Set(userIDKey, user)
+		c.Next()
+	}
+}
+
+// GetCalendar handles GET /api/todos/calendar.ics?as=event|todo&token=
+func GetCalendar(c *gin.Context) {
+	respondCalendar(c, "")
+}
+
+// GetListCalendar handles GET /api/lists/:id/calendar.ics?as=event|todo&token=
+func GetListCalendar(c *gin.Context) {
+	respondCalendar(c, c.Param("id"))
+}
+
+// respondCalendar sends the calendar feed of a list, or of every todo when listId is empty
+func respondCalendar(c *gin.Context, listId string) {
+	var filter models.CalendarFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	calendar, err := services.GetCalendar(currentUser(c), listId, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Content-Disposition", `inline; filename="listy.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(calendar))
+}
+
+// IssueCalendarToken handles POST /api/calendar/token, replacing the
+// caller's calendar token and returning the new one with its feed URL
+func IssueCalendarToken(c *gin.Context) {
+	token, stored, err := services.IssueCalendarToken(currentUser(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	feed := url.URL{Scheme: scheme, Host: c.Request.Host, Path: "/api/todos/calendar.ics", RawQuery: url.Values{"token": {token}}.Encode()}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": models.CalendarSubscription{
+		Token:     token,
+		URL:       feed.String(),
+		CreatedAt: stored.CreatedAt,
+	}})
+}
+
+// RevokeCalendarToken handles DELETE /api/calendar/token
+func RevokeCalendarToken(c *gin.Context) {
+	if err := services.RevokeCalendarToken(currentUser(c)); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"listy-api/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOpenAPI handles GET /api/openapi.json
+func GetOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, openapi.Spec())
+}
+
+// docsPage renders /api/openapi.json with Swagger UI
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>Listy API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="docs"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    SwaggerUIBundle({ url: "/api/openapi.json", dom_id: "#docs", persistAuthorization: true });
+  </script>
+</body>
+</html>
+`
+
+// GetDocs handles GET /api/docs
+func GetDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
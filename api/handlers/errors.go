@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"listy-api/auth"
+	"listy-api/models"
+	"listy-api/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Errors responds for handlers that fail: they record the error with
+// c.Error and return without writing, and Errors answers with the status
+// and code of the error's kind and a models.ErrorResponse. Errors of type
+// gin.ErrorTypeBind are failed bindings, reported as validation errors with
+// a detail per invalid field. The error's meta adds details when it is a
+// []string and is sent as data otherwise.
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+
+		status, code := errorStatus(last.Err)
+		body := models.ErrorResponse{Error: models.Error{Code: code, Message: last.Error()}}
+		if last.IsType(gin.ErrorTypeBind) {
+			status, body.Error.Code = http.StatusBadRequest, models.CodeValidation
+			var fields validator.ValidationErrors
+			if errors.As(last.Err, &fields) {
+				for _, field := range fields {
+					body.Error.Details = append(body.Error.Details, field.Error())
+				}
+			}
+		}
+		switch meta := last.Meta.(type) {
+		case nil:
+		case []string:
+			body.Error.Details = append(body.Error.Details, meta...)
+		default:
+			body.Data = meta
+		}
+		c.JSON(status, body)
+	}
+}
+
+// errorStatus maps an error to the status and code of its kind
+func errorStatus(err error) (int, string) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, services.ErrValidation):
+		return http.StatusBadRequest, models.CodeValidation
+	case errors.Is(err, services.ErrBadCredentials), errors.Is(err, services.ErrInvalidCalendarToken), errors.Is(err, auth.ErrInvalidToken):
+		return http.StatusUnauthorized, models.CodeUnauthorized
+	case errors.Is(err, services.ErrForbidden), errors.Is(err, auth.ErrNotAUser):
+		return http.StatusForbidden, models.CodeForbidden
+	case errors.Is(err, services.ErrNotFound):
+		return http.StatusNotFound, models.CodeNotFound
+	case errors.Is(err, services.ErrVersionConflict):
+		return http.StatusPreconditionFailed, models.CodeVersionConflict
+	case errors.Is(err, services.ErrConflict):
+		return http.StatusConflict, models.CodeConflict
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge, models.CodeTooLarge
+	case errors.Is(err, services.ErrUpstreamAI):
+		return http.StatusBadGateway, models.CodeUpstreamAI
+	case errors.Is(err, auth.ErrDisabled):
+		return http.StatusServiceUnavailable, models.CodeUnavailable
+	default:
+		return http.StatusInternalServerError, models.CodeInternal
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+
+	"listy-api/models"
+	"listy-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAllLists handles GET /api/lists?archived=true
+func GetAllLists(c *gin.Context) {
+	var filter models.ListFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	lists, err := services.GetLists(currentUser(c), filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": lists})
+}
+
+// GetList handles GET /api/lists/:id
+func GetList(c *gin.Context) {
+	list, err := services.GetList(currentUser(c), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// CreateList handles POST /api/lists
+func CreateList(c *gin.Context) {
+	var req models.CreateListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	list, err := services.CreateList(currentUser(c), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": list})
+}
+
+// UpdateList handles PUT /api/lists/:id (rename, recolour, reorder, archive)
+func UpdateList(c *gin.Context) {
+	var req models.UpdateListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	list, err := services.UpdateList(currentUser(c), c.Param("id"), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// DeleteList handles DELETE /api/lists/:id?todos=move|delete
+func DeleteList(c *gin.Context) {
+	var req models.DeleteListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	if err := services.DeleteList(currentUser(c), c.Param("id"), req); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "List deleted successfully"})
+}
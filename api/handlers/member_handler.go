@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"listy-api/models"
+	"listy-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMembers handles GET /api/lists/:id/members
+func GetMembers(c *gin.Context) {
+	members, err := services.GetMembers(currentUser(c), c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": members})
+}
+
+// AddMember handles POST /api/lists/:id/members, inviting a user by email or user_id
+func AddMember(c *gin.Context) {
+	var req models.AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	member, err := services.AddMember(currentUser(c), c.Param("id"), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": member})
+}
+
+// UpdateMember handles PUT /api/lists/:id/members/:userId (change role)
+func UpdateMember(c *gin.Context) {
+	var req models.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	member, err := services.UpdateMember(currentUser(c), c.Param("id"), c.Param("userId"), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": member})
+}
+
+// RemoveMember handles DELETE /api/lists/:id/members/:userId; members may remove themselves
+func RemoveMember(c *gin.Context) {
+	if err := services.RemoveMember(currentUser(c), c.Param("id"), c.Param("userId")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Member removed successfully"})
+}
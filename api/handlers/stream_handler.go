@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"listy-api/models"
+	"listy-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment so proxies keep it open
+var streamHeartbeat = 25 * time.Second
+
+// StreamTodos handles GET /api/todos/stream?list=<id|main>, sending todo
+// changes as Server-Sent Events until the client disconnects. Clients resume
+// with the Last-Event-ID header (or ?last_event_id=) after reconnecting.
+func StreamTodos(c *gin.Context) {
+	var filter models.StreamFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = filter.LastEventId
+	}
+
+	watch, err := services.WatchTodos(currentUser(c), filter, lastEventId)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer watch.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+		case event, ok := <-watch.C:
+			if !ok {
+				// Fell behind; the client reconnects with its last event ID
+				return
+			}
+			if err := writeEvent(c.Writer, event); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeEvent writes an event in the text/event-stream format, with the
+// whole event as JSON in its data field
+func writeEvent(w io.Writer, event models.TodoEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+	return err
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"listy-api/models"
+	"listy-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTags handles GET /api/tags
+func GetTags(c *gin.Context) {
+	tags, err := services.GetTags(currentUser(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": tags})
+}
+
+// AddTags handles POST /api/todos/:id/tags
+func AddTags(c *gin.Context) {
+	changeTags(c, services.AddTags)
+}
+
+// RemoveTags handles DELETE /api/todos/:id/tags
+func RemoveTags(c *gin.Context) {
+	changeTags(c, services.RemoveTags)
+}
+
+// changeTags applies a TagsRequest to the todo in the path with change
+func changeTags(c *gin.Context, change func(user string, id int, tags []string, ifMatch int) (*models.Todo, error)) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	ifMatch, ok := ifMatchVersion(c, id)
+	if !ok {
+		return
+	}
+
+	var req models.TagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	todo, err := change(currentUser(c), id, req.Tags, ifMatch)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("ETag", todoETag(todo))
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": todo})
+}
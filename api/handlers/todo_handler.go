@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"listy-api/models"
 	"listy-api/services"
@@ -10,50 +13,141 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// GetTodos handles GET /api/todos
+// respondTodoPage writes a listing response, adding next_cursor when another page follows
+func respondTodoPage(c *gin.Context, todos []models.Todo, nextCursor string) {
+	response := gin.H{"success": true, "data": todos}
+	if nextCursor != "" {
+		response["next_cursor"] = nextCursor
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// errInvalidID is recorded for todo IDs in paths that are not numbers
+var errInvalidID = fmt.Errorf("%w: todo ID must be a number", services.ErrValidation)
+
+// todoETag is the ETag of a todo's stored state: its quoted version number.
+// Subtask progress and children in responses are not covered.
+func todoETag(todo *models.Todo) string {
+	return strconv.Quote(strconv.Itoa(todo.Version))
+}
+
+// ifMatchVersion reads the todo version a change is conditional on from the
+// If-Match header: 0 when there is none or it is "*". It records a version
+// conflict and returns false when the header is not a todo ETag.
+func ifMatchVersion(c *gin.Context, id int) (int, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return 0, true
+	}
+	if unquoted, err := strconv.Unquote(header); err == nil {
+		if version, err := strconv.Atoi(unquoted); err == nil && version > 0 {
+			return version, true
+		}
+	}
+	c.Error(fmt.Errorf("%w: If-Match %s is not an ETag of todo %d", services.ErrVersionConflict, header, id))
+	return 0, false
+}
+
+// GetTodos handles GET /api/todos?done=&list=&q=&sort=&limit=&cursor=
 func GetTodos(c *gin.Context) {
-	todos, err := services.GetAllTodos()
+	var filter models.TodoFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	todos, nextCursor, err := services.ListTodos(currentUser(c), filter)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": todos})
+	respondTodoPage(c, todos, nextCursor)
 }
 
-// GetPendingTodos handles GET /api/todos/pending
+// GetPendingTodos handles GET /api/todos/pending?limit=&cursor=
 func GetPendingTodos(c *gin.Context) {
-	todos, err := services.GetPendingTodos()
+	var page models.PageRequest
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	todos, nextCursor, err := services.GetPendingTodos(currentUser(c), page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": todos})
+	respondTodoPage(c, todos, nextCursor)
 }
 
-// GetCompletedTodos handles GET /api/todos/completed
+// GetCompletedTodos handles GET /api/todos/completed?limit=&cursor=
 func GetCompletedTodos(c *gin.Context) {
-	todos, err := services.GetCompletedTodos()
+	var page models.PageRequest
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	todos, nextCursor, err := services.GetCompletedTodos(currentUser(c), page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": todos})
+	respondTodoPage(c, todos, nextCursor)
+}
+
+// GetOverdueTodos handles GET /api/todos/overdue?limit=&cursor=
+func GetOverdueTodos(c *gin.Context) {
+	handleDueView(c, services.GetOverdueTodos)
+}
+
+// GetTodayTodos handles GET /api/todos/today?tz=&limit=&cursor=
+func GetTodayTodos(c *gin.Context) {
+	handleDueView(c, services.GetTodayTodos)
 }
 
-// GetTodoByID handles GET /api/todos/:id
+// GetUpcomingTodos handles GET /api/todos/upcoming?days=&limit=&cursor=
+func GetUpcomingTodos(c *gin.Context) {
+	handleDueView(c, services.GetUpcomingTodos)
+}
+
+// handleDueView binds the due-date view parameters and responds with one page
+func handleDueView(c *gin.Context, view func(string, models.DueFilter) ([]models.Todo, string, error)) {
+	var filter models.DueFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	todos, nextCursor, err := view(currentUser(c), filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	respondTodoPage(c, todos, nextCursor)
+}
+
+// GetTodoByID handles GET /api/todos/:id?include=children
 func GetTodoByID(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		c.Error(errInvalidID)
+		return
+	}
+
+	var req models.TodoDetailRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	todo, err := services.GetTodoByID(id)
+	todo, err := services.GetTodoDetails(currentUser(c), id, req)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
+	c.Header("ETag", todoETag(todo))
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": todo})
 }
 
@@ -61,20 +155,59 @@ func GetTodoByID(c *gin.Context) {
 func CreateTodo(c *gin.Context) {
 	var req models.CreateTodoRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	todo, err := services.CreateTodo(req.Item, req.ListId)
+	todo, err := services.CreateTodo(currentUser(c), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"success": true, "data": todo})
 }
 
-// GetTodosByList handles GET /api/todos/list/:listId
+// BatchTodos handles POST /api/todos/batch. Each operation gets a result
+// with the status it would have had on its own; when an atomic batch fails,
+// the response has that status and the other operations report 424.
+func BatchTodos(c *gin.Context) {
+	var req models.BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	outcomes, err := services.RunBatch(currentUser(c), req)
+	if errors.Is(err, services.ErrInvalidBatch) {
+		c.Error(err)
+		return
+	}
+
+	results := make([]models.BatchResult, len(outcomes))
+	for i, outcome := range outcomes {
+		op := req.Operations[i]
+		result := models.BatchResult{Op: op.Op, Id: op.Id, Status: http.StatusOK, Data: outcome.Todo}
+		switch {
+		case errors.Is(outcome.Err, services.ErrNotApplied):
+			result.Status, result.Code, result.Error = http.StatusFailedDependency, models.CodeNotApplied, outcome.Err.Error()
+		case outcome.Err != nil:
+			result.Status, result.Code = errorStatus(outcome.Err)
+			result.Error = outcome.Err.Error()
+		case op.Op == models.BatchCreate:
+			result.Id, result.Status = outcome.Todo.Id, http.StatusCreated
+		}
+		results[i] = result
+	}
+
+	if err != nil {
+		c.Error(err).SetMeta(results)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": results})
+}
+
+// GetTodosByList handles GET /api/todos/list/:listId?limit=&cursor=
 // If listId is "main" or empty, returns main list todos (list_id is NULL)
 func GetTodosByList(c *gin.Context) {
 	listIdParam := c.Param("listId")
@@ -84,89 +217,87 @@ func GetTodosByList(c *gin.Context) {
 		listId = &listIdParam
 	}
 
-	todos, err := services.GetTodosByListId(listId)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var page models.PageRequest
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": todos})
-}
 
-// GetAllLists handles GET /api/lists
-func GetAllLists(c *gin.Context) {
-	listIds, err := services.GetAllListIds()
+	todos, nextCursor, err := services.GetTodosByListId(currentUser(c), listId, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": listIds})
+	respondTodoPage(c, todos, nextCursor)
 }
 
-// UpdateTodo handles PUT /api/todos/:id
+// UpdateTodo handles PUT /api/todos/:id, optionally with If-Match: "<version>"
 func UpdateTodo(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		c.Error(errInvalidID)
+		return
+	}
+	ifMatch, ok := ifMatchVersion(c, id)
+	if !ok {
 		return
 	}
 
 	var req models.UpdateTodoRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(err).SetType(gin.ErrorTypeBind)
 		return
 	}
 
-	todo, err := services.UpdateTodo(id, req)
+	todo, err := services.UpdateTodo(currentUser(c), id, req, ifMatch)
 	if err != nil {
-		if err.Error() == "todo with ID "+strconv.Itoa(id)+" not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		c.Error(err)
 		return
 	}
 
+	c.Header("ETag", todoETag(todo))
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": todo})
 }
 
-// DeleteTodo handles DELETE /api/todos/:id
+// DeleteTodo handles DELETE /api/todos/:id, optionally with If-Match: "<version>"
 func DeleteTodo(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		c.Error(errInvalidID)
+		return
+	}
+	ifMatch, ok := ifMatchVersion(c, id)
+	if !ok {
 		return
 	}
 
-	err = services.DeleteTodo(id)
+	err = services.DeleteTodo(currentUser(c), id, ifMatch)
 	if err != nil {
-		if err.Error() == "todo with ID "+strconv.Itoa(id)+" not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Todo deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Todo moved to the trash"})
 }
 
-// ToggleTodo handles PATCH /api/todos/:id/toggle
+// ToggleTodo handles PATCH /api/todos/:id/toggle, optionally with If-Match: "<version>"
 func ToggleTodo(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		c.Error(errInvalidID)
+		return
+	}
+	ifMatch, ok := ifMatchVersion(c, id)
+	if !ok {
 		return
 	}
 
-	todo, err := services.ToggleTodo(id)
+	todo, err := services.ToggleTodo(currentUser(c), id, ifMatch)
 	if err != nil {
-		if err.Error() == "todo with ID "+strconv.Itoa(id)+" not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		c.Error(err)
 		return
 	}
 
+	c.Header("ETag", todoETag(todo))
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": todo})
 }
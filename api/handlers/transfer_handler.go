@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"listy-api/models"
+	"listy-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportSize is the largest file POST /api/import accepts, in bytes
+const maxImportSize = 5 << 20
+
+// ExportTodos handles GET /api/export?format=json|csv|markdown|todotxt&list=&tz=
+func ExportTodos(c *gin.Context) {
+	var filter models.ExportFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data, format, err := services.ExportTodos(currentUser(c), filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="listy.`+format.Extension()+`"`)
+	c.Data(http.StatusOK, format.ContentType(), data)
+}
+
+// ImportTodos handles POST /api/import?format=&list=&tz=&dry_run=&dedupe=
+// with the file as the request body. It responds 201 with what was
+// created, or 200 for a dry run.
+func ImportTodos(c *gin.Context) {
+	var opts models.ImportOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.Error(fmt.Errorf("import files are limited to 5 MB: %w", err))
+		return
+	}
+	if err != nil {
+		c.Error(fmt.Errorf("%w: reading the file: %v", services.ErrValidation, err))
+		return
+	}
+
+	result, err := services.ImportTodos(currentUser(c), data, opts)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	status := http.StatusCreated
+	if result.DryRun {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{"success": true, "data": result})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"listy-api/models"
+	"listy-api/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTrash handles GET /api/trash?limit=&cursor=
+func GetTrash(c *gin.Context) {
+	var page models.PageRequest
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.Error(err).SetType(gin.ErrorTypeBind)
+		return
+	}
+
+	todos, nextCursor, err := services.GetTrash(currentUser(c), page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	respondTodoPage(c, todos, nextCursor)
+}
+
+// RestoreTodo handles POST /api/todos/:id/restore, optionally with If-Match: "<version>"
+func RestoreTodo(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(errInvalidID)
+		return
+	}
+	ifMatch, ok := ifMatchVersion(c, id)
+	if !ok {
+		return
+	}
+
+	todo, err := services.RestoreTodo(currentUser(c), id, ifMatch)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("ETag", todoETag(todo))
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": todo})
+}
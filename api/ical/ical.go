@@ -0,0 +1,97 @@
+// Package ical writes iCalendar (RFC 5545) data: components made of
+// properties, with text escaped and long lines folded.
+package ical
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineLength is the most octets a content line may have before folding
+const maxLineLength = 75
+
+// Component is a BEGIN/END block such as VCALENDAR, VTODO or VALARM
+type Component struct {
+	Name       string
+	Properties []Property
+	Components []Component
+}
+
+// Property is one content line. Params holds parameters as they are
+// written, e.g. "VALUE=DATE-TIME"; Value must already be escaped where the
+// value type needs it, as Text does.
+type Property struct {
+	Name   string
+	Params []string
+	Value  string
+}
+
+// Add appends a property to the component
+func (c *Component) Add(name, value string, params ...string) {
+	c.Properties = append(c.Properties, Property{Name: name, Params: params, Value: value})
+}
+
+// String returns the component with CRLF line endings
+func (c Component) String() string {
+	var b strings.Builder
+	c.write(&b)
+	return b.String()
+}
+
+func (c Component) write(b *strings.Builder) {
+	writeLine(b, "BEGIN:"+c.Name)
+	for _, p := range c.Properties {
+		line := p.Name
+		for _, param := range p.Params {
+			line += ";" + param
+		}
+		writeLine(b, line+":"+p.Value)
+	}
+	for _, child := range c.Components {
+		child.write(b)
+	}
+	writeLine(b, "END:"+c.Name)
+}
+
+// writeLine writes a content line, folding it into lines of at most
+// maxLineLength octets that continue with a space, without splitting a
+// UTF-8 character
+func writeLine(b *strings.Builder, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineLength - 1 // The leading space counts
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// textEscaper escapes the characters TEXT values cannot contain as is
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// Text escapes a TEXT value
+func Text(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// TextList escapes and joins the values of a multi-valued TEXT property
+// such as CATEGORIES
+func TextList(values []string) string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = Text(v)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// DateTime formats an instant as a UTC DATE-TIME value
+func DateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
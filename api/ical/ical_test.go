@@ -0,0 +1,51 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComponent_String(t *testing.T) {
+	todo := Component{Name: "VTODO"}
+	todo.Add("SUMMARY", Text("Milk, eggs; bread\nand \\ jam"))
+	todo.Add("DUE", DateTime(time.Date(2026, time.March, 2, 10, 0, 0, 0, time.FixedZone("CET", 3600))))
+	todo.Add("CATEGORIES", TextList([]string{"home", "a,b"}))
+	alarm := Component{Name: "VALARM"}
+	alarm.Add("TRIGGER", "20260302T080000Z", "VALUE=DATE-TIME")
+	todo.Components = append(todo.Components, alarm)
+
+	want := "BEGIN:VTODO\r\n" +
+		`SUMMARY:Milk\, eggs\; bread\nand \\ jam` + "\r\n" +
+		"DUE:20260302T090000Z\r\n" +
+		`CATEGORIES:home,a\,b` + "\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"TRIGGER;VALUE=DATE-TIME:20260302T080000Z\r\n" +
+		"END:VALARM\r\n" +
+		"END:VTODO\r\n"
+	if got := todo.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestComponent_FoldsLongLines(t *testing.T) {
+	event := Component{Name: "VEVENT"}
+	event.Add("SUMMARY", strings.Repeat("é", 100))
+	lines := strings.Split(strings.TrimSuffix(event.String(), "\r\n"), "\r\n")
+
+	var unfolded strings.Builder
+	for i, line := range lines {
+		if len(line) > maxLineLength {
+			t.Errorf("line %d has %d octets, want at most %d", i, len(line), maxLineLength)
+		}
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+		} else {
+			unfolded.WriteString("\n" + line)
+		}
+	}
+	want := "\nBEGIN:VEVENT\nSUMMARY:" + strings.Repeat("é", 100) + "\nEND:VEVENT"
+	if unfolded.String() != want {
+		t.Errorf("unfolded = %q, want %q", unfolded.String(), want)
+	}
+}
@@ -4,22 +4,73 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
+	"listy-api/auth"
 	"listy-api/database"
 	"listy-api/handlers"
+	"listy-api/services"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
-	// Initialize Supabase
-	err := database.InitSupabase()
+	// Initialize the todo store (Supabase, SQLite or in-memory, see LISTY_STORE)
+	err := database.InitStore()
 	if err != nil {
-		log.Fatalf("Failed to initialize Supabase: %v", err)
+		log.Fatalf("Failed to initialize store: %v", err)
 	}
 
-	// Set up Gin router
+	// Configure bearer token authentication (LISTY_JWT_SECRET, SUPABASE_JWT_SECRET,
+	// or LISTY_AUTH_DISABLED=1 to run without it)
+	if err := auth.Init(); err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+	if !auth.Enabled() {
+		log.Println("Warning: LISTY_AUTH_DISABLED is set, authentication is disabled and all requests share one anonymous owner")
+	}
+
+	// Purge the trash regularly (LISTY_TRASH_RETENTION)
+	if err := services.InitTrash(); err != nil {
+		log.Fatalf("Failed to configure the trash: %v", err)
+	}
+	if services.TrashRetention() > 0 {
+		go purgeTrash(time.Hour)
+	}
+
+	r := setupRouter()
+
+	// Get port from environment or default to 8080
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	fmt.Printf("🚀 Server starting on port %s\n", port)
+	fmt.Printf("📡 API endpoints available at http://localhost:%s/api\n", port)
+	fmt.Printf("❤️  Health check: http://localhost:%s/api/health\n", port)
+	fmt.Printf("📖 API docs: http://localhost:%s/api/docs\n", port)
+
+	if err := r.Run(":" + port); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
+
+// purgeTrash deletes expired todos from the trash now and then every interval
+func purgeTrash(interval time.Duration) {
+	for {
+		if purged, err := services.PurgeExpiredTrash(); err != nil {
+			log.Printf("Failed to purge the trash: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d todos from the trash", purged)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// setupRouter builds the Gin engine with middleware and every API route
+func setupRouter() *gin.Engine {
 	r := gin.Default()
 
 	// CORS middleware - allow requests from Next.js frontend
@@ -31,14 +82,30 @@ func main() {
 	}
 	config.AllowOrigins = allowedOrigins
 	config.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "Last-Event-ID", "If-Match"}
+	config.ExposeHeaders = []string{"ETag"}
 	r.Use(cors.New(config))
 
+	// Error responses - handlers record errors, this answers with their status and code
+	r.Use(handlers.Errors())
+
 	// Health check endpoint
 	r.GET("/api/health", handlers.HealthCheck)
 
+	// OpenAPI document of every route, and docs rendering it
+	r.GET("/api/openapi.json", handlers.GetOpenAPI) // GET /api/openapi.json
+	r.GET("/api/docs", handlers.GetDocs)            // GET /api/docs
+
+	// Auth routes - register and login need no token
+	authRoutes := r.Group("/api/auth")
+	{
+		authRoutes.POST("/register", handlers.Register)            // POST /api/auth/register
+		authRoutes.POST("/login", handlers.Login)                  // POST /api/auth/login
+		authRoutes.GET("/me", handlers.RequireUser(), handlers.Me) // GET /api/auth/me
+	}
+
 	// AI routes - register BEFORE /api/todos/:id to avoid route conflicts
-	ai := r.Group("/api/todos/ai")
+	ai := r.Group("/api/todos/ai", handlers.RequireUser())
 	{
 		ai.POST("/breakdown", handlers.GenerateTaskBreakdown)   // POST /api/todos/ai/breakdown (for main list)
 		ai.POST("/subtasks", handlers.GenerateSubtaskBreakdown) // POST /api/todos/ai/subtasks (for subtasks)
@@ -46,36 +113,61 @@ func main() {
 	}
 
 	// Todo routes
-	api := r.Group("/api/todos")
+	api := r.Group("/api/todos", handlers.RequireUser())
 	{
 		api.GET("", handlers.GetTodos)                    // GET /api/todos
 		api.GET("/pending", handlers.GetPendingTodos)     // GET /api/todos/pending
 		api.GET("/completed", handlers.GetCompletedTodos) // GET /api/todos/completed
+		api.GET("/overdue", handlers.GetOverdueTodos)     // GET /api/todos/overdue
+		api.GET("/today", handlers.GetTodayTodos)         // GET /api/todos/today?tz=Europe/Paris
+		api.GET("/upcoming", handlers.GetUpcomingTodos)   // GET /api/todos/upcoming?days=7
 		api.GET("/list/:listId", handlers.GetTodosByList) // GET /api/todos/list/:listId (or "main" for main list)
+		api.GET("/stream", handlers.StreamTodos)          // GET /api/todos/stream?list=work (Server-Sent Events)
 		api.GET("/:id", handlers.GetTodoByID)             // GET /api/todos/:id
 		api.POST("", handlers.CreateTodo)                 // POST /api/todos
+		api.POST("/batch", handlers.BatchTodos)           // POST /api/todos/batch
 		api.PUT("/:id", handlers.UpdateTodo)              // PUT /api/todos/:id
 		api.PATCH("/:id/toggle", handlers.ToggleTodo)     // PATCH /api/todos/:id/toggle
-		api.DELETE("/:id", handlers.DeleteTodo)           // DELETE /api/todos/:id
+		api.DELETE("/:id", handlers.DeleteTodo)           // DELETE /api/todos/:id (moves it to the trash)
+		api.POST("/:id/restore", handlers.RestoreTodo)    // POST /api/todos/:id/restore
+		api.GET("/:id/history", handlers.GetTodoHistory)  // GET /api/todos/:id/history
+		api.POST("/:id/tags", handlers.AddTags)           // POST /api/todos/:id/tags
+		api.DELETE("/:id/tags", handlers.RemoveTags)      // DELETE /api/todos/:id/tags
 	}
 
+	// Deleted todos, kept until LISTY_TRASH_RETENTION has passed
+	r.GET("/api/trash", handlers.RequireUser(), handlers.GetTrash) // GET /api/trash
+
+	// Calendar feeds; calendar apps pass the token from POST /api/calendar/token as ?token=
+	r.GET("/api/todos/calendar.ics", handlers.RequireCalendarUser(), handlers.GetCalendar)         // GET /api/todos/calendar.ics?as=event|todo
+	r.GET("/api/lists/:id/calendar.ics", handlers.RequireCalendarUser(), handlers.GetListCalendar) // GET /api/lists/:id/calendar.ics
+	r.POST("/api/calendar/token", handlers.RequireUser(), handlers.IssueCalendarToken)             // POST /api/calendar/token (replaces the old one)
+	r.DELETE("/api/calendar/token", handlers.RequireUser(), handlers.RevokeCalendarToken)          // DELETE /api/calendar/token
+
+	// Todos as JSON, CSV, Markdown checklists or todo.txt files
+	r.GET("/api/export", handlers.RequireUser(), handlers.ExportTodos)  // GET /api/export?format=csv&list=work
+	r.POST("/api/import", handlers.RequireUser(), handlers.ImportTodos) // POST /api/import?dry_run=true&dedupe=true (body: the file)
+
+	// Every tag on the user's todos, with pending and done counts
+	r.GET("/api/tags", handlers.RequireUser(), handlers.GetTags) // GET /api/tags
+
+	// Who changed which todo, oldest first
+	r.GET("/api/activity", handlers.RequireUser(), handlers.GetActivity) // GET /api/activity?since=
+
 	// List routes
-	lists := r.Group("/api/lists")
+	lists := r.Group("/api/lists", handlers.RequireUser())
 	{
-		lists.GET("", handlers.GetAllLists) // GET /api/lists
-	}
+		lists.GET("", handlers.GetAllLists)       // GET /api/lists?archived=true
+		lists.POST("", handlers.CreateList)       // POST /api/lists
+		lists.GET("/:id", handlers.GetList)       // GET /api/lists/:id
+		lists.PUT("/:id", handlers.UpdateList)    // PUT /api/lists/:id
+		lists.DELETE("/:id", handlers.DeleteList) // DELETE /api/lists/:id?todos=move|delete
 
-	// Get port from environment or default to 8080
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+		lists.GET("/:id/members", handlers.GetMembers)              // GET /api/lists/:id/members
+		lists.POST("/:id/members", handlers.AddMember)              // POST /api/lists/:id/members
+		lists.PUT("/:id/members/:userId", handlers.UpdateMember)    // PUT /api/lists/:id/members/:userId
+		lists.DELETE("/:id/members/:userId", handlers.RemoveMember) // DELETE /api/lists/:id/members/:userId
 	}
 
-	fmt.Printf("🚀 Server starting on port %s\n", port)
-	fmt.Printf("📡 API endpoints available at http://localhost:%s/api\n", port)
-	fmt.Printf("❤️  Health check: http://localhost:%s/api/health\n", port)
-
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
-	}
+	return r
 }
@@ -0,0 +1,1676 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"listy-api/auth"
+	"listy-api/database"
+	"listy-api/models"
+	"listy-api/openapi"
+	"listy-api/sdk"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+}
+
+// useStore swaps the active store for the duration of a test
+func useStore(t *testing.T, store database.TodoStore) {
+	previous := database.Store
+	database.Store = store
+	t.Cleanup(func() { database.Store = previous })
+}
+
+func TestCreateTodo_ConcurrentRequestsGetUniqueIDs(t *testing.T) {
+	const requests = 50
+
+	sqliteStore, err := database.NewSQLiteStore(filepath.Join(t.TempDir(), "listy.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore() error = %v", err)
+	}
+	defer sqliteStore.Close()
+
+	stores := map[string]database.TodoStore{
+		"memory": database.NewMemoryStore(),
+		"sqlite": sqliteStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			useStore(t, store)
+			router := setupRouter()
+
+			var wg sync.WaitGroup
+			ids := make(chan int, requests)
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					req := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(`{"item":"Concurrent"}`))
+					req.Header.Set("Content-Type", "application/json")
+					w := httptest.NewRecorder()
+					router.ServeHTTP(w, req)
+
+					if w.Code != http.StatusCreated {
+						t.Errorf("POST /api/todos status = %d, body = %s", w.Code, w.Body.String())
+						return
+					}
+					var resp struct {
+						Data models.Todo `json:"data"`
+					}
+					if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+						t.Errorf("failed to parse response: %v", err)
+						return
+					}
+					ids <- resp.Data.Id
+				}()
+			}
+			wg.Wait()
+			close(ids)
+
+			seen := make(map[int]bool)
+			for id := range ids {
+				if seen[id] {
+					t.Errorf("POST /api/todos returned duplicate ID %d", id)
+				}
+				seen[id] = true
+			}
+			if len(seen) != requests {
+				t.Errorf("got %d unique IDs, want %d", len(seen), requests)
+			}
+
+			todos, err := store.QueryTodos(database.TodoQuery{})
+			if err != nil {
+				t.Fatalf("QueryTodos() error = %v", err)
+			}
+			if len(todos) != requests {
+				t.Errorf("store has %d todos, want %d", len(todos), requests)
+			}
+		})
+	}
+}
+
+func TestGetTodos_Pagination(t *testing.T) {
+	store := database.NewMemoryStore()
+	useStore(t, store)
+	router := setupRouter()
+
+	for i := 0; i < 5; i++ {
+		if _, err := store.InsertTodo(models.Todo{Item: "Paged"}); err != nil {
+			t.Fatalf("InsertTodo() error = %v", err)
+		}
+	}
+
+	var ids []int
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/todos?limit=2&cursor="+cursor, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/todos status = %d, body = %s", w.Code, w.Body.String())
+		}
+
+		var resp struct {
+			Data       []models.Todo `json:"data"`
+			NextCursor string        `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("failed to parse response: %v", err)
+		}
+		if len(resp.Data) > 2 {
+			t.Errorf("page has %d todos, want at most 2", len(resp.Data))
+		}
+		for _, todo := range resp.Data {
+			ids = append(ids, todo.Id)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+
+	if want := []int{1, 2, 3, 4, 5}; !slices.Equal(ids, want) {
+		t.Errorf("paged IDs = %v, want %v", ids, want)
+	}
+
+	badRequests := []string{
+		"/api/todos?cursor=not-a-cursor",
+		"/api/todos/pending?limit=-1",
+		"/api/todos/completed?limit=100000",
+	}
+	for _, path := range badRequests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s status = %d, want %d", path, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGetTodos_QueryParameters(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	router := setupRouter()
+
+	work := "work"
+	for _, todo := range []models.CreateTodoRequest{
+		{Item: "Buy milk"},
+		{Item: "Buy oat milk", ListId: &work},
+		{Item: "Write report", ListId: &work},
+	} {
+		body, _ := json.Marshal(todo)
+		req := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/api/todos/3/toggle", nil))
+
+	tests := []struct {
+		query   string
+		wantIDs []int
+	}{
+		{"", []int{1, 2, 3}},
+		{"?done=false", []int{1, 2}},
+		{"?done=true&list=work", []int{3}},
+		{"?list=main", []int{1}},
+		{"?q=MILK", []int{1, 2}},
+		{"?q=milk&list=work", []int{2}},
+		{"?sort=-created_at", []int{3, 2, 1}},
+		{"?sort=done,-id", []int{2, 1, 3}},
+		{"?q=milk&sort=-id&limit=1", []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/todos"+tt.query, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Data []models.Todo `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			var ids []int
+			for _, todo := range resp.Data {
+				ids = append(ids, todo.Id)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("IDs = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+
+	for _, query := range []string{"?done=maybe", "?sort=priority", "?sort=-", "?parent=first"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/todos"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/todos%s status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestCreateAITasks_KeepsMetadata(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	router := setupRouter()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/todos/ai/create", `{"tasks": [
+		{"text": "Install Go", "priority": "High", "estimated_time": "15 minutes", "category": "setup"},
+		{"text": "Read the tour", "priority": "urgent", "estimated_time": "1 hour", "category": "Learning"}
+	]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/todos/ai/create status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var created struct {
+		Data []models.Todo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(created.Data) != 2 {
+		t.Fatalf("created %d todos, want 2", len(created.Data))
+	}
+	if got := created.Data[0]; got.Priority != "high" || got.EstimatedTime != "15 minutes" || got.Category != "setup" {
+		t.Errorf("first todo = %+v, want high/15 minutes/setup", got)
+	}
+	if got := created.Data[1]; got.Priority != "" || got.Category != "Learning" {
+		t.Errorf("second todo = %+v, want unknown priority dropped and category kept", got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/todos?category=learning", nil))
+	var listed struct {
+		Data []models.Todo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(listed.Data) != 1 || listed.Data[0].Id != 2 {
+		t.Errorf("GET ?category=learning = %+v, want todo 2", listed.Data)
+	}
+
+	if w := do(http.MethodPut, "/api/todos/2", `{"priority": "low", "category": ""}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT /api/todos/2 status = %d, body = %s", w.Code, w.Body.String())
+	}
+	todo, err := database.GetTodo(2)
+	if err != nil {
+		t.Fatalf("GetTodo() error = %v", err)
+	}
+	if todo.Priority != "low" || todo.Category != "" || todo.EstimatedTime != "1 hour" {
+		t.Errorf("after PUT todo = %+v, want priority low, category cleared, estimate kept", todo)
+	}
+
+	if w := do(http.MethodPut, "/api/todos/2", `{"priority": ""}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT clearing the priority status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if todo, err := database.GetTodo(2); err != nil || todo.Priority != "" {
+		t.Errorf("after clearing the priority todo = %+v, %v; want no priority", todo, err)
+	}
+
+	if w := do(http.MethodPut, "/api/todos/2", `{"priority": "urgent"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT with unknown priority status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/todos?priority=urgent", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GET ?priority=urgent status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestLists(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	router := setupRouter()
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	getLists := func(path string) []models.List {
+		w := do(http.MethodGet, path, "")
+		var resp struct {
+			Data []models.List `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("GET %s: failed to parse response: %v", path, err)
+		}
+		return resp.Data
+	}
+
+	if w := do(http.MethodPost, "/api/lists", `{"name": "Groceries & Errands", "color": "#22c55e"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/lists status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/api/lists", `{"name": "Groceries errands"}`); w.Code != http.StatusConflict {
+		t.Errorf("POST duplicate list status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w := do(http.MethodPost, "/api/lists", `{"id": "main", "name": "Main"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("POST reserved list ID status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	// Adding a todo to an unknown list creates it, as clients did before lists existed
+	do(http.MethodPost, "/api/todos", `{"item": "Install Go", "list_id": "learn_go"}`)
+	do(http.MethodPost, "/api/todos", `{"item": "Buy milk", "list_id": "groceries_errands"}`)
+	do(http.MethodPost, "/api/todos", `{"item": "Buy eggs", "list_id": "groceries_errands"}`)
+	do(http.MethodPatch, "/api/todos/3/toggle", "")
+
+	lists := getLists("/api/lists")
+	if len(lists) != 2 {
+		t.Fatalf("GET /api/lists = %+v, want 2 lists", lists)
+	}
+	if got := lists[0]; got.Id != "groceries_errands" || got.Name != "Groceries & Errands" || got.PendingCount != 1 || got.DoneCount != 1 {
+		t.Errorf("first list = %+v, want groceries_errands with 1 pending and 1 done", got)
+	}
+	if got := lists[1]; got.Id != "learn_go" || got.Name != "Learn Go" || got.PendingCount != 1 {
+		t.Errorf("second list = %+v, want auto-created learn_go", got)
+	}
+
+	if w := do(http.MethodPut, "/api/lists/learn_go", `{"name": "Go", "archived": true}`); w.Code != http.StatusOK {
+		t.Fatalf("PUT /api/lists/learn_go status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if lists := getLists("/api/lists"); len(lists) != 1 {
+		t.Errorf("GET /api/lists after archive = %+v, want archived list hidden", lists)
+	}
+	if lists := getLists("/api/lists?archived=true"); len(lists) != 2 || lists[1].Name != "Go" {
+		t.Errorf("GET /api/lists?archived=true = %+v, want renamed archived list included", lists)
+	}
+
+	if w := do(http.MethodDelete, "/api/lists/learn_go", ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE /api/lists/learn_go status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodDelete, "/api/lists/groceries_errands?todos=delete", ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE ?todos=delete status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/api/lists/learn_go", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET deleted list status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := do(http.MethodDelete, "/api/lists/learn_go?todos=shred", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("DELETE ?todos=shred status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	todos, err := database.QueryTodos(database.TodoQuery{})
+	if err != nil {
+		t.Fatalf("QueryTodos() error = %v", err)
+	}
+	if len(todos) != 1 || todos[0].Item != "Install Go" || todos[0].ListId != nil {
+		t.Errorf("todos = %+v, want only Install Go, moved to the main list", todos)
+	}
+}
+
+func TestGetTodo_IncludeChildren(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	router := setupRouter()
+
+	for _, body := range []string{
+		`{"item": "Plan trip"}`,
+		`{"item": "Book flights", "parent_id": 1}`,
+		`{"item": "Book hotel", "parent_id": 1}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("POST %s status = %d, body = %s", body, w.Code, w.Body.String())
+		}
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/api/todos/2/toggle", nil))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/todos/1?include=children", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/todos/1?include=children status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data models.Todo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(resp.Data.Children) != 2 || resp.Data.Subtasks == nil || *resp.Data.Subtasks != (models.SubtaskProgress{Done: 1, Total: 2}) {
+		t.Errorf("GET ?include=children = %+v, want 2 children and 1/2 done", resp.Data)
+	}
+
+	for path, want := range map[string]int{
+		"/api/todos/1?include=parents": http.StatusBadRequest,
+		"/api/todos/2":                 http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("GET %s status = %d, want %d", path, w.Code, want)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/todos", strings.NewReader(`{"item": "Orphan", "parent_id": 99}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST with missing parent status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestTodoVersions(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	router := setupRouter()
+	if w := request(router, http.MethodPost, "/api/todos", "", `{"item": "Buy milk"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/todos status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	// Two clients read version 1; the second one's changes are rejected
+	steps := []struct {
+		method, path, ifMatch, body string
+		wantStatus                  int
+		wantETag                    string
+	}{
+		{http.MethodGet, "/api/todos/1", "", "", http.StatusOK, `"1"`},
+		{http.MethodPatch, "/api/todos/1/toggle", `"1"`, "", http.StatusOK, `"2"`},
+		{http.MethodPatch, "/api/todos/1/toggle", `"1"`, "", http.StatusPreconditionFailed, ""},
+		{http.MethodPut, "/api/todos/1", `"1"`, `{"item": "Buy oat milk"}`, http.StatusPreconditionFailed, ""},
+		{http.MethodDelete, "/api/todos/1", `"1"`, "", http.StatusPreconditionFailed, ""},
+		{http.MethodPut, "/api/todos/1", `W/"2"`, `{"item": "Buy oat milk"}`, http.StatusPreconditionFailed, ""},
+		{http.MethodPut, "/api/todos/1", `"2"`, `{"item": "Buy oat milk"}`, http.StatusOK, `"3"`},
+		{http.MethodPut, "/api/todos/1", "*", `{"item": "Buy soy milk"}`, http.StatusOK, `"4"`},
+		{http.MethodPatch, "/api/todos/1/toggle", "", "", http.StatusOK, `"5"`}, // Unconditional changes always apply
+		{http.MethodGet, "/api/todos/1", "", "", http.StatusOK, `"5"`},
+		{http.MethodPatch, "/api/todos/2/toggle", `"1"`, "", http.StatusNotFound, ""},
+		{http.MethodDelete, "/api/todos/1", `"5"`, "", http.StatusOK, ""},
+		{http.MethodGet, "/api/todos/1", "", "", http.StatusNotFound, ""},
+		{http.MethodPost, "/api/todos/1/restore", `"5"`, "", http.StatusPreconditionFailed, ""}, // Deleting changed it too
+		{http.MethodPost, "/api/todos/1/restore", `"6"`, "", http.StatusOK, `"7"`},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		req.Header.Set("Content-Type", "application/json")
+		if step.ifMatch != "" {
+			req.Header.Set("If-Match", step.ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != step.wantStatus {
+			t.Fatalf("%s %s If-Match %s status = %d, want %d, body = %s", step.method, step.path, step.ifMatch, w.Code, step.wantStatus, w.Body.String())
+		}
+		if etag := w.Header().Get("ETag"); etag != step.wantETag {
+			t.Errorf("%s %s ETag = %q, want %q", step.method, step.path, etag, step.wantETag)
+		}
+	}
+}
+
+func TestTrash(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	router := setupRouter()
+	trash := func() []string {
+		t.Helper()
+		w := request(router, http.MethodGet, "/api/trash", "", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/trash status = %d, body = %s", w.Code, w.Body.String())
+		}
+		var resp struct {
+			Data []models.Todo `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("GET /api/trash: failed to parse response: %v", err)
+		}
+		var items []string
+		for _, todo := range resp.Data {
+			if todo.DeletedAt == nil {
+				t.Errorf("GET /api/trash returned %q without deleted_at", todo.Item)
+			}
+			items = append(items, todo.Item)
+		}
+		return items
+	}
+
+	request(router, http.MethodPost, "/api/todos", "", `{"item": "Buy milk"}`)
+	request(router, http.MethodPost, "/api/todos", "", `{"item": "Call Sam", "list_id": "errands"}`)
+	if w := request(router, http.MethodDelete, "/api/todos/1", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE /api/todos/1 status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := request(router, http.MethodDelete, "/api/lists/errands?todos=delete", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE /api/lists/errands status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if items := trash(); !slices.Equal(items, []string{"Call Sam", "Buy milk"}) {
+		t.Errorf("GET /api/trash = %v, want both deleted todos, latest first", items)
+	}
+
+	if w := request(router, http.MethodPost, "/api/todos/2/restore", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("POST /api/todos/2/restore status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := request(router, http.MethodPost, "/api/todos/2/restore", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("POST restore outside the trash status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if items := trash(); !slices.Equal(items, []string{"Buy milk"}) {
+		t.Errorf("GET /api/trash after restore = %v, want [Buy milk]", items)
+	}
+
+	// The list is gone, so the restored todo is back in the main list
+	todo, err := database.GetTodo(2)
+	if err != nil {
+		t.Fatalf("GetTodo() error = %v", err)
+	}
+	if todo.DeletedAt != nil || todo.ListId != nil {
+		t.Errorf("restored todo = %+v, want it in the main list", todo)
+	}
+}
+
+func TestBatchTodos(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	router := setupRouter()
+	for _, item := range []string{"A", "B", "C"} {
+		if w := request(router, http.MethodPost, "/api/todos", "", `{"item": "`+item+`"}`); w.Code != http.StatusCreated {
+			t.Fatalf("POST /api/todos status = %d, body = %s", w.Code, w.Body.String())
+		}
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantStatuses []int
+	}{
+		{
+			"each operation on its own",
+			`{"operations": [{"op": "toggle", "id": 1}, {"op": "update", "id": 2, "changes": {"item": "B2"}},
+				{"op": "delete", "id": 99}, {"op": "create", "todo": {"item": "D"}}]}`,
+			http.StatusOK, []int{200, 200, 404, 201},
+		},
+		{
+			"atomic batch stops at a failing check",
+			`{"atomic": true, "operations": [{"op": "toggle", "id": 1}, {"op": "delete", "id": 99}]}`,
+			http.StatusNotFound, []int{424, 404},
+		},
+		{
+			// Each parent change is valid alone but together they form a cycle,
+			// so the second fails while applying and the first two are undone
+			"atomic batch undoes applied operations",
+			`{"atomic": true, "operations": [{"op": "create", "todo": {"item": "E"}},
+				{"op": "update", "id": 1, "changes": {"parent_id": 2}}, {"op": "update", "id": 2, "changes": {"parent_id": 1}}]}`,
+			http.StatusBadRequest, []int{424, 424, 400},
+		},
+		{
+			"atomic batch with a stale version",
+			`{"atomic": true, "operations": [{"op": "update", "id": 3, "changes": {"item": "C2"}}, {"op": "delete", "id": 4, "version": 2}]}`,
+			http.StatusPreconditionFailed, []int{424, 412},
+		},
+		{
+			"atomic batch applies everything",
+			`{"atomic": true, "operations": [{"op": "delete", "id": 4, "version": 1}, {"op": "toggle", "id": 3}, {"op": "create", "todo": {"item": "F"}}]}`,
+			http.StatusOK, []int{200, 200, 201},
+		},
+		{
+			"atomic batch undoes a restore",
+			`{"atomic": true, "operations": [{"op": "restore", "id": 4},
+				{"op": "update", "id": 1, "changes": {"parent_id": 2}}, {"op": "update", "id": 2, "changes": {"parent_id": 1}}]}`,
+			http.StatusBadRequest, []int{424, 424, 400},
+		},
+		{
+			"atomic batch restores",
+			`{"atomic": true, "operations": [{"op": "restore", "id": 4}]}`,
+			http.StatusOK, []int{200},
+		},
+		{
+			"todo named twice in an atomic batch",
+			`{"atomic": true, "operations": [{"op": "toggle", "id": 2}, {"op": "delete", "id": 2}]}`,
+			http.StatusBadRequest, nil,
+		},
+		{
+			"update without changes",
+			`{"operations": [{"op": "update", "id": 2}]}`,
+			http.StatusBadRequest, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(router, http.MethodPost, "/api/todos/batch", "", tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("POST /api/todos/batch status = %d, want %d, body = %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var resp struct {
+				Data []models.BatchResult `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			var statuses []int
+			for _, result := range resp.Data {
+				statuses = append(statuses, result.Status)
+			}
+			if !slices.Equal(statuses, tt.wantStatuses) {
+				t.Errorf("result statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+		})
+	}
+
+	// Only the successful operations left a trace: A done, B renamed, C done,
+	// D deleted and restored, F created
+	todos, err := database.QueryTodos(database.TodoQuery{})
+	if err != nil {
+		t.Fatalf("QueryTodos() error = %v", err)
+	}
+	var got []string
+	for _, todo := range todos {
+		got = append(got, fmt.Sprintf("%s %v %v", todo.Item, todo.Done, todo.ParentId != nil))
+	}
+	want := []string{"A true false", "B2 false false", "C true false", "D false false", "F false false"}
+	if !slices.Equal(got, want) {
+		t.Errorf("todos after batches = %q, want %q", got, want)
+	}
+
+	// Undone creates are gone for good rather than in the trash
+	w := request(router, http.MethodGet, "/api/trash", "", "")
+	var trash struct {
+		Data []models.Todo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &trash); err != nil {
+		t.Fatalf("GET /api/trash: failed to parse response: %v", err)
+	}
+	if w.Code != http.StatusOK || len(trash.Data) != 0 {
+		t.Errorf("GET /api/trash status = %d, todos = %+v, want none", w.Code, trash.Data)
+	}
+
+	// Undone operations are not in the activity log
+	for id, wantActions := range map[int][]string{
+		4: {models.EventCreated, models.EventDeleted, models.EventRestored},
+		5: nil, // E, created by the batch that was undone
+	} {
+		activity, err := database.QueryActivity(database.ActivityQuery{TodoId: id})
+		if err != nil {
+			t.Fatalf("QueryActivity() error = %v", err)
+		}
+		var actions []string
+		for _, entry := range activity {
+			actions = append(actions, entry.Action)
+		}
+		if !slices.Equal(actions, wantActions) {
+			t.Errorf("activity of todo %d = %v, want %v", id, actions, wantActions)
+		}
+	}
+}
+
+// activityFailingStore is a store that cannot write to the activity log
+type activityFailingStore struct {
+	database.TodoStore
+}
+
+func (activityFailingStore) InsertActivity(models.Activity) (*models.Activity, error) {
+	return nil, errors.New("disk full")
+}
+
+func TestActivityFailure(t *testing.T) {
+	useStore(t, activityFailingStore{database.NewMemoryStore()})
+	router := setupRouter()
+
+	// The batch applied, so it must not be reported as failed and retried
+	w := request(router, http.MethodPost, "/api/todos/batch", "", `{"atomic": true, "operations": [{"op": "create", "todo": {"item": "A"}}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /api/todos/batch status = %d, want 200, body = %s", w.Code, w.Body.String())
+	}
+	if todos, err := database.QueryTodos(database.TodoQuery{}); err != nil || len(todos) != 1 {
+		t.Errorf("todos after the batch = %+v, %v; want the created todo", todos, err)
+	}
+
+	// Nor is an import that was saved
+	w = request(router, http.MethodPost, "/api/import?format=markdown", "", "- [ ] B\n")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/import status = %d, want 201, body = %s", w.Code, w.Body.String())
+	}
+}
+
+// useAuth enables authentication for the duration of a test
+func useAuth(t *testing.T) {
+	auth.Configure("test-secret", "")
+	t.Cleanup(func() { auth.Configure("", "") })
+}
+
+// request sends a JSON request to the router, authenticated with token unless it is empty
+func request(router http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// signUp registers an account and returns its token and user ID
+func signUp(t *testing.T, router http.Handler, email string) (string, string) {
+	t.Helper()
+	w := request(router, http.MethodPost, "/api/auth/register", "", `{"email": "`+email+`", "password": "correct horse"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/auth/register status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data models.AuthResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	return resp.Data.Token, resp.Data.User.Id
+}
+
+func TestAuth_SecretRequiresToken(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	t.Setenv("LISTY_JWT_SECRET", "test-secret")
+	if err := auth.Init(); err != nil {
+		t.Fatalf("auth.Init() error = %v", err)
+	}
+	t.Cleanup(func() { auth.Configure("", "") })
+
+	w := request(setupRouter(), http.MethodGet, "/api/todos", "", "")
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), `"code":"unauthorized"`) {
+		t.Errorf("GET /api/todos without a token status = %d, body = %s, want 401", w.Code, w.Body.String())
+	}
+}
+
+func TestAuth(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	useAuth(t)
+	router := setupRouter()
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		return request(router, method, path, token, body)
+	}
+	ada, _ := signUp(t, router, "ada@example.com")
+	grace, _ := signUp(t, router, "grace@example.com")
+
+	if w := do(http.MethodPost, "/api/todos", ada, `{"item": "Ada's todo", "list_id": "work"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/todos status = %d, body = %s", w.Code, w.Body.String())
+	}
+	do(http.MethodPost, "/api/todos", grace, `{"item": "Grace's todo"}`)
+
+	w := do(http.MethodGet, "/api/todos", grace, "")
+	var resp struct {
+		Data []models.Todo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(resp.Data) != 1 || resp.Data[0].Item != "Grace's todo" {
+		t.Errorf("GET /api/todos as grace = %+v, want only her own todo", resp.Data)
+	}
+
+	noUser, _, _ := auth.IssueToken("", "")
+	tests := []struct {
+		name         string
+		method, path string
+		token, body  string
+		want         int
+	}{
+		{"no token", http.MethodGet, "/api/todos", "", "", http.StatusUnauthorized},
+		{"garbage token", http.MethodGet, "/api/lists", "not-a-jwt", "", http.StatusUnauthorized},
+		{"token without a user", http.MethodGet, "/api/todos", noUser, "", http.StatusForbidden},
+		{"AI routes need a token", http.MethodPost, "/api/todos/ai/breakdown", "", `{"goal": "x"}`, http.StatusUnauthorized},
+		{"health needs no token", http.MethodGet, "/api/health", "", "", http.StatusOK},
+		{"own todo", http.MethodGet, "/api/todos/1", ada, "", http.StatusOK},
+		{"someone else's todo", http.MethodGet, "/api/todos/1", grace, "", http.StatusForbidden},
+		{"updating someone else's todo", http.MethodPut, "/api/todos/1", grace, `{"done": true}`, http.StatusForbidden},
+		{"deleting someone else's todo", http.MethodDelete, "/api/todos/1", grace, "", http.StatusForbidden},
+		{"subtask of someone else's todo", http.MethodPost, "/api/todos", grace, `{"item": "x", "parent_id": 1}`, http.StatusForbidden},
+		{"someone else's list", http.MethodGet, "/api/lists/work", grace, "", http.StatusForbidden},
+		{"adding to someone else's list", http.MethodPost, "/api/todos", grace, `{"item": "x", "list_id": "work"}`, http.StatusForbidden},
+		{"wrong password", http.MethodPost, "/api/auth/login", "", `{"email": "ada@example.com", "password": "wrong horse"}`, http.StatusUnauthorized},
+		{"login", http.MethodPost, "/api/auth/login", "", `{"email": "ADA@example.com", "password": "correct horse"}`, http.StatusOK},
+		{"register twice", http.MethodPost, "/api/auth/register", "", `{"email": "ada@example.com", "password": "correct horse"}`, http.StatusConflict},
+		{"short password", http.MethodPost, "/api/auth/register", "", `{"email": "bob@example.com", "password": "short"}`, http.StatusBadRequest},
+		{"me", http.MethodGet, "/api/auth/me", ada, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.path, tt.token, tt.body)
+			if w.Code != tt.want {
+				t.Errorf("%s %s status = %d, want %d (body %s)", tt.method, tt.path, w.Code, tt.want, w.Body.String())
+			}
+			if w.Code == http.StatusUnauthorized && tt.token == "" && w.Header().Get("WWW-Authenticate") == "" && tt.path != "/api/auth/login" {
+				t.Error("401 response is missing WWW-Authenticate")
+			}
+		})
+	}
+}
+
+func TestSharedLists(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	useAuth(t)
+	router := setupRouter()
+
+	ada, adaId := signUp(t, router, "ada@example.com")
+	grace, graceId := signUp(t, router, "grace@example.com")
+	linus, linusId := signUp(t, router, "linus@example.com")
+	if w := request(router, http.MethodPost, "/api/todos", ada, `{"item": "Plan sprint", "list_id": "work"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/todos status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	// Steps run in order; each depends on the memberships set up before it
+	steps := []struct {
+		name         string
+		method, path string
+		token, body  string
+		want         int
+		wantBody     string // Substring the response must contain
+	}{
+		{"not shared yet", http.MethodGet, "/api/lists/work", grace, "", http.StatusForbidden, ""},
+		{"only the owner invites", http.MethodPost, "/api/lists/work/members", grace, `{"email": "grace@example.com", "role": "editor"}`, http.StatusForbidden, ""},
+		{"invite by email", http.MethodPost, "/api/lists/work/members", ada, `{"email": "grace@example.com", "role": "viewer"}`, http.StatusCreated, ""},
+		{"invite by user ID", http.MethodPost, "/api/lists/work/members", ada, `{"user_id": "` + linusId + `", "role": "editor"}`, http.StatusCreated, ""},
+		{"invite twice", http.MethodPost, "/api/lists/work/members", ada, `{"email": "grace@example.com", "role": "editor"}`, http.StatusConflict, ""},
+		{"invite unknown email", http.MethodPost, "/api/lists/work/members", ada, `{"email": "bob@example.com", "role": "viewer"}`, http.StatusNotFound, ""},
+		{"invite the owner", http.MethodPost, "/api/lists/work/members", ada, `{"email": "ada@example.com", "role": "viewer"}`, http.StatusBadRequest, ""},
+		{"invite with a bad role", http.MethodPost, "/api/lists/work/members", ada, `{"email": "grace@example.com", "role": "owner"}`, http.StatusBadRequest, ""},
+		{"viewer reads the list", http.MethodGet, "/api/lists/work", grace, "", http.StatusOK, ""},
+		{"viewer reads a todo", http.MethodGet, "/api/todos/1", grace, "", http.StatusOK, ""},
+		{"viewer lists members", http.MethodGet, "/api/lists/work/members", grace, "", http.StatusOK, `"email":"grace@example.com","role":"viewer"`},
+		{"shared list in viewer's lists", http.MethodGet, "/api/lists", grace, "", http.StatusOK, `"id":"work","name":"Work"`},
+		{"shared list carries the role", http.MethodGet, "/api/lists/work", grace, "", http.StatusOK, `"role":"viewer"`},
+		{"shared todos in viewer's todos", http.MethodGet, "/api/todos", grace, "", http.StatusOK, `"item":"Plan sprint"`},
+		{"viewer cannot toggle", http.MethodPatch, "/api/todos/1/toggle", grace, "", http.StatusForbidden, ""},
+		{"viewer cannot add", http.MethodPost, "/api/todos", grace, `{"item": "x", "list_id": "work"}`, http.StatusForbidden, ""},
+		{"editor toggles", http.MethodPatch, "/api/todos/1/toggle", linus, "", http.StatusOK, ""},
+		{"editor adds", http.MethodPost, "/api/todos", linus, `{"item": "Review PRs", "list_id": "work"}`, http.StatusCreated, `"owner_id":"` + adaId + `"`},
+		{"editor updates", http.MethodPut, "/api/todos/2", linus, `{"item": "Review all PRs"}`, http.StatusOK, ""},
+		{"editor cannot rename the list", http.MethodPut, "/api/lists/work", linus, `{"name": "Mine"}`, http.StatusForbidden, ""},
+		{"editor cannot change roles", http.MethodPut, "/api/lists/work/members/" + graceId, linus, `{"role": "editor"}`, http.StatusForbidden, ""},
+		{"owner promotes", http.MethodPut, "/api/lists/work/members/" + graceId, ada, `{"role": "editor"}`, http.StatusOK, ""},
+		{"promoted member deletes", http.MethodDelete, "/api/todos/2", grace, "", http.StatusOK, ""},
+		{"owner removes", http.MethodDelete, "/api/lists/work/members/" + graceId, ada, "", http.StatusOK, ""},
+		{"removed member loses access", http.MethodGet, "/api/todos/1", grace, "", http.StatusForbidden, ""},
+		{"removed member's todos", http.MethodGet, "/api/todos", grace, "", http.StatusOK, `"data":[]`},
+		{"member leaves", http.MethodDelete, "/api/lists/work/members/" + linusId, linus, "", http.StatusOK, ""},
+		{"leaving twice", http.MethodDelete, "/api/lists/work/members/" + linusId, ada, "", http.StatusNotFound, ""},
+	}
+	for _, step := range steps {
+		w := request(router, step.method, step.path, step.token, step.body)
+		if w.Code != step.want {
+			t.Fatalf("%s: %s %s status = %d, want %d (body %s)", step.name, step.method, step.path, w.Code, step.want, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), step.wantBody) {
+			t.Errorf("%s: %s %s body = %s, want it to contain %s", step.name, step.method, step.path, w.Body.String(), step.wantBody)
+		}
+	}
+}
+
+func TestActivity(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	useAuth(t)
+	router := setupRouter()
+
+	ada, adaId := signUp(t, router, "ada@example.com")
+	grace, graceId := signUp(t, router, "grace@example.com")
+	linus, _ := signUp(t, router, "linus@example.com")
+	setup := []struct{ method, path, token, body string }{
+		{http.MethodPost, "/api/todos", ada, `{"item": "Plan sprint", "list_id": "work"}`},
+		{http.MethodPost, "/api/lists/work/members", ada, `{"email": "grace@example.com", "role": "editor"}`},
+		{http.MethodPatch, "/api/todos/1/toggle", grace, ""},
+	}
+	for _, step := range setup {
+		if w := request(router, step.method, step.path, step.token, step.body); w.Code >= http.StatusBadRequest {
+			t.Fatalf("%s %s status = %d, body = %s", step.method, step.path, w.Code, w.Body.String())
+		}
+	}
+
+	w := request(router, http.MethodGet, "/api/todos/1/history", ada, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/todos/1/history status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var history struct{ Data []models.Activity }
+	if err := json.Unmarshal(w.Body.Bytes(), &history); err != nil {
+		t.Fatalf("history response: %v", err)
+	}
+	if len(history.Data) != 2 || history.Data[0].ActorId != adaId || history.Data[1].ActorId != graceId {
+		t.Fatalf("history = %+v, want ada's create then grace's toggle", history.Data)
+	}
+	if change := history.Data[1].Changes["done"]; change.Before != false || change.After != true {
+		t.Errorf("toggle changes = %+v, want done false -> true", history.Data[1].Changes)
+	}
+
+	since := url.QueryEscape(history.Data[0].At.Format(time.RFC3339Nano))
+	steps := []struct {
+		name        string
+		path, token string
+		want        int
+		wantBody    string
+	}{
+		{"member reads history", "/api/todos/1/history", grace, http.StatusOK, `"action":"toggled"`},
+		{"stranger cannot read history", "/api/todos/1/history", linus, http.StatusForbidden, ""},
+		{"missing todo", "/api/todos/9/history", ada, http.StatusNotFound, ""},
+		{"activity feed", "/api/activity", grace, http.StatusOK, `"action":"created"`},
+		{"feed since", "/api/activity?since=" + since, ada, http.StatusOK, `"data":[{"id":2,`},
+		{"stranger's feed", "/api/activity", linus, http.StatusOK, `"data":[]`},
+		{"bad since", "/api/activity?since=yesterday", ada, http.StatusBadRequest, ""},
+		{"limit too large", "/api/activity?limit=1000", ada, http.StatusBadRequest, ""},
+	}
+	for _, step := range steps {
+		w := request(router, http.MethodGet, step.path, step.token, "")
+		if w.Code != step.want {
+			t.Fatalf("%s: GET %s status = %d, want %d (body %s)", step.name, step.path, w.Code, step.want, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), step.wantBody) {
+			t.Errorf("%s: GET %s body = %s, want it to contain %s", step.name, step.path, w.Body.String(), step.wantBody)
+		}
+	}
+}
+
+// openStream connects to GET /api/todos/stream; the stream closes when the test ends
+func openStream(t *testing.T, server *httptest.Server, query, lastEventId string) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/todos/stream"+query, nil)
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/todos/stream error = %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("GET /api/todos/stream status = %d, content type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+// readEvent reads the next event from a stream, skipping comments
+func readEvent(t *testing.T, stream *bufio.Reader) models.TodoEvent {
+	t.Helper()
+	var event models.TodoEvent
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				t.Fatalf("failed to parse event %q: %v", data, err)
+			}
+		}
+		if line == "" && event.Type != "" {
+			return event
+		}
+	}
+}
+
+func TestStreamTodos(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	server := httptest.NewServer(setupRouter())
+	t.Cleanup(server.Close) // Runs after the streams opened below are closed
+	post := func(method, path, body string) {
+		if w := request(server.Config.Handler, method, path, "", body); w.Code >= 300 {
+			t.Fatalf("%s %s status = %d, body = %s", method, path, w.Code, w.Body.String())
+		}
+	}
+	want := func(stream *bufio.Reader, eventType, item string) models.TodoEvent {
+		t.Helper()
+		event := readEvent(t, stream)
+		if event.Type != eventType || event.Todo == nil || event.Todo.Item != item {
+			t.Fatalf("event = %s %+v, want %s of %q", event.Type, event.Todo, eventType, item)
+		}
+		return event
+	}
+
+	post(http.MethodPost, "/api/lists", `{"id": "work", "name": "Work"}`)
+	all := openStream(t, server, "", "")
+	work := openStream(t, server, "?list=work", "")
+
+	post(http.MethodPost, "/api/todos", `{"item": "Buy milk"}`)
+	post(http.MethodPost, "/api/todos", `{"item": "Write report", "list_id": "work"}`)
+	want(all, models.EventCreated, "Buy milk")
+	created := want(all, models.EventCreated, "Write report")
+	want(work, models.EventCreated, "Write report") // Main list todos are filtered out
+
+	post(http.MethodPatch, "/api/todos/2/toggle", "")
+	if event := want(work, models.EventToggled, "Write report"); !event.Todo.Done {
+		t.Errorf("toggled event todo = %+v, want done", event.Todo)
+	}
+	post(http.MethodDelete, "/api/todos/1", "")
+
+	// Reconnecting replays what was missed since the last event seen
+	resumed := openStream(t, server, "", created.Id)
+	want(resumed, models.EventToggled, "Write report")
+	want(resumed, models.EventDeleted, "Buy milk")
+
+	if event := readEvent(t, openStream(t, server, "", "unknown-1")); event.Type != models.EventReset {
+		t.Errorf("event after an unknown Last-Event-ID = %+v, want reset", event)
+	}
+	if w := request(server.Config.Handler, http.MethodGet, "/api/todos/stream?list=missing", "", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET /api/todos/stream?list=missing status = %d, want 404", w.Code)
+	}
+}
+
+func TestTags(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	router := setupRouter()
+	for _, body := range []string{
+		`{"item": "Buy milk", "tags": ["#Errands", "urgent"]}`,
+		`{"item": "Fix sink", "tags": ["home", "urgent"]}`,
+		`{"item": "Read book"}`,
+	} {
+		if w := request(router, http.MethodPost, "/api/todos", "", body); w.Code != http.StatusCreated {
+			t.Fatalf("POST /api/todos status = %d, body = %s", w.Code, w.Body.String())
+		}
+	}
+
+	steps := []struct {
+		method, path, body string
+		want               int
+		wantTags           []string
+	}{
+		{http.MethodPost, "/api/todos/3/tags", `{"tags": ["#Home", "books", "home"]}`, http.StatusOK, []string{"books", "home"}},
+		{http.MethodPost, "/api/todos/3/tags", `{"tags": ["two words"]}`, http.StatusBadRequest, nil},
+		{http.MethodPost, "/api/todos/3/tags", `{"tags": []}`, http.StatusBadRequest, nil},
+		{http.MethodDelete, "/api/todos/3/tags", `{"tags": ["books", "unused"]}`, http.StatusOK, []string{"home"}},
+		{http.MethodPut, "/api/todos/2", `{"tags": ["Home"]}`, http.StatusOK, []string{"home"}},
+		{http.MethodDelete, "/api/todos/9/tags", `{"tags": ["home"]}`, http.StatusNotFound, nil},
+	}
+	for _, step := range steps {
+		w := request(router, step.method, step.path, "", step.body)
+		if w.Code != step.want {
+			t.Fatalf("%s %s status = %d, want %d, body = %s", step.method, step.path, w.Code, step.want, w.Body.String())
+		}
+		if step.want != http.StatusOK {
+			continue
+		}
+		var resp struct{ Data models.Todo }
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s %s response: %v", step.method, step.path, err)
+		}
+		if !slices.Equal(resp.Data.Tags, step.wantTags) {
+			t.Errorf("%s %s tags = %v, want %v", step.method, step.path, resp.Data.Tags, step.wantTags)
+		}
+	}
+
+	filters := []struct {
+		query   string
+		wantIDs []int
+	}{
+		{"tag=home", []int{2, 3}},
+		{"tag=%23urgent&tag=errands", []int{1}},
+		{"tag=urgent&tag=home&tag_mode=any", []int{1, 2, 3}},
+		{"tag=garden", nil},
+	}
+	for _, filter := range filters {
+		w := request(router, http.MethodGet, "/api/todos?"+filter.query, "", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET /api/todos?%s status = %d, body = %s", filter.query, w.Code, w.Body.String())
+		}
+		var resp struct{ Data []models.Todo }
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("GET /api/todos?%s response: %v", filter.query, err)
+		}
+		var ids []int
+		for _, todo := range resp.Data {
+			ids = append(ids, todo.Id)
+		}
+		if !slices.Equal(ids, filter.wantIDs) {
+			t.Errorf("GET /api/todos?%s IDs = %v, want %v", filter.query, ids, filter.wantIDs)
+		}
+	}
+	for _, query := range []string{"tag=a%20b", "tag=home&tag_mode=some"} {
+		if w := request(router, http.MethodGet, "/api/todos?"+query, "", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /api/todos?%s status = %d, want 400", query, w.Code)
+		}
+	}
+
+	if w := request(router, http.MethodPatch, "/api/todos/1/toggle", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("PATCH /api/todos/1/toggle status = %d, body = %s", w.Code, w.Body.String())
+	}
+	w := request(router, http.MethodGet, "/api/tags", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /api/tags status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var tags struct{ Data []models.Tag }
+	if err := json.Unmarshal(w.Body.Bytes(), &tags); err != nil {
+		t.Fatalf("tags response: %v", err)
+	}
+	want := []models.Tag{
+		{Name: "errands", DoneCount: 1},
+		{Name: "home", PendingCount: 2},
+		{Name: "urgent", DoneCount: 1},
+	}
+	if !slices.Equal(tags.Data, want) {
+		t.Errorf("GET /api/tags = %+v, want %+v", tags.Data, want)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	useAuth(t)
+	router := setupRouter()
+
+	ada, _ := signUp(t, router, "ada@example.com")
+	grace, _ := signUp(t, router, "grace@example.com")
+	for _, step := range []struct{ token, body string }{
+		{ada, `{"item": "Pay rent, then relax", "due_at": "2026-03-01T09:00:00Z", "remind_at": "2026-02-28T18:00:00Z", "priority": "high", "tags": ["home"]}`},
+		{ada, `{"item": "Plan sprint", "list_id": "work", "due_at": "2026-03-02T10:00:00+01:00"}`},
+		{ada, `{"item": "Someday"}`},
+		{ada, `{"item": "Filed taxes", "due_at": "2026-02-15T12:00:00Z"}`},
+		{grace, `{"item": "Grace's dentist", "due_at": "2026-03-03T08:00:00Z"}`},
+	} {
+		if w := request(router, http.MethodPost, "/api/todos", step.token, step.body); w.Code != http.StatusCreated {
+			t.Fatalf("POST /api/todos status = %d, body = %s", w.Code, w.Body.String())
+		}
+	}
+	if w := request(router, http.MethodPatch, "/api/todos/4/toggle", ada, ""); w.Code != http.StatusOK {
+		t.Fatalf("PATCH /api/todos/4/toggle status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	w := request(router, http.MethodPost, "/api/calendar/token", ada, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/calendar/token status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var subscription struct{ Data models.CalendarSubscription }
+	if err := json.Unmarshal(w.Body.Bytes(), &subscription); err != nil {
+		t.Fatalf("token response: %v", err)
+	}
+	feed, err := url.Parse(subscription.Data.URL)
+	if err != nil || feed.Path != "/api/todos/calendar.ics" || feed.Query().Get("token") != subscription.Data.Token {
+		t.Fatalf("subscription URL = %q, want the feed with the token", subscription.Data.URL)
+	}
+
+	// Calendar apps send no Authorization header, only the token
+	w = request(router, http.MethodGet, feed.RequestURI(), "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s status = %d, body = %s", feed.Path, w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/calendar") {
+		t.Errorf("Content-Type = %q, want text/calendar", got)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"BEGIN:VEVENT\r\nUID:todo-1@listy\r\n",
+		`SUMMARY:Pay rent\, then relax` + "\r\n",
+		"DTSTART:20260301T090000Z\r\n",
+		"PRIORITY:1\r\nCATEGORIES:home\r\n",
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\n",
+		"TRIGGER;VALUE=DATE-TIME:20260228T180000Z\r\n",
+		"DTSTART:20260302T090000Z\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("feed lacks %q:\n%s", want, body)
+		}
+	}
+	for _, unwanted := range []string{"Someday", "Filed taxes", "dentist"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("feed contains %q, which is undated, done or someone else's:\n%s", unwanted, body)
+		}
+	}
+
+	// As VTODOs, completed todos are included with their status
+	w = request(router, http.MethodGet, "/api/todos/calendar.ics?as=todo", ada, "")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, "SUMMARY:Filed taxes\r\nDUE:20260215T120000Z\r\nSTATUS:COMPLETED\r\n") {
+		t.Errorf("GET ?as=todo status = %d, body = %s, want the completed VTODO", w.Code, body)
+	}
+
+	w = request(router, http.MethodGet, "/api/lists/work/calendar.ics?token="+url.QueryEscape(subscription.Data.Token), "", "")
+	if body := w.Body.String(); w.Code != http.StatusOK || !strings.Contains(body, "Plan sprint") || strings.Contains(body, "Pay rent") {
+		t.Errorf("GET list feed status = %d, body = %s, want only the work list", w.Code, body)
+	}
+
+	steps := []struct {
+		name, path, token string
+		want              int
+	}{
+		{"no token", "/api/todos/calendar.ics", "", http.StatusUnauthorized},
+		{"wrong token", "/api/todos/calendar.ics?token=nope", "", http.StatusUnauthorized},
+		{"someone else's list", "/api/lists/work/calendar.ics", grace, http.StatusForbidden},
+		{"unknown component", "/api/todos/calendar.ics?as=journal", ada, http.StatusBadRequest},
+	}
+	for _, step := range steps {
+		if w := request(router, http.MethodGet, step.path, step.token, ""); w.Code != step.want {
+			t.Errorf("%s: GET %s status = %d, want %d", step.name, step.path, w.Code, step.want)
+		}
+	}
+
+	// Revoking, or issuing a new token, stops the old URL working
+	if w := request(router, http.MethodDelete, "/api/calendar/token", ada, ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE /api/calendar/token status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := request(router, http.MethodGet, feed.RequestURI(), "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET with a revoked token status = %d, want 401", w.Code)
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	useAuth(t)
+	router := setupRouter()
+
+	ada, _ := signUp(t, router, "ada@example.com")
+	grace, _ := signUp(t, router, "grace@example.com")
+
+	importFile := func(token, query, body string) (int, models.ImportResult) {
+		t.Helper()
+		w := request(router, http.MethodPost, "/api/import"+query, token, body)
+		var resp struct{ Data models.ImportResult }
+		if w.Code < 300 {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("import response: %v", err)
+			}
+		}
+		return w.Code, resp.Data
+	}
+
+	// The legacy todos.json of the first CLI, with "Walk" twice
+	legacy, err := os.ReadFile("../todos.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, result := importFile(ada, "?dedupe=true", string(legacy))
+	if code != http.StatusCreated || result.Format != "json" || result.Created != 3 || result.Skipped != 1 {
+		t.Fatalf("legacy import = %d %+v, want 3 created and 1 skipped", code, result)
+	}
+	if done := result.Todos[2]; done.Item != "Buy Oil" || !done.Done {
+		t.Errorf("legacy todo 4 = %+v, want Buy Oil done", done)
+	}
+
+	// Importing it again with dedupe finds everything already there
+	if code, result := importFile(ada, "?dedupe=true", string(legacy)); code != http.StatusCreated || result.Created != 0 || result.Skipped != 4 {
+		t.Errorf("repeated legacy import = %d %+v, want all 4 skipped", code, result)
+	}
+
+	checklist := "## work\n\n- [ ] Release #ops due:2026-03-02\n  - [x] Changelog\n  - [ ] Tag\n"
+	code, result = importFile(ada, "?dry_run=true&tz=%2B01:00", checklist)
+	if code != http.StatusOK || !result.DryRun || result.Format != "markdown" || result.Created != 3 || result.Todos[0].Id != 0 {
+		t.Fatalf("dry run = %d %+v, want 3 todos without IDs", code, result)
+	}
+	if due := result.Todos[0].DueAt; due == nil || !due.Equal(time.Date(2026, time.March, 2, 22, 59, 0, 0, time.UTC)) {
+		t.Errorf("dry run due = %v, want 23:59 at +01:00", due)
+	}
+	if w := request(router, http.MethodGet, "/api/todos/list/work", ada, ""); strings.Contains(w.Body.String(), "Release") {
+		t.Errorf("dry run created todos: %s", w.Body.String())
+	}
+
+	code, result = importFile(ada, "?tz=%2B01:00", checklist)
+	if code != http.StatusCreated || result.Created != 3 {
+		t.Fatalf("markdown import = %d %+v, want 3 created", code, result)
+	}
+	release, changelog := result.Todos[0], result.Todos[1]
+	if release.ListId == nil || *release.ListId != "work" || len(release.Tags) != 1 || release.Tags[0] != "ops" {
+		t.Errorf("imported %+v, want Release in work tagged ops", release)
+	}
+	if changelog.ParentId == nil || *changelog.ParentId != release.Id || !changelog.Done {
+		t.Errorf("imported %+v, want a done subtask of %d", changelog, release.Id)
+	}
+
+	w := request(router, http.MethodGet, "/api/export?format=markdown&list=work&tz=%2B01:00", ada, "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/markdown") {
+		t.Fatalf("GET /api/export status = %d, Content-Type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w.Body.String() != checklist {
+		t.Errorf("markdown export = %q, want %q", w.Body.String(), checklist)
+	}
+
+	w = request(router, http.MethodGet, "/api/export?format=csv", ada, "")
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="listy.csv"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 7 || !strings.HasPrefix(lines[1], "1,Buy Ramen,false,") {
+		t.Errorf("csv export = %q, want a header and 6 todos", w.Body.String())
+	}
+
+	// Grace can copy Ada's CSV into her own account, but not into Ada's list
+	csv := w.Body.String()
+	if code, result := importFile(grace, "?list=main", csv); code != http.StatusCreated || result.Created != 6 || result.Todos[5].ParentId == nil || *result.Todos[5].ParentId != result.Todos[3].Id {
+		t.Errorf("grace's import = %d %+v, want 6 todos with the subtasks relinked", code, result)
+	}
+	if w := request(router, http.MethodGet, "/api/export?format=todotxt", grace, ""); !strings.Contains(w.Body.String(), "x Changelog\n") || strings.Contains(w.Body.String(), "+work") {
+		t.Errorf("grace's todo.txt export = %q", w.Body.String())
+	}
+
+	steps := []struct {
+		name, token, query, body string
+		want                     int
+	}{
+		{"bad json", ada, "", `[{"item": }]`, http.StatusBadRequest},
+		{"bad due date", ada, "?format=todotxt", "Call mom due:soon\n", http.StatusBadRequest},
+		{"bad priority", ada, "?format=csv", "item,priority\nmilk,urgent\n", http.StatusBadRequest},
+		{"bad tag", ada, "", `[{"item": "milk", "tags": ["a b"]}]`, http.StatusBadRequest},
+		{"unknown format", ada, "?format=xml", "<todo/>", http.StatusBadRequest},
+		{"someone else's list", grace, "?list=work", "milk\n", http.StatusForbidden},
+	}
+	for _, step := range steps {
+		if code, _ := importFile(step.token, step.query, step.body); code != step.want {
+			t.Errorf("%s: POST /api/import status = %d, want %d", step.name, code, step.want)
+		}
+	}
+	if w := request(router, http.MethodGet, "/api/export?format=xml", ada, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/export?format=xml status = %d, want 400", w.Code)
+	}
+}
+
+// failingStore is a store whose inserts start failing after the first few
+type failingStore struct {
+	database.TodoStore
+	inserts int
+}
+
+func (s *failingStore) InsertTodo(todo models.Todo) (*models.Todo, error) {
+	if s.inserts == 0 {
+		return nil, errors.New("disk full")
+	}
+	s.inserts--
+	return s.TodoStore.InsertTodo(todo)
+}
+
+func TestImport_FailurePartwayLeavesNothing(t *testing.T) {
+	useStore(t, &failingStore{TodoStore: database.NewMemoryStore(), inserts: 2})
+	router := setupRouter()
+
+	w := request(router, http.MethodPost, "/api/import?format=markdown", "", "## errands\n\n- [ ] Release\n  - [x] Changelog\n- [ ] Tag\n")
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("POST /api/import status = %d, want 500, body = %s", w.Code, w.Body.String())
+	}
+	for _, path := range []string{"/api/todos", "/api/trash", "/api/activity", "/api/lists"} {
+		w := request(router, http.MethodGet, path, "", "")
+		var resp struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("GET %s: failed to parse response: %v", path, err)
+		}
+		if w.Code != http.StatusOK || len(resp.Data) != 0 {
+			t.Errorf("GET %s after a failed import status = %d, body = %s, want nothing", path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSDK(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	useAuth(t)
+	server := httptest.NewServer(setupRouter())
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+	anonymous := sdk.New(server.URL, sdk.WithRetries(0, 0))
+
+	if err := anonymous.Health(ctx); err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+	creds := models.Credentials{Email: "ada@example.com", Password: "correct horse"}
+	if _, err := anonymous.Register(ctx, creds); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if _, err := anonymous.Register(ctx, creds); !errors.Is(err, sdk.ErrConflict) {
+		t.Errorf("Register() twice error = %v, want ErrConflict", err)
+	}
+	if _, err := anonymous.Todo(ctx, 1); !errors.Is(err, sdk.ErrUnauthorized) {
+		t.Errorf("Todo() without a token error = %v, want ErrUnauthorized", err)
+	}
+	session, err := anonymous.Login(ctx, creds)
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	ada := anonymous.WithToken(session.Token)
+	if me, err := ada.Me(ctx); err != nil || me.Email != creds.Email || !me.AuthEnabled {
+		t.Fatalf("Me() = %+v, %v; want Ada", me, err)
+	}
+	graceAuth, err := anonymous.Register(ctx, models.Credentials{Email: "grace@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	grace := anonymous.WithToken(graceAuth.Token)
+
+	// Lists and members
+	if _, err := ada.CreateList(ctx, models.CreateListRequest{Name: "Work"}); err != nil {
+		t.Fatalf("CreateList() error = %v", err)
+	}
+	if _, err := ada.CreateList(ctx, models.CreateListRequest{}); !errors.Is(err, sdk.ErrValidation) {
+		t.Errorf("CreateList() without a name error = %v, want ErrValidation", err)
+	}
+	archived := true
+	if list, err := ada.UpdateList(ctx, "work", models.UpdateListRequest{Archived: &archived}); err != nil || !list.Archived {
+		t.Errorf("UpdateList() = %+v, %v; want the list archived", list, err)
+	}
+	if lists, err := ada.Lists(ctx, models.ListFilter{Archived: true}); err != nil || len(lists) != 1 {
+		t.Errorf("Lists() = %+v, %v; want the archived list", lists, err)
+	}
+	if _, err := ada.AddMember(ctx, "work", models.AddMemberRequest{Email: "grace@example.com", Role: models.RoleViewer}); err != nil {
+		t.Fatalf("AddMember() error = %v", err)
+	}
+	if _, err := ada.UpdateMember(ctx, "work", graceAuth.User.Id, models.UpdateMemberRequest{Role: models.RoleEditor}); err != nil {
+		t.Errorf("UpdateMember() error = %v", err)
+	}
+	if members, err := grace.Members(ctx, "work"); err != nil || len(members) != 1 || members[0].Role != models.RoleEditor {
+		t.Errorf("Members() = %+v, %v; want Grace as an editor", members, err)
+	}
+	if _, err := grace.List(ctx, "work"); err != nil {
+		t.Errorf("List() as a member error = %v", err)
+	}
+
+	// Todos
+	work := "work"
+	todo, err := ada.CreateTodo(ctx, models.CreateTodoRequest{Item: "Plan sprint", ListId: &work, Tags: []string{"q3"}})
+	if err != nil {
+		t.Fatalf("CreateTodo() error = %v", err)
+	}
+	if _, err := grace.ToggleTodo(ctx, todo.Id, todo.Version); err != nil {
+		t.Errorf("ToggleTodo() as an editor error = %v", err)
+	}
+	if _, err := ada.ToggleTodo(ctx, todo.Id, todo.Version); !errors.Is(err, sdk.ErrConflict) {
+		t.Errorf("ToggleTodo() at a stale version error = %v, want ErrConflict", err)
+	}
+	if err := ada.RemoveMember(ctx, "work", graceAuth.User.Id); err != nil {
+		t.Errorf("RemoveMember() error = %v", err)
+	}
+	if _, err := grace.Todo(ctx, todo.Id); !errors.Is(err, sdk.ErrForbidden) {
+		t.Errorf("Todo() after leaving the list error = %v, want ErrForbidden", err)
+	}
+	item := "Plan the sprint"
+	todo, err = ada.UpdateTodo(ctx, todo.Id, 0, models.UpdateTodoRequest{Item: &item})
+	if err != nil || todo.Item != item || !todo.Done {
+		t.Fatalf("UpdateTodo() = %+v, %v; want the renamed done todo", todo, err)
+	}
+	if todo, err = ada.AddTags(ctx, todo.Id, todo.Version, []string{"planning"}); err != nil || len(todo.Tags) != 2 {
+		t.Errorf("AddTags() = %+v, %v; want two tags", todo, err)
+	}
+	if todo, err = ada.RemoveTags(ctx, todo.Id, 0, []string{"q3"}); err != nil || len(todo.Tags) != 1 {
+		t.Errorf("RemoveTags() = %+v, %v; want one tag", todo, err)
+	}
+	if tags, err := ada.Tags(ctx); err != nil || len(tags) != 1 || tags[0].DoneCount != 1 {
+		t.Errorf("Tags() = %+v, %v; want planning on a done todo", tags, err)
+	}
+	parent := todo.Id
+	if _, err := ada.CreateTodo(ctx, models.CreateTodoRequest{Item: "Book a room", ParentId: &parent}); err != nil {
+		t.Fatalf("CreateTodo() subtask error = %v", err)
+	}
+	if tree, err := ada.TodoTree(ctx, todo.Id); err != nil || len(tree.Children) != 1 {
+		t.Errorf("TodoTree() = %+v, %v; want one subtask", tree, err)
+	}
+
+	tasks := models.CreateAITasksRequest{ParentId: &parent, Tasks: []models.AITask{{Text: "Draft agenda", Priority: "high", Category: "planning"}}}
+	if created, err := ada.CreateAITasks(ctx, tasks); err != nil || len(created.Todos) != 1 || created.Todos[0].Priority != "high" {
+		t.Errorf("CreateAITasks() = %+v, %v; want the task as a high priority todo", created, err)
+	}
+
+	// Listings and history
+	yesterday := time.Now().AddDate(0, 0, -1)
+	if _, err := ada.CreateTodo(ctx, models.CreateTodoRequest{Item: "Pay rent", DueAt: &yesterday}); err != nil {
+		t.Fatalf("CreateTodo() error = %v", err)
+	}
+	for name, seq := range map[string]iter.Seq2[models.Todo, error]{
+		"Todos":          ada.Todos(ctx, models.TodoFilter{PageRequest: models.PageRequest{Limit: 1}}),
+		"PendingTodos":   ada.PendingTodos(ctx, models.PageRequest{}),
+		"CompletedTodos": ada.CompletedTodos(ctx, models.PageRequest{}),
+		"OverdueTodos":   ada.OverdueTodos(ctx, models.DueFilter{}),
+		"TodayTodos":     ada.TodayTodos(ctx, models.DueFilter{}),
+		"UpcomingTodos":  ada.UpcomingTodos(ctx, models.DueFilter{Days: 3}),
+		"ListTodos":      ada.ListTodos(ctx, "main", models.PageRequest{}),
+	} {
+		want := map[string]int{"Todos": 4, "PendingTodos": 3, "CompletedTodos": 1, "OverdueTodos": 1, "ListTodos": 1}[name]
+		if todos, err := sdk.Collect(seq); err != nil || len(todos) != want {
+			t.Errorf("%s() = %d todos, %v; want %d", name, len(todos), err, want)
+		}
+	}
+	if history, err := ada.TodoHistory(ctx, todo.Id); err != nil || len(history) < 4 || history[0].Action != "created" {
+		t.Errorf("TodoHistory() = %+v, %v; want the todo's changes", history, err)
+	}
+	if activity, err := ada.Activity(ctx, models.ActivityFilter{Limit: 2}); err != nil || len(activity) != 2 {
+		t.Errorf("Activity() = %d entries, %v; want 2", len(activity), err)
+	}
+
+	// Trash and batches
+	if err := ada.DeleteTodo(ctx, todo.Id, 0); err != nil {
+		t.Fatalf("DeleteTodo() error = %v", err)
+	}
+	if trash, err := sdk.Collect(ada.Trash(ctx, models.PageRequest{})); err != nil || len(trash) != 3 {
+		t.Errorf("Trash() = %+v, %v; want the deleted todo and its subtasks", trash, err)
+	}
+	if _, err := ada.RestoreTodo(ctx, todo.Id); err != nil {
+		t.Errorf("RestoreTodo() error = %v", err)
+	}
+	results, err := ada.BatchTodos(ctx, models.BatchRequest{Atomic: true, Operations: []models.BatchOperation{
+		{Op: "create", Todo: &models.CreateTodoRequest{Item: "Never created"}},
+		{Op: "toggle", Id: 999},
+	}})
+	if !errors.Is(err, sdk.ErrNotFound) || len(results) != 2 || results[1].Status != http.StatusNotFound {
+		t.Errorf("BatchTodos() atomic = %+v, %v; want the results with ErrNotFound", results, err)
+	}
+	results, err = ada.BatchTodos(ctx, models.BatchRequest{Operations: []models.BatchOperation{{Op: "toggle", Id: todo.Id}}})
+	if err != nil || len(results) != 1 || results[0].Data.Done {
+		t.Errorf("BatchTodos() = %+v, %v; want the todo reopened", results, err)
+	}
+
+	// Export, import and calendars
+	exported, err := ada.Export(ctx, models.ExportFilter{Format: "csv", List: "main"})
+	if err != nil || !strings.Contains(string(exported), "Pay rent") {
+		t.Fatalf("Export() = %q, %v; want the main list as CSV", exported, err)
+	}
+	if imported, err := grace.Import(ctx, exported, models.ImportOptions{DryRun: true}); err != nil || imported.Format != "csv" || imported.Created != 1 {
+		t.Errorf("Import() = %+v, %v; want a CSV dry run of 1 todo", imported, err)
+	}
+	subscription, err := ada.IssueCalendarToken(ctx)
+	if err != nil {
+		t.Fatalf("IssueCalendarToken() error = %v", err)
+	}
+	feed, err := anonymous.Calendar(ctx, models.CalendarFilter{Token: subscription.Token})
+	if err != nil || !strings.Contains(string(feed), "SUMMARY:Pay rent") {
+		t.Errorf("Calendar() = %q, %v; want the feed", feed, err)
+	}
+	if _, err := ada.ListCalendar(ctx, "work", models.CalendarFilter{As: "todo"}); err != nil {
+		t.Errorf("ListCalendar() error = %v", err)
+	}
+	if err := ada.RevokeCalendarToken(ctx); err != nil {
+		t.Errorf("RevokeCalendarToken() error = %v", err)
+	}
+
+	// An unknown Last-Event-ID starts the stream with a reset
+	events, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	for event, err := range ada.StreamTodos(events, models.StreamFilter{LastEventId: "unknown-1"}) {
+		if err != nil || event.Type != models.EventReset {
+			t.Errorf("StreamTodos() first event = %+v, %v; want reset", event, err)
+		}
+		break
+	}
+
+	if err := ada.DeleteList(ctx, "work", models.DeleteListRequest{Todos: "delete"}); err != nil {
+		t.Errorf("DeleteList() error = %v", err)
+	}
+	if _, err := ada.List(ctx, "work"); !errors.Is(err, sdk.ErrNotFound) {
+		t.Errorf("List() after deleting it error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	router := setupRouter()
+	spec := openapi.Spec()
+
+	// Every registered route is documented, and nothing else is
+	documented := 0
+	for _, path := range spec.Paths {
+		documented += len(path)
+	}
+	for _, route := range router.Routes() {
+		if _, ok := spec.Paths[openapi.Path(route.Path)][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s is missing from the OpenAPI document", route.Method, route.Path)
+		}
+	}
+	if routes := len(router.Routes()); documented != routes {
+		t.Errorf("the OpenAPI document has %d operations for %d routes", documented, routes)
+	}
+
+	w := request(router, http.MethodGet, "/api/openapi.json", "", "")
+	var served struct {
+		OpenAPI    string `json:"openapi"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &served); w.Code != http.StatusOK || err != nil {
+		t.Fatalf("GET /api/openapi.json status = %d, error = %v", w.Code, err)
+	}
+	for _, name := range []string{"Success", "ErrorResponse", "Error", "Todo", "CreateTodoRequest", "BatchResult", "List", "TodoEvent"} {
+		if _, ok := served.Components.Schemas[name]; !ok {
+			t.Errorf("GET /api/openapi.json has no %s schema", name)
+		}
+	}
+
+	// Every reference points at a schema
+	for _, ref := range regexp.MustCompile(`"\$ref":"#/components/schemas/(\w+)"`).FindAllStringSubmatch(w.Body.String(), -1) {
+		if _, ok := served.Components.Schemas[ref[1]]; !ok {
+			t.Errorf("GET /api/openapi.json refers to a missing %s schema", ref[1])
+		}
+	}
+
+	if w := request(router, http.MethodGet, "/api/docs", "", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/api/openapi.json") {
+		t.Errorf("GET /api/docs status = %d, want the page rendering /api/openapi.json", w.Code)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	useStore(t, database.NewMemoryStore())
+	t.Setenv("OPENAI_API_KEY", "")
+	router := setupRouter()
+	if w := request(router, http.MethodPost, "/api/todos", "", `{"item": "Buy milk"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/todos status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if w := request(router, http.MethodPost, "/api/lists", "", `{"id": "work", "name": "Work"}`); w.Code != http.StatusCreated {
+		t.Fatalf("POST /api/lists status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name         string
+		method, path string
+		body         string
+		status       int
+		code         string
+		details      bool
+	}{
+		{"missing todo", http.MethodGet, "/api/todos/9", "", http.StatusNotFound, models.CodeNotFound, false},
+		{"missing list", http.MethodGet, "/api/lists/home", "", http.StatusNotFound, models.CodeNotFound, false},
+		{"todo ID that is not a number", http.MethodGet, "/api/todos/x", "", http.StatusBadRequest, models.CodeValidation, false},
+		{"invalid body", http.MethodPost, "/api/todos", `{"priority": "urgent"}`, http.StatusBadRequest, models.CodeValidation, true},
+		{"invalid tag", http.MethodPost, "/api/todos/1/tags", `{"tags": ["no spaces"]}`, http.StatusBadRequest, models.CodeValidation, false},
+		{"existing list", http.MethodPost, "/api/lists", `{"id": "work", "name": "Work"}`, http.StatusConflict, models.CodeConflict, false},
+		{"stale version", http.MethodPost, "/api/todos/batch", `{"atomic": true, "operations": [{"op": "toggle", "id": 1, "version": 7}]}`, http.StatusPreconditionFailed, models.CodeVersionConflict, false},
+		{"no AI key", http.MethodPost, "/api/todos/ai/breakdown", `{"goal": "Learn Go"}`, http.StatusBadGateway, models.CodeUpstreamAI, false},
+		{"sign-in not configured", http.MethodPost, "/api/auth/login", `{"email": "ada@example.com", "password": "correct horse"}`, http.StatusServiceUnavailable, models.CodeUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := request(router, tt.method, tt.path, "", tt.body)
+			var body models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != tt.status {
+				t.Fatalf("%s %s = %d %s, want %d", tt.method, tt.path, w.Code, w.Body.String(), tt.status)
+			}
+			if body.Error.Code != tt.code || body.Error.Message == "" || (len(body.Error.Details) > 0) != tt.details {
+				t.Errorf("%s %s error = %+v, want code %q with a message", tt.method, tt.path, body.Error, tt.code)
+			}
+			if !strings.Contains(w.Body.String(), `"success":false`) {
+				t.Errorf("%s %s body = %s, want success false", tt.method, tt.path, w.Body.String())
+			}
+		})
+	}
+
+	// An atomic batch that fails still reports each operation, with its code
+	w := request(router, http.MethodPost, "/api/todos/batch", "", `{"atomic": true, "operations": [{"op": "toggle", "id": 1}, {"op": "delete", "id": 9}]}`)
+	var batch struct {
+		Error models.Error         `json:"error"`
+		Data  []models.BatchResult `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &batch); err != nil || w.Code != http.StatusNotFound || batch.Error.Code != models.CodeNotFound {
+		t.Fatalf("atomic batch = %d %s, want 404 not_found", w.Code, w.Body.String())
+	}
+	if len(batch.Data) != 2 || batch.Data[0].Code != models.CodeNotApplied || batch.Data[1].Code != models.CodeNotFound {
+		t.Errorf("atomic batch results = %+v, want not_applied then not_found", batch.Data)
+	}
+}
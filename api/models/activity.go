@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Activity is one entry in the audit log: a change someone made to a todo
+type Activity struct {
+	Id      int                    `json:"id"`
+	TodoId  int                    `json:"todo_id"`
+	ActorId string                 `json:"actor_id,omitempty"` // Who made the change; empty when authentication is disabled
+	Action  string                 `json:"action"`             // The event type: created, updated, toggled, deleted or restored
+	Changes map[string]FieldChange `json:"changes,omitempty"`  // Changed todo fields by JSON name
+	At      time.Time              `json:"at"`
+
+	// The todo's owner and list after the change, deciding who may read the entry
+	OwnerId string  `json:"owner_id,omitempty"`
+	ListId  *string `json:"list_id,omitempty"`
+}
+
+// FieldChange is a todo field's value before and after a change; null when unset
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// ActivityFilter holds the query parameters accepted by GET /api/activity
+type ActivityFilter struct {
+	Since *time.Time `form:"since"`                         // RFC 3339; only changes made after this instant
+	Limit int        `form:"limit" binding:"min=0,max=500"` // Defaults to 100
+}
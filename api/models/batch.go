@@ -0,0 +1,44 @@
+package models
+
+// Operations accepted by POST /api/todos/batch
+const (
+	BatchCreate  = "create"
+	BatchUpdate  = "update"
+	BatchToggle  = "toggle"
+	BatchDelete  = "delete"
+	BatchRestore = "restore"
+)
+
+// MaxBatchSize is the most operations one batch may contain (see BatchRequest's binding)
+const MaxBatchSize = 100
+
+// BatchRequest represents the request body for POST /api/todos/batch
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,max=100,dive"`
+	Atomic     bool             `json:"atomic,omitempty"` // Apply every operation or none of them
+}
+
+// BatchOperation is one change in a batch: a create with Todo, an update
+// with Changes, or a toggle, delete or restore of the todo with Id
+type BatchOperation struct {
+	Op      string             `json:"op" binding:"required,oneof=create update toggle delete restore"`
+	Id      int                `json:"id,omitempty" binding:"required_unless=Op create"`
+	Version int                `json:"version,omitempty"` // Only apply while the todo is at this version, like If-Match
+	Todo    *CreateTodoRequest `json:"todo,omitempty" binding:"required_if=Op create"`
+	Changes *UpdateTodoRequest `json:"changes,omitempty" binding:"required_if=Op update"`
+}
+
+// BatchResult reports the outcome of one operation, in request order
+type BatchResult struct {
+	Op     string `json:"op"`
+	Id     int    `json:"id,omitempty"`
+	Status int    `json:"status"` // The HTTP status the operation would have had as its own request
+	Data   *Todo  `json:"data,omitempty"`
+	Code   string `json:"code,omitempty"` // The code of the error, as in an error response
+	Error  string `json:"error,omitempty"`
+}
+
+// OK reports whether the operation succeeded
+func (r BatchResult) OK() bool {
+	return r.Status < 300
+}
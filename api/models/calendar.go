@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// CalendarToken lets calendar apps, which cannot send a bearer token, read
+// a user's calendar feeds with ?token=. A user has at most one.
+type CalendarToken struct {
+	UserId    string    `json:"user_id"`
+	TokenHash string    `json:"-"` // SHA-256 of the token, which is only shown when issued
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CalendarSubscription is returned when a calendar token is issued
+type CalendarSubscription struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"` // Feed of every todo the user can see
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CalendarFilter holds the query parameters of the calendar feeds
+type CalendarFilter struct {
+	As    string `form:"as" binding:"omitempty,oneof=event todo"` // VEVENT (default) or VTODO entries
+	Token string `form:"token"`                                   // Calendar token, read by RequireCalendarUser instead of a bearer token
+}
@@ -0,0 +1,33 @@
+package models
+
+// Codes of error responses, one per kind of error; clients switch on these
+// rather than on messages, which are for people
+const (
+	CodeValidation      = "validation"       // 400: the request can never succeed as it is
+	CodeUnauthorized    = "unauthorized"     // 401: no valid token, calendar token or credentials
+	CodeForbidden       = "forbidden"        // 403: the caller's role does not allow it
+	CodeNotFound        = "not_found"        // 404: the todo, list, member or user does not exist
+	CodeConflict        = "conflict"         // 409: e.g. the list or member already exists
+	CodeVersionConflict = "version_conflict" // 412: the todo changed since the If-Match version
+	CodeTooLarge        = "too_large"        // 413: the upload is over the size limit
+	CodeNotApplied      = "not_applied"      // 424: only in batch results, skipped as another operation failed
+	CodeInternal        = "internal"         // 500: the store or the server failed
+	CodeUpstreamAI      = "upstream_ai"      // 502: OpenAI failed or is not configured
+	CodeUnavailable     = "unavailable"      // 503: e.g. local sign-in is not configured
+)
+
+// Error describes why a request failed
+type Error struct {
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"` // Per-item failures, e.g. of invalid fields or AI tasks that could not be created
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Success bool  `json:"success"` // Always false, as in the {success, data, error} envelope
+	Error   Error `json:"error"`
+
+	// Set when a failed atomic batch still reports every operation's result
+	Data any `json:"data,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Todo change event types sent by GET /api/todos/stream
+const (
+	EventCreated  = "created"
+	EventUpdated  = "updated"
+	EventToggled  = "toggled"
+	EventDeleted  = "deleted"  // Moved to the trash
+	EventRestored = "restored" // Taken back out of the trash
+	// EventReset tells a reconnecting client that the events it missed are
+	// no longer available, so it must re-fetch its todos
+	EventReset = "reset"
+)
+
+// TodoEvent is a single change to a todo. Deleted events carry the todo as
+// it is in the trash; reset events carry no todo.
+type TodoEvent struct {
+	Id   string    `json:"id"` // Opaque; send it back as Last-Event-ID to resume
+	Type string    `json:"type"`
+	Todo *Todo     `json:"todo,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+// StreamFilter holds the query parameters accepted by GET /api/todos/stream
+type StreamFilter struct {
+	List        string `form:"list"`          // Only todos in this list; "main" selects the main list
+	LastEventId string `form:"last_event_id"` // For clients that cannot send the Last-Event-ID header
+}
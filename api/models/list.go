@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// List is a named collection of todos. Todo.ListId refers to List.Id;
+// the main list is implicit and has no List record.
+type List struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"` // Hex colour, e.g. "#4f46e5"
+	Position  int       `json:"position"`        // Lists are ordered by position, then ID
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+	OwnerId   string    `json:"owner_id,omitempty"` // User the list belongs to; empty when authentication is disabled
+
+	// The caller's role in the list: owner, editor or viewer. Set by the services; never stored.
+	Role string `json:"role,omitempty"`
+
+	// Computed by the store when lists are loaded; never written
+	PendingCount int `json:"pending_count"`
+	DoneCount    int `json:"done_count"`
+}
+
+// CreateListRequest represents the request body for creating a list
+type CreateListRequest struct {
+	Id       string `json:"id,omitempty"` // Optional: derived from the name when omitted
+	Name     string `json:"name" binding:"required"`
+	Color    string `json:"color,omitempty" binding:"omitempty,hexcolor"`
+	Position int    `json:"position,omitempty"`
+}
+
+// UpdateListRequest represents the request body for renaming, recolouring,
+// reordering or archiving a list
+type UpdateListRequest struct {
+	Name     *string `json:"name,omitempty" binding:"omitempty,min=1"`
+	Color    *string `json:"color,omitempty" binding:"omitempty,hexcolor"` // An empty string clears the colour
+	Position *int    `json:"position,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+// ListFilter holds the query parameters accepted by GET /api/lists
+type ListFilter struct {
+	Archived bool `form:"archived"` // Include archived lists
+}
+
+// DeleteListRequest holds the query parameters accepted by DELETE /api/lists/:id
+type DeleteListRequest struct {
+	Todos string `form:"todos" binding:"omitempty,oneof=move delete"` // "move" (default) moves todos to the main list, "delete" to the trash
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Roles a user can have in a list. The owner manages the list and its
+// members; editors can add, change and delete its todos; viewers can only read.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// ListMember shares a list with a user other than its owner
+type ListMember struct {
+	ListId    string    `json:"list_id"`
+	UserId    string    `json:"user_id"`
+	Email     string    `json:"email,omitempty"` // Known when the member was invited by email
+	Role      string    `json:"role"`            // RoleEditor or RoleViewer
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddMemberRequest represents the request body for inviting a member to a list.
+// Exactly one of Email (a registered account) or UserId (e.g. a Supabase Auth user) is required.
+type AddMemberRequest struct {
+	Email  string `json:"email,omitempty" binding:"omitempty,email"`
+	UserId string `json:"user_id,omitempty"`
+	Role   string `json:"role" binding:"required,oneof=viewer editor"`
+}
+
+// UpdateMemberRequest represents the request body for changing a member's role
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=viewer editor"`
+}
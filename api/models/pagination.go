@@ -0,0 +1,31 @@
+package models
+
+// PageRequest holds the pagination query parameters accepted by listing endpoints.
+// A zero Limit with no Cursor returns the full listing.
+type PageRequest struct {
+	Limit  int    `form:"limit" binding:"min=0,max=500"` // Page size
+	Cursor string `form:"cursor"`                        // next_cursor from the previous page
+}
+
+// TodoFilter holds the query parameters accepted by GET /api/todos
+type TodoFilter struct {
+	PageRequest
+	Done   *bool  `form:"done"` // Only pending (false) or completed (true) todos
+	List   string `form:"list"` // List ID, or "main" for the main list
+	Search string `form:"q"`    // Case-insensitive substring match on item
+	Sort   string `form:"sort"` // Comma-separated fields, "-" prefix for descending, e.g. "-created_at,item"
+
+	Priority string `form:"priority" binding:"omitempty,oneof=high medium low"`
+	Category string `form:"category"` // Case-insensitive exact match
+	Parent   string `form:"parent"`   // "none" for top-level todos, or a todo ID for its subtasks
+
+	Tags    []string `form:"tag"`                                        // Repeatable: ?tag=urgent&tag=home
+	TagMode string   `form:"tag_mode" binding:"omitempty,oneof=all any"` // Whether todos need all the tags (default) or any of them
+}
+
+// DueFilter holds the query parameters accepted by the overdue/today/upcoming views
+type DueFilter struct {
+	PageRequest
+	TZ   string `form:"tz"`                           // IANA zone ("Europe/Paris") or UTC offset ("+02:00") defining "today"; defaults to UTC
+	Days int    `form:"days" binding:"min=0,max=366"` // Window for upcoming; defaults to 7
+}
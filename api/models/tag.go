@@ -0,0 +1,13 @@
+package models
+
+// Tag is a label on todos with how many of the caller's todos carry it
+type Tag struct {
+	Name         string `json:"name"`
+	PendingCount int    `json:"pending_count"`
+	DoneCount    int    `json:"done_count"`
+}
+
+// TagsRequest represents the request body for POST and DELETE /api/todos/:id/tags
+type TagsRequest struct {
+	Tags []string `json:"tags" binding:"required,min=1,max=20"` // With or without a leading #
+}
@@ -1,21 +1,59 @@
 package models
 
-// Todo represents a todo item
-type Todo struct {
-	Id     int     `json:"id"`
-	Item   string  `json:"item"`
-	Done   bool    `json:"done"`
-	ListId *string `json:"list_id,omitempty"` // NULL means main list, otherwise it's a list identifier
-}
+import (
+	"time"
+
+	"listy-api/core"
+)
+
+// Todo represents a todo item; the model is shared with the CLIs through core
+type Todo = core.Todo
+
+// SubtaskProgress rolls up a todo's direct subtasks, e.g. 3 of 5 done
+type SubtaskProgress = core.SubtaskProgress
+
+// Priorities are the accepted values of Todo.Priority, highest first
+var Priorities = []string{"high", "medium", "low"}
 
 // CreateTodoRequest represents the request body for creating a todo
 type CreateTodoRequest struct {
-	Item   string  `json:"item" binding:"required"`
-	ListId *string `json:"list_id,omitempty"` // Optional: if provided, adds to specific list
+	Item     string     `json:"item" binding:"required"`
+	ListId   *string    `json:"list_id,omitempty"` // Optional: if provided, adds to specific list
+	DueAt    *time.Time `json:"due_at,omitempty"`
+	RemindAt *time.Time `json:"remind_at,omitempty"`
+
+	Priority      string `json:"priority,omitempty" binding:"omitempty,oneof=high medium low"`
+	EstimatedTime string `json:"estimated_time,omitempty"`
+	Category      string `json:"category,omitempty"`
+
+	ParentId     *int     `json:"parent_id,omitempty"` // Optional: creates a subtask in the parent's list
+	AutoComplete bool     `json:"auto_complete,omitempty"`
+	Recurrence   string   `json:"recurrence,omitempty"` // Optional: RRULE repeating the todo when it is completed
+	Tags         []string `json:"tags,omitempty"`
 }
 
 // UpdateTodoRequest represents the request body for updating a todo
 type UpdateTodoRequest struct {
-	Item *string `json:"item,omitempty"`
-	Done *bool   `json:"done,omitempty"`
+	Item          *string    `json:"item,omitempty"`
+	Done          *bool      `json:"done,omitempty"`
+	DueAt         *time.Time `json:"due_at,omitempty"`
+	RemindAt      *time.Time `json:"remind_at,omitempty"`
+	ClearDueAt    bool       `json:"clear_due_at,omitempty"`    // Remove the due date
+	ClearRemindAt bool       `json:"clear_remind_at,omitempty"` // Remove the reminder
+
+	// An empty string clears the field
+	Priority      *string `json:"priority,omitempty" binding:"omitempty,oneof=high medium low ''"`
+	EstimatedTime *string `json:"estimated_time,omitempty"`
+	Category      *string `json:"category,omitempty"`
+
+	ParentId      *int      `json:"parent_id,omitempty"`       // Move under another todo
+	ClearParentId bool      `json:"clear_parent_id,omitempty"` // Make the todo top-level again
+	AutoComplete  *bool     `json:"auto_complete,omitempty"`
+	Recurrence    *string   `json:"recurrence,omitempty"` // An empty string stops the todo repeating
+	Tags          *[]string `json:"tags,omitempty"`       // Replaces every tag; an empty list removes them
+}
+
+// TodoDetailRequest holds the query parameters accepted by GET /api/todos/:id
+type TodoDetailRequest struct {
+	Include string `form:"include" binding:"omitempty,oneof=children"` // "children" nests the subtask tree
 }
@@ -0,0 +1,26 @@
+package models
+
+// ExportFilter holds the query parameters of GET /api/export
+type ExportFilter struct {
+	Format string `form:"format" binding:"omitempty,oneof=json csv markdown todotxt"` // Defaults to json
+	List   string `form:"list"`                                                       // List ID, or "main" for the main list; every list by default
+	TZ     string `form:"tz"`                                                         // Zone that due dates at 23:59 are written as a date in; defaults to UTC
+}
+
+// ImportOptions holds the query parameters of POST /api/import, whose body is the file
+type ImportOptions struct {
+	Format string `form:"format" binding:"omitempty,oneof=json csv markdown todotxt"` // Detected from the file by default
+	List   string `form:"list"`                                                       // Puts every todo in this list ("main" for the main list) instead of the one the file names
+	TZ     string `form:"tz"`                                                         // Zone a due date without a time is 23:59 in; defaults to UTC
+	DryRun bool   `form:"dry_run"`                                                    // Report what would be imported without saving anything
+	Dedupe bool   `form:"dedupe"`                                                     // Skip todos whose text matches another one's with the same list and parent
+}
+
+// ImportResult reports what an import added
+type ImportResult struct {
+	Format  string `json:"format"`
+	DryRun  bool   `json:"dry_run"`
+	Created int    `json:"created"`
+	Skipped int    `json:"skipped"` // Duplicates left out by dedupe
+	Todos   []Todo `json:"todos"`   // The created todos; with dry_run, the ones that would be, without IDs
+}
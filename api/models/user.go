@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// User is an account that signs in with an email and password to receive a
+// locally issued token. Users of Supabase Auth sign in through Supabase and
+// have no User record; their token's subject is used as the owner ID directly.
+type User struct {
+	Id           string    `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"` // bcrypt hash; never sent to clients
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Credentials represents the request body for registering and signing in
+type Credentials struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8,max=72"` // bcrypt uses at most 72 bytes
+}
+
+// AuthResponse is returned by the register and login endpoints
+type AuthResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+}
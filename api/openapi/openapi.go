@@ -0,0 +1,262 @@
+// Package openapi describes the API as an OpenAPI 3 document. The document
+// is built from the route table in routes.go and the request and response
+// types of the models package, so the schemas follow the models as they
+// change; a test in the main package checks the table against the routes
+// the router registers.
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"listy-api/models"
+)
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Tags       []Tag                           `json:"tags"`
+	Paths      map[string]map[string]Operation `json:"paths"` // By path, then lower-case method
+	Components Components                      `json:"components"`
+}
+
+// Info names and describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// Tag groups operations in the docs UI
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Components holds the schemas and security schemes operations refer to
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is a way to authenticate
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	Name   string `json:"name,omitempty"`
+	In     string `json:"in,omitempty"`
+
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Operation is one method on a path
+type Operation struct {
+	OperationId string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags"`
+	Security    []map[string][]string `json:"security,omitempty"` // Schemes any one of which is accepted; none for public routes
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"` // By status code
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query or header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes what an operation accepts
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"` // By media type
+}
+
+// MediaType is the schema of a body in one media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Response describes what an operation answers with one status
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// Header is a response header
+type Header struct {
+	Description string  `json:"description"`
+	Schema      *Schema `json:"schema"`
+}
+
+var (
+	spec     *Document
+	specOnce sync.Once
+)
+
+// Spec returns the document, built on first use
+func Spec() *Document {
+	specOnce.Do(func() { spec = build() })
+	return spec
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// Path turns a Gin route path into an OpenAPI one, e.g. "/api/todos/:id"
+// into "/api/todos/{id}"
+func Path(ginPath string) string {
+	return pathParam.ReplaceAllString(ginPath, "{$1}")
+}
+
+// build describes every route in the route table
+func build() *Document {
+	s := schemas{}
+	s["Success"] = &Schema{
+		Type:        "object",
+		Description: "The envelope of every JSON response outside the health, AI breakdown, calendar, export and stream routes",
+		Required:    []string{"success"},
+		Properties: map[string]*Schema{
+			"success": {Type: "boolean"},
+			"message": {Type: "string"},
+		},
+	}
+	s.of(models.ErrorResponse{})
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:   "Listy API",
+			Version: "1.0.0",
+			Description: "Todos, lists, sharing, calendar feeds and AI task breakdowns. " +
+				"JSON responses are wrapped in {\"success\": true, \"data\": ...}; errors are " +
+				"{\"success\": false, \"error\": {\"code\": ..., \"message\": ..., \"details\": [...]}}, where code names the kind of error for clients to switch on.",
+		},
+		Tags:  tags,
+		Paths: map[string]map[string]Operation{},
+		Components: Components{
+			Schemas: s,
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearer": {
+					Type: "http", Scheme: "bearer", BearerFormat: "JWT",
+					Description: "A token from /api/auth/login or Supabase Auth; not needed when the server runs without a JWT secret",
+				},
+				"calendarToken": {
+					Type: "apiKey", Name: "token", In: "query",
+					Description: "A calendar token from /api/calendar/token, for calendar apps that cannot send headers",
+				},
+			},
+		},
+	}
+	for _, r := range routes {
+		path := Path(r.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]Operation{}
+		}
+		doc.Paths[path][strings.ToLower(r.method)] = r.operation(s)
+	}
+	s.of(models.TodoEvent{}) // Sent as text on the stream, so only named in its description
+	return doc
+}
+
+// operation describes a route
+func (r route) operation(s schemas) Operation {
+	op := Operation{OperationId: r.id, Summary: r.summary, Tags: []string{r.tag}, Responses: map[string]Response{}}
+	switch r.access {
+	case bearer:
+		op.Security = []map[string][]string{{"bearer": {}}}
+	case calendar:
+		op.Security = []map[string][]string{{"bearer": {}}, {"calendarToken": {}}}
+	}
+
+	todoId := strings.HasPrefix(r.path, "/api/todos/:id")
+	for _, match := range pathParam.FindAllStringSubmatch(r.path, -1) {
+		param := Parameter{Name: match[1], In: "path", Required: true, Schema: &Schema{Type: "string"}}
+		param.Description = pathParams[match[1]]
+		if match[1] == "id" && todoId {
+			param.Schema, param.Description = &Schema{Type: "integer"}, "Todo ID"
+		}
+		op.Parameters = append(op.Parameters, param)
+	}
+	if r.query != nil {
+		for _, param := range s.parameters(r.query) {
+			if r.access == calendar && param.Name == "token" {
+				continue // Described by the calendarToken scheme
+			}
+			op.Parameters = append(op.Parameters, param)
+		}
+	}
+	for _, header := range r.headers {
+		op.Parameters = append(op.Parameters, Parameter{Name: header, In: "header", Description: headerParams[header], Schema: &Schema{Type: "string"}})
+	}
+	switch body := r.body.(type) {
+	case nil:
+	case []byte:
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"application/octet-stream": {Schema: &Schema{Type: "string", Format: "binary"}},
+		}}
+	default:
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"application/json": {Schema: s.of(body)},
+		}}
+	}
+
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := Response{Description: http.StatusText(status), Content: r.content(s)}
+	if r.etag {
+		success.Headers = map[string]Header{"ETag": {Description: "The todo's version, to send back as If-Match", Schema: &Schema{Type: "string"}}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	failures := slices.Clone(r.errors)
+	if r.access != public {
+		failures = append(failures, http.StatusUnauthorized)
+	}
+	if r.query != nil || r.body != nil || todoId {
+		failures = append(failures, http.StatusBadRequest)
+	}
+	for _, status := range failures {
+		op.Responses[strconv.Itoa(status)] = Response{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{"application/json": {Schema: &Schema{Ref: "#/components/schemas/ErrorResponse"}}},
+		}
+	}
+	return op
+}
+
+// content describes the success response's body
+func (r route) content(s schemas) map[string]MediaType {
+	if r.raw != nil {
+		return r.raw
+	}
+	if r.unwrapped != nil {
+		return map[string]MediaType{"application/json": {Schema: s.of(r.unwrapped)}}
+	}
+	envelope := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	if r.data != nil {
+		envelope.Properties["data"] = s.of(r.data)
+	}
+	if r.paginated {
+		envelope.Properties["next_cursor"] = &Schema{Type: "string", Description: "Set when more results follow; pass it back as cursor"}
+	}
+	for name, schema := range r.extra {
+		envelope.Properties[name] = schema
+	}
+	schema := &Schema{Ref: "#/components/schemas/Success"}
+	if len(envelope.Properties) > 0 {
+		schema = &Schema{AllOf: []*Schema{schema, envelope}}
+	}
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
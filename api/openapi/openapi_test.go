@@ -0,0 +1,74 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type page struct {
+	Limit int `form:"limit" binding:"min=0,max=500"`
+}
+
+type node struct {
+	Name     string     `json:"name" binding:"required,min=1"`
+	Kind     string     `json:"kind,omitempty" binding:"omitempty,oneof=leaf branch"`
+	Parent   *node      `json:"parent"`
+	Children []node     `json:"children,omitempty" binding:"max=3,dive"`
+	At       *time.Time `json:"at,omitempty"`
+	Secret   string     `json:"-"`
+	Extra    map[string]any
+}
+
+type nodeFilter struct {
+	page
+	Tags []string `form:"tag"`
+	Kind string   `form:"kind" binding:"required"`
+}
+
+func TestSchemas(t *testing.T) {
+	s := schemas{}
+	if ref := s.of([]node{}); ref.Type != "array" || ref.Items.Ref != "#/components/schemas/node" {
+		t.Fatalf("schema of []node = %+v, want an array of references", ref)
+	}
+
+	got, _ := json.Marshal(s["node"])
+	want := `{"type":"object","properties":{` +
+		`"Extra":{"type":"object","additionalProperties":{}},` +
+		`"at":{"type":"string","format":"date-time"},` +
+		`"children":{"type":"array","maxItems":3,"items":{"$ref":"#/components/schemas/node"}},` +
+		`"kind":{"type":"string","enum":["leaf","branch"]},` +
+		`"name":{"type":"string","minLength":1},` +
+		`"parent":{"nullable":true,"allOf":[{"$ref":"#/components/schemas/node"}]}},` +
+		`"required":["name"]}`
+	if string(got) != want {
+		t.Errorf("node schema = %s\nwant %s", got, want)
+	}
+
+	params := s.parameters(nodeFilter{})
+	if len(params) != 3 {
+		t.Fatalf("parameters(nodeFilter) = %+v, want limit, tag and kind", params)
+	}
+	if limit := params[0]; limit.Name != "limit" || *limit.Schema.Minimum != 0 || *limit.Schema.Maximum != 500 {
+		t.Errorf("limit parameter = %+v, want 0 to 500", limit)
+	}
+	if tag := params[1]; tag.Name != "tag" || tag.Schema.Type != "array" || tag.Required {
+		t.Errorf("tag parameter = %+v, want an optional array", tag)
+	}
+	if kind := params[2]; kind.Name != "kind" || !kind.Required {
+		t.Errorf("kind parameter = %+v, want it required", kind)
+	}
+}
+
+func TestPath(t *testing.T) {
+	for ginPath, want := range map[string]string{
+		"/api/todos":                     "/api/todos",
+		"/api/todos/:id/toggle":          "/api/todos/{id}/toggle",
+		"/api/lists/:id/members/:userId": "/api/lists/{id}/members/{userId}",
+		"/api/lists/:id/calendar.ics":    "/api/lists/{id}/calendar.ics",
+	} {
+		if got := Path(ginPath); got != want {
+			t.Errorf("Path(%q) = %q, want %q", ginPath, got, want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package openapi
+
+import (
+	"net/http"
+
+	"listy-api/models"
+)
+
+// access is how a route authenticates its caller
+type access int
+
+const (
+	bearer   access = iota // RequireUser: a bearer token when authentication is enabled
+	public                 // No token
+	calendar               // RequireCalendarUser: a bearer token or a calendar token in ?token=
+)
+
+// route describes one registered route: what it takes and what it answers.
+// Values such as models.TodoFilter{} stand for their types.
+type route struct {
+	method, path string // As registered with Gin, e.g. "/api/todos/:id"
+	id, tag      string // operationId and the tag it is grouped under
+	summary      string
+	access       access
+
+	query   any      // The struct bound with ShouldBindQuery
+	headers []string // Request headers it reads, described in headerParams
+	body    any      // The JSON request body, or []byte for a raw file
+
+	status    int  // Success status; 200 when zero
+	data      any  // The success envelope's data
+	paginated bool // The envelope has next_cursor
+	etag      bool // The response sets ETag to the todo's version
+	extra     map[string]*Schema
+
+	unwrapped any                  // A JSON body sent without the envelope
+	raw       map[string]MediaType // A body that is not JSON, by media type
+
+	errors []int // Error statuses besides 401 for authenticated routes and 400 for ones taking input or a todo ID
+}
+
+var tags = []Tag{
+	{Name: "health", Description: "Liveness"},
+	{Name: "auth", Description: "Accounts and tokens"},
+	{Name: "todos", Description: "Todos, subtasks, due-date views, batches and the change stream"},
+	{Name: "trash", Description: "Deleted todos, kept until the retention period has passed"},
+	{Name: "tags", Description: "Tagging todos"},
+	{Name: "activity", Description: "Who changed which todo"},
+	{Name: "lists", Description: "Lists and sharing them"},
+	{Name: "calendar", Description: "iCalendar feeds and calendar tokens"},
+	{Name: "transfer", Description: "Import and export as JSON, CSV, Markdown or todo.txt"},
+	{Name: "ai", Description: "AI task breakdowns"},
+	{Name: "docs", Description: "This document"},
+}
+
+var pathParams = map[string]string{
+	"id":     "List ID",
+	"listId": "List ID, or \"main\" for the main list",
+	"userId": "User ID of a list member",
+}
+
+var headerParams = map[string]string{
+	"If-Match":      "A version from the todo's ETag, e.g. \"3\": the change is only made while the todo is at that version, or fails with 412",
+	"Last-Event-ID": "The ID of the last event received, to replay what was missed since",
+}
+
+// todoChange is an operation on a todo that can be made conditional
+func todoChange(r route) route {
+	r.headers = append(r.headers, "If-Match")
+	r.data, r.etag = models.Todo{}, true
+	r.errors = append(r.errors, http.StatusForbidden, http.StatusNotFound, http.StatusPreconditionFailed)
+	return r
+}
+
+// todoPage is a paginated todo listing
+func todoPage(r route) route {
+	r.data, r.paginated = []models.Todo{}, true
+	if r.query == nil {
+		r.query = models.PageRequest{}
+	}
+	return r
+}
+
+var aiTasks = map[string]*Schema{"warnings": {Type: "array", Items: &Schema{Type: "string"}, Description: "Tasks that could not be created"}}
+
+// routes is every route setupRouter registers; TestOpenAPI checks they match
+var routes = []route{
+	{method: "GET", path: "/api/health", id: "health", tag: "health", summary: "Check that the API is up", access: public,
+		unwrapped: struct {
+			Status  string `json:"status"`
+			Service string `json:"service"`
+		}{}},
+	{method: "GET", path: "/api/openapi.json", id: "getOpenAPI", tag: "docs", summary: "This OpenAPI document", access: public,
+		raw: map[string]MediaType{"application/json": {Schema: &Schema{Type: "object"}}}},
+	{method: "GET", path: "/api/docs", id: "getDocs", tag: "docs", summary: "Interactive documentation of this document", access: public,
+		raw: map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}}},
+
+	// Auth
+	{method: "POST", path: "/api/auth/register", id: "register", tag: "auth", summary: "Create an account and sign in", access: public,
+		body: models.Credentials{}, status: http.StatusCreated, data: models.AuthResponse{}, errors: []int{http.StatusConflict, http.StatusServiceUnavailable}},
+	{method: "POST", path: "/api/auth/login", id: "login", tag: "auth", summary: "Exchange an email and password for a token", access: public,
+		body: models.Credentials{}, data: models.AuthResponse{}, errors: []int{http.StatusUnauthorized, http.StatusServiceUnavailable}},
+	{method: "GET", path: "/api/auth/me", id: "me", tag: "auth", summary: "The user the token belongs to",
+		data: struct {
+			Id          string `json:"id"`
+			Email       string `json:"email"`
+			AuthEnabled bool   `json:"auth_enabled"`
+		}{}},
+
+	// AI
+	{method: "POST", path: "/api/todos/ai/breakdown", id: "breakDownGoal", tag: "ai", summary: "Suggest tasks that reach a goal",
+		body: models.AITaskBreakdownRequest{}, unwrapped: models.AITaskBreakdownResponse{}, errors: []int{http.StatusBadGateway}},
+	{method: "POST", path: "/api/todos/ai/subtasks", id: "breakDownTask", tag: "ai", summary: "Suggest subtasks of a task, or none when it cannot be broken down",
+		body: models.AITaskBreakdownRequest{}, unwrapped: models.AITaskBreakdownResponse{}, errors: []int{http.StatusBadGateway}},
+	{method: "POST", path: "/api/todos/ai/create", id: "createAITasks", tag: "ai", summary: "Add todos for suggested tasks, keeping priority, estimate and category",
+		body: models.CreateAITasksRequest{}, status: http.StatusCreated, data: []models.Todo{}, extra: aiTasks,
+		errors: []int{http.StatusForbidden, http.StatusInternalServerError}},
+
+	// Todos
+	todoPage(route{method: "GET", path: "/api/todos", id: "listTodos", tag: "todos", summary: "Todos matching filters, sorted and paginated",
+		query: models.TodoFilter{}}),
+	todoPage(route{method: "GET", path: "/api/todos/pending", id: "listPendingTodos", tag: "todos", summary: "Todos not yet done"}),
+	todoPage(route{method: "GET", path: "/api/todos/completed", id: "listCompletedTodos", tag: "todos", summary: "Done todos"}),
+	todoPage(route{method: "GET", path: "/api/todos/overdue", id: "listOverdueTodos", tag: "todos", summary: "Pending todos past their due date",
+		query: models.DueFilter{}}),
+	todoPage(route{method: "GET", path: "/api/todos/today", id: "listTodayTodos", tag: "todos", summary: "Pending todos due today in the tz zone",
+		query: models.DueFilter{}}),
+	todoPage(route{method: "GET", path: "/api/todos/upcoming", id: "listUpcomingTodos", tag: "todos", summary: "Pending todos due in the next few days, 7 by default",
+		query: models.DueFilter{}}),
+	todoPage(route{method: "GET", path: "/api/todos/list/:listId", id: "listTodosInList", tag: "todos", summary: "Todos in a list",
+		errors: []int{http.StatusForbidden, http.StatusNotFound}}),
+	{method: "GET", path: "/api/todos/stream", id: "streamTodos", tag: "todos", summary: "Server-Sent Events announcing every change to the todos the user can see",
+		query: models.StreamFilter{}, headers: []string{"Last-Event-ID"}, errors: []int{http.StatusForbidden, http.StatusNotFound},
+		raw: map[string]MediaType{"text/event-stream": {Schema: &Schema{
+			Type:        "string",
+			Description: "Events with an id and a data line holding a TodoEvent as JSON; lines starting with \":\" keep the connection alive",
+		}}}},
+	{method: "GET", path: "/api/todos/:id", id: "getTodo", tag: "todos", summary: "A todo with its version, and optionally its subtask tree",
+		query: models.TodoDetailRequest{}, data: models.Todo{}, etag: true, errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{method: "POST", path: "/api/todos", id: "createTodo", tag: "todos", summary: "Add a todo",
+		body: models.CreateTodoRequest{}, status: http.StatusCreated, data: models.Todo{}, errors: []int{http.StatusForbidden}},
+	{method: "POST", path: "/api/todos/batch", id: "batchTodos", tag: "todos", summary: "Apply up to 100 creates, updates, toggles, deletes and restores, optionally all or none",
+		body: models.BatchRequest{}, data: []models.BatchResult{},
+		errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusPreconditionFailed}},
+	todoChange(route{method: "PUT", path: "/api/todos/:id", id: "updateTodo", tag: "todos", summary: "Change a todo",
+		body: models.UpdateTodoRequest{}}),
+	todoChange(route{method: "PATCH", path: "/api/todos/:id/toggle", id: "toggleTodo", tag: "todos", summary: "Flip a todo's done status"}),
+	{method: "DELETE", path: "/api/todos/:id", id: "deleteTodo", tag: "todos", summary: "Move a todo and its subtasks to the trash",
+		headers: []string{"If-Match"}, errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusPreconditionFailed}},
+	todoChange(route{method: "POST", path: "/api/todos/:id/restore", id: "restoreTodo", tag: "trash", summary: "Take a todo out of the trash"}),
+	{method: "GET", path: "/api/todos/:id/history", id: "getTodoHistory", tag: "activity", summary: "Every recorded change to a todo, oldest first",
+		data: []models.Activity{}, errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	todoChange(route{method: "POST", path: "/api/todos/:id/tags", id: "addTags", tag: "tags", summary: "Tag a todo",
+		body: models.TagsRequest{}}),
+	todoChange(route{method: "DELETE", path: "/api/todos/:id/tags", id: "removeTags", tag: "tags", summary: "Untag a todo",
+		body: models.TagsRequest{}}),
+
+	todoPage(route{method: "GET", path: "/api/trash", id: "listTrash", tag: "trash", summary: "Deleted todos, most recently deleted first"}),
+
+	// Calendar
+	{method: "GET", path: "/api/todos/calendar.ics", id: "getCalendar", tag: "calendar", summary: "iCalendar feed of every todo the user can see", access: calendar,
+		query: models.CalendarFilter{}, raw: map[string]MediaType{"text/calendar": {Schema: &Schema{Type: "string"}}}},
+	{method: "GET", path: "/api/lists/:id/calendar.ics", id: "getListCalendar", tag: "calendar", summary: "iCalendar feed of one list", access: calendar,
+		query: models.CalendarFilter{}, raw: map[string]MediaType{"text/calendar": {Schema: &Schema{Type: "string"}}},
+		errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{method: "POST", path: "/api/calendar/token", id: "issueCalendarToken", tag: "calendar", summary: "Issue a calendar token, replacing the previous one",
+		status: http.StatusCreated, data: models.CalendarSubscription{}},
+	{method: "DELETE", path: "/api/calendar/token", id: "revokeCalendarToken", tag: "calendar", summary: "Stop the calendar token working"},
+
+	// Import and export
+	{method: "GET", path: "/api/export", id: "exportTodos", tag: "transfer", summary: "Every todo, or one list's, as a file",
+		query: models.ExportFilter{}, errors: []int{http.StatusForbidden, http.StatusNotFound},
+		raw: map[string]MediaType{
+			"application/json": {Schema: &Schema{Type: "string"}},
+			"text/csv":         {Schema: &Schema{Type: "string"}},
+			"text/markdown":    {Schema: &Schema{Type: "string"}},
+			"text/plain":       {Schema: &Schema{Type: "string", Description: "todo.txt"}},
+		}},
+	{method: "POST", path: "/api/import", id: "importTodos", tag: "transfer", summary: "Add the todos in a file; a dry run answers 200 without saving",
+		query: models.ImportOptions{}, body: []byte{}, status: http.StatusCreated, data: models.ImportResult{},
+		errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge}},
+
+	{method: "GET", path: "/api/tags", id: "listTags", tag: "tags", summary: "Every tag on the user's todos with pending and done counts",
+		data: []models.Tag{}},
+	{method: "GET", path: "/api/activity", id: "listActivity", tag: "activity", summary: "Changes to the todos the user can see, oldest first",
+		query: models.ActivityFilter{}, data: []models.Activity{}},
+
+	// Lists
+	{method: "GET", path: "/api/lists", id: "listLists", tag: "lists", summary: "The lists the user owns or is a member of, with todo counts",
+		query: models.ListFilter{}, data: []models.List{}},
+	{method: "POST", path: "/api/lists", id: "createList", tag: "lists", summary: "Create a list",
+		body: models.CreateListRequest{}, status: http.StatusCreated, data: models.List{}, errors: []int{http.StatusConflict}},
+	{method: "GET", path: "/api/lists/:id", id: "getList", tag: "lists", summary: "A list with its todo counts",
+		data: models.List{}, errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{method: "PUT", path: "/api/lists/:id", id: "updateList", tag: "lists", summary: "Rename, recolour, reorder or archive a list",
+		body: models.UpdateListRequest{}, data: models.List{}, errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{method: "DELETE", path: "/api/lists/:id", id: "deleteList", tag: "lists", summary: "Delete a list, moving its todos to the main list or the trash",
+		query: models.DeleteListRequest{}, errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{method: "GET", path: "/api/lists/:id/members", id: "listMembers", tag: "lists", summary: "The users a list is shared with",
+		data: []models.ListMember{}, errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{method: "POST", path: "/api/lists/:id/members", id: "addMember", tag: "lists", summary: "Share a list with a user, by email or user ID",
+		body: models.AddMemberRequest{}, status: http.StatusCreated, data: models.ListMember{},
+		errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict}},
+	{method: "PUT", path: "/api/lists/:id/members/:userId", id: "updateMember", tag: "lists", summary: "Change a member's role",
+		body: models.UpdateMemberRequest{}, data: models.ListMember{}, errors: []int{http.StatusForbidden, http.StatusNotFound}},
+	{method: "DELETE", path: "/api/lists/:id/members/:userId", id: "removeMember", tag: "lists", summary: "Stop sharing a list with a user",
+		errors: []int{http.StatusForbidden, http.StatusNotFound}},
+}
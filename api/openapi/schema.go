@@ -0,0 +1,198 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI 3.0 schema object, limited to what the models need
+type Schema struct {
+	Ref         string   `json:"$ref,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Format      string   `json:"format,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Nullable    bool     `json:"nullable,omitempty"`
+	Enum        []string `json:"enum,omitempty"`
+	Pattern     string   `json:"pattern,omitempty"`
+	Minimum     *int     `json:"minimum,omitempty"`
+	Maximum     *int     `json:"maximum,omitempty"`
+	MinLength   *int     `json:"minLength,omitempty"`
+	MaxLength   *int     `json:"maxLength,omitempty"`
+	MinItems    *int     `json:"minItems,omitempty"`
+	MaxItems    *int     `json:"maxItems,omitempty"`
+
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// oneofValue matches one value of a oneof rule: a word, or text in single quotes
+var oneofValue = regexp.MustCompile(`'[^']*'|\S+`)
+
+// schemas turns Go types into schemas, collecting every named struct in
+// components so it is described once and referenced by name. Struct fields
+// are described by their json tags and the validation their binding tags
+// ask for: required, oneof, min, max, email and hexcolor.
+type schemas map[string]*Schema
+
+// of returns the schema of v's type; v is a value such as models.Todo{}
+// or []models.Todo{}
+func (s schemas) of(v any) *Schema {
+	return s.forType(reflect.TypeOf(v))
+}
+
+func (s schemas) forType(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Pointer:
+		return s.forType(t.Elem())
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		if _, ok := s[t.Name()]; !ok {
+			s[t.Name()] = &Schema{} // Reserved first, so recursive types end
+			*s[t.Name()] = *s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	case t.Kind() == reflect.Struct:
+		return s.object(t)
+	case t == reflect.TypeFor[json.RawMessage]():
+		return &Schema{}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &Schema{Type: "array", Items: s.forType(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.forType(t.Elem())}
+	case t.Kind() == reflect.Interface:
+		return &Schema{} // Any JSON value
+	}
+	return scalar(t)
+}
+
+// scalar returns the schema of a boolean, number or string type
+func scalar(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	}
+	return &Schema{Type: "string"}
+}
+
+// object describes a struct's JSON fields, flattening embedded structs
+func (s schemas) object(t reflect.Type) *Schema {
+	object := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, field := range reflect.VisibleFields(t) {
+		name, omitempty, ok := jsonName(field)
+		if !ok || field.Anonymous && field.Tag.Get("json") == "" {
+			continue
+		}
+		property := s.forType(field.Type)
+		if field.Type.Kind() == reflect.Pointer && !omitempty {
+			property = nullable(property)
+		}
+		required := constrain(property, field.Type, field.Tag.Get("binding"))
+		if required {
+			object.Required = append(object.Required, name)
+		}
+		object.Properties[name] = property
+	}
+	return object
+}
+
+// nullable marks a schema as allowing null; references are wrapped, as
+// siblings of $ref are ignored
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AllOf: []*Schema{schema}, Nullable: true}
+	}
+	schema.Nullable = true
+	return schema
+}
+
+// jsonName returns the name a field is encoded under, and whether it is
+// encoded at all
+func jsonName(field reflect.StructField) (name string, omitempty, ok bool) {
+	if !field.IsExported() {
+		return "", false, false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, options, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, slices.Contains(strings.Split(options, ","), "omitempty"), true
+}
+
+// constrain adds the validation of a binding tag to a field's schema and
+// reports whether the field is required
+func constrain(schema *Schema, t reflect.Type, binding string) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		if rule == "dive" {
+			break // The rules after it apply to the elements
+		}
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "oneof":
+			// Like the validator, values may be quoted, e.g. '' for an empty string
+			for _, v := range oneofValue.FindAllString(value, -1) {
+				schema.Enum = append(schema.Enum, strings.Trim(v, "'"))
+			}
+		case "email":
+			schema.Format = "email"
+		case "hexcolor":
+			schema.Pattern = "^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$"
+		case "min", "max":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			bound := map[reflect.Kind]map[string]**int{
+				reflect.Slice:  {"min": &schema.MinItems, "max": &schema.MaxItems},
+				reflect.String: {"min": &schema.MinLength, "max": &schema.MaxLength},
+			}[t.Kind()]
+			if bound == nil {
+				bound = map[string]**int{"min": &schema.Minimum, "max": &schema.Maximum}
+			}
+			*bound[key] = &n
+		}
+	}
+	return required
+}
+
+// parameters describes the query parameters of a struct bound with
+// ShouldBindQuery, such as models.TodoFilter, by their form tags
+func (s schemas) parameters(query any) []Parameter {
+	var params []Parameter
+	for _, field := range reflect.VisibleFields(reflect.TypeOf(query)) {
+		name := field.Tag.Get("form")
+		if field.Anonymous || name == "" || name == "-" {
+			continue
+		}
+		schema := s.forType(field.Type)
+		required := constrain(schema, field.Type, field.Tag.Get("binding"))
+		params = append(params, Parameter{Name: name, In: "query", Required: required, Schema: schema})
+	}
+	return params
+}
@@ -0,0 +1,386 @@
+package recurrence
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"listy-api/core"
+)
+
+// ErrInvalid is wrapped by every error Parse returns
+var ErrInvalid = core.NewError(core.ErrValidation, "invalid recurrence")
+
+// Frequency is an RRULE FREQ value
+type Frequency string
+
+// Supported frequencies; sub-daily ones make no sense for todos
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// WeekdayNum is a BYDAY value: a weekday, optionally the Nth in the month
+// or year (-1 is the last)
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+// dayCodes are the RRULE weekday names, indexed by time.Weekday
+var dayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// searchDays bounds how far Next looks for an occurrence, so rules that can
+// never match again (BYMONTHDAY=31;BYMONTH=2) end the series
+const searchDays = 366 * 100
+
+// Rule is a parsed iCalendar (RFC 5545) recurrence rule. The supported parts
+// are FREQ, INTERVAL, BYDAY, BYMONTHDAY, BYMONTH, COUNT and UNTIL; WKST is
+// accepted and weeks always start on Monday. A rule may be preceded by a
+// DTSTART line, whose time zone decides which day an occurrence falls on.
+type Rule struct {
+	Freq       Frequency
+	Interval   int // Every Interval-th day, week, month or year; at least 1
+	ByDay      []WeekdayNum
+	ByMonthDay []int // 1 to 31, or -1 for the last day of the month and so on
+	ByMonth    []time.Month
+	Count      int        // Occurrences left in the series, 0 for no limit
+	Until      *time.Time // No occurrences after this instant
+	Start      *time.Time // DTSTART; nil when the rule has none
+}
+
+// Parse reads a rule such as "FREQ=WEEKLY;BYDAY=MO", optionally prefixed
+// with "RRULE:" and preceded by a line like "DTSTART;TZID=Europe/Berlin:20260302T090000"
+func Parse(s string) (*Rule, error) {
+	rule := &Rule{Interval: 1}
+	var ruleLine string
+	for _, line := range strings.FieldsFunc(strings.TrimSpace(s), func(r rune) bool { return r == '\n' || r == '\r' }) {
+		line = strings.TrimSpace(line)
+		name, _, _ := strings.Cut(line, ":")
+		name, _, _ = strings.Cut(name, ";")
+		switch strings.ToUpper(name) {
+		case "DTSTART":
+			start, err := parseStart(line)
+			if err != nil {
+				return nil, err
+			}
+			rule.Start = &start
+		case "RRULE":
+			ruleLine = line[len("RRULE:"):]
+		default:
+			if ruleLine != "" || !strings.Contains(line, "=") {
+				return nil, fmt.Errorf("%w: unexpected line %q", ErrInvalid, line)
+			}
+			ruleLine = line
+		}
+	}
+	if ruleLine == "" {
+		return nil, fmt.Errorf("%w: missing RRULE", ErrInvalid)
+	}
+
+	for _, part := range strings.Split(ruleLine, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%w: %q is not NAME=VALUE", ErrInvalid, part)
+		}
+		var err error
+		switch key = strings.ToUpper(strings.TrimSpace(key)); key {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(value))
+			if !slices.Contains([]Frequency{Daily, Weekly, Monthly, Yearly}, rule.Freq) {
+				return nil, fmt.Errorf("%w: FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY", ErrInvalid)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parseNumber(key, value, 1, 1000)
+		case "COUNT":
+			rule.Count, err = parseNumber(key, value, 1, 100000)
+		case "UNTIL":
+			var until time.Time
+			until, err = parseDateTime(value, time.UTC)
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				var weekday WeekdayNum
+				if weekday, err = parseWeekdayNum(day); err != nil {
+					break
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				var n int
+				if n, err = parseNumber(key, day, -31, 31); err != nil {
+					break
+				}
+				if n == 0 {
+					err = fmt.Errorf("%w: BYMONTHDAY cannot be 0", ErrInvalid)
+					break
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(value, ",") {
+				var n int
+				if n, err = parseNumber(key, month, 1, 12); err != nil {
+					break
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+		default:
+			return nil, fmt.Errorf("%w: %s is not supported", ErrInvalid, key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalid)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot be combined", ErrInvalid)
+	}
+	if rule.Freq == Daily || rule.Freq == Weekly {
+		for _, day := range rule.ByDay {
+			if day.N != 0 {
+				return nil, fmt.Errorf("%w: numbered BYDAY needs FREQ=MONTHLY or YEARLY", ErrInvalid)
+			}
+		}
+	}
+	if rule.Freq == Weekly && len(rule.ByMonthDay) > 0 {
+		return nil, fmt.Errorf("%w: BYMONTHDAY cannot be used with FREQ=WEEKLY", ErrInvalid)
+	}
+	return rule, nil
+}
+
+// String returns the rule in the form Parse reads, with its parts in a fixed order
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = strconv.Itoa(int(month))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = dayCodes[day.Weekday]
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	s := strings.Join(parts, ";")
+
+	if r.Start == nil {
+		return s
+	}
+	start := "DTSTART:" + r.Start.UTC().Format("20060102T150405Z")
+	if loc := r.Start.Location(); loc != time.UTC {
+		start = "DTSTART;TZID=" + loc.String() + ":" + r.Start.Format("20060102T150405")
+	}
+	return start + "\nRRULE:" + s
+}
+
+// Next returns the first occurrence strictly after the given instant, in
+// the series that starts at start: intervals are counted from it, and
+// occurrences fall on its time of day and in its time zone. The second
+// result is false once the series has ended. COUNT is not consulted; the
+// caller tracks how many occurrences are left.
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	after = after.In(start.Location())
+	day := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, start.Location())
+	for range searchDays {
+		if r.matches(start, day) {
+			at := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+			if at.After(after) && !at.Before(start) {
+				if r.Until != nil && at.After(*r.Until) {
+					return time.Time{}, false
+				}
+				return at, true
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}, false
+}
+
+// matches reports whether an occurrence of the series starting at start falls on day
+func (r *Rule) matches(start, day time.Time) bool {
+	if periodsBetween(r.Freq, start, day)%r.Interval != 0 {
+		return false
+	}
+
+	if len(r.ByMonth) > 0 && !slices.Contains(r.ByMonth, day.Month()) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !slices.ContainsFunc(r.ByMonthDay, func(n int) bool { return monthDayIs(day, n) }) {
+		return false
+	}
+	if len(r.ByDay) > 0 && !slices.ContainsFunc(r.ByDay, func(w WeekdayNum) bool { return r.weekdayIs(day, w) }) {
+		return false
+	}
+
+	// Without BY parts, the rule repeats what the start falls on
+	switch r.Freq {
+	case Weekly:
+		if len(r.ByDay) == 0 {
+			return day.Weekday() == start.Weekday()
+		}
+	case Monthly:
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			return day.Day() == start.Day()
+		}
+	case Yearly:
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 {
+			return day.Day() == start.Day() && (len(r.ByMonth) > 0 || day.Month() == start.Month())
+		}
+	}
+	return true
+}
+
+// weekdayIs reports whether day is the given weekday, and for a numbered
+// one whether it is the Nth of its month (or of its year for a yearly rule
+// without BYMONTH)
+func (r *Rule) weekdayIs(day time.Time, w WeekdayNum) bool {
+	if day.Weekday() != w.Weekday {
+		return false
+	}
+	if w.N == 0 {
+		return true
+	}
+	if r.Freq == Yearly && len(r.ByMonth) == 0 {
+		if w.N > 0 {
+			return (day.YearDay()-1)/7+1 == w.N
+		}
+		daysInYear := time.Date(day.Year(), time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+		return (daysInYear-day.YearDay())/7+1 == -w.N
+	}
+	if w.N > 0 {
+		return (day.Day()-1)/7+1 == w.N
+	}
+	return (daysIn(day)-day.Day())/7+1 == -w.N
+}
+
+// monthDayIs reports whether day is the nth day of its month, counting back from the end when n < 0
+func monthDayIs(day time.Time, n int) bool {
+	if n > 0 {
+		return day.Day() == n
+	}
+	return day.Day() == daysIn(day)+n+1
+}
+
+// daysIn returns the number of days in day's month
+func daysIn(day time.Time) int {
+	return time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// periodsBetween counts the days, weeks (starting on Monday), months or
+// years from start to day, by calendar date
+func periodsBetween(freq Frequency, start, day time.Time) int {
+	from := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	switch freq {
+	case Weekly:
+		from = from.AddDate(0, 0, -(int(from.Weekday())+6)%7)
+		to = to.AddDate(0, 0, -(int(to.Weekday())+6)%7)
+		return int(to.Sub(from).Hours()/24) / 7
+	case Monthly:
+		return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	case Yearly:
+		return to.Year() - from.Year()
+	default:
+		return int(to.Sub(from).Hours() / 24)
+	}
+}
+
+// parseStart reads a DTSTART line: a UTC time, a floating time (taken as
+// UTC) or a local time with TZID
+func parseStart(line string) (time.Time, error) {
+	params, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: %q has no value", ErrInvalid, line)
+	}
+	loc := time.UTC
+	for _, param := range strings.Split(params, ";")[1:] {
+		name, zone, _ := strings.Cut(param, "=")
+		if !strings.EqualFold(name, "TZID") {
+			return time.Time{}, fmt.Errorf("%w: DTSTART parameter %q is not supported", ErrInvalid, param)
+		}
+		var err error
+		if loc, err = time.LoadLocation(zone); err != nil {
+			return time.Time{}, fmt.Errorf("%w: unknown time zone %q", ErrInvalid, zone)
+		}
+	}
+	return parseDateTime(value, loc)
+}
+
+// parseDateTime reads an iCalendar DATE or DATE-TIME; a trailing Z means UTC, otherwise loc
+func parseDateTime(value string, loc *time.Location) (time.Time, error) {
+	value = strings.ToUpper(value)
+	if strings.HasSuffix(value, "Z") {
+		value, loc = strings.TrimSuffix(value, "Z"), time.UTC
+	}
+	for _, layout := range []string{"20060102T150405", "20060102"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: %q is not a date such as 20260302T090000Z", ErrInvalid, value)
+}
+
+// parseWeekdayNum reads a BYDAY value such as MO, 1MO or -1FR
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("%w: %q is not a weekday such as MO or -1FR", ErrInvalid, s)
+	}
+	weekday := slices.Index(dayCodes, s[len(s)-2:])
+	if weekday < 0 {
+		return WeekdayNum{}, fmt.Errorf("%w: %q is not a weekday such as MO or -1FR", ErrInvalid, s)
+	}
+	day := WeekdayNum{Weekday: time.Weekday(weekday)}
+	if n := strings.TrimPrefix(s[:len(s)-2], "+"); n != "" {
+		var err error
+		if day.N, err = parseNumber("BYDAY", n, -53, 53); err != nil {
+			return WeekdayNum{}, err
+		}
+		if day.N == 0 {
+			return WeekdayNum{}, fmt.Errorf("%w: BYDAY cannot number a weekday 0", ErrInvalid)
+		}
+	}
+	return day, nil
+}
+
+// parseNumber reads an integer rule value between min and max
+func parseNumber(key, value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%w: %s=%s must be a number from %d to %d", ErrInvalid, key, value, min, max)
+	}
+	return n, nil
+}
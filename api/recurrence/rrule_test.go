@@ -0,0 +1,104 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"FREQ=WEEKLY;BYDAY=MO", "FREQ=WEEKLY;BYDAY=MO"},
+		{"RRULE:freq=monthly;bymonthday=1", "FREQ=MONTHLY;BYMONTHDAY=1"},
+		{"FREQ=MONTHLY;BYDAY=-1FR;INTERVAL=1;WKST=MO", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"FREQ=YEARLY;BYMONTH=3;BYDAY=+2SU;COUNT=5", "FREQ=YEARLY;BYMONTH=3;BYDAY=2SU;COUNT=5"},
+		{"FREQ=DAILY;INTERVAL=3;UNTIL=20261231", "FREQ=DAILY;INTERVAL=3;UNTIL=20261231T000000Z"},
+		{"DTSTART;TZID=America/New_York:20260302T090000\nRRULE:FREQ=WEEKLY", "DTSTART;TZID=America/New_York:20260302T090000\nRRULE:FREQ=WEEKLY"},
+		{"DTSTART:20260302T090000Z\r\nFREQ=DAILY", "DTSTART:20260302T090000Z\nRRULE:FREQ=DAILY"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20261231",
+		"FREQ=DAILY;BYSETPOS=1",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"DTSTART;TZID=Mars/Olympus:20260302T090000\nFREQ=DAILY",
+		"every monday",
+	}
+	for _, in := range invalid {
+		if _, err := Parse(in); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalid", in, err)
+		}
+	}
+}
+
+func TestRule_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	// Monday 2 March 2026, 09:00 UTC
+	monday := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		rule         string
+		start, after time.Time
+		want         time.Time // Zero when the series has ended
+	}{
+		{"daily", "FREQ=DAILY", monday, monday, monday.AddDate(0, 0, 1)},
+		{"every third day", "FREQ=DAILY;INTERVAL=3", monday, monday.AddDate(0, 0, 4), monday.AddDate(0, 0, 6)},
+		{"weekdays skip the weekend", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", monday, monday.AddDate(0, 0, 4), monday.AddDate(0, 0, 7)},
+		{"weekly repeats the start's weekday", "FREQ=WEEKLY", monday, monday, monday.AddDate(0, 0, 7)},
+		{"weekly on several days", "FREQ=WEEKLY;BYDAY=MO,TH", monday, monday, monday.AddDate(0, 0, 3)},
+		{"every other week counts from the start", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", monday, monday.AddDate(0, 0, 1), monday.AddDate(0, 0, 14)},
+		{"late completion skips missed occurrences", "FREQ=WEEKLY", monday, monday.AddDate(0, 0, 20), monday.AddDate(0, 0, 21)},
+		{"on the 1st", "FREQ=MONTHLY;BYMONTHDAY=1", monday, monday, time.Date(2026, time.April, 1, 9, 0, 0, 0, time.UTC)},
+		{"monthly repeats the start's day", "FREQ=MONTHLY", monday, monday, time.Date(2026, time.April, 2, 9, 0, 0, 0, time.UTC)},
+		{"the 31st skips short months", "FREQ=MONTHLY;BYMONTHDAY=31", monday, time.Date(2026, time.March, 31, 10, 0, 0, 0, time.UTC), time.Date(2026, time.May, 31, 9, 0, 0, 0, time.UTC)},
+		{"last day of the month", "FREQ=MONTHLY;BYMONTHDAY=-1", monday, monday, time.Date(2026, time.March, 31, 9, 0, 0, 0, time.UTC)},
+		{"last Friday", "FREQ=MONTHLY;BYDAY=-1FR", monday, monday, time.Date(2026, time.March, 27, 9, 0, 0, 0, time.UTC)},
+		{"first Monday", "FREQ=MONTHLY;BYDAY=1MO", monday, monday, time.Date(2026, time.April, 6, 9, 0, 0, 0, time.UTC)},
+		{"yearly", "FREQ=YEARLY", monday, monday, monday.AddDate(1, 0, 0)},
+		{"second Sunday of May", "FREQ=YEARLY;BYMONTH=5;BYDAY=2SU", monday, monday, time.Date(2026, time.May, 10, 9, 0, 0, 0, time.UTC)},
+		{"until ends the series", "FREQ=WEEKLY;UNTIL=20260305T000000Z", monday, monday, time.Time{}},
+		{"never again", "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30", monday, monday, time.Time{}},
+		{"start in the future", "FREQ=DAILY", monday, monday.AddDate(0, 0, -5), monday},
+		// 23:30 in Berlin is still Monday there, though it is 22:30 UTC
+		{"weekday in the start's time zone", "FREQ=WEEKLY;BYDAY=MO", time.Date(2026, time.March, 2, 23, 30, 0, 0, berlin), time.Date(2026, time.March, 2, 23, 30, 0, 0, berlin), time.Date(2026, time.March, 9, 23, 30, 0, 0, berlin)},
+		{"same local time across DST", "FREQ=WEEKLY", time.Date(2026, time.March, 23, 9, 0, 0, 0, berlin), time.Date(2026, time.March, 23, 9, 0, 0, 0, berlin), time.Date(2026, time.March, 30, 9, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.rule)
+		if err != nil {
+			t.Fatalf("%s: Parse(%q) error = %v", tt.name, tt.rule, err)
+		}
+		got, ok := rule.Next(tt.start, tt.after)
+		if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+			t.Errorf("%s: Next() = %v, %v, want %v", tt.name, got, ok, tt.want)
+		}
+		if ok && got.Location() != tt.start.Location() {
+			t.Errorf("%s: Next() location = %v, want %v", tt.name, got.Location(), tt.start.Location())
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"listy-api/models"
+)
+
+// AITasksResult reports the todos created from AI tasks. Warnings name the
+// tasks that could not be created when others could.
+type AITasksResult struct {
+	Message  string
+	Todos    []models.Todo
+	Warnings []string
+}
+
+// BreakDownGoal asks the AI for tasks that reach a goal (POST /api/todos/ai/breakdown)
+func (c *Client) BreakDownGoal(ctx context.Context, goal string) (*models.AITaskBreakdownResponse, error) {
+	return c.breakdown(ctx, "/api/todos/ai/breakdown", goal)
+}
+
+// BreakDownTask asks the AI for subtasks of a task; none come back when it
+// cannot be meaningfully broken down (POST /api/todos/ai/subtasks)
+func (c *Client) BreakDownTask(ctx context.Context, task string) (*models.AITaskBreakdownResponse, error) {
+	return c.breakdown(ctx, "/api/todos/ai/subtasks", task)
+}
+
+// breakdown calls one of the AI routes, which answer with the breakdown
+// itself rather than in the usual data field
+func (c *Client) breakdown(ctx context.Context, path, goal string) (*models.AITaskBreakdownResponse, error) {
+	resp, err := c.send(ctx, request{method: http.MethodPost, path: path, body: models.AITaskBreakdownRequest{Goal: goal}})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var breakdown models.AITaskBreakdownResponse
+	if err := json.NewDecoder(resp.Body).Decode(&breakdown); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &breakdown, nil
+}
+
+// CreateAITasks adds todos for AI tasks, keeping their priority, estimate
+// and category (POST /api/todos/ai/create)
+func (c *Client) CreateAITasks(ctx context.Context, req models.CreateAITasksRequest) (*AITasksResult, error) {
+	resp, err := c.send(ctx, request{method: http.MethodPost, path: "/api/todos/ai/create", body: req})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	env, err := decodeEnvelope(resp)
+	if err != nil {
+		return nil, err
+	}
+	result := &AITasksResult{Message: env.Message, Warnings: env.Warnings}
+	if err := json.Unmarshal(env.Data, &result.Todos); err != nil {
+		return nil, fmt.Errorf("failed to parse response data: %w", err)
+	}
+	return result, nil
+}
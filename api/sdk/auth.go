@@ -0,0 +1,31 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+
+	"listy-api/models"
+)
+
+// Me is the user a token belongs to
+type Me struct {
+	Id          string `json:"id"`
+	Email       string `json:"email"`
+	AuthEnabled bool   `json:"auth_enabled"` // False when the API accepts requests without a token
+}
+
+// Register creates an account and returns a token for it (POST /api/auth/register)
+func (c *Client) Register(ctx context.Context, creds models.Credentials) (*models.AuthResponse, error) {
+	return call[*models.AuthResponse](ctx, c, request{method: http.MethodPost, path: "/api/auth/register", body: creds})
+}
+
+// Login exchanges an email and password for a token (POST /api/auth/login).
+// Pass the token to WithToken to make requests as the user.
+func (c *Client) Login(ctx context.Context, creds models.Credentials) (*models.AuthResponse, error) {
+	return call[*models.AuthResponse](ctx, c, request{method: http.MethodPost, path: "/api/auth/login", body: creds})
+}
+
+// Me returns the user the client's token belongs to (GET /api/auth/me)
+func (c *Client) Me(ctx context.Context) (*Me, error) {
+	return call[*Me](ctx, c, request{method: http.MethodGet, path: "/api/auth/me"})
+}
@@ -0,0 +1,37 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+
+	"listy-api/models"
+)
+
+// Calendar fetches the iCalendar feed of every todo the user can see
+// (GET /api/todos/calendar.ics): pending todos with due dates as events, or
+// every todo as a VTODO with filter.As "todo". Setting filter.Token reads
+// the feed with a calendar token, as calendar apps do.
+func (c *Client) Calendar(ctx context.Context, filter models.CalendarFilter) ([]byte, error) {
+	return c.raw(ctx, calendarRequest("/api/todos/calendar.ics", filter))
+}
+
+// ListCalendar fetches the iCalendar feed of one list (GET /api/lists/:id/calendar.ics)
+func (c *Client) ListCalendar(ctx context.Context, listId string, filter models.CalendarFilter) ([]byte, error) {
+	return c.raw(ctx, calendarRequest(listPath(listId, "calendar.ics"), filter))
+}
+
+func calendarRequest(path string, filter models.CalendarFilter) request {
+	header := http.Header{"Accept": {"text/calendar"}}
+	return request{method: http.MethodGet, path: path, query: Query(filter), header: header}
+}
+
+// IssueCalendarToken issues a token calendar apps can read the feeds with,
+// replacing the previous one (POST /api/calendar/token)
+func (c *Client) IssueCalendarToken(ctx context.Context) (*models.CalendarSubscription, error) {
+	return call[*models.CalendarSubscription](ctx, c, request{method: http.MethodPost, path: "/api/calendar/token"})
+}
+
+// RevokeCalendarToken stops the calendar token working (DELETE /api/calendar/token)
+func (c *Client) RevokeCalendarToken(ctx context.Context) error {
+	return c.exec(ctx, request{method: http.MethodDelete, path: "/api/calendar/token"})
+}
@@ -0,0 +1,373 @@
+// Package sdk is a typed Go client for the listy API. Requests and
+// responses use the API's own models, so the client cannot drift from the
+// handlers: a change to a model changes both sides. Every method takes a
+// context, failed calls return an *Error that matches ErrNotFound,
+// ErrConflict, ErrValidation and the other sentinels with errors.Is, and
+// requests that are safe to repeat are retried when the API is briefly
+// unavailable.
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPageSize is the number of todos requested per page while iterating
+// a listing whose filter sets no limit
+const DefaultPageSize = 100
+
+// Client calls the listy API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithToken sends token as a bearer token with every request
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient makes requests with httpClient instead of one with a 30
+// second timeout. The todo stream ignores its timeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithRetries sets how many times a request that is safe to repeat is
+// retried after a connection error or a 429, 502, 503 or 504 response, and
+// the wait before the first retry, which doubles for each one after. The
+// default is 2 retries after 200ms; 0 turns retrying off.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = retries, backoff }
+}
+
+// New creates a client for the API at baseURL, e.g. "http://localhost:8080"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retries:    2,
+		backoff:    200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithToken returns a copy of the client that sends token instead, e.g.
+// the one returned by Login
+func (c *Client) WithToken(token string) *Client {
+	copy := *c
+	copy.token = token
+	return &copy
+}
+
+// request describes one API call
+type request struct {
+	method  string
+	path    string      // Escaped path, e.g. "/api/todos/3"
+	query   url.Values  // Sent as the query string when non-empty
+	body    any         // JSON-encoded, unless it is a []byte, which is sent as is
+	version int         // Sent as If-Match when non-zero
+	header  http.Header // Extra headers, replacing the defaults
+}
+
+// envelope is the JSON body of every response outside the AI, health,
+// calendar, export and stream routes
+type envelope struct {
+	Success    bool            `json:"success"`
+	Data       json.RawMessage `json:"data"`
+	Message    string          `json:"message"`
+	NextCursor string          `json:"next_cursor"`
+	Warnings   []string        `json:"warnings"`
+}
+
+// idempotent reports whether repeating a request has the same effect as
+// sending it once, so it may be retried
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a response status means the request may
+// succeed if sent again
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// send makes a request, retrying it if it is idempotent and failed in a way
+// worth retrying, and returns the response of a 2xx status. Other statuses
+// are returned as an *Error.
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	var payload []byte
+	contentType := ""
+	switch body := r.body.(type) {
+	case nil:
+	case []byte:
+		payload, contentType = body, "application/octet-stream"
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		payload, contentType = data, "application/json"
+	}
+	target := c.baseURL + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+
+	attempts := 1
+	if idempotent(r.method) {
+		attempts += max(c.retries, 0)
+	}
+	wait := c.backoff
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, r.method, target, bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("Accept", "application/json")
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		if r.version != 0 {
+			req.Header.Set("If-Match", strconv.Quote(strconv.Itoa(r.version)))
+		}
+		for key, value := range r.header {
+			req.Header[key] = value
+		}
+
+		resp, err := c.httpClient.Do(req)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return nil, ctx.Err()
+		case err != nil && attempt < attempts:
+		case err != nil:
+			return nil, fmt.Errorf("failed to connect to API: %w", err)
+		case resp.StatusCode < 300:
+			return resp, nil
+		case retryable(resp.StatusCode) && attempt < attempts:
+			if after, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && after > 0 {
+				wait = time.Duration(after) * time.Second
+			}
+			resp.Body.Close()
+		default:
+			defer resp.Body.Close()
+			return nil, readError(resp)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// call makes a request and decodes the data of its response
+func call[T any](ctx context.Context, c *Client, r request) (T, error) {
+	var out T
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+	env, err := decodeEnvelope(resp)
+	if err != nil {
+		return out, err
+	}
+	if len(env.Data) > 0 && string(env.Data) != "null" {
+		if err := json.Unmarshal(env.Data, &out); err != nil {
+			return out, fmt.Errorf("failed to parse response data: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// exec makes a request whose response carries no data
+func (c *Client) exec(ctx context.Context, r request) error {
+	_, err := call[json.RawMessage](ctx, c, r)
+	return err
+}
+
+// raw makes a request and returns the body of its response as is
+func (c *Client) raw(ctx context.Context, r request) ([]byte, error) {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return data, nil
+}
+
+func decodeEnvelope(resp *http.Response) (envelope, error) {
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return env, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return env, nil
+}
+
+// pages iterates over a paginated listing, fetching a page of the size
+// query asks for (DefaultPageSize by default) per request and following
+// next_cursor until the last page. Iteration stops after the first error.
+func pages[T any](ctx context.Context, c *Client, path string, query url.Values) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		query := cloneValues(query)
+		if query.Get("limit") == "" {
+			query.Set("limit", strconv.Itoa(DefaultPageSize))
+		}
+		for {
+			items, next, err := page[T](ctx, c, path, query)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if next == "" {
+				return
+			}
+			query.Set("cursor", next)
+		}
+	}
+}
+
+// page fetches one page of a listing and the cursor of the next, if any
+func page[T any](ctx context.Context, c *Client, path string, query url.Values) ([]T, string, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	env, err := decodeEnvelope(resp)
+	if err != nil {
+		return nil, "", err
+	}
+	var items []T
+	if err := json.Unmarshal(env.Data, &items); err != nil {
+		return nil, "", fmt.Errorf("failed to parse response data: %w", err)
+	}
+	return items, env.NextCursor, nil
+}
+
+// Collect drains a listing iterator into a slice, stopping at the first error
+func Collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	items := []T{}
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func cloneValues(values url.Values) url.Values {
+	clone := url.Values{}
+	for key, v := range values {
+		clone[key] = append([]string(nil), v...)
+	}
+	return clone
+}
+
+// todoPath joins a todo ID and optional further segments into a path
+func todoPath(id int, rest ...string) string {
+	return "/api/todos/" + strings.Join(append([]string{strconv.Itoa(id)}, rest...), "/")
+}
+
+// listPath joins escaped segments under /api/lists
+func listPath(id string, rest ...string) string {
+	path := "/api/lists/" + url.PathEscape(id)
+	for _, segment := range rest {
+		path += "/" + url.PathEscape(segment)
+	}
+	return path
+}
+
+// Query encodes a query struct of the models package, such as
+// models.TodoFilter, as the client sends it: by its form tags, leaving out
+// zero values, flattening embedded structs and repeating the parameter of
+// each slice element.
+func Query(filter any) url.Values {
+	query := url.Values{}
+	addValues(query, reflect.ValueOf(filter))
+	return query
+}
+
+func addValues(query url.Values, v reflect.Value) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		if field.Anonymous {
+			addValues(query, value)
+			continue
+		}
+		name := field.Tag.Get("form")
+		if name == "" || name == "-" || value.IsZero() {
+			continue
+		}
+		if value.Kind() == reflect.Pointer {
+			value = value.Elem()
+		}
+		switch item := value.Interface().(type) {
+		case time.Time:
+			query.Set(name, item.Format(time.RFC3339Nano))
+		case []string:
+			query[name] = append(query[name], item...)
+		default:
+			query.Set(name, fmt.Sprint(item))
+		}
+	}
+}
+
+// Health checks that the API is up
+func (c *Client) Health(ctx context.Context) error {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/api/health"})
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,97 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"listy-api/models"
+)
+
+// Kinds of failure an *Error matches with errors.Is, by the response's
+// code or, for responses without one (e.g. from a proxy), by status
+var (
+	ErrValidation   = errors.New("invalid request")                             // validation, too_large; 400, 413, 422
+	ErrUnauthorized = errors.New("not signed in")                               // unauthorized; 401
+	ErrForbidden    = errors.New("not allowed")                                 // forbidden; 403
+	ErrNotFound     = errors.New("not found")                                   // not_found; 404
+	ErrConflict     = errors.New("changed by someone else or already existing") // conflict, version_conflict; 409, 412
+	ErrUpstreamAI   = errors.New("AI service failed")                           // upstream_ai
+	ErrUnavailable  = errors.New("temporarily unavailable")                     // unavailable; 429, 502, 503, 504
+)
+
+// codeKinds maps the codes of error responses to the kind they match
+var codeKinds = map[string]error{
+	models.CodeValidation:      ErrValidation,
+	models.CodeTooLarge:        ErrValidation,
+	models.CodeUnauthorized:    ErrUnauthorized,
+	models.CodeForbidden:       ErrForbidden,
+	models.CodeNotFound:        ErrNotFound,
+	models.CodeConflict:        ErrConflict,
+	models.CodeVersionConflict: ErrConflict,
+	models.CodeUpstreamAI:      ErrUpstreamAI,
+	models.CodeUnavailable:     ErrUnavailable,
+}
+
+// Error is an error response from the API
+type Error struct {
+	StatusCode int
+	Code       string   // The response's code, such as models.CodeNotFound; empty when it has none
+	Message    string   // The response's message, or its body or status text when it has none
+	Details    []string // Per-item failures, e.g. of invalid fields or tasks that could not be created
+
+	// The response's data, if any: atomic batches that fail still report
+	// every operation's result
+	Data json.RawMessage
+
+	Header http.Header // The response's headers, e.g. the WWW-Authenticate challenge of a 401
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("API error %d: %s", e.StatusCode, e.Message)
+}
+
+// Is matches the sentinel for the error's code or status, e.g.
+// errors.Is(err, sdk.ErrNotFound)
+func (e *Error) Is(target error) bool {
+	if kind, ok := codeKinds[e.Code]; ok {
+		return target == kind
+	}
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return target == ErrValidation
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusForbidden:
+		return target == ErrForbidden
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return target == ErrConflict
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return target == ErrUnavailable
+	}
+	return false
+}
+
+// readError turns an unsuccessful response into an *Error
+func readError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, Header: resp.Header}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var errResp struct {
+		Error models.Error    `json:"error"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if json.Unmarshal(body, &errResp) == nil {
+		apiErr.Code, apiErr.Message, apiErr.Details, apiErr.Data = errResp.Error.Code, errResp.Error.Message, errResp.Error.Details, errResp.Data
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	return apiErr
+}
@@ -0,0 +1,57 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+
+	"listy-api/models"
+)
+
+// Lists fetches the user's lists and those shared with them, with their
+// todo counts (GET /api/lists)
+func (c *Client) Lists(ctx context.Context, filter models.ListFilter) ([]models.List, error) {
+	return call[[]models.List](ctx, c, request{method: http.MethodGet, path: "/api/lists", query: Query(filter)})
+}
+
+// List fetches a list with its todo counts (GET /api/lists/:id)
+func (c *Client) List(ctx context.Context, id string) (*models.List, error) {
+	return call[*models.List](ctx, c, request{method: http.MethodGet, path: listPath(id)})
+}
+
+// CreateList creates a list, deriving its ID from the name unless req sets
+// one (POST /api/lists)
+func (c *Client) CreateList(ctx context.Context, req models.CreateListRequest) (*models.List, error) {
+	return call[*models.List](ctx, c, request{method: http.MethodPost, path: "/api/lists", body: req})
+}
+
+// UpdateList renames, recolours, reorders or archives a list (PUT /api/lists/:id)
+func (c *Client) UpdateList(ctx context.Context, id string, req models.UpdateListRequest) (*models.List, error) {
+	return call[*models.List](ctx, c, request{method: http.MethodPut, path: listPath(id), body: req})
+}
+
+// DeleteList deletes a list, moving its todos to the main list or, with
+// Todos "delete", to the trash (DELETE /api/lists/:id)
+func (c *Client) DeleteList(ctx context.Context, id string, req models.DeleteListRequest) error {
+	return c.exec(ctx, request{method: http.MethodDelete, path: listPath(id), query: Query(req)})
+}
+
+// Members fetches the users a list is shared with (GET /api/lists/:id/members)
+func (c *Client) Members(ctx context.Context, listId string) ([]models.ListMember, error) {
+	return call[[]models.ListMember](ctx, c, request{method: http.MethodGet, path: listPath(listId, "members")})
+}
+
+// AddMember shares a list with a user (POST /api/lists/:id/members)
+func (c *Client) AddMember(ctx context.Context, listId string, req models.AddMemberRequest) (*models.ListMember, error) {
+	return call[*models.ListMember](ctx, c, request{method: http.MethodPost, path: listPath(listId, "members"), body: req})
+}
+
+// UpdateMember changes a member's role (PUT /api/lists/:id/members/:userId)
+func (c *Client) UpdateMember(ctx context.Context, listId, userId string, req models.UpdateMemberRequest) (*models.ListMember, error) {
+	return call[*models.ListMember](ctx, c, request{method: http.MethodPut, path: listPath(listId, "members", userId), body: req})
+}
+
+// RemoveMember stops sharing a list with a user; members may remove
+// themselves (DELETE /api/lists/:id/members/:userId)
+func (c *Client) RemoveMember(ctx context.Context, listId, userId string) error {
+	return c.exec(ctx, request{method: http.MethodDelete, path: listPath(listId, "members", userId)})
+}
@@ -0,0 +1,162 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"listy-api/models"
+)
+
+func TestQuery(t *testing.T) {
+	done := false
+	since := time.Date(2026, time.March, 4, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		filter any
+		want   string
+	}{
+		{models.TodoFilter{}, ""},
+		{models.TodoFilter{PageRequest: models.PageRequest{Limit: 20, Cursor: "abc"}, Done: &done, Search: "milk"},
+			"cursor=abc&done=false&limit=20&q=milk"},
+		{models.TodoFilter{Tags: []string{"home", "urgent"}, TagMode: "any"}, "tag=home&tag=urgent&tag_mode=any"},
+		{models.ActivityFilter{Since: &since}, "since=2026-03-04T10%3A00%3A00Z"},
+		{models.ImportOptions{Format: "csv", DryRun: true}, "dry_run=true&format=csv"},
+	}
+
+	for _, tt := range tests {
+		if got := Query(tt.filter).Encode(); got != tt.want {
+			t.Errorf("Query(%+v) = %q, want %q", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+		msg    string
+	}{
+		{http.StatusNotFound, `{"error": {"code": "not_found", "message": "todo with ID 9 not found"}}`, ErrNotFound, "todo with ID 9 not found"},
+		{http.StatusPreconditionFailed, `{"error": {"code": "version_conflict", "message": "version mismatch"}}`, ErrConflict, "version mismatch"},
+		{http.StatusConflict, `{"error": {"code": "conflict", "message": "list exists"}}`, ErrConflict, "list exists"},
+		{http.StatusBadRequest, `{"error": {"code": "validation", "message": "item is required"}}`, ErrValidation, "item is required"},
+		{http.StatusBadGateway, `{"error": {"code": "upstream_ai", "message": "no response from OpenAI"}}`, ErrUpstreamAI, "no response from OpenAI"},
+		{http.StatusBadRequest, `{"error": {"code": "not_found", "message": "coded"}}`, ErrNotFound, "coded"},
+		{http.StatusUnauthorized, ``, ErrUnauthorized, "Unauthorized"},
+		{http.StatusForbidden, `not JSON`, ErrForbidden, "not JSON"},
+	}
+
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		}))
+		_, err := New(server.URL).Todo(context.Background(), 9)
+		server.Close()
+
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status || apiErr.Message != tt.msg {
+			t.Errorf("status %d: error = %#v, want an *Error with message %q", tt.status, err, tt.msg)
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("status %d: errors.Is(%v, %v) = false", tt.status, err, tt.want)
+		}
+		if errors.Is(err, ErrUnavailable) {
+			t.Errorf("status %d: error matches ErrUnavailable", tt.status)
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "data": models.Todo{Id: 1, Item: "Buy milk"}})
+	}))
+	defer server.Close()
+	client := New(server.URL, WithRetries(2, time.Millisecond))
+
+	todo, err := client.Todo(context.Background(), 1)
+	if err != nil || todo.Item != "Buy milk" || calls.Load() != 3 {
+		t.Fatalf("Todo() = %v, %v after %d calls; want the todo on the third", todo, err, calls.Load())
+	}
+
+	// Creating is not idempotent, so it is sent once
+	calls.Store(0)
+	if _, err := client.CreateTodo(context.Background(), models.CreateTodoRequest{Item: "Call mom"}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("CreateTodo() error = %v, want ErrUnavailable", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("CreateTodo() sent %d requests, want 1", calls.Load())
+	}
+
+	// Running out of retries returns the last error
+	calls.Store(-10)
+	if _, err := client.Todo(context.Background(), 1); !errors.Is(err, ErrUnavailable) || calls.Load() != -7 {
+		t.Errorf("Todo() error = %v after %d calls, want ErrUnavailable after 3", err, calls.Load()+10)
+	}
+
+	// Cancelling stops the waiting
+	calls.Store(-10)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New(server.URL, WithRetries(5, time.Hour)).Todo(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Todo() with a cancelled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	var got []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r)
+		response := map[string]any{"success": true}
+		switch {
+		case r.URL.Path == "/api/todos" && r.URL.Query().Get("cursor") == "":
+			response["data"] = []models.Todo{{Id: 1}, {Id: 2}}
+			response["next_cursor"] = "next"
+		case r.URL.Path == "/api/todos":
+			response["data"] = []models.Todo{{Id: 3}}
+		default:
+			response["data"] = models.Todo{Id: 3, Version: 5}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+	client := New(server.URL, WithToken("secret"))
+	ctx := context.Background()
+
+	todos, err := Collect(client.Todos(ctx, models.TodoFilter{PageRequest: models.PageRequest{Limit: 2}, List: "main"}))
+	if err != nil || len(todos) != 3 {
+		t.Fatalf("Todos() = %v, %v; want 3 todos over two pages", todos, err)
+	}
+	if query := got[1].URL.Query(); query.Get("cursor") != "next" || query.Get("limit") != "2" || query.Get("list") != "main" {
+		t.Errorf("second page query = %v, want the cursor with the same filter", query)
+	}
+
+	if _, err := client.WithToken("other").UpdateTodo(ctx, 3, 4, models.UpdateTodoRequest{}); err != nil {
+		t.Fatalf("UpdateTodo() error = %v", err)
+	}
+	last := got[len(got)-1]
+	if last.Method != http.MethodPut || last.URL.Path != "/api/todos/3" || last.Header.Get("If-Match") != `"4"` {
+		t.Errorf("UpdateTodo() sent %s %s with If-Match %q", last.Method, last.URL.Path, last.Header.Get("If-Match"))
+	}
+	if last.Header.Get("Authorization") != "Bearer other" || got[0].Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Authorization = %q then %q, want each client's token", got[0].Header.Get("Authorization"), last.Header.Get("Authorization"))
+	}
+
+	if err := client.RemoveMember(ctx, "work/home", "user 1"); err != nil {
+		t.Fatalf("RemoveMember() error = %v", err)
+	}
+	if path := got[len(got)-1].URL.EscapedPath(); path != "/api/lists/work%2Fhome/members/user%201" {
+		t.Errorf("RemoveMember() path = %q, want the IDs escaped", path)
+	}
+}
@@ -0,0 +1,200 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"listy-api/models"
+)
+
+// Todos iterates over the todos matching filter (GET /api/todos), page by
+// page. Limit sets the page size and Cursor where to start.
+func (c *Client) Todos(ctx context.Context, filter models.TodoFilter) iter.Seq2[models.Todo, error] {
+	return pages[models.Todo](ctx, c, "/api/todos", Query(filter))
+}
+
+// TodosPage fetches one page of todos and the cursor of the next, empty on the last page
+func (c *Client) TodosPage(ctx context.Context, filter models.TodoFilter) ([]models.Todo, string, error) {
+	return page[models.Todo](ctx, c, "/api/todos", Query(filter))
+}
+
+// PendingTodos iterates over the todos not yet done (GET /api/todos/pending)
+func (c *Client) PendingTodos(ctx context.Context, page models.PageRequest) iter.Seq2[models.Todo, error] {
+	return pages[models.Todo](ctx, c, "/api/todos/pending", Query(page))
+}
+
+// CompletedTodos iterates over the done todos (GET /api/todos/completed)
+func (c *Client) CompletedTodos(ctx context.Context, page models.PageRequest) iter.Seq2[models.Todo, error] {
+	return pages[models.Todo](ctx, c, "/api/todos/completed", Query(page))
+}
+
+// OverdueTodos iterates over the pending todos past their due date (GET /api/todos/overdue)
+func (c *Client) OverdueTodos(ctx context.Context, filter models.DueFilter) iter.Seq2[models.Todo, error] {
+	return pages[models.Todo](ctx, c, "/api/todos/overdue", Query(filter))
+}
+
+// TodayTodos iterates over the pending todos due today in filter.TZ (GET /api/todos/today)
+func (c *Client) TodayTodos(ctx context.Context, filter models.DueFilter) iter.Seq2[models.Todo, error] {
+	return pages[models.Todo](ctx, c, "/api/todos/today", Query(filter))
+}
+
+// UpcomingTodos iterates over the pending todos due in the next filter.Days
+// days (GET /api/todos/upcoming)
+func (c *Client) UpcomingTodos(ctx context.Context, filter models.DueFilter) iter.Seq2[models.Todo, error] {
+	return pages[models.Todo](ctx, c, "/api/todos/upcoming", Query(filter))
+}
+
+// ListTodos iterates over the todos in a list, or "main" for the main list
+// (GET /api/todos/list/:listId)
+func (c *Client) ListTodos(ctx context.Context, listId string, page models.PageRequest) iter.Seq2[models.Todo, error] {
+	return pages[models.Todo](ctx, c, "/api/todos/list/"+url.PathEscape(listId), Query(page))
+}
+
+// Trash iterates over the deleted todos, most recently deleted first (GET /api/trash)
+func (c *Client) Trash(ctx context.Context, page models.PageRequest) iter.Seq2[models.Todo, error] {
+	return pages[models.Todo](ctx, c, "/api/trash", Query(page))
+}
+
+// Todo fetches a todo with its current version (GET /api/todos/:id)
+func (c *Client) Todo(ctx context.Context, id int) (*models.Todo, error) {
+	return call[*models.Todo](ctx, c, request{method: http.MethodGet, path: todoPath(id)})
+}
+
+// TodoTree fetches a todo with its subtasks nested under Children
+// (GET /api/todos/:id?include=children)
+func (c *Client) TodoTree(ctx context.Context, id int) (*models.Todo, error) {
+	query := Query(models.TodoDetailRequest{Include: "children"})
+	return call[*models.Todo](ctx, c, request{method: http.MethodGet, path: todoPath(id), query: query})
+}
+
+// CreateTodo adds a todo (POST /api/todos)
+func (c *Client) CreateTodo(ctx context.Context, req models.CreateTodoRequest) (*models.Todo, error) {
+	return call[*models.Todo](ctx, c, request{method: http.MethodPost, path: "/api/todos", body: req})
+}
+
+// UpdateTodo changes a todo (PUT /api/todos/:id). A non-zero version makes
+// the change conditional: it fails with ErrConflict if the todo changed since.
+func (c *Client) UpdateTodo(ctx context.Context, id, version int, req models.UpdateTodoRequest) (*models.Todo, error) {
+	return call[*models.Todo](ctx, c, request{method: http.MethodPut, path: todoPath(id), body: req, version: version})
+}
+
+// ToggleTodo flips a todo's done status (PATCH /api/todos/:id/toggle), only
+// while it is at version unless version is 0
+func (c *Client) ToggleTodo(ctx context.Context, id, version int) (*models.Todo, error) {
+	return call[*models.Todo](ctx, c, request{method: http.MethodPatch, path: todoPath(id, "toggle"), version: version})
+}
+
+// DeleteTodo moves a todo to the trash (DELETE /api/todos/:id), only while
+// it is at version unless version is 0
+func (c *Client) DeleteTodo(ctx context.Context, id, version int) error {
+	return c.exec(ctx, request{method: http.MethodDelete, path: todoPath(id), version: version})
+}
+
+// RestoreTodo takes a todo out of the trash (POST /api/todos/:id/restore)
+func (c *Client) RestoreTodo(ctx context.Context, id int) (*models.Todo, error) {
+	return call[*models.Todo](ctx, c, request{method: http.MethodPost, path: todoPath(id, "restore")})
+}
+
+// BatchTodos applies up to models.MaxBatchSize operations in one request
+// (POST /api/todos/batch) and returns each one's result. When an atomic
+// batch fails, the results are returned along with the *Error.
+func (c *Client) BatchTodos(ctx context.Context, req models.BatchRequest) ([]models.BatchResult, error) {
+	results, err := call[[]models.BatchResult](ctx, c, request{method: http.MethodPost, path: "/api/todos/batch", body: req})
+	var apiErr *Error
+	if errors.As(err, &apiErr) && len(apiErr.Data) > 0 {
+		json.Unmarshal(apiErr.Data, &results)
+	}
+	return results, err
+}
+
+// TodoHistory fetches every recorded change to a todo, oldest first (GET /api/todos/:id/history)
+func (c *Client) TodoHistory(ctx context.Context, id int) ([]models.Activity, error) {
+	return call[[]models.Activity](ctx, c, request{method: http.MethodGet, path: todoPath(id, "history")})
+}
+
+// Activity fetches the changes to the todos the user can see, oldest first (GET /api/activity)
+func (c *Client) Activity(ctx context.Context, filter models.ActivityFilter) ([]models.Activity, error) {
+	return call[[]models.Activity](ctx, c, request{method: http.MethodGet, path: "/api/activity", query: Query(filter)})
+}
+
+// AddTags tags a todo (POST /api/todos/:id/tags), only while it is at
+// version unless version is 0
+func (c *Client) AddTags(ctx context.Context, id, version int, tags []string) (*models.Todo, error) {
+	body := models.TagsRequest{Tags: tags}
+	return call[*models.Todo](ctx, c, request{method: http.MethodPost, path: todoPath(id, "tags"), body: body, version: version})
+}
+
+// RemoveTags untags a todo (DELETE /api/todos/:id/tags), only while it is at
+// version unless version is 0
+func (c *Client) RemoveTags(ctx context.Context, id, version int, tags []string) (*models.Todo, error) {
+	body := models.TagsRequest{Tags: tags}
+	return call[*models.Todo](ctx, c, request{method: http.MethodDelete, path: todoPath(id, "tags"), body: body, version: version})
+}
+
+// Tags fetches every tag on the user's todos with its counts (GET /api/tags)
+func (c *Client) Tags(ctx context.Context) ([]models.Tag, error) {
+	return call[[]models.Tag](ctx, c, request{method: http.MethodGet, path: "/api/tags"})
+}
+
+// StreamTodos follows the todo change stream (GET /api/todos/stream),
+// yielding events until the connection ends or ctx is cancelled. Passing
+// the ID of the last event seen as filter.LastEventId resumes after it.
+// The stream is not retried and ignores the HTTP client's timeout.
+func (c *Client) StreamTodos(ctx context.Context, filter models.StreamFilter) iter.Seq2[models.TodoEvent, error] {
+	return func(yield func(models.TodoEvent, error) bool) {
+		stream := *c
+		httpClient := *c.httpClient
+		httpClient.Timeout = 0
+		stream.httpClient, stream.retries = &httpClient, 0
+
+		lastEventId := filter.LastEventId
+		filter.LastEventId = "" // Sent as the header instead
+		header := http.Header{"Accept": {"text/event-stream"}}
+		if lastEventId != "" {
+			header.Set("Last-Event-ID", lastEventId)
+		}
+		r := request{method: http.MethodGet, path: "/api/todos/stream", query: Query(filter), header: header}
+		resp, err := stream.send(ctx, r)
+		if err != nil {
+			if ctx.Err() == nil {
+				yield(models.TodoEvent{}, err)
+			}
+			return
+		}
+		defer resp.Body.Close()
+
+		// Events are "field: value" lines ended by a blank line; lines
+		// starting with ":" are keep-alive comments
+		scanner := bufio.NewScanner(resp.Body)
+		var data string
+		for scanner.Scan() {
+			line := scanner.Text()
+			if value, ok := strings.CutPrefix(line, "data: "); ok {
+				data += value
+				continue
+			}
+			if line != "" || data == "" {
+				continue
+			}
+			var event models.TodoEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				yield(models.TodoEvent{}, fmt.Errorf("failed to parse event: %w", err))
+				return
+			}
+			data = ""
+			if !yield(event, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			yield(models.TodoEvent{}, fmt.Errorf("stream interrupted: %w", err))
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+
+	"listy-api/models"
+)
+
+// Export fetches todos as a json, csv, markdown or todotxt file (GET /api/export)
+func (c *Client) Export(ctx context.Context, filter models.ExportFilter) ([]byte, error) {
+	header := http.Header{"Accept": {"*/*"}}
+	return c.raw(ctx, request{method: http.MethodGet, path: "/api/export", query: Query(filter), header: header})
+}
+
+// Import adds the todos in a file (POST /api/import). With opts.DryRun
+// nothing is saved and the result shows what would be.
+func (c *Client) Import(ctx context.Context, data []byte, opts models.ImportOptions) (*models.ImportResult, error) {
+	return call[*models.ImportResult](ctx, c, request{method: http.MethodPost, path: "/api/import", query: Query(opts), body: data})
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"listy-api/database"
+	"listy-api/models"
+)
+
+// DefaultActivityLimit is how many entries GET /api/activity returns without ?limit
+const DefaultActivityLimit = 100
+
+// untrackedFields are the todo fields left out of activity diffs: ones that
+// never change, change with every write, or are only filled in for responses
+var untrackedFields = []string{"id", "created_at", "version", "owner_id", "previous_id", "subtasks", "children", "next"}
+
+// GetTodoHistory returns every recorded change to a todo the user can see,
+// oldest first. Todos in the trash still have their history.
+func GetTodoHistory(user string, id int) ([]models.Activity, error) {
+	if _, err := accessTodo(user, id, models.RoleViewer); err != nil {
+		if _, trashedErr := loadTodo(user, id, models.RoleViewer, true); trashedErr != nil {
+			return nil, err
+		}
+	}
+	return database.QueryActivity(database.ActivityQuery{TodoId: id})
+}
+
+// GetActivity returns the changes to the todos the user can see, oldest
+// first, optionally only those made after filter.Since
+func GetActivity(user string, filter models.ActivityFilter) ([]models.Activity, error) {
+	shared, _, err := sharedWith(user)
+	if err != nil {
+		return nil, err
+	}
+	limit := filter.Limit
+	if limit == 0 {
+		limit = DefaultActivityLimit
+	}
+	return database.QueryActivity(database.ActivityQuery{Owner: &user, SharedLists: shared, Since: filter.Since, Limit: limit})
+}
+
+// journal holds back the changes made during an atomic batch, so they are
+// announced and logged only once the whole batch has applied, and can be
+// undone if it does not. A nil journal records every change at once.
+type journal struct {
+	changes []change
+	lists   []string // IDs of the lists created for the changes
+}
+
+// change is a recorded change to a todo; before is nil for created todos
+type change struct {
+	user, action string
+	before       *models.Todo
+	after        models.Todo
+}
+
+// record records the change at once without a journal, and holds it back otherwise
+func (j *journal) record(user, action string, before *models.Todo, after models.Todo) error {
+	if j == nil {
+		return record(user, action, before, after)
+	}
+	j.changes = append(j.changes, change{user: user, action: action, before: before, after: after})
+	return nil
+}
+
+// commit records the held back changes in the order they were made. The
+// changes have already been applied, so a change that cannot be recorded
+// does not stop the others; the errors are returned together.
+func (j *journal) commit() error {
+	var errs []error
+	for _, c := range j.changes {
+		if err := record(c.user, c.action, c.before, c.after); err != nil {
+			errs = append(errs, fmt.Errorf("todo %d: %w", c.after.Id, err))
+		}
+	}
+	j.changes, j.lists = nil, nil
+	return errors.Join(errs...)
+}
+
+// createdList notes a list created for a todo, so a rollback deletes it too
+func (j *journal) createdList(id string) {
+	if j != nil {
+		j.lists = append(j.lists, id)
+	}
+}
+
+// rollback undoes the held back changes, most recent first, without
+// recording anything: created todos are deleted for good, changed ones are
+// stored as they were before and created lists are deleted. It returns err
+// along with anything that could not be undone.
+func (j *journal) rollback(err error) error {
+	for i := len(j.changes) - 1; i >= 0; i-- {
+		if undoErr := undoChange(j.changes[i]); undoErr != nil {
+			err = errors.Join(err, fmt.Errorf("undoing an earlier operation failed: %w", undoErr))
+		}
+	}
+	for i := len(j.lists) - 1; i >= 0; i-- {
+		if undoErr := database.DeleteList(j.lists[i]); undoErr != nil && !errors.Is(undoErr, database.ErrListNotFound) {
+			err = errors.Join(err, fmt.Errorf("deleting list %q failed: %w", j.lists[i], undoErr))
+		}
+	}
+	j.changes, j.lists = nil, nil
+	return err
+}
+
+// undoChange deletes a created todo or stores a changed one as it was
+func undoChange(c change) error {
+	if c.before == nil {
+		// Stores that cascade may already have deleted it with its parent
+		if err := database.DeleteTodo(c.after.Id); err != nil && !errors.Is(err, database.ErrNotFound) {
+			return err
+		}
+		return nil
+	}
+	current, err := database.GetTodo(c.before.Id)
+	if err != nil {
+		return err
+	}
+	before := *c.before
+	before.Version = current.Version
+	return database.UpdateTodo(before.Id, before)
+}
+
+// record announces a change the user made to a todo and appends it to the
+// activity log. before is nil for created todos.
+func record(user, action string, before *models.Todo, after models.Todo) error {
+	publish(action, after)
+
+	changes, err := diffTodos(before, after)
+	if err != nil {
+		return err
+	}
+	_, err = database.InsertActivity(models.Activity{
+		TodoId:  after.Id,
+		ActorId: user,
+		Action:  action,
+		Changes: changes,
+		At:      now().UTC(),
+		OwnerId: after.OwnerId,
+		ListId:  after.ListId,
+	})
+	return err
+}
+
+// diffTodos lists the fields that differ between two versions of a todo by
+// JSON name. For a new todo it lists the fields that were set.
+func diffTodos(before *models.Todo, after models.Todo) (map[string]models.FieldChange, error) {
+	old := map[string]any{}
+	if before != nil {
+		var err error
+		if old, err = todoFields(*before); err != nil {
+			return nil, err
+		}
+	}
+	updated, err := todoFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]models.FieldChange{}
+	for name, value := range updated {
+		if before == nil && (value == false || value == "") {
+			continue
+		}
+		if !reflect.DeepEqual(old[name], value) {
+			changes[name] = models.FieldChange{Before: old[name], After: value}
+		}
+	}
+	for name, value := range old {
+		if _, ok := updated[name]; !ok {
+			changes[name] = models.FieldChange{Before: value}
+		}
+	}
+	return changes, nil
+}
+
+// todoFields returns a todo's tracked fields as they are sent to clients
+func todoFields(todo models.Todo) (map[string]any, error) {
+	data, err := json.Marshal(todo)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range untrackedFields {
+		delete(fields, name)
+	}
+	return fields, nil
+}
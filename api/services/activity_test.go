@@ -0,0 +1,101 @@
+package services
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"listy-api/models"
+)
+
+func TestActivity(t *testing.T) {
+	useMemoryStore(t)
+	monday := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	pinClock(t, monday)
+
+	parent, err := CreateTodo("", models.CreateTodoRequest{Item: "Plan trip", AutoComplete: true})
+	if err != nil {
+		t.Fatalf("CreateTodo() error = %v", err)
+	}
+	child, err := CreateTodo("", models.CreateTodoRequest{Item: "Book flights", ParentId: &parent.Id, Priority: "high"})
+	if err != nil {
+		t.Fatalf("CreateTodo(subtask) error = %v", err)
+	}
+	pinClock(t, monday.Add(time.Hour))
+	item := "Book flights to Lisbon"
+	if _, err := UpdateTodo("", child.Id, models.UpdateTodoRequest{Item: &item}, 0); err != nil {
+		t.Fatalf("UpdateTodo() error = %v", err)
+	}
+	// Completing the only subtask completes the parent too
+	if _, err := ToggleTodo("", child.Id, 0); err != nil {
+		t.Fatalf("ToggleTodo() error = %v", err)
+	}
+	if err := DeleteTodo("", parent.Id, 0); err != nil {
+		t.Fatalf("DeleteTodo() error = %v", err)
+	}
+	if _, err := RestoreTodo("", parent.Id, 0); err != nil {
+		t.Fatalf("RestoreTodo() error = %v", err)
+	}
+
+	actions := func(entries []models.Activity) []string {
+		var actions []string
+		for _, entry := range entries {
+			actions = append(actions, entry.Action)
+		}
+		return actions
+	}
+	history, err := GetTodoHistory("", child.Id)
+	if err != nil {
+		t.Fatalf("GetTodoHistory() error = %v", err)
+	}
+	want := []string{models.EventCreated, models.EventUpdated, models.EventToggled, models.EventDeleted, models.EventRestored}
+	if got := actions(history); !slices.Equal(got, want) {
+		t.Fatalf("GetTodoHistory() actions = %v, want %v", got, want)
+	}
+
+	created := map[string]models.FieldChange{
+		"item":      {After: "Book flights"},
+		"priority":  {After: "high"},
+		"parent_id": {After: float64(parent.Id)},
+	}
+	if !reflect.DeepEqual(history[0].Changes, created) {
+		t.Errorf("created changes = %+v, want %+v", history[0].Changes, created)
+	}
+	updated := map[string]models.FieldChange{"item": {Before: "Book flights", After: item}}
+	if !reflect.DeepEqual(history[1].Changes, updated) || !history[1].At.Equal(monday.Add(time.Hour)) {
+		t.Errorf("updated = %+v, want %+v at %v", history[1], updated, monday.Add(time.Hour))
+	}
+	if change := history[3].Changes["deleted_at"]; change.Before != nil || change.After == nil {
+		t.Errorf("deleted changes = %+v, want deleted_at set", history[3].Changes)
+	}
+
+	parentHistory, err := GetTodoHistory("", parent.Id)
+	if err != nil {
+		t.Fatalf("GetTodoHistory(parent) error = %v", err)
+	}
+	want = []string{models.EventCreated, models.EventUpdated, models.EventDeleted, models.EventRestored}
+	if got := actions(parentHistory); !slices.Equal(got, want) {
+		t.Errorf("GetTodoHistory(parent) actions = %v, want the auto-completion recorded", got)
+	}
+
+	all, err := GetActivity("", models.ActivityFilter{})
+	if err != nil {
+		t.Fatalf("GetActivity() error = %v", err)
+	}
+	if len(all) != len(history)+len(parentHistory) {
+		t.Errorf("GetActivity() returned %d entries, want %d", len(all), len(history)+len(parentHistory))
+	}
+	since := monday
+	recent, err := GetActivity("", models.ActivityFilter{Since: &since, Limit: 2})
+	if err != nil {
+		t.Fatalf("GetActivity(since) error = %v", err)
+	}
+	if got := actions(recent); !slices.Equal(got, []string{models.EventUpdated, models.EventToggled}) {
+		t.Errorf("GetActivity(since, limit 2) actions = %v, want the first two changes after creation", got)
+	}
+
+	if _, err := GetTodoHistory("", 99); err == nil {
+		t.Error("GetTodoHistory() of a missing todo succeeded")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"listy-api/models"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/sashabaranov/go-openai"
@@ -16,7 +17,7 @@ func GenerateTaskBreakdown(goal string) ([]models.AITask, error) {
 	// Get API key from environment
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+		return nil, fmt.Errorf("%w: OPENAI_API_KEY environment variable not set", ErrUpstreamAI)
 	}
 
 	// Initialize OpenAI client
@@ -30,12 +31,15 @@ Goal: "%s"
 
 Generate a JSON array of tasks. Each task should have:
 - text: A clear, actionable task description
+- priority: "high", "medium" or "low"
+- estimated_time: A rough duration, e.g. "15 minutes" or "2 hours"
+- category: A short one or two word category, e.g. "setup" or "learning"
 
 Return ONLY a valid JSON array, no other text. Example format:
 [
-  {"text": "Install Go on your system"},
-  {"text": "Read Go documentation basics"},
-  {"text": "Write your first Hello World program"}
+  {"text": "Install Go on your system", "priority": "high", "estimated_time": "15 minutes", "category": "setup"},
+  {"text": "Read Go documentation basics", "priority": "medium", "estimated_time": "1 hour", "category": "learning"},
+  {"text": "Write your first Hello World program", "priority": "medium", "estimated_time": "10 minutes", "category": "practice"}
 ]`, goal)
 
 	// Make API call
@@ -55,11 +59,11 @@ Return ONLY a valid JSON array, no other text. Example format:
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("OpenAI API error: %v", err)
+		return nil, fmt.Errorf("%w: OpenAI API error: %v", ErrUpstreamAI, err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from OpenAI")
+		return nil, fmt.Errorf("%w: no response from OpenAI", ErrUpstreamAI)
 	}
 
 	// Extract the response content
@@ -74,24 +78,41 @@ Return ONLY a valid JSON array, no other text. Example format:
 	// Parse JSON response
 	var tasks []models.AITask
 	if err := json.Unmarshal([]byte(content), &tasks); err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %v. Response was: %s", err, content)
+		return nil, fmt.Errorf("%w: failed to parse AI response: %v. Response was: %s", ErrUpstreamAI, err, content)
 	}
 
 	// Validate tasks
 	if len(tasks) == 0 {
-		return nil, fmt.Errorf("no tasks generated")
+		return nil, fmt.Errorf("%w: no tasks generated", ErrUpstreamAI)
 	}
 
 	return tasks, nil
 }
 
+// AITaskTodo converts an AI-generated task into a create request, keeping its metadata.
+// A priority outside high/medium/low is dropped rather than failing the whole batch.
+func AITaskTodo(task models.AITask, listId *string) models.CreateTodoRequest {
+	priority := strings.ToLower(strings.TrimSpace(task.Priority))
+	if !slices.Contains(models.Priorities, priority) {
+		priority = ""
+	}
+
+	return models.CreateTodoRequest{
+		Item:          task.Text,
+		ListId:        listId,
+		Priority:      priority,
+		EstimatedTime: task.EstimatedTime,
+		Category:      task.Category,
+	}
+}
+
 // GenerateSubtaskBreakdown uses OpenAI to generate subtasks for a specific task
 // It intelligently determines if the task can be broken down into subtasks
 func GenerateSubtaskBreakdown(task string) ([]models.AITask, error) {
 	// Get API key from environment
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
-		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+		return nil, fmt.Errorf("%w: OPENAI_API_KEY environment variable not set", ErrUpstreamAI)
 	}
 
 	// Initialize OpenAI client
@@ -136,11 +157,11 @@ Return ONLY the JSON array:`, task)
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("OpenAI API error: %v", err)
+		return nil, fmt.Errorf("%w: OpenAI API error: %v", ErrUpstreamAI, err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from OpenAI")
+		return nil, fmt.Errorf("%w: no response from OpenAI", ErrUpstreamAI)
 	}
 
 	// Extract the response content
@@ -163,7 +184,7 @@ Return ONLY the JSON array:`, task)
 		if strings.TrimSpace(content) == "[]" {
 			return []models.AITask{}, nil
 		}
-		return nil, fmt.Errorf("no valid JSON array found in response. Response was: %s", content)
+		return nil, fmt.Errorf("%w: no valid JSON array found in response. Response was: %s", ErrUpstreamAI, content)
 	}
 
 	// Extract just the JSON array part
@@ -172,7 +193,7 @@ Return ONLY the JSON array:`, task)
 	// Parse JSON response
 	var tasks []models.AITask
 	if err := json.Unmarshal([]byte(jsonContent), &tasks); err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %v. Extracted JSON: %s, Full response: %s", err, jsonContent, content)
+		return nil, fmt.Errorf("%w: failed to parse AI response: %v. Extracted JSON: %s, Full response: %s", ErrUpstreamAI, err, jsonContent, content)
 	}
 
 	// Empty array is valid - means task cannot be broken down
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"listy-api/auth"
+	"listy-api/database"
+	"listy-api/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrForbidden is returned when the caller has no access to the todo or list, or too little to change it
+	ErrForbidden = errors.New("forbidden")
+	// ErrBadCredentials is returned by Login for an unknown email or wrong password
+	ErrBadCredentials = errors.New("invalid email or password")
+	// ErrUserExists is returned by Register when the email is already registered
+	ErrUserExists = database.ErrUserExists
+)
+
+// Register creates a password account and signs it in
+func Register(req models.Credentials) (*models.AuthResponse, error) {
+	if !auth.CanIssueTokens() {
+		return nil, auth.ErrDisabled
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %v", err)
+	}
+
+	user, err := database.InsertUser(models.User{
+		Id:           uuid.NewString(),
+		Email:        strings.TrimSpace(req.Email),
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now().UTC(),
+	})
+	if errors.Is(err, database.ErrUserExists) {
+		return nil, fmt.Errorf("%w: %s", ErrUserExists, req.Email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return signIn(*user)
+}
+
+// Login checks an email and password and issues a token for the account
+func Login(req models.Credentials) (*models.AuthResponse, error) {
+	if !auth.CanIssueTokens() {
+		return nil, auth.ErrDisabled
+	}
+
+	user, err := database.GetUserByEmail(strings.TrimSpace(req.Email))
+	if errors.Is(err, database.ErrUserNotFound) {
+		return nil, ErrBadCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		return nil, ErrBadCredentials
+	}
+	return signIn(*user)
+}
+
+// signIn issues a token for the user
+func signIn(user models.User) (*models.AuthResponse, error) {
+	token, expires, err := auth.IssueToken(user.Id, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuthResponse{Token: token, ExpiresAt: expires, User: user}, nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"listy-api/core"
+	"listy-api/models"
+)
+
+var (
+	// ErrInvalidBatch is returned for a batch that cannot be run as a whole
+	ErrInvalidBatch = core.NewError(ErrValidation, "invalid batch")
+	// ErrNotApplied marks the operations of an atomic batch that were skipped
+	// or undone because another operation failed
+	ErrNotApplied = errors.New("not applied because another operation in the batch failed")
+)
+
+// BatchOutcome is the result of one batch operation: the created or changed
+// todo (nil for deletes), or why the operation failed
+type BatchOutcome struct {
+	Todo *models.Todo
+	Err  error
+}
+
+// RunBatch applies the operations in order and reports each one's outcome.
+// Without req.Atomic every operation is attempted on its own. With it, every
+// operation is checked before any is applied, and if one still fails the
+// ones already applied are undone without a trace; the error then names the
+// failed operation. Deletes in an atomic batch run last.
+func RunBatch(user string, req models.BatchRequest) ([]BatchOutcome, error) {
+	outcomes := make([]BatchOutcome, len(req.Operations))
+	if !req.Atomic {
+		for i, op := range req.Operations {
+			outcomes[i].Todo, outcomes[i].Err = applyOperation(nil, user, op)
+		}
+		return outcomes, nil
+	}
+
+	// Each todo may only be named once, so checking against the current
+	// state is enough to know the whole batch can be applied
+	seen := make(map[int]bool)
+	for i, op := range req.Operations {
+		if op.Op == models.BatchCreate {
+			continue
+		}
+		if seen[op.Id] {
+			return nil, fmt.Errorf("%w: operation %d: todo %d appears more than once in an atomic batch", ErrInvalidBatch, i+1, op.Id)
+		}
+		seen[op.Id] = true
+	}
+
+	fail := func(i int, err error) ([]BatchOutcome, error) {
+		for j := range outcomes {
+			outcomes[j] = BatchOutcome{Err: ErrNotApplied}
+		}
+		outcomes[i].Err = err
+		return outcomes, fmt.Errorf("operation %d: %w", i+1, err)
+	}
+	for i, op := range req.Operations {
+		if err := checkOperation(user, op); err != nil {
+			return fail(i, err)
+		}
+	}
+
+	order := make([]int, 0, len(req.Operations))
+	var deletes []int
+	for i, op := range req.Operations {
+		if op.Op == models.BatchDelete {
+			deletes = append(deletes, i)
+		} else {
+			order = append(order, i)
+		}
+	}
+	order = append(order, deletes...)
+
+	// Changes are held back until every operation has applied, so a batch
+	// that is rolled back leaves no trace in the trash, activity or events
+	j := &journal{}
+	for _, i := range order {
+		todo, err := applyOperation(j, user, req.Operations[i])
+		if err != nil {
+			return fail(i, j.rollback(err))
+		}
+		outcomes[i].Todo = todo
+	}
+	// Failing the batch now would have a client that retries apply it
+	// twice, so changes missing from the activity log are only logged
+	if err := j.commit(); err != nil {
+		log.Printf("Failed to record the changes of an applied batch: %v", err)
+	}
+	return outcomes, nil
+}
+
+// applyOperation runs one batch operation through the matching service,
+// recording its changes in j
+func applyOperation(j *journal, user string, op models.BatchOperation) (*models.Todo, error) {
+	switch op.Op {
+	case models.BatchCreate:
+		return createTodo(j, user, *op.Todo)
+	case models.BatchUpdate:
+		return retryConflicts(op.Version, func() (*models.Todo, error) { return updateTodo(j, user, op.Id, *op.Changes, op.Version) })
+	case models.BatchToggle:
+		return retryConflicts(op.Version, func() (*models.Todo, error) { return toggleTodo(j, user, op.Id, op.Version) })
+	case models.BatchDelete:
+		_, err := retryConflicts(op.Version, func() (*models.Todo, error) { return nil, deleteTodo(j, user, op.Id, op.Version) })
+		return nil, err
+	case models.BatchRestore:
+		return restoreTodo(j, user, op.Id, op.Version)
+	}
+	return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidBatch, op.Op)
+}
+
+// checkOperation reports why an operation would fail, without changing anything
+func checkOperation(user string, op models.BatchOperation) error {
+	if op.Op == models.BatchCreate {
+		if _, err := normalizeRecurrence(op.Todo.Recurrence); err != nil {
+			return err
+		}
+		if _, err := normalizeTags(op.Todo.Tags); err != nil {
+			return err
+		}
+		listId := op.Todo.ListId
+		if op.Todo.ParentId != nil {
+			parent, err := resolveParent(user, 0, *op.Todo.ParentId)
+			if err != nil {
+				return err
+			}
+			if listId == nil || *listId == "" {
+				listId = parent.ListId
+			}
+		}
+		if listId == nil || *listId == "" {
+			return nil
+		}
+		if err := validateListId(*listId); err != nil {
+			return err
+		}
+		// Missing lists are created along with the todo
+		if _, err := accessList(user, *listId, models.RoleEditor); err != nil && !errors.Is(err, ErrListNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	todo, err := loadTodo(user, op.Id, models.RoleEditor, op.Op == models.BatchRestore)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(todo, op.Version); err != nil {
+		return err
+	}
+	if op.Op == models.BatchRestore {
+		return checkRestore(todo)
+	}
+	if op.Op == models.BatchUpdate && op.Changes.Recurrence != nil {
+		if _, err := normalizeRecurrence(*op.Changes.Recurrence); err != nil {
+			return err
+		}
+	}
+	if op.Op == models.BatchUpdate && op.Changes.Tags != nil {
+		if _, err := normalizeTags(*op.Changes.Tags); err != nil {
+			return err
+		}
+	}
+	if op.Op == models.BatchUpdate && op.Changes.ParentId != nil {
+		if _, err := resolveParent(user, op.Id, *op.Changes.ParentId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
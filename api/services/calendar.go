@@ -0,0 +1,164 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"listy-api/database"
+	"listy-api/ical"
+	"listy-api/models"
+)
+
+// ErrInvalidCalendarToken is returned for a calendar token no user has
+var ErrInvalidCalendarToken = errors.New("invalid calendar token")
+
+// calendarProdId identifies the API as the producer of calendar feeds
+const calendarProdId = "-//Listy//Listy API//EN"
+
+// priorityLevels maps todo priorities to the iCalendar PRIORITY scale, where 1 is highest
+var priorityLevels = map[string]string{"high": "1", "medium": "5", "low": "9"}
+
+// IssueCalendarToken creates a secret for reading the user's calendar feeds
+// without a bearer token, replacing the one issued before. Only its hash is
+// stored, so the token cannot be shown again.
+func IssueCalendarToken(user string) (string, *models.CalendarToken, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("error generating calendar token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	stored := models.CalendarToken{UserId: user, TokenHash: hashCalendarToken(token), CreatedAt: now().UTC()}
+	if err := database.SetCalendarToken(stored); err != nil {
+		return "", nil, err
+	}
+	return token, &stored, nil
+}
+
+// RevokeCalendarToken stops the user's calendar token from working
+func RevokeCalendarToken(user string) error {
+	return database.DeleteCalendarToken(user)
+}
+
+// CalendarUser returns the user a calendar token was issued to
+func CalendarUser(token string) (string, error) {
+	stored, err := database.GetCalendarToken(hashCalendarToken(token))
+	if errors.Is(err, database.ErrCalendarTokenNotFound) {
+		return "", ErrInvalidCalendarToken
+	}
+	if err != nil {
+		return "", err
+	}
+	return stored.UserId, nil
+}
+
+// hashCalendarToken returns the hex SHA-256 a token is stored as
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetCalendar renders the user's todos with due dates, or only those in the
+// list with listId when it is non-empty, as an iCalendar feed. Todos become
+// VEVENTs at their due time, leaving out completed ones, or VTODOs with
+// their status when filter.As is "todo".
+func GetCalendar(user, listId string, filter models.CalendarFilter) (string, error) {
+	name := "Listy"
+	q := database.TodoQuery{Sort: []database.SortField{{Field: "due_at"}, {Field: "id"}}}
+	if listId != "" {
+		list, err := accessList(user, listId, models.RoleViewer)
+		if err != nil {
+			return "", err
+		}
+		name += ": " + list.Name
+		q.FilterList, q.ListId = true, &list.Id
+	} else {
+		shared, _, err := sharedWith(user)
+		if err != nil {
+			return "", err
+		}
+		q.Owner, q.SharedLists = &user, shared
+	}
+	asTodos := filter.As == "todo"
+	if !asTodos {
+		pending := false
+		q.Done = &pending
+	}
+
+	todos, err := database.QueryTodos(q)
+	if err != nil {
+		return "", err
+	}
+
+	calendar := ical.Component{Name: "VCALENDAR"}
+	calendar.Add("VERSION", "2.0")
+	calendar.Add("PRODID", calendarProdId)
+	calendar.Add("CALSCALE", "GREGORIAN")
+	calendar.Add("X-WR-CALNAME", ical.Text(name))
+	stamp := ical.DateTime(now())
+	for _, todo := range todos {
+		if todo.DueAt != nil {
+			calendar.Components = append(calendar.Components, todoComponent(todo, asTodos, stamp))
+		}
+	}
+	return calendar.String(), nil
+}
+
+// todoComponent renders a todo with a due date as a VTODO or VEVENT
+func todoComponent(todo models.Todo, asTodo bool, stamp string) ical.Component {
+	c := ical.Component{Name: "VEVENT"}
+	if asTodo {
+		c.Name = "VTODO"
+	}
+	c.Add("UID", todoUID(todo.Id))
+	c.Add("DTSTAMP", stamp)
+	c.Add("CREATED", ical.DateTime(todo.CreatedAt))
+	c.Add("SEQUENCE", strconv.Itoa(max(todo.Version-1, 0)))
+	c.Add("SUMMARY", ical.Text(todo.Item))
+	if asTodo {
+		c.Add("DUE", ical.DateTime(*todo.DueAt))
+		if todo.Done {
+			c.Add("STATUS", "COMPLETED")
+		} else {
+			c.Add("STATUS", "NEEDS-ACTION")
+		}
+		if todo.ParentId != nil {
+			c.Add("RELATED-TO", todoUID(*todo.ParentId))
+		}
+	} else {
+		// Without DTEND the event takes no time, like a deadline
+		c.Add("DTSTART", ical.DateTime(*todo.DueAt))
+	}
+	if level, ok := priorityLevels[todo.Priority]; ok {
+		c.Add("PRIORITY", level)
+	}
+	if todo.EstimatedTime != "" {
+		c.Add("DESCRIPTION", ical.Text("Estimated time: "+todo.EstimatedTime))
+	}
+	var categories []string
+	if todo.Category != "" {
+		categories = append(categories, todo.Category)
+	}
+	if categories = append(categories, todo.Tags...); len(categories) > 0 {
+		c.Add("CATEGORIES", ical.TextList(categories))
+	}
+
+	if todo.RemindAt != nil && !todo.Done {
+		alarm := ical.Component{Name: "VALARM"}
+		alarm.Add("ACTION", "DISPLAY")
+		alarm.Add("DESCRIPTION", ical.Text(todo.Item))
+		alarm.Add("TRIGGER", ical.DateTime(*todo.RemindAt), "VALUE=DATE-TIME")
+		c.Components = append(c.Components, alarm)
+	}
+	return c
+}
+
+// todoUID is the UID of a todo's calendar entry, stable across feeds
+func todoUID(id int) string {
+	return "todo-" + strconv.Itoa(id) + "@listy"
+}
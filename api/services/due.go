@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"listy-api/database"
+	"listy-api/models"
+)
+
+// DefaultUpcomingDays is the upcoming window when no days parameter is given
+const DefaultUpcomingDays = 7
+
+// pendingSort lists pending work by due date, undated todos last
+var pendingSort = []database.SortField{{Field: "due_at"}}
+
+// now is replaced in tests to pin the clock
+var now = time.Now
+
+// GetOverdueTodos returns the pending todos visible to the user whose due date has passed
+func GetOverdueTodos(user string, filter models.DueFilter) ([]models.Todo, string, error) {
+	current := now()
+	return queryDue(user, nil, &current, filter.PageRequest)
+}
+
+// GetTodayTodos returns the pending todos visible to the user due during the current day in filter.TZ
+func GetTodayTodos(user string, filter models.DueFilter) ([]models.Todo, string, error) {
+	loc, err := parseLocation(filter.TZ)
+	if err != nil {
+		return nil, "", err
+	}
+
+	current := now().In(loc)
+	start := time.Date(current.Year(), current.Month(), current.Day(), 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1)
+	return queryDue(user, &start, &end, filter.PageRequest)
+}
+
+// GetUpcomingTodos returns the pending todos visible to the user due within the next filter.Days days
+func GetUpcomingTodos(user string, filter models.DueFilter) ([]models.Todo, string, error) {
+	days := filter.Days
+	if days == 0 {
+		days = DefaultUpcomingDays
+	}
+
+	start := now()
+	end := start.AddDate(0, 0, days)
+	return queryDue(user, &start, &end, filter.PageRequest)
+}
+
+// queryDue pages through the pending todos visible to the user due in [after, before), soonest first
+func queryDue(user string, after, before *time.Time, page models.PageRequest) ([]models.Todo, string, error) {
+	done := false
+	return queryVisible(user, database.TodoQuery{
+		Done:      &done,
+		DueAfter:  after,
+		DueBefore: before,
+		Sort:      pendingSort,
+	}, page)
+}
+
+// parseLocation accepts an IANA zone name or a "+hh:mm" UTC offset; empty means UTC
+func parseLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+
+	if offset, err := time.Parse("-07:00", tz); err == nil {
+		_, seconds := offset.Zone()
+		return time.FixedZone(tz, seconds), nil
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidQuery, tz)
+	}
+	return loc, nil
+}
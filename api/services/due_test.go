@@ -0,0 +1,121 @@
+package services
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"listy-api/database"
+	"listy-api/models"
+)
+
+// pinClock fixes now() for the duration of a test
+func pinClock(t *testing.T, at time.Time) {
+	previous := now
+	now = func() time.Time { return at }
+	t.Cleanup(func() { now = previous })
+}
+
+// useMemoryStore swaps in an empty in-memory store for the duration of a test
+func useMemoryStore(t *testing.T) {
+	previous := database.Store
+	database.Store = database.NewMemoryStore()
+	t.Cleanup(func() { database.Store = previous })
+}
+
+func todoIDs(todos []models.Todo) []int {
+	var ids []int
+	for _, todo := range todos {
+		ids = append(ids, todo.Id)
+	}
+	return ids
+}
+
+func TestDueViews(t *testing.T) {
+	useMemoryStore(t)
+	// 22:00 UTC on Friday is already Saturday in Tokyo
+	current := time.Date(2026, time.March, 6, 22, 0, 0, 0, time.UTC)
+	pinClock(t, current)
+
+	at := func(d time.Duration) *time.Time {
+		due := current.Add(d)
+		return &due
+	}
+	seed := []models.CreateTodoRequest{
+		{Item: "No due date"},
+		{Item: "Overdue since this morning", DueAt: at(-20 * time.Hour)},
+		{Item: "Overdue by an hour", DueAt: at(-time.Hour)},
+		{Item: "Due in an hour", DueAt: at(time.Hour)},
+		{Item: "Due in three days", DueAt: at(72 * time.Hour)},
+		{Item: "Due in two weeks", DueAt: at(14 * 24 * time.Hour)},
+		{Item: "Done and overdue", DueAt: at(-2 * time.Hour)},
+	}
+	for _, req := range seed {
+		if _, err := CreateTodo("", req); err != nil {
+			t.Fatalf("CreateTodo() error = %v", err)
+		}
+	}
+	done := true
+	if _, err := UpdateTodo("", 7, models.UpdateTodoRequest{Done: &done}, 0); err != nil {
+		t.Fatalf("UpdateTodo() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		view    func(string, models.DueFilter) ([]models.Todo, string, error)
+		filter  models.DueFilter
+		wantIDs []int
+	}{
+		{"overdue", GetOverdueTodos, models.DueFilter{}, []int{2, 3}},
+		{"today in UTC", GetTodayTodos, models.DueFilter{}, []int{2, 3, 4}},
+		{"today in Tokyo", GetTodayTodos, models.DueFilter{TZ: "Asia/Tokyo"}, []int{3, 4}},
+		{"today by offset", GetTodayTodos, models.DueFilter{TZ: "+09:00"}, []int{3, 4}},
+		{"upcoming week", GetUpcomingTodos, models.DueFilter{}, []int{4, 5}},
+		{"upcoming month", GetUpcomingTodos, models.DueFilter{Days: 30}, []int{4, 5, 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todos, _, err := tt.view("", tt.filter)
+			if err != nil {
+				t.Fatalf("view error = %v", err)
+			}
+			if ids := todoIDs(todos); !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("IDs = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+
+	pending, _, err := GetPendingTodos("", models.PageRequest{})
+	if err != nil {
+		t.Fatalf("GetPendingTodos() error = %v", err)
+	}
+	if ids, want := todoIDs(pending), []int{2, 3, 4, 5, 6, 1}; !slices.Equal(ids, want) {
+		t.Errorf("GetPendingTodos() IDs = %v, want %v (by due date, undated last)", ids, want)
+	}
+
+	if _, _, err := GetTodayTodos("", models.DueFilter{TZ: "Mars/Olympus"}); err == nil {
+		t.Error("GetTodayTodos() with unknown zone should fail")
+	}
+}
+
+func TestUpdateTodo_DueDates(t *testing.T) {
+	useMemoryStore(t)
+
+	due := time.Date(2026, time.March, 6, 17, 0, 0, 0, time.FixedZone("CET", 3600))
+	todo, err := CreateTodo("", models.CreateTodoRequest{Item: "Pay rent", DueAt: &due, RemindAt: &due})
+	if err != nil {
+		t.Fatalf("CreateTodo() error = %v", err)
+	}
+
+	updated, err := UpdateTodo("", todo.Id, models.UpdateTodoRequest{ClearDueAt: true}, 0)
+	if err != nil {
+		t.Fatalf("UpdateTodo() error = %v", err)
+	}
+	if updated.DueAt != nil {
+		t.Errorf("UpdateTodo() due_at = %v, want cleared", updated.DueAt)
+	}
+	if updated.RemindAt == nil || !updated.RemindAt.Equal(due) {
+		t.Errorf("UpdateTodo() remind_at = %v, want unchanged %v", updated.RemindAt, due)
+	}
+}
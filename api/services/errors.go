@@ -0,0 +1,19 @@
+package services
+
+import "listy-api/core"
+
+// The kinds of error the services fail with, besides ErrForbidden and the
+// errors of signing in. Every sentinel error of the services is of one of
+// them, e.g. errors.Is(ErrListNotFound, ErrNotFound); errors of no kind are
+// failures of the store.
+var (
+	// ErrNotFound is the kind of errors for todos, lists, members and users that do not exist
+	ErrNotFound = core.ErrNotFound
+	// ErrValidation is the kind of errors for requests that can never succeed as they are
+	ErrValidation = core.ErrValidation
+	// ErrConflict is the kind of errors for changes that clash with the stored state,
+	// such as ErrVersionConflict and ErrListExists
+	ErrConflict = core.ErrConflict
+	// ErrUpstreamAI is the kind of errors for AI breakdowns OpenAI did not deliver
+	ErrUpstreamAI = core.ErrUpstreamAI
+)
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"listy-api/core"
+	"listy-api/database"
+	"listy-api/models"
+)
+
+var (
+	// ErrListNotFound is returned when no list has the requested ID
+	ErrListNotFound = database.ErrListNotFound
+	// ErrListExists is returned when creating a list whose ID is taken
+	ErrListExists = database.ErrListExists
+	// ErrInvalidList is returned for list IDs that cannot be used in URLs
+	ErrInvalidList = core.NewError(ErrValidation, "invalid list")
+)
+
+// GetLists returns the user's own lists and those shared with them, with
+// their pending and done counts, ordered by position
+func GetLists(user string, filter models.ListFilter) ([]models.List, error) {
+	shared, roles, err := sharedWith(user)
+	if err != nil {
+		return nil, err
+	}
+
+	lists, err := database.QueryLists(database.ListQuery{OwnerId: user, SharedIds: shared, IncludeArchived: filter.Archived})
+	if err != nil {
+		return nil, err
+	}
+	for i := range lists {
+		if lists[i].OwnerId == user {
+			lists[i].Role = models.RoleOwner
+		} else {
+			lists[i].Role = roles[lists[i].Id]
+		}
+	}
+	return lists, nil
+}
+
+// GetList returns a single list with its pending and done counts,
+// failing with ErrForbidden unless it is the user's or shared with them
+func GetList(user, id string) (*models.List, error) {
+	return accessList(user, id, models.RoleViewer)
+}
+
+// CreateList creates a list owned by the user; without an explicit ID one is derived
+// from the name. List IDs are shared by all users, so an ID another user has
+// taken fails with ErrListExists.
+func CreateList(user string, req models.CreateListRequest) (*models.List, error) {
+	name := strings.TrimSpace(req.Name)
+	id := strings.TrimSpace(req.Id)
+	if id == "" {
+		id = listIdFromName(name)
+	}
+	if err := validateListId(id); err != nil {
+		return nil, err
+	}
+
+	list, err := database.InsertList(models.List{
+		Id:        id,
+		Name:      name,
+		Color:     req.Color,
+		Position:  req.Position,
+		CreatedAt: time.Now().UTC(),
+		OwnerId:   user,
+	})
+	if errors.Is(err, database.ErrListExists) {
+		return nil, fmt.Errorf("%w: %q", ErrListExists, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	list.Role = models.RoleOwner
+	return list, nil
+}
+
+// UpdateList renames, recolours, reorders or archives a list. Only its owner may.
+func UpdateList(user, id string, req models.UpdateListRequest) (*models.List, error) {
+	list, err := accessList(user, id, models.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		list.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Color != nil {
+		list.Color = *req.Color
+	}
+	if req.Position != nil {
+		list.Position = *req.Position
+	}
+	if req.Archived != nil {
+		list.Archived = *req.Archived
+	}
+
+	if err := database.UpdateList(*list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DeleteList deletes a list and stops sharing it. Its todos move to the
+// owner's main list, and with req.Todos "delete" they go on to the trash,
+// so restoring them puts them in the main list. Only the list's owner may
+// delete it.
+func DeleteList(user, id string, req models.DeleteListRequest) error {
+	if _, err := accessList(user, id, models.RoleOwner); err != nil {
+		return err
+	}
+	// Load the todos first so their watchers can be told where they went
+	todos, err := database.QueryTodos(database.TodoQuery{FilterList: true, ListId: &id})
+	if err != nil {
+		return err
+	}
+
+	err = database.DeleteList(id)
+	if errors.Is(err, database.ErrListNotFound) {
+		return fmt.Errorf("%w: %q", ErrListNotFound, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	deletedAt := now().UTC()
+	for _, todo := range todos {
+		before := todo
+		todo.ListId = nil
+		todo.Version++
+		if err := record(user, models.EventUpdated, &before, todo); err != nil {
+			return err
+		}
+		if req.Todos != "delete" {
+			continue
+		}
+		if _, err := markDeleted(nil, user, todo, &deletedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureList creates the list a todo is being added to if it does not exist
+// yet, so clients that only send list_id keep working. It returns the user
+// the todo belongs to: the list's owner, or the user for the main list.
+// Adding to a list the user cannot edit fails with ErrForbidden. A list it
+// creates is noted in j.
+func ensureList(j *journal, user string, listId *string) (string, error) {
+	if listId == nil {
+		return user, nil
+	}
+	if err := validateListId(*listId); err != nil {
+		return "", err
+	}
+
+	list, err := accessList(user, *listId, models.RoleEditor)
+	if err == nil {
+		return list.OwnerId, nil
+	}
+	if !errors.Is(err, ErrListNotFound) {
+		return "", err
+	}
+	_, err = database.InsertList(models.List{
+		Id:        *listId,
+		Name:      listNameFromId(*listId),
+		CreatedAt: time.Now().UTC(),
+		OwnerId:   user,
+	})
+	if errors.Is(err, database.ErrListExists) {
+		// Created concurrently, possibly by another user
+		if list, err = accessList(user, *listId, models.RoleEditor); err != nil {
+			return "", err
+		}
+		return list.OwnerId, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	j.createdList(*listId)
+	return user, nil
+}
+
+// validateListId rejects IDs that clash with the main list or cannot be used as a path segment
+func validateListId(id string) error {
+	switch {
+	case id == "":
+		return fmt.Errorf("%w: list ID must not be empty", ErrInvalidList)
+	case id == "main":
+		return fmt.Errorf("%w: %q is reserved for the main list", ErrInvalidList, id)
+	case strings.ContainsAny(id, "/?#%"):
+		return fmt.Errorf("%w: list ID %q must not contain / ? # or %%", ErrInvalidList, id)
+	}
+	return nil
+}
+
+// listIdFromName turns "Learn Go!" into "learn_go"
+func listIdFromName(name string) string {
+	var id strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if underscore && id.Len() > 0 {
+				id.WriteByte('_')
+			}
+			id.WriteRune(r)
+			underscore = false
+		} else {
+			underscore = true
+		}
+	}
+	return id.String()
+}
+
+// listNameFromId turns "learn_go" into "Learn Go", matching how the web UI
+// displayed list IDs before lists had names
+func listNameFromId(id string) string {
+	words := strings.Split(id, "_")
+	for i, word := range words {
+		if r, size := utf8.DecodeRuneInString(word); size > 0 {
+			words[i] = string(unicode.ToUpper(r)) + word[size:]
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"listy-api/core"
+	"listy-api/database"
+	"listy-api/models"
+)
+
+var (
+	// ErrMemberNotFound is returned when the user is not a member of the list
+	ErrMemberNotFound = database.ErrMemberNotFound
+	// ErrMemberExists is returned when inviting a user who is already a member
+	ErrMemberExists = database.ErrMemberExists
+	// ErrUserNotFound is returned when inviting an email nobody has registered
+	ErrUserNotFound = database.ErrUserNotFound
+	// ErrInvalidMember is returned for invitations that name nobody, or the list's owner
+	ErrInvalidMember = core.NewError(ErrValidation, "invalid member")
+)
+
+// roleRank orders roles so checks can ask for "at least editor"
+var roleRank = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+// GetMembers returns the members of a list; anyone the list is shared with may see them
+func GetMembers(user, listId string) ([]models.ListMember, error) {
+	if _, err := accessList(user, listId, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return database.QueryMembers(listId)
+}
+
+// AddMember shares a list with another user by email or user ID. Only the list's owner may.
+func AddMember(user, listId string, req models.AddMemberRequest) (*models.ListMember, error) {
+	list, err := accessList(user, listId, models.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	member := models.ListMember{ListId: listId, Role: req.Role, CreatedAt: time.Now().UTC()}
+	email, userId := strings.TrimSpace(req.Email), strings.TrimSpace(req.UserId)
+	switch {
+	case email != "" && userId != "":
+		return nil, fmt.Errorf("%w: give email or user_id, not both", ErrInvalidMember)
+	case email != "":
+		invited, err := database.GetUserByEmail(email)
+		if errors.Is(err, database.ErrUserNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		if err != nil {
+			return nil, err
+		}
+		member.UserId, member.Email = invited.Id, invited.Email
+	case userId != "":
+		member.UserId = userId
+	default:
+		return nil, fmt.Errorf("%w: email or user_id is required", ErrInvalidMember)
+	}
+	if member.UserId == list.OwnerId {
+		return nil, fmt.Errorf("%w: the owner of list %q cannot also be a member", ErrInvalidMember, listId)
+	}
+
+	created, err := database.InsertMember(member)
+	if errors.Is(err, database.ErrMemberExists) {
+		return nil, fmt.Errorf("%w: %s in list %q", ErrMemberExists, member.UserId, listId)
+	}
+	return created, err
+}
+
+// UpdateMember changes a member's role. Only the list's owner may.
+func UpdateMember(user, listId, memberId string, req models.UpdateMemberRequest) (*models.ListMember, error) {
+	if _, err := accessList(user, listId, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	member, err := getMember(listId, memberId)
+	if err != nil {
+		return nil, err
+	}
+	member.Role = req.Role
+	if err := database.UpdateMember(*member); err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// RemoveMember stops sharing a list with a member. The list's owner may
+// remove anyone; members may remove themselves to leave the list.
+func RemoveMember(user, listId, memberId string) error {
+	minRole := models.RoleOwner
+	if memberId == user {
+		minRole = models.RoleViewer
+	}
+	if _, err := accessList(user, listId, minRole); err != nil {
+		return err
+	}
+
+	if _, err := getMember(listId, memberId); err != nil {
+		return err
+	}
+	return database.DeleteMember(listId, memberId)
+}
+
+// getMember loads a membership, wrapping ErrMemberNotFound with the IDs
+func getMember(listId, userId string) (*models.ListMember, error) {
+	member, err := database.GetMember(listId, userId)
+	if errors.Is(err, database.ErrMemberNotFound) {
+		return nil, fmt.Errorf("%w: %s in list %q", ErrMemberNotFound, userId, listId)
+	}
+	return member, err
+}
+
+// accessList loads a list with the user's role in it, failing with
+// ErrForbidden unless the role is at least minRole
+func accessList(user, id, minRole string) (*models.List, error) {
+	list, err := database.GetList(id)
+	if errors.Is(err, database.ErrListNotFound) {
+		return nil, fmt.Errorf("%w: %q", ErrListNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list.Role, err = listRole(user, list.Id, list.OwnerId)
+	if err != nil {
+		return nil, err
+	}
+	if roleRank[list.Role] < roleRank[minRole] {
+		if list.Role == "" {
+			return nil, fmt.Errorf("%w: list %q belongs to another user", ErrForbidden, id)
+		}
+		return nil, fmt.Errorf("%w: list %q needs %s access, you are a %s", ErrForbidden, id, minRole, list.Role)
+	}
+	return list, nil
+}
+
+// listRole returns the user's role in a list, or "" if it is not shared with them
+func listRole(user, listId, ownerId string) (string, error) {
+	if ownerId == user {
+		return models.RoleOwner, nil
+	}
+	member, err := database.GetMember(listId, user)
+	if errors.Is(err, database.ErrMemberNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
+
+// sharedWith returns the IDs of the lists shared with the user, with their roles
+func sharedWith(user string) ([]string, map[string]string, error) {
+	memberships, err := database.QueryMemberships(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids := make([]string, len(memberships))
+	roles := make(map[string]string, len(memberships))
+	for i, member := range memberships {
+		ids[i] = member.ListId
+		roles[member.ListId] = member.Role
+	}
+	return ids, roles, nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"listy-api/core"
+	"listy-api/database"
+	"listy-api/models"
+)
+
+// DefaultPageSize is used when a cursor is supplied without a limit
+const DefaultPageSize = 50
+
+// ErrInvalidQuery is wrapped by errors caused by bad listing parameters
+var ErrInvalidQuery = core.NewError(ErrValidation, "invalid query")
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = fmt.Errorf("%w: invalid cursor", ErrInvalidQuery)
+
+// cursorPrefix versions the cursor format so it can change without breaking old clients silently
+const cursorPrefix = "o1:"
+
+// encodeCursor turns an offset into an opaque cursor string
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// decodeCursor turns a cursor back into an offset; an empty cursor is offset 0
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, ErrInvalidCursor
+	}
+
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return offset, nil
+}
+
+// queryPage runs q restricted to the requested page and rolls up subtask progress.
+// It returns the todos and the cursor for the next page, which is empty on the last page.
+func queryPage(q database.TodoQuery, page models.PageRequest) ([]models.Todo, string, error) {
+	offset, err := decodeCursor(page.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limit := page.Limit
+	if limit == 0 && page.Cursor != "" {
+		limit = DefaultPageSize
+	}
+	if limit == 0 {
+		todos, err := database.QueryTodos(q)
+		if err != nil {
+			return nil, "", err
+		}
+		return todos, "", withSubtaskProgress(todos)
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	q.Limit = limit + 1
+	q.Offset = offset
+	todos, err := database.QueryTodos(q)
+	if err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(todos) > limit {
+		todos, nextCursor = todos[:limit], encodeCursor(offset+limit)
+	}
+	return todos, nextCursor, withSubtaskProgress(todos)
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"strings"
+
+	"listy-api/database"
+	"listy-api/models"
+	"listy-api/recurrence"
+)
+
+// ErrInvalidRecurrence is returned for a recurrence that is not a supported RRULE
+var ErrInvalidRecurrence = recurrence.ErrInvalid
+
+// normalizeRecurrence checks a recurrence rule and returns it in canonical
+// form; an empty rule stays empty
+func normalizeRecurrence(rule string) (string, error) {
+	if strings.TrimSpace(rule) == "" {
+		return "", nil
+	}
+	parsed, err := recurrence.Parse(rule)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
+
+// nextOccurrence is called with a recurring todo that is about to be saved
+// as done. It moves the rule off the todo, so the series goes on from the
+// new occurrence alone, and returns the occurrence to add once the todo is
+// saved: due at the first time the rule allows after both the old due date
+// and now, so a late completion skips the occurrences that were missed.
+// Without a due date or DTSTART the series starts when the todo is
+// completed. It returns nil when the series has ended.
+func nextOccurrence(todo *models.Todo) (*models.Todo, error) {
+	if todo.Recurrence == "" {
+		return nil, nil
+	}
+	rule, err := recurrence.Parse(todo.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	todo.Recurrence = ""
+
+	completedAt := now().UTC()
+	start, after := completedAt, completedAt
+	if todo.DueAt != nil {
+		start = *todo.DueAt
+		if todo.DueAt.After(after) {
+			after = *todo.DueAt
+		}
+	}
+	if rule.Start != nil {
+		start = *rule.Start
+	}
+	if rule.Count == 1 {
+		return nil, nil
+	}
+	due, ok := rule.Next(start, after)
+	if !ok {
+		return nil, nil
+	}
+	if rule.Count > 1 {
+		rule.Count--
+	}
+	due = due.UTC()
+	previousId := todo.Id
+
+	next := models.Todo{
+		Item:      todo.Item,
+		ListId:    todo.ListId,
+		CreatedAt: completedAt,
+		OwnerId:   todo.OwnerId,
+		DueAt:     &due,
+
+		Priority:      todo.Priority,
+		EstimatedTime: todo.EstimatedTime,
+		Category:      todo.Category,
+
+		ParentId:     todo.ParentId,
+		AutoComplete: todo.AutoComplete,
+		Recurrence:   rule.String(),
+		PreviousId:   &previousId,
+		Tags:         todo.Tags,
+	}
+	// Keep the reminder as far ahead of the due date as it was
+	if todo.RemindAt != nil && todo.DueAt != nil {
+		remindAt := due.Add(todo.RemindAt.Sub(*todo.DueAt))
+		next.RemindAt = &remindAt
+	}
+	return &next, nil
+}
+
+// addOccurrence stores the next occurrence of a completed recurring todo
+// and records it as created by the user
+func addOccurrence(j *journal, user string, next *models.Todo) (*models.Todo, error) {
+	if next == nil {
+		return nil, nil
+	}
+	todo, err := database.InsertTodo(*next)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.record(user, models.EventCreated, nil, *todo); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// reopenedOccurrence is called with a completed todo that is about to be
+// saved as pending again. When the occurrence its completion added is still
+// pending, the rule moves back onto the todo, unless it has been given a
+// new one, and that occurrence is returned to remove once the todo is
+// saved, so undoing a completion neither ends the series nor repeats it twice.
+func reopenedOccurrence(todo *models.Todo) (*models.Todo, error) {
+	pending := false
+	occurrences, err := database.QueryTodos(database.TodoQuery{PreviousId: &todo.Id, Done: &pending})
+	if err != nil || len(occurrences) == 0 {
+		return nil, err
+	}
+	next := occurrences[0]
+	if next.Recurrence == "" {
+		return nil, nil // No longer part of the series
+	}
+	if todo.Recurrence == "" {
+		rule, err := recurrence.Parse(next.Recurrence)
+		if err != nil {
+			return nil, err
+		}
+		// Completing the todo counted it off the series
+		if rule.Count > 0 {
+			rule.Count++
+		}
+		todo.Recurrence = rule.String()
+	}
+	return &next, nil
+}
+
+// removeOccurrence moves the occurrence a reopened todo had added to the
+// trash, with its subtasks, and records it as deleted by the user
+func removeOccurrence(j *journal, user string, next *models.Todo) error {
+	if next == nil {
+		return nil
+	}
+	return deleteTodo(j, user, next.Id, 0)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"listy-api/models"
+)
+
+func TestRecurringTodos(t *testing.T) {
+	useMemoryStore(t)
+	monday := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+	remind := monday.Add(-time.Hour)
+	pinClock(t, monday.Add(-2*time.Hour))
+
+	plants, err := CreateTodo("", models.CreateTodoRequest{
+		Item: "Water plants", DueAt: &monday, RemindAt: &remind, Category: "home",
+		Recurrence: "rrule:freq=weekly;byday=mo;count=2",
+	})
+	if err != nil {
+		t.Fatalf("CreateTodo() error = %v", err)
+	}
+	if plants.Recurrence != "FREQ=WEEKLY;BYDAY=MO;COUNT=2" {
+		t.Errorf("CreateTodo() recurrence = %q, want it normalized", plants.Recurrence)
+	}
+	if _, err := CreateTodo("", models.CreateTodoRequest{Item: "x", Recurrence: "every monday"}); !errors.Is(err, ErrInvalidRecurrence) {
+		t.Errorf("CreateTodo(bad recurrence) error = %v, want ErrInvalidRecurrence", err)
+	}
+
+	// Completing it early adds the occurrence after its due date
+	done, err := ToggleTodo("", plants.Id, 0)
+	if err != nil {
+		t.Fatalf("ToggleTodo() error = %v", err)
+	}
+	next := done.Next
+	if next == nil || next.DueAt == nil || !next.DueAt.Equal(monday.AddDate(0, 0, 7)) {
+		t.Fatalf("ToggleTodo() next = %+v, want one due a week later", next)
+	}
+	if !next.RemindAt.Equal(remind.AddDate(0, 0, 7)) || next.Category != "home" || next.Done {
+		t.Errorf("next = %+v, want a pending copy with the reminder moved along", next)
+	}
+	if done.Recurrence != "" || next.Recurrence != "FREQ=WEEKLY;BYDAY=MO;COUNT=1" {
+		t.Errorf("recurrence = %q then %q, want the rule moved to the next occurrence with one left", done.Recurrence, next.Recurrence)
+	}
+
+	// Reopening it takes the rule back and trashes the occurrence, so
+	// completing it again repeats it only once
+	reopened, err := ToggleTodo("", plants.Id, 0)
+	if err != nil {
+		t.Fatalf("ToggleTodo(reopen) error = %v", err)
+	}
+	if reopened.Recurrence != plants.Recurrence {
+		t.Errorf("ToggleTodo(reopen) recurrence = %q, want %q back", reopened.Recurrence, plants.Recurrence)
+	}
+	if _, err := GetTodoByID("", next.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetTodoByID(next) error = %v, want the occurrence gone", err)
+	}
+	again, err := ToggleTodo("", plants.Id, 0)
+	if err != nil {
+		t.Fatalf("ToggleTodo(again) error = %v", err)
+	}
+	if next = again.Next; next == nil || !next.DueAt.Equal(monday.AddDate(0, 0, 7)) || next.Recurrence != "FREQ=WEEKLY;BYDAY=MO;COUNT=1" {
+		t.Fatalf("ToggleTodo(again) next = %+v, want the same occurrence added again", next)
+	}
+
+	// Completing the last occurrence, weeks late, ends the series
+	pinClock(t, monday.AddDate(0, 0, 30))
+	doneValue := true
+	last, err := UpdateTodo("", next.Id, models.UpdateTodoRequest{Done: &doneValue}, 0)
+	if err != nil {
+		t.Fatalf("UpdateTodo() error = %v", err)
+	}
+	if last.Next != nil {
+		t.Errorf("UpdateTodo() next = %+v, want none after the last occurrence", last.Next)
+	}
+
+	// A late completion skips the occurrences that were missed
+	invoice, err := CreateTodo("", models.CreateTodoRequest{Item: "Send invoice", DueAt: &monday, Recurrence: "FREQ=MONTHLY;BYMONTHDAY=1"})
+	if err != nil {
+		t.Fatalf("CreateTodo() error = %v", err)
+	}
+	sent, err := UpdateTodo("", invoice.Id, models.UpdateTodoRequest{Done: &doneValue}, 0)
+	if err != nil {
+		t.Fatalf("UpdateTodo() error = %v", err)
+	}
+	if want := time.Date(2026, time.May, 1, 9, 0, 0, 0, time.UTC); sent.Next == nil || !sent.Next.DueAt.Equal(want) {
+		t.Errorf("UpdateTodo() next = %+v, want due %v", sent.Next, want)
+	}
+
+	todos, _, err := ListTodos("", models.TodoFilter{})
+	if err != nil {
+		t.Fatalf("ListTodos() error = %v", err)
+	}
+	if len(todos) != 4 {
+		t.Errorf("ListTodos() = %d todos, want the two originals and two occurrences", len(todos))
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"sync"
+
+	"listy-api/events"
+	"listy-api/models"
+)
+
+// Events carries todo changes to GET /api/todos/stream subscribers. It only
+// reaches clients of this process, so every API instance streams the
+// changes made through it.
+var Events = events.NewBroker(events.DefaultHistory)
+
+// publish announces a change to a todo
+func publish(eventType string, todo models.Todo) {
+	Events.Publish(eventType, todo)
+}
+
+// TodoWatch delivers the todo changes a user may see. C is closed when the
+// watch is closed or the subscriber falls too far behind; the client should
+// then reconnect with the last event ID it received.
+type TodoWatch struct {
+	C <-chan models.TodoEvent
+
+	sub       *events.Subscription
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WatchTodos subscribes to changes of the todos visible to the user,
+// optionally only those in filter.List ("main" for the main list). Events
+// after lastEventId are replayed first; if they are no longer available a
+// reset event is sent instead so the client knows to re-fetch.
+func WatchTodos(user string, filter models.StreamFilter, lastEventId string) (*TodoWatch, error) {
+	if filter.List != "" && filter.List != "main" {
+		if _, err := GetList(user, filter.List); err != nil {
+			return nil, err
+		}
+	}
+
+	sub, backlog, resumed := Events.Subscribe(lastEventId)
+	out := make(chan models.TodoEvent)
+	watch := &TodoWatch{C: out, sub: sub, done: make(chan struct{})}
+
+	go func() {
+		defer close(out)
+		send := func(event models.TodoEvent) bool {
+			if event.Todo != nil && !eventVisible(user, filter.List, *event.Todo) {
+				return true
+			}
+			select {
+			case out <- event:
+				return true
+			case <-watch.done:
+				return false
+			}
+		}
+
+		if !resumed && !send(models.TodoEvent{Id: sub.Start, Type: models.EventReset, At: now().UTC()}) {
+			return
+		}
+		for _, event := range backlog {
+			if !send(event) {
+				return
+			}
+		}
+		for event := range sub.C {
+			if !send(event) {
+				return
+			}
+		}
+	}()
+	return watch, nil
+}
+
+// Close stops the watch and releases its subscription
+func (w *TodoWatch) Close() {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		Events.Unsubscribe(w.sub)
+	})
+}
+
+// eventVisible reports whether a change to todo should reach the user,
+// given the list they are watching
+func eventVisible(user, list string, todo models.Todo) bool {
+	switch {
+	case list == "main" && todo.ListId != nil:
+		return false
+	case list != "" && list != "main" && (todo.ListId == nil || *todo.ListId != list):
+		return false
+	case todo.OwnerId == user:
+		return true
+	case todo.ListId == nil:
+		return false
+	}
+	role, err := listRole(user, *todo.ListId, todo.OwnerId)
+	return err == nil && role != ""
+}
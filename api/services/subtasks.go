@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"listy-api/core"
+	"listy-api/database"
+	"listy-api/models"
+)
+
+// ErrInvalidParent is returned when parent_id names a missing todo or would make a todo its own ancestor
+var ErrInvalidParent = core.NewError(ErrValidation, "invalid parent")
+
+// GetTodoDetails returns a todo with its subtask progress and, if requested, its whole subtask tree
+func GetTodoDetails(user string, id int, req models.TodoDetailRequest) (*models.Todo, error) {
+	todo, err := GetTodoByID(user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Include == "children" {
+		if err := loadSubtaskTree(todo); err != nil {
+			return nil, err
+		}
+		return todo, nil
+	}
+
+	todos := []models.Todo{*todo}
+	if err := withSubtaskProgress(todos); err != nil {
+		return nil, err
+	}
+	return &todos[0], nil
+}
+
+// withSubtaskProgress fills in the subtask roll-up of each todo that has subtasks
+func withSubtaskProgress(todos []models.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(todos))
+	ids := make([]int, len(todos))
+	for i, todo := range todos {
+		index[todo.Id] = i
+		ids[i] = todo.Id
+	}
+	children, err := database.QueryTodos(database.TodoQuery{ParentIds: ids})
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		parent := &todos[index[*child.ParentId]]
+		if parent.Subtasks == nil {
+			parent.Subtasks = &models.SubtaskProgress{}
+		}
+		parent.Subtasks.Total++
+		if child.Done {
+			parent.Subtasks.Done++
+		}
+	}
+	return nil
+}
+
+// loadSubtaskTree attaches every descendant of root under Children,
+// querying one level of the tree at a time
+func loadSubtaskTree(root *models.Todo) error {
+	level := []*models.Todo{root}
+	for len(level) > 0 {
+		byId := make(map[int]*models.Todo, len(level))
+		ids := make([]int, len(level))
+		for i, todo := range level {
+			byId[todo.Id] = todo
+			ids[i] = todo.Id
+		}
+
+		children, err := database.QueryTodos(database.TodoQuery{ParentIds: ids})
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			parent := byId[*child.ParentId]
+			parent.Children = append(parent.Children, child)
+			if parent.Subtasks == nil {
+				parent.Subtasks = &models.SubtaskProgress{}
+			}
+			parent.Subtasks.Total++
+			if child.Done {
+				parent.Subtasks.Done++
+			}
+		}
+
+		// Children slices are complete now, so pointers into them stay valid
+		var next []*models.Todo
+		for _, todo := range level {
+			for i := range todo.Children {
+				next = append(next, &todo.Children[i])
+			}
+		}
+		level = next
+	}
+	return nil
+}
+
+// resolveParent loads the todo that id is being placed under, rejecting
+// missing parents, todos the user cannot edit and cycles. id is 0 for a
+// todo that does not exist yet.
+func resolveParent(user string, id, parentId int) (*models.Todo, error) {
+	parent, err := accessTodo(user, parentId, models.RoleEditor)
+	if errors.Is(err, ErrForbidden) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParent, err)
+	}
+
+	for ancestor := parent; id != 0; {
+		if ancestor.Id == id {
+			return nil, fmt.Errorf("%w: todo %d cannot be a subtask of its own subtask %d", ErrInvalidParent, id, parentId)
+		}
+		if ancestor.ParentId == nil {
+			break
+		}
+		if ancestor, err = database.GetTodo(*ancestor.ParentId); err != nil {
+			return nil, err
+		}
+	}
+	return parent, nil
+}
+
+// syncParent marks an auto-completing parent done once all its subtasks are
+// done, or pending again when one is reopened, and repeats up the tree.
+// The caller has already checked access to the subtask, and the changes
+// are recorded as the user's.
+func syncParent(j *journal, user string, parentId *int) error {
+	for parentId != nil {
+		parent, err := database.GetTodo(*parentId)
+		if err != nil {
+			return err
+		}
+		if !parent.AutoComplete {
+			return nil
+		}
+
+		children, err := database.QueryTodos(database.TodoQuery{ParentIds: []int{parent.Id}})
+		if err != nil {
+			return err
+		}
+		if len(children) == 0 {
+			return nil
+		}
+		done := true
+		for _, child := range children {
+			done = done && child.Done
+		}
+		if parent.Done == done {
+			return nil
+		}
+
+		before := *parent
+		parent.Done = done
+		var next, reopened *models.Todo
+		if done {
+			if next, err = nextOccurrence(parent); err != nil {
+				return err
+			}
+		} else if reopened, err = reopenedOccurrence(parent); err != nil {
+			return err
+		}
+		err = database.UpdateTodo(parent.Id, *parent)
+		if errors.Is(err, database.ErrVersionConflict) {
+			continue // Changed meanwhile; check the latest version again
+		}
+		if err != nil {
+			return err
+		}
+		parent.Version++
+		if err := j.record(user, models.EventUpdated, &before, *parent); err != nil {
+			return err
+		}
+		if _, err := addOccurrence(j, user, next); err != nil {
+			return err
+		}
+		if err := removeOccurrence(j, user, reopened); err != nil {
+			return err
+		}
+		parentId = parent.ParentId
+	}
+	return nil
+}
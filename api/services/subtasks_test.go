@@ -0,0 +1,83 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"listy-api/models"
+)
+
+func TestSubtasks(t *testing.T) {
+	useMemoryStore(t)
+
+	work := "work"
+	create := func(req models.CreateTodoRequest) *models.Todo {
+		t.Helper()
+		todo, err := CreateTodo("", req)
+		if err != nil {
+			t.Fatalf("CreateTodo(%q) error = %v", req.Item, err)
+		}
+		return todo
+	}
+	parent := create(models.CreateTodoRequest{Item: "Learn Go", ListId: &work, AutoComplete: true})
+	first := create(models.CreateTodoRequest{Item: "Install Go", ParentId: &parent.Id})
+	second := create(models.CreateTodoRequest{Item: "Take the tour", ParentId: &parent.Id})
+	nested := create(models.CreateTodoRequest{Item: "Read about generics", ParentId: &second.Id})
+
+	if first.ListId == nil || *first.ListId != "work" {
+		t.Errorf("subtask list_id = %v, want parent's list work", first.ListId)
+	}
+	if _, err := CreateTodo("", models.CreateTodoRequest{Item: "Orphan", ParentId: new(int)}); !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("CreateTodo() with missing parent error = %v, want ErrInvalidParent", err)
+	}
+	if _, err := UpdateTodo("", parent.Id, models.UpdateTodoRequest{ParentId: &nested.Id}, 0); !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("UpdateTodo() creating a cycle error = %v, want ErrInvalidParent", err)
+	}
+
+	topLevel, _, err := ListTodos("", models.TodoFilter{Parent: "none"})
+	if err != nil {
+		t.Fatalf("ListTodos() error = %v", err)
+	}
+	if len(topLevel) != 1 || topLevel[0].Subtasks == nil || *topLevel[0].Subtasks != (models.SubtaskProgress{Done: 0, Total: 2}) {
+		t.Fatalf("ListTodos(parent=none) = %+v, want the parent with 0/2 subtasks done", topLevel)
+	}
+
+	// Finishing every subtask completes the auto-completing parent; reopening one reopens it
+	for _, id := range []int{first.Id, second.Id} {
+		if _, err := ToggleTodo("", id, 0); err != nil {
+			t.Fatalf("ToggleTodo(%d) error = %v", id, err)
+		}
+	}
+	if got, _ := GetTodoByID("", parent.Id); !got.Done {
+		t.Error("parent not auto-completed after all subtasks were done")
+	}
+	if _, err := ToggleTodo("", first.Id, 0); err != nil {
+		t.Fatalf("ToggleTodo() error = %v", err)
+	}
+	if got, _ := GetTodoByID("", parent.Id); got.Done {
+		t.Error("parent still done after a subtask was reopened")
+	}
+
+	tree, err := GetTodoDetails("", parent.Id, models.TodoDetailRequest{Include: "children"})
+	if err != nil {
+		t.Fatalf("GetTodoDetails() error = %v", err)
+	}
+	if len(tree.Children) != 2 || len(tree.Children[1].Children) != 1 || tree.Children[1].Children[0].Id != nested.Id {
+		t.Errorf("GetTodoDetails() tree = %+v, want two children with one grandchild", tree)
+	}
+	if *tree.Subtasks != (models.SubtaskProgress{Done: 1, Total: 2}) {
+		t.Errorf("GetTodoDetails() subtasks = %+v, want 1/2", *tree.Subtasks)
+	}
+
+	if err := DeleteTodo("", second.Id, 0); err != nil {
+		t.Fatalf("DeleteTodo() error = %v", err)
+	}
+	remaining, _, err := ListTodos("", models.TodoFilter{})
+	if err != nil {
+		t.Fatalf("ListTodos() error = %v", err)
+	}
+	if ids := todoIDs(remaining); !slices.Equal(ids, []int{parent.Id, first.Id}) {
+		t.Errorf("after deleting a subtask IDs = %v, want its own subtasks deleted too", ids)
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"listy-api/core"
+	"listy-api/database"
+	"listy-api/models"
+)
+
+// MaxTags is how many tags a todo can carry
+const MaxTags = 20
+
+// maxTagLength is the longest tag name, in characters
+const maxTagLength = 32
+
+// ErrInvalidTag is returned for tags that are not a short word, or when a
+// todo would carry more than MaxTags
+var ErrInvalidTag = core.NewError(ErrValidation, "invalid tag")
+
+// normalizeTags lower-cases tags, strips a leading #, and returns them
+// sorted without duplicates. Tags may use letters, digits, - and _.
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if err := validateTag(name); err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, name)
+	}
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+	if len(normalized) > MaxTags {
+		return nil, fmt.Errorf("%w: a todo can have at most %d tags", ErrInvalidTag, MaxTags)
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+	return normalized, nil
+}
+
+// validateTag checks a lower-case tag name without its #
+func validateTag(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: tag is empty", ErrInvalidTag)
+	}
+	if len([]rune(name)) > maxTagLength {
+		return fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidTag, name, maxTagLength)
+	}
+	for _, r := range name {
+		if !isTagRune(r) {
+			return fmt.Errorf("%w: %q may only contain letters, digits, - and _", ErrInvalidTag, name)
+		}
+	}
+	return nil
+}
+
+// isTagRune reports whether r may appear in a tag name
+func isTagRune(r rune) bool {
+	return r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tagFilter normalizes the tags of a ?tag filter, reporting bad ones as an
+// invalid query
+func tagFilter(tags []string) ([]string, error) {
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	return normalized, nil
+}
+
+// GetTags returns every tag on the todos the user can see outside the
+// trash, with how many of them are pending and done
+func GetTags(user string) ([]models.Tag, error) {
+	shared, _, err := sharedWith(user)
+	if err != nil {
+		return nil, err
+	}
+	return database.CountTags(database.TodoQuery{Owner: &user, SharedLists: shared})
+}
+
+// AddTags adds tags to a todo, keeping the ones it has, unless ifMatch is
+// set and the todo is no longer at that version
+func AddTags(user string, id int, tags []string, ifMatch int) (*models.Todo, error) {
+	return retryConflicts(ifMatch, func() (*models.Todo, error) {
+		return changeTags(user, id, ifMatch, func(current []string) []string { return append(slices.Clone(current), tags...) })
+	})
+}
+
+// RemoveTags takes tags off a todo; tags it does not have are ignored
+func RemoveTags(user string, id int, tags []string, ifMatch int) (*models.Todo, error) {
+	removed, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	return retryConflicts(ifMatch, func() (*models.Todo, error) {
+		return changeTags(user, id, ifMatch, func(current []string) []string {
+			return slices.DeleteFunc(slices.Clone(current), func(tag string) bool { return slices.Contains(removed, tag) })
+		})
+	})
+}
+
+// changeTags saves a todo with the tags change returns for its current ones.
+// The todo is returned unchanged when its tags stay the same.
+func changeTags(user string, id int, ifMatch int, change func([]string) []string) (*models.Todo, error) {
+	todo, err := accessTodo(user, id, models.RoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(todo, ifMatch); err != nil {
+		return nil, err
+	}
+
+	before := *todo
+	if todo.Tags, err = normalizeTags(change(todo.Tags)); err != nil {
+		return nil, err
+	}
+	if slices.Equal(todo.Tags, before.Tags) {
+		return todo, nil
+	}
+
+	if err := database.UpdateTodo(id, *todo); err != nil {
+		return nil, err
+	}
+	todo.Version++
+	if err := record(user, models.EventUpdated, &before, *todo); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
@@ -1,171 +1,239 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"listy-api/database"
 	"listy-api/models"
-	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// GetNextID calculates the next ID based on existing todos
-func GetNextID(todos []models.Todo) int {
-	if len(todos) == 0 {
-		return 1
-	}
-	maxID := 0
-	for _, todo := range todos {
-		if todo.Id > maxID {
-			maxID = todo.Id
-		}
+// ErrVersionConflict is returned when a todo changed since the version the caller last saw
+var ErrVersionConflict = database.ErrVersionConflict
+
+// ListTodos returns one page of the todos the user can see matching filter, plus the cursor for the next page
+func ListTodos(user string, filter models.TodoFilter) ([]models.Todo, string, error) {
+	q := database.TodoQuery{
+		Done:     filter.Done,
+		Search:   strings.TrimSpace(filter.Search),
+		Priority: filter.Priority,
+		Category: strings.TrimSpace(filter.Category),
 	}
-	return maxID + 1
-}
 
-// GetAllTodos returns all todos sorted by ID
-func GetAllTodos() ([]models.Todo, error) {
-	todos, err := database.LoadTodos()
+	tags, err := tagFilter(filter.Tags)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	q.Tags, q.AnyTag = tags, filter.TagMode == "any"
 
-	// Sort by ID
-	sort.Slice(todos, func(i, j int) bool {
-		return todos[i].Id < todos[j].Id
-	})
+	if filter.List != "" {
+		q.FilterList = true
+		if filter.List != "main" {
+			q.ListId = &filter.List
+		}
+	}
 
-	return todos, nil
-}
+	switch filter.Parent {
+	case "":
+	case "none":
+		q.TopLevel = true
+	default:
+		parentId, err := strconv.Atoi(filter.Parent)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: parent must be \"none\" or a todo ID", ErrInvalidQuery)
+		}
+		q.ParentIds = []int{parentId}
+	}
 
-// GetTodosByListId returns todos for a specific list (nil listId means main list)
-func GetTodosByListId(listId *string) ([]models.Todo, error) {
-	allTodos, err := GetAllTodos()
+	sort, err := parseSort(filter.Sort)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-
-	var filtered []models.Todo
-	for _, todo := range allTodos {
-		// If listId is nil, return todos with nil listId (main list)
-		// If listId is provided, return todos matching that listId
-		if listId == nil {
-			if todo.ListId == nil {
-				filtered = append(filtered, todo)
-			}
-		} else {
-			if todo.ListId != nil && *todo.ListId == *listId {
-				filtered = append(filtered, todo)
-			}
-		}
+	q.Sort = sort
+	if q.Sort == nil && q.Done != nil && !*q.Done {
+		q.Sort = pendingSort
 	}
 
-	return filtered, nil
+	return queryVisible(user, q, filter.PageRequest)
 }
 
-// GetAllListIds returns all unique list IDs (excluding main list)
-func GetAllListIds() ([]string, error) {
-	todos, err := GetAllTodos()
-	if err != nil {
-		return nil, err
+// parseSort turns "-created_at,item" into sort fields, rejecting unknown fields
+func parseSort(sort string) ([]database.SortField, error) {
+	if strings.TrimSpace(sort) == "" {
+		return nil, nil
 	}
 
-	listMap := make(map[string]bool)
-	for _, todo := range todos {
-		if todo.ListId != nil && *todo.ListId != "" {
-			listMap[*todo.ListId] = true
+	var fields []database.SortField
+	for _, key := range strings.Split(sort, ",") {
+		key = strings.TrimSpace(key)
+		field := database.SortField{Field: strings.TrimPrefix(key, "-"), Desc: strings.HasPrefix(key, "-")}
+		if !database.IsSortField(field.Field) {
+			return nil, fmt.Errorf("%w: unsupported sort field %q", ErrInvalidQuery, field.Field)
 		}
+		fields = append(fields, field)
 	}
 
-	var listIds []string
-	for listId := range listMap {
-		listIds = append(listIds, listId)
-	}
+	return fields, nil
+}
+
+// GetTodosByListId returns one page of the todos in a specific list (nil listId means the user's main list)
+func GetTodosByListId(user string, listId *string, page models.PageRequest) ([]models.Todo, string, error) {
+	return queryVisible(user, database.TodoQuery{FilterList: true, ListId: listId}, page)
+}
 
-	// Sort for consistency
-	sort.Strings(listIds)
-	return listIds, nil
+// GetPendingTodos returns one page of the pending todos the user can see, soonest due first
+func GetPendingTodos(user string, page models.PageRequest) ([]models.Todo, string, error) {
+	done := false
+	return queryVisible(user, database.TodoQuery{Done: &done, Sort: pendingSort}, page)
 }
 
-// GetPendingTodos returns only pending todos
-func GetPendingTodos() ([]models.Todo, error) {
-	todos, err := GetAllTodos()
+// GetCompletedTodos returns one page of the completed todos the user can see
+func GetCompletedTodos(user string, page models.PageRequest) ([]models.Todo, string, error) {
+	done := true
+	return queryVisible(user, database.TodoQuery{Done: &done}, page)
+}
+
+// queryVisible runs queryPage over the user's own todos and those in lists shared with them
+func queryVisible(user string, q database.TodoQuery, page models.PageRequest) ([]models.Todo, string, error) {
+	shared, _, err := sharedWith(user)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	q.Owner, q.SharedLists = &user, shared
+	return queryPage(q, page)
+}
 
-	var pending []models.Todo
-	for _, todo := range todos {
-		if !todo.Done {
-			pending = append(pending, todo)
-		}
-	}
+// GetTodoByID finds a todo by ID, failing with ErrForbidden unless it is the
+// user's or in a list shared with them
+func GetTodoByID(user string, id int) (*models.Todo, error) {
+	return accessTodo(user, id, models.RoleViewer)
+}
 
-	return pending, nil
+// accessTodo loads a todo, failing with ErrForbidden unless the user's role
+// is at least minRole. Todos belong to the owner of their list, so the owner
+// has every role and members have their role in the list. Todos in the
+// trash are not found.
+func accessTodo(user string, id int, minRole string) (*models.Todo, error) {
+	return loadTodo(user, id, minRole, false)
 }
 
-// GetCompletedTodos returns only completed todos
-func GetCompletedTodos() ([]models.Todo, error) {
-	todos, err := GetAllTodos()
+// loadTodo is accessTodo for todos in the trash when trashed is set, and
+// for the others when it is not
+func loadTodo(user string, id int, minRole string, trashed bool) (*models.Todo, error) {
+	todo, err := database.GetTodo(id)
+	if err == nil && (todo.DeletedAt != nil) != trashed {
+		err = database.ErrNotFound
+	}
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, fmt.Errorf("todo with ID %d %w", id, ErrNotFound)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	var completed []models.Todo
-	for _, todo := range todos {
-		if todo.Done {
-			completed = append(completed, todo)
+	role := ""
+	if todo.OwnerId == user {
+		role = models.RoleOwner
+	} else if todo.ListId != nil {
+		if role, err = listRole(user, *todo.ListId, todo.OwnerId); err != nil {
+			return nil, err
+		}
+	}
+	if roleRank[role] < roleRank[minRole] {
+		if role == "" {
+			return nil, fmt.Errorf("%w: todo %d belongs to another user", ErrForbidden, id)
 		}
+		return nil, fmt.Errorf("%w: todo %d needs %s access to list %q, you are a %s", ErrForbidden, id, minRole, *todo.ListId, role)
 	}
 
-	return completed, nil
+	return todo, nil
+}
+
+// CreateTodo creates a new todo; the store allocates its ID. Todos in a
+// list belong to the list's owner, and need editor access to add.
+// A list_id naming a list that does not exist yet creates that list.
+// Subtasks default to their parent's list.
+func CreateTodo(user string, req models.CreateTodoRequest) (*models.Todo, error) {
+	return createTodo(nil, user, req)
 }
 
-// GetTodoByID finds a todo by ID
-func GetTodoByID(id int) (*models.Todo, error) {
-	todos, err := GetAllTodos()
+func createTodo(j *journal, user string, req models.CreateTodoRequest) (*models.Todo, error) {
+	if req.ListId != nil && *req.ListId == "" {
+		req.ListId = nil
+	}
+	if req.ParentId != nil {
+		parent, err := resolveParent(user, 0, *req.ParentId)
+		if err != nil {
+			return nil, err
+		}
+		if req.ListId == nil {
+			req.ListId = parent.ListId
+		}
+	}
+	rule, err := normalizeRecurrence(req.Recurrence)
 	if err != nil {
 		return nil, err
 	}
-
-	for i := range todos {
-		if todos[i].Id == id {
-			return &todos[i], nil
-		}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
 	}
-
-	return nil, fmt.Errorf("todo with ID %d not found", id)
-}
-
-// CreateTodo creates a new todo
-func CreateTodo(item string, listId *string) (*models.Todo, error) {
-	// Get all todos to calculate next ID
-	todos, err := GetAllTodos()
+	owner, err := ensureList(j, user, req.ListId)
 	if err != nil {
 		return nil, err
 	}
 
-	nextID := GetNextID(todos)
 	newTodo := models.Todo{
-		Id:     nextID,
-		Item:   item,
-		Done:   false,
-		ListId: listId,
+		Item:      req.Item,
+		Done:      false,
+		ListId:    req.ListId,
+		CreatedAt: time.Now().UTC(),
+		OwnerId:   owner,
+		DueAt:     req.DueAt,
+		RemindAt:  req.RemindAt,
+
+		Priority:      req.Priority,
+		EstimatedTime: strings.TrimSpace(req.EstimatedTime),
+		Category:      strings.TrimSpace(req.Category),
+
+		ParentId:     req.ParentId,
+		AutoComplete: req.AutoComplete,
+		Recurrence:   rule,
+		Tags:         tags,
 	}
 
-	err = database.InsertTodo(newTodo)
+	todo, err := database.InsertTodo(newTodo)
 	if err != nil {
 		return nil, err
 	}
+	if err := j.record(user, models.EventCreated, nil, *todo); err != nil {
+		return nil, err
+	}
+	// A new pending subtask reopens an auto-completed parent
+	if err := syncParent(j, user, todo.ParentId); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
 
-	return &newTodo, nil
+// UpdateTodo updates an existing todo. A non-zero ifMatch is the version the
+// caller last saw; the update fails with ErrVersionConflict if it has changed.
+func UpdateTodo(user string, id int, req models.UpdateTodoRequest, ifMatch int) (*models.Todo, error) {
+	return retryConflicts(ifMatch, func() (*models.Todo, error) { return updateTodo(nil, user, id, req, ifMatch) })
 }
 
-// UpdateTodo updates an existing todo
-func UpdateTodo(id int, req models.UpdateTodoRequest) (*models.Todo, error) {
+func updateTodo(j *journal, user string, id int, req models.UpdateTodoRequest, ifMatch int) (*models.Todo, error) {
 	// Get existing todo
-	todo, err := GetTodoByID(id)
+	todo, err := accessTodo(user, id, models.RoleEditor)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkVersion(todo, ifMatch); err != nil {
+		return nil, err
+	}
+	before := *todo
 
 	// Update fields if provided
 	if req.Item != nil {
@@ -174,40 +242,195 @@ func UpdateTodo(id int, req models.UpdateTodoRequest) (*models.Todo, error) {
 	if req.Done != nil {
 		todo.Done = *req.Done
 	}
+	if req.DueAt != nil {
+		todo.DueAt = req.DueAt
+	}
+	if req.ClearDueAt {
+		todo.DueAt = nil
+	}
+	if req.RemindAt != nil {
+		todo.RemindAt = req.RemindAt
+	}
+	if req.ClearRemindAt {
+		todo.RemindAt = nil
+	}
+	if req.Priority != nil {
+		todo.Priority = *req.Priority
+	}
+	if req.EstimatedTime != nil {
+		todo.EstimatedTime = strings.TrimSpace(*req.EstimatedTime)
+	}
+	if req.Category != nil {
+		todo.Category = strings.TrimSpace(*req.Category)
+	}
+	if req.AutoComplete != nil {
+		todo.AutoComplete = *req.AutoComplete
+	}
+	if req.Recurrence != nil {
+		if todo.Recurrence, err = normalizeRecurrence(*req.Recurrence); err != nil {
+			return nil, err
+		}
+	}
+	if req.Tags != nil {
+		if todo.Tags, err = normalizeTags(*req.Tags); err != nil {
+			return nil, err
+		}
+	}
+	oldParentId := todo.ParentId
+	if req.ParentId != nil {
+		if _, err := resolveParent(user, id, *req.ParentId); err != nil {
+			return nil, err
+		}
+		todo.ParentId = req.ParentId
+	}
+	if req.ClearParentId {
+		todo.ParentId = nil
+	}
+	var next, reopened *models.Todo
+	if todo.Done && !before.Done {
+		if next, err = nextOccurrence(todo); err != nil {
+			return nil, err
+		}
+	} else if !todo.Done && before.Done {
+		if reopened, err = reopenedOccurrence(todo); err != nil {
+			return nil, err
+		}
+	}
 
 	// Save to database
 	err = database.UpdateTodo(id, *todo)
 	if err != nil {
 		return nil, err
 	}
+	todo.Version++
+	if err := j.record(user, models.EventUpdated, &before, *todo); err != nil {
+		return nil, err
+	}
+	if next, err = addOccurrence(j, user, next); err != nil {
+		return nil, err
+	}
+	if err := removeOccurrence(j, user, reopened); err != nil {
+		return nil, err
+	}
 
-	return todo, nil
+	// Roll the done state up to the old and new parents. Turning auto-complete
+	// on also applies it to this todo's current subtasks.
+	sync := []*int{oldParentId, todo.ParentId}
+	if req.AutoComplete != nil && *req.AutoComplete {
+		sync = append(sync, &todo.Id)
+	}
+	for _, parentId := range sync {
+		if err := syncParent(j, user, parentId); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := GetTodoByID(user, id)
+	if err != nil {
+		return nil, err
+	}
+	updated.Next = next
+	return updated, nil
 }
 
-// DeleteTodo deletes a todo by ID
-func DeleteTodo(id int) error {
-	// Check if todo exists
-	_, err := GetTodoByID(id)
+// DeleteTodo moves a todo by ID to the trash, along with its subtasks,
+// unless ifMatch is set and the todo is no longer at that version.
+// RestoreTodo brings it back until the trash is purged.
+func DeleteTodo(user string, id int, ifMatch int) error {
+	_, err := retryConflicts(ifMatch, func() (*models.Todo, error) { return nil, deleteTodo(nil, user, id, ifMatch) })
+	return err
+}
+
+func deleteTodo(j *journal, user string, id int, ifMatch int) error {
+	todo, err := accessTodo(user, id, models.RoleEditor)
 	if err != nil {
 		return err
 	}
+	if err := checkVersion(todo, ifMatch); err != nil {
+		return err
+	}
 
-	return database.DeleteTodo(id)
+	before := *todo
+	deletedAt := now().UTC()
+	todo.DeletedAt = &deletedAt
+	if err := database.UpdateTodo(id, *todo); err != nil {
+		return err
+	}
+	todo.Version++
+	if err := j.record(user, models.EventDeleted, &before, *todo); err != nil {
+		return err
+	}
+	if err := trashSubtasks(j, user, id, deletedAt); err != nil {
+		return err
+	}
+	// The remaining siblings may now all be done
+	return syncParent(j, user, todo.ParentId)
 }
 
-// ToggleTodo toggles the done status of a todo
-func ToggleTodo(id int) (*models.Todo, error) {
-	todo, err := GetTodoByID(id)
+// ToggleTodo toggles the done status of a todo, unless ifMatch is set and
+// the todo is no longer at that version
+func ToggleTodo(user string, id int, ifMatch int) (*models.Todo, error) {
+	return retryConflicts(ifMatch, func() (*models.Todo, error) { return toggleTodo(nil, user, id, ifMatch) })
+}
+
+func toggleTodo(j *journal, user string, id int, ifMatch int) (*models.Todo, error) {
+	todo, err := accessTodo(user, id, models.RoleEditor)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkVersion(todo, ifMatch); err != nil {
+		return nil, err
+	}
 
+	before := *todo
 	todo.Done = !todo.Done
+	var next, reopened *models.Todo
+	if todo.Done {
+		if next, err = nextOccurrence(todo); err != nil {
+			return nil, err
+		}
+	} else if reopened, err = reopenedOccurrence(todo); err != nil {
+		return nil, err
+	}
 
 	err = database.UpdateTodo(id, *todo)
 	if err != nil {
 		return nil, err
 	}
+	todo.Version++
+	if err := j.record(user, models.EventToggled, &before, *todo); err != nil {
+		return nil, err
+	}
+	if todo.Next, err = addOccurrence(j, user, next); err != nil {
+		return nil, err
+	}
+	if err := removeOccurrence(j, user, reopened); err != nil {
+		return nil, err
+	}
 
+	if err := syncParent(j, user, todo.ParentId); err != nil {
+		return nil, err
+	}
 	return todo, nil
 }
+
+// checkVersion rejects a change made against an outdated version of the todo;
+// ifMatch 0 accepts any version
+func checkVersion(todo *models.Todo, ifMatch int) error {
+	if ifMatch != 0 && todo.Version != ifMatch {
+		return fmt.Errorf("%w: todo %d is at version %d, not %d", ErrVersionConflict, todo.Id, todo.Version, ifMatch)
+	}
+	return nil
+}
+
+// retryConflicts reruns an unconditional change when another request updated
+// the todo between it being read and written, so neither change is lost.
+// Changes made against a specific version are not retried.
+func retryConflicts(ifMatch int, change func() (*models.Todo, error)) (*models.Todo, error) {
+	for attempt := 1; ; attempt++ {
+		todo, err := change()
+		if ifMatch != 0 || attempt == 3 || !errors.Is(err, ErrVersionConflict) {
+			return todo, err
+		}
+	}
+}
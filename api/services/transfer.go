@@ -0,0 +1,318 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"listy-api/database"
+	"listy-api/models"
+	"listy-api/transfer"
+)
+
+// ErrInvalidImport is returned for a file that cannot be imported as a whole
+var ErrInvalidImport = transfer.ErrInvalid
+
+// MaxImportTodos is how many todos one import may hold
+const MaxImportTodos = 1000
+
+// ExportTodos writes the todos the user can see outside the trash, or only
+// those in filter.List, oldest first in filter.Format (JSON by default)
+func ExportTodos(user string, filter models.ExportFilter) ([]byte, transfer.Format, error) {
+	format := transfer.Format(filter.Format)
+	if format == "" {
+		format = transfer.JSON
+	}
+	loc, err := parseLocation(filter.TZ)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var q database.TodoQuery
+	if filter.List != "" {
+		q.FilterList = true
+		if filter.List != "main" {
+			q.ListId = &filter.List
+		}
+	}
+	shared, _, err := sharedWith(user)
+	if err != nil {
+		return nil, "", err
+	}
+	q.Owner, q.SharedLists = &user, shared
+
+	todos, err := database.QueryTodos(q)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := transfer.Encode(format, todos, loc)
+	return data, format, err
+}
+
+// importTarget is where an imported todo ended up: a todo that already
+// existed, found by dedupe, or the plan at index plan
+type importTarget struct {
+	listId *string
+	id     int
+	plan   int
+}
+
+// key identifies the target as a parent in dedupe keys
+func (t importTarget) key() string {
+	if t.id != 0 {
+		return "id:" + strconv.Itoa(t.id)
+	}
+	return "new:" + strconv.Itoa(t.plan)
+}
+
+// importPlan is a todo an import creates
+type importPlan struct {
+	n      int // Position of the todo in the file, from 1
+	req    models.CreateTodoRequest
+	done   bool
+	parent int // Index of the plan creating the parent, or -1
+}
+
+// ImportTodos adds the todos in a file, reading it in opts.Format or the
+// format it looks like. Every todo is checked before any is created, and
+// if creating one still fails the ones created before it are moved to the
+// trash again. IDs and parent IDs in the file only link subtasks to their
+// parent within it; subtasks go in their parent's list. Completed todos do
+// not repeat, and creation times are not kept.
+//
+// With opts.Dedupe a todo is skipped when one with the same text, ignoring
+// case, already has the same list and parent, or comes earlier in the file;
+// its subtasks go under that todo instead. With opts.DryRun nothing is
+// saved and the result shows what would be.
+func ImportTodos(user string, data []byte, opts models.ImportOptions) (*models.ImportResult, error) {
+	format := transfer.Format(opts.Format)
+	if format == "" {
+		format = transfer.Detect(data)
+	}
+	loc, err := parseLocation(opts.TZ)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := transfer.Decode(format, data, loc)
+	if err != nil {
+		return nil, err
+	}
+	if len(todos) > MaxImportTodos {
+		return nil, fmt.Errorf("%w: the file holds %d todos, at most %d can be imported at once", ErrInvalidImport, len(todos), MaxImportTodos)
+	}
+	order, err := importOrder(todos)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]importTarget)
+	if opts.Dedupe {
+		if seen, err = dedupeTargets(user); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &models.ImportResult{Format: string(format), DryRun: opts.DryRun, Todos: []models.Todo{}}
+	targets := make(map[int]importTarget)
+	var plans []importPlan
+	for _, i := range order {
+		todo := todos[i]
+		plan := importPlan{
+			n: i + 1,
+			req: models.CreateTodoRequest{
+				Item:          strings.TrimSpace(todo.Item),
+				ListId:        todo.ListId,
+				DueAt:         todo.DueAt,
+				RemindAt:      todo.RemindAt,
+				Priority:      strings.ToLower(todo.Priority),
+				EstimatedTime: todo.EstimatedTime,
+				Category:      todo.Category,
+				AutoComplete:  todo.AutoComplete,
+				Tags:          todo.Tags,
+			},
+			done:   todo.Done,
+			parent: -1,
+		}
+		if !todo.Done {
+			plan.req.Recurrence = todo.Recurrence
+		}
+		switch opts.List {
+		case "":
+		case "main":
+			plan.req.ListId = nil
+		default:
+			plan.req.ListId = &opts.List
+		}
+
+		parentKey := ""
+		if todo.ParentId != nil {
+			if parent, ok := targets[*todo.ParentId]; ok {
+				plan.req.ListId, parentKey = parent.listId, parent.key()
+				if parent.id != 0 {
+					plan.req.ParentId = &parent.id
+				} else {
+					plan.parent = parent.plan
+				}
+			}
+		}
+		if plan.req.ListId != nil && *plan.req.ListId == "" {
+			plan.req.ListId = nil
+		}
+		if err := checkImport(user, plan.req); err != nil {
+			return nil, fmt.Errorf("todo %d: %w", plan.n, err)
+		}
+
+		key := dedupeKey(plan.req.ListId, parentKey, plan.req.Item)
+		target, duplicate := seen[key]
+		if !opts.Dedupe || !duplicate {
+			target = importTarget{listId: plan.req.ListId, plan: len(plans)}
+			seen[key] = target
+			plans = append(plans, plan)
+		} else {
+			result.Skipped++
+		}
+		if todo.Id != 0 {
+			targets[todo.Id] = target
+		}
+	}
+
+	if opts.DryRun {
+		for _, plan := range plans {
+			tags, _ := normalizeTags(plan.req.Tags)
+			rule, _ := normalizeRecurrence(plan.req.Recurrence)
+			result.Todos = append(result.Todos, models.Todo{
+				Item:          plan.req.Item,
+				Done:          plan.done,
+				ListId:        plan.req.ListId,
+				DueAt:         plan.req.DueAt,
+				RemindAt:      plan.req.RemindAt,
+				Priority:      plan.req.Priority,
+				EstimatedTime: strings.TrimSpace(plan.req.EstimatedTime),
+				Category:      strings.TrimSpace(plan.req.Category),
+				ParentId:      plan.req.ParentId,
+				AutoComplete:  plan.req.AutoComplete,
+				Recurrence:    rule,
+				Tags:          tags,
+			})
+		}
+		result.Created = len(result.Todos)
+		return result, nil
+	}
+
+	// As in an atomic batch, a failed import is undone without a trace
+	j := &journal{}
+	ids := make([]int, len(plans))
+	for i, plan := range plans {
+		if plan.parent >= 0 {
+			parentId := ids[plan.parent]
+			plan.req.ParentId = &parentId
+		}
+		todo, err := createTodo(j, user, plan.req)
+		if err != nil {
+			return nil, j.rollback(fmt.Errorf("todo %d: %w", plan.n, err))
+		}
+		if plan.done {
+			done := true
+			change := func() (*models.Todo, error) {
+				return updateTodo(j, user, todo.Id, models.UpdateTodoRequest{Done: &done}, 0)
+			}
+			if todo, err = retryConflicts(0, change); err != nil {
+				return nil, j.rollback(fmt.Errorf("todo %d: %w", plan.n, err))
+			}
+		}
+		ids[i] = todo.Id
+		result.Todos = append(result.Todos, *todo)
+	}
+	result.Created = len(result.Todos)
+	// As with a batch, an import that was saved is not reported as failed
+	if err := j.commit(); err != nil {
+		log.Printf("Failed to record the changes of an import: %v", err)
+	}
+	return result, nil
+}
+
+// checkImport reports why a todo in an import could not be created
+func checkImport(user string, req models.CreateTodoRequest) error {
+	if req.Priority != "" && !slices.Contains(models.Priorities, req.Priority) {
+		return fmt.Errorf("%w: priority %q is not high, medium or low", ErrInvalidImport, req.Priority)
+	}
+	return checkOperation(user, models.BatchOperation{Op: models.BatchCreate, Todo: &req})
+}
+
+// importOrder returns the indexes of todos in file order, except that
+// subtasks come after their parent. A parent ID no todo in the file has is
+// ignored, making the todo top-level. Two todos with the same ID are rejected.
+func importOrder(todos []models.Todo) ([]int, error) {
+	index := make(map[int]int)
+	for i, todo := range todos {
+		if todo.Id == 0 {
+			continue
+		}
+		if first, ok := index[todo.Id]; ok {
+			return nil, fmt.Errorf("%w: todos %d and %d both have ID %d", ErrInvalidImport, first+1, i+1, todo.Id)
+		}
+		index[todo.Id] = i
+	}
+
+	children := make(map[int][]int)
+	var roots []int
+	for i, todo := range todos {
+		if todo.ParentId != nil {
+			if parent, ok := index[*todo.ParentId]; ok {
+				children[parent] = append(children[parent], i)
+				continue
+			}
+		}
+		roots = append(roots, i)
+	}
+
+	order := make([]int, 0, len(todos))
+	var visit func(i int)
+	visit = func(i int) {
+		order = append(order, i)
+		for _, child := range children[i] {
+			visit(child)
+		}
+	}
+	for _, i := range roots {
+		visit(i)
+	}
+	if len(order) < len(todos) {
+		return nil, fmt.Errorf("%w: parent IDs form a cycle", ErrInvalidImport)
+	}
+	return order, nil
+}
+
+// dedupeTargets returns the todos the user can see outside the trash by
+// their dedupe key
+func dedupeTargets(user string) (map[string]importTarget, error) {
+	shared, _, err := sharedWith(user)
+	if err != nil {
+		return nil, err
+	}
+	todos, err := database.QueryTodos(database.TodoQuery{Owner: &user, SharedLists: shared})
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]importTarget, len(todos))
+	for _, todo := range todos {
+		parentKey := ""
+		if todo.ParentId != nil {
+			parentKey = importTarget{id: *todo.ParentId}.key()
+		}
+		targets[dedupeKey(todo.ListId, parentKey, todo.Item)] = importTarget{listId: todo.ListId, id: todo.Id}
+	}
+	return targets, nil
+}
+
+// dedupeKey is what two todos share when dedupe treats them as the same
+func dedupeKey(listId *string, parentKey, item string) string {
+	list := ""
+	if listId != nil {
+		list = *listId
+	}
+	return strconv.Quote(list) + " " + parentKey + " " + strings.ToLower(strings.TrimSpace(item))
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"listy-api/models"
+)
+
+func TestImportOrder(t *testing.T) {
+	parent := func(id int) *int { return &id }
+
+	order, err := importOrder([]models.Todo{
+		{Id: 1, Item: "Socks", ParentId: parent(2)},
+		{Id: 2, Item: "Pack bags"},
+		{Id: 3, Item: "Book flights", ParentId: parent(9)},
+	})
+	if err != nil || !slices.Equal(order, []int{1, 0, 2}) {
+		t.Errorf("importOrder() = %v, %v; want the subtask after its parent", order, err)
+	}
+
+	tests := []struct {
+		name  string
+		todos []models.Todo
+		want  string
+	}{
+		{"repeated ID", []models.Todo{{Id: 1, Item: "a"}, {Id: 1, Item: "b"}, {Item: "c", ParentId: parent(1)}}, "todos 1 and 2 both have ID 1"},
+		{"cycle", []models.Todo{{Id: 1, Item: "a", ParentId: parent(2)}, {Id: 2, Item: "b", ParentId: parent(1)}}, "cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := importOrder(tt.todos)
+			if !errors.Is(err, ErrInvalidImport) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("importOrder() error = %v, want ErrInvalidImport about %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"listy-api/database"
+	"listy-api/models"
+)
+
+// DefaultTrashRetention is how long deleted todos stay in the trash unless
+// LISTY_TRASH_RETENTION says otherwise
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// trashRetention is how long deleted todos can be restored; 0 keeps them until they are
+var trashRetention = DefaultTrashRetention
+
+// InitTrash reads LISTY_TRASH_RETENTION, a duration such as 720h after
+// which deleted todos are purged; 0 keeps them in the trash for good.
+// Call it after the environment has been loaded.
+func InitTrash() error {
+	if retention := os.Getenv("LISTY_TRASH_RETENTION"); retention != "" {
+		d, err := time.ParseDuration(retention)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid LISTY_TRASH_RETENTION %q (expected a duration such as 720h, or 0 to keep deleted todos)", retention)
+		}
+		trashRetention = d
+	}
+	return nil
+}
+
+// TrashRetention reports how long deleted todos stay in the trash; 0 means until they are restored
+func TrashRetention() time.Duration {
+	return trashRetention
+}
+
+// GetTrash returns the deleted todos the user can see, most recently deleted first
+func GetTrash(user string, page models.PageRequest) ([]models.Todo, string, error) {
+	q := database.TodoQuery{Trashed: true, Sort: []database.SortField{{Field: "deleted_at", Desc: true}}}
+	return queryVisible(user, q, page)
+}
+
+// RestoreTodo takes a todo out of the trash, along with the subtasks that
+// were deleted with it, unless ifMatch is set and the todo is no longer at
+// that version. A subtask deleted along with its parent can only come back
+// with the parent.
+func RestoreTodo(user string, id int, ifMatch int) (*models.Todo, error) {
+	return restoreTodo(nil, user, id, ifMatch)
+}
+
+func restoreTodo(j *journal, user string, id int, ifMatch int) (*models.Todo, error) {
+	todo, err := loadTodo(user, id, models.RoleEditor, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(todo, ifMatch); err != nil {
+		return nil, err
+	}
+	if err := checkRestore(todo); err != nil {
+		return nil, err
+	}
+
+	deletedAt := *todo.DeletedAt
+	restored, err := markDeleted(j, user, *todo, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := restoreSubtasks(j, user, id, deletedAt); err != nil {
+		return nil, err
+	}
+
+	// A restored pending subtask may reopen its parent
+	if err := syncParent(j, user, restored.ParentId); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// checkRestore rejects restoring a subtask whose parent is still in the trash
+func checkRestore(todo *models.Todo) error {
+	if todo.ParentId == nil {
+		return nil
+	}
+	parent, err := database.GetTodo(*todo.ParentId)
+	if err != nil {
+		return err
+	}
+	if parent.DeletedAt != nil {
+		return fmt.Errorf("%w: todo %d is in the trash with its parent %d, restore that instead", ErrInvalidParent, todo.Id, parent.Id)
+	}
+	return nil
+}
+
+// PurgeTrash permanently deletes the todos that went into the trash before
+// the given instant and returns how many there were
+func PurgeTrash(before time.Time) (int, error) {
+	expired, err := database.QueryTodos(database.TodoQuery{Trashed: true, DeletedBefore: &before})
+	if err != nil {
+		return 0, err
+	}
+	for _, todo := range expired {
+		// Stores that cascade may already have deleted it with its parent
+		if err := database.DeleteTodo(todo.Id); err != nil && !errors.Is(err, database.ErrNotFound) {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+// PurgeExpiredTrash purges the todos that have been in the trash longer than the retention
+func PurgeExpiredTrash() (int, error) {
+	if trashRetention == 0 {
+		return 0, nil
+	}
+	return PurgeTrash(now().Add(-trashRetention))
+}
+
+// trashSubtasks moves every descendant of the todo still outside the trash
+// into it, marked with the same deletion time as the todo
+func trashSubtasks(j *journal, user string, id int, deletedAt time.Time) error {
+	children, err := database.QueryTodos(database.TodoQuery{ParentIds: []int{id}})
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if _, err := markDeleted(j, user, child, &deletedAt); err != nil {
+			return err
+		}
+		if err := trashSubtasks(j, user, child.Id, deletedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreSubtasks takes the descendants deleted at the same time as their
+// ancestor back out of the trash; ones deleted earlier stay there
+func restoreSubtasks(j *journal, user string, id int, deletedAt time.Time) error {
+	children, err := database.QueryTodos(database.TodoQuery{ParentIds: []int{id}, Trashed: true})
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if !child.DeletedAt.Equal(deletedAt) {
+			continue
+		}
+		if _, err := markDeleted(j, user, child, nil); err != nil {
+			return err
+		}
+		if err := restoreSubtasks(j, user, child.Id, deletedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markDeleted stores the todo with the given deletion time, nil taking it
+// out of the trash, and records the change as the user's. When another
+// request changed the todo meanwhile, the latest version is re-read and
+// marked instead.
+func markDeleted(j *journal, user string, todo models.Todo, deletedAt *time.Time) (*models.Todo, error) {
+	action := models.EventDeleted
+	if deletedAt == nil {
+		action = models.EventRestored
+	}
+	for attempt := 1; ; attempt++ {
+		before := todo
+		todo.DeletedAt = deletedAt
+		err := database.UpdateTodo(todo.Id, todo)
+		if err == nil {
+			todo.Version++
+			return &todo, j.record(user, action, &before, todo)
+		}
+		if attempt == 3 || !errors.Is(err, database.ErrVersionConflict) {
+			return nil, err
+		}
+		latest, err := database.GetTodo(todo.Id)
+		if err != nil {
+			return nil, err
+		}
+		todo = *latest
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"listy-api/models"
+)
+
+func TestTrash(t *testing.T) {
+	useMemoryStore(t)
+	monday := time.Date(2026, time.March, 2, 9, 0, 0, 0, time.UTC)
+
+	create := func(req models.CreateTodoRequest) *models.Todo {
+		t.Helper()
+		todo, err := CreateTodo("", req)
+		if err != nil {
+			t.Fatalf("CreateTodo(%q) error = %v", req.Item, err)
+		}
+		return todo
+	}
+	visible := func() []int {
+		t.Helper()
+		todos, _, err := ListTodos("", models.TodoFilter{})
+		if err != nil {
+			t.Fatalf("ListTodos() error = %v", err)
+		}
+		return todoIDs(todos)
+	}
+	trash := func() []int {
+		t.Helper()
+		todos, _, err := GetTrash("", models.PageRequest{})
+		if err != nil {
+			t.Fatalf("GetTrash() error = %v", err)
+		}
+		return todoIDs(todos)
+	}
+
+	parent := create(models.CreateTodoRequest{Item: "Plan trip", AutoComplete: true})
+	booked := create(models.CreateTodoRequest{Item: "Book flights", ParentId: &parent.Id})
+	packed := create(models.CreateTodoRequest{Item: "Pack", ParentId: &parent.Id})
+	other := create(models.CreateTodoRequest{Item: "Water plants"})
+
+	// A subtask deleted on its own stays in the trash when its parent comes back
+	pinClock(t, monday)
+	if err := DeleteTodo("", booked.Id, 0); err != nil {
+		t.Fatalf("DeleteTodo(subtask) error = %v", err)
+	}
+	pinClock(t, monday.Add(time.Hour))
+	if err := DeleteTodo("", parent.Id, 0); err != nil {
+		t.Fatalf("DeleteTodo(parent) error = %v", err)
+	}
+	if ids := visible(); !slices.Equal(ids, []int{other.Id}) {
+		t.Errorf("todos after delete = %v, want only %d", ids, other.Id)
+	}
+	if ids := trash(); !slices.Equal(ids, []int{parent.Id, packed.Id, booked.Id}) {
+		t.Errorf("trash = %v, want latest deletions first", ids)
+	}
+	if _, err := GetTodoByID("", parent.Id); err == nil {
+		t.Error("GetTodoByID() found a todo in the trash")
+	}
+
+	if _, err := RestoreTodo("", packed.Id, 0); !errors.Is(err, ErrInvalidParent) {
+		t.Errorf("RestoreTodo(subtask of trashed parent) error = %v, want ErrInvalidParent", err)
+	}
+	if _, err := RestoreTodo("", other.Id, 0); err == nil {
+		t.Error("RestoreTodo() of a todo outside the trash succeeded")
+	}
+	restored, err := RestoreTodo("", parent.Id, 0)
+	if err != nil {
+		t.Fatalf("RestoreTodo() error = %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Version != 3 {
+		t.Errorf("RestoreTodo() = %+v, want out of the trash at version 3", restored)
+	}
+	if ids := visible(); !slices.Equal(ids, []int{parent.Id, packed.Id, other.Id}) {
+		t.Errorf("todos after restore = %v, want the parent back with the subtask deleted with it", ids)
+	}
+
+	// Only todos deleted before the cut-off are purged
+	pinClock(t, monday.Add(2*time.Hour))
+	if err := DeleteTodo("", other.Id, 0); err != nil {
+		t.Fatalf("DeleteTodo() error = %v", err)
+	}
+	purged, err := PurgeTrash(monday.Add(90 * time.Minute))
+	if err != nil {
+		t.Fatalf("PurgeTrash() error = %v", err)
+	}
+	if purged != 1 || !slices.Equal(trash(), []int{other.Id}) {
+		t.Errorf("PurgeTrash() = %d leaving %v, want 1 leaving [%d]", purged, trash(), other.Id)
+	}
+	if _, err := RestoreTodo("", booked.Id, 0); err == nil {
+		t.Error("RestoreTodo() of a purged todo succeeded")
+	}
+}
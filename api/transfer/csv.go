@@ -0,0 +1,164 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"listy-api/models"
+)
+
+// csvColumns are the columns of a CSV export. Imports need a header naming
+// at least item; the other columns may be left out or come in any order.
+var csvColumns = []string{
+	"id", "item", "done", "list_id", "parent_id", "created_at", "due_at", "remind_at",
+	"priority", "estimated_time", "category", "auto_complete", "recurrence", "tags",
+}
+
+// encodeCSV writes todos as CSV with a header row. Tags are separated by
+// spaces; times are RFC 3339.
+func encodeCSV(todos []models.Todo) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvColumns); err != nil {
+		return nil, err
+	}
+	for _, todo := range todos {
+		record := []string{
+			strconv.Itoa(todo.Id),
+			todo.Item,
+			strconv.FormatBool(todo.Done),
+			"",
+			"",
+			todo.CreatedAt.Format(time.RFC3339),
+			"",
+			"",
+			todo.Priority,
+			todo.EstimatedTime,
+			todo.Category,
+			strconv.FormatBool(todo.AutoComplete),
+			todo.Recurrence,
+			strings.Join(todo.Tags, " "),
+		}
+		if todo.ListId != nil {
+			record[3] = *todo.ListId
+		}
+		if todo.ParentId != nil {
+			record[4] = strconv.Itoa(*todo.ParentId)
+		}
+		if todo.DueAt != nil {
+			record[6] = todo.DueAt.Format(time.RFC3339)
+		}
+		if todo.RemindAt != nil {
+			record[7] = todo.RemindAt.Format(time.RFC3339)
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// decodeCSV reads CSV with a header row, ignoring columns it does not know
+func decodeCSV(data []byte, loc *time.Location) ([]models.Todo, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["item"]; !ok {
+		return nil, fmt.Errorf("%w: the CSV header has no item column", ErrInvalid)
+	}
+
+	todos := make([]models.Todo, 0, len(records)-1)
+	for n, record := range records[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		fail := func(column string, err error) ([]models.Todo, error) {
+			return nil, fmt.Errorf("%w: line %d, %s: %v", ErrInvalid, n+2, column, err)
+		}
+
+		todo := models.Todo{
+			Item:          field("item"),
+			Priority:      strings.ToLower(field("priority")),
+			EstimatedTime: field("estimated_time"),
+			Category:      field("category"),
+			Recurrence:    field("recurrence"),
+		}
+		if todo.Item == "" && strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		if value := field("id"); value != "" {
+			if todo.Id, err = strconv.Atoi(value); err != nil {
+				return fail("id", err)
+			}
+		}
+		if value := field("parent_id"); value != "" {
+			parent, err := strconv.Atoi(value)
+			if err != nil {
+				return fail("parent_id", err)
+			}
+			todo.ParentId = &parent
+		}
+		if tags := strings.Fields(field("tags")); len(tags) > 0 {
+			todo.Tags = tags
+		}
+		if value := field("list_id"); value != "" {
+			todo.ListId = &value
+		}
+		if todo.Done, err = parseBool(field("done")); err != nil {
+			return fail("done", err)
+		}
+		if todo.AutoComplete, err = parseBool(field("auto_complete")); err != nil {
+			return fail("auto_complete", err)
+		}
+		if value := field("created_at"); value != "" {
+			if todo.CreatedAt, err = parseTime(value, loc); err != nil {
+				return fail("created_at", err)
+			}
+		}
+		for _, column := range []struct {
+			name string
+			dst  **time.Time
+		}{{"due_at", &todo.DueAt}, {"remind_at", &todo.RemindAt}} {
+			if value := field(column.name); value != "" {
+				t, err := parseTime(value, loc)
+				if err != nil {
+					return fail(column.name, err)
+				}
+				*column.dst = &t
+			}
+		}
+		todos = append(todos, todo)
+	}
+	return todos, nil
+}
+
+// parseBool reads true/false, 1/0, yes/no or x; empty is false
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", "false", "0", "no", "n":
+		return false, nil
+	case "true", "1", "yes", "y", "x":
+		return true, nil
+	}
+	return false, fmt.Errorf("%q is not true or false", value)
+}
@@ -0,0 +1,101 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"listy-api/models"
+)
+
+// jsonTodo is a todo in a JSON export: the fields of models.Todo that mean
+// something outside the store it came from. Field names match without
+// regard to case when decoding, so the legacy {"Id", "Item", "Done"} files
+// of the first listy CLI read as they are.
+type jsonTodo struct {
+	Id        int        `json:"id,omitempty"`
+	Item      string     `json:"item"`
+	Done      bool       `json:"done"`
+	ListId    *string    `json:"list_id,omitempty"`
+	ParentId  *int       `json:"parent_id,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+	RemindAt  *time.Time `json:"remind_at,omitempty"`
+
+	Priority      string   `json:"priority,omitempty"`
+	EstimatedTime string   `json:"estimated_time,omitempty"`
+	Category      string   `json:"category,omitempty"`
+	AutoComplete  bool     `json:"auto_complete,omitempty"`
+	Recurrence    string   `json:"recurrence,omitempty"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// encodeJSON writes todos as an indented JSON array
+func encodeJSON(todos []models.Todo) ([]byte, error) {
+	out := make([]jsonTodo, len(todos))
+	for i, todo := range todos {
+		out[i] = jsonTodo{
+			Id:            todo.Id,
+			Item:          todo.Item,
+			Done:          todo.Done,
+			ListId:        todo.ListId,
+			ParentId:      todo.ParentId,
+			CreatedAt:     &todo.CreatedAt,
+			DueAt:         todo.DueAt,
+			RemindAt:      todo.RemindAt,
+			Priority:      todo.Priority,
+			EstimatedTime: todo.EstimatedTime,
+			Category:      todo.Category,
+			AutoComplete:  todo.AutoComplete,
+			Recurrence:    todo.Recurrence,
+			Tags:          todo.Tags,
+		}
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// decodeJSON reads an array of todos, or an object holding one under
+// "todos" or "data" as API responses do
+func decodeJSON(data []byte) ([]models.Todo, error) {
+	var in []jsonTodo
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var wrapped struct {
+			Todos []jsonTodo `json:"todos"`
+			Data  []jsonTodo `json:"data"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		in = append(wrapped.Todos, wrapped.Data...)
+	} else if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	todos := make([]models.Todo, len(in))
+	for i, todo := range in {
+		todos[i] = models.Todo{
+			Id:            todo.Id,
+			Item:          todo.Item,
+			Done:          todo.Done,
+			ListId:        todo.ListId,
+			ParentId:      todo.ParentId,
+			DueAt:         todo.DueAt,
+			RemindAt:      todo.RemindAt,
+			Priority:      todo.Priority,
+			EstimatedTime: todo.EstimatedTime,
+			Category:      todo.Category,
+			AutoComplete:  todo.AutoComplete,
+			Recurrence:    todo.Recurrence,
+			Tags:          todo.Tags,
+		}
+		if todo.CreatedAt != nil {
+			todos[i].CreatedAt = *todo.CreatedAt
+		}
+	}
+	return todos, nil
+}
@@ -0,0 +1,143 @@
+package transfer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"listy-api/models"
+)
+
+// encodeMarkdown writes todos as a "- [ ]" checklist with subtasks indented
+// under their parent. Todos in the main list come first; every other list
+// follows under a "## list_id" heading.
+func encodeMarkdown(todos []models.Todo, loc *time.Location) []byte {
+	var b strings.Builder
+	for i, list := range byList(todos) {
+		if list[0].ListId != nil {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString("## " + *list[0].ListId + "\n\n")
+		}
+
+		inList := make(map[int]bool)
+		children := make(map[int][]models.Todo)
+		for _, todo := range list {
+			inList[todo.Id] = true
+		}
+		var roots []models.Todo
+		for _, todo := range list {
+			if todo.ParentId != nil && inList[*todo.ParentId] {
+				children[*todo.ParentId] = append(children[*todo.ParentId], todo)
+			} else {
+				roots = append(roots, todo)
+			}
+		}
+
+		var write func(todo models.Todo, depth int)
+		write = func(todo models.Todo, depth int) {
+			box := "[ ]"
+			if todo.Done {
+				box = "[x]"
+			}
+			b.WriteString(strings.Repeat("  ", depth) + "- " + box + " " + todo.Item + inlineSuffix(todo, loc) + "\n")
+			for _, child := range children[todo.Id] {
+				write(child, depth+1)
+			}
+		}
+		for _, todo := range roots {
+			write(todo, 0)
+		}
+	}
+	return []byte(b.String())
+}
+
+// decodeMarkdown reads "- [ ]" and "- [x]" items, with "*" or "+" bullets
+// too. An item indented under another is its subtask, and a heading puts
+// the items after it in the list it names ("main" for the main list).
+// Other lines, plain bullets included, are ignored.
+func decodeMarkdown(data []byte, loc *time.Location) ([]models.Todo, error) {
+	type open struct{ indent, id int }
+	var todos []models.Todo
+	var listId *string
+	var parents []open
+
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if heading, ok := markdownHeading(line); ok {
+			listId = nil
+			if !strings.EqualFold(heading, "main") {
+				listId = &heading
+			}
+			parents = nil
+			continue
+		}
+
+		indent, done, text, ok := checklistItem(line)
+		if !ok {
+			continue
+		}
+		words, tags, due, err := splitInline(text, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalid, n+1, err)
+		}
+
+		todo := models.Todo{
+			Id:     len(todos) + 1,
+			Item:   strings.Join(words, " "),
+			Done:   done,
+			ListId: listId,
+			DueAt:  due,
+			Tags:   tags,
+		}
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+		if len(parents) > 0 {
+			parent := parents[len(parents)-1].id
+			todo.ParentId = &parent
+		}
+		parents = append(parents, open{indent, todo.Id})
+		todos = append(todos, todo)
+	}
+	return todos, nil
+}
+
+// markdownHeading returns the text of an ATX heading such as "## Work"
+func markdownHeading(line string) (string, bool) {
+	trimmed := strings.TrimLeft(line, "#")
+	if trimmed == line || !strings.HasPrefix(trimmed, " ") {
+		return "", false
+	}
+	heading := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(trimmed), "#"))
+	return heading, heading != ""
+}
+
+// checklistItem splits a "  - [x] text" line into its indentation in
+// columns, with tabs counting four, whether it is checked, and its text
+func checklistItem(line string) (indent int, done bool, text string, ok bool) {
+	rest := line
+	for len(rest) > 0 && (rest[0] == ' ' || rest[0] == '\t') {
+		if rest[0] == '\t' {
+			indent += 4
+		} else {
+			indent++
+		}
+		rest = rest[1:]
+	}
+	if len(rest) < 5 || !strings.ContainsRune("-*+", rune(rest[0])) || rest[1] != ' ' || rest[2] != '[' || rest[4] != ']' {
+		return 0, false, "", false
+	}
+	switch rest[3] {
+	case ' ':
+	case 'x', 'X':
+		done = true
+	default:
+		return 0, false, "", false
+	}
+	if len(rest) > 5 && rest[5] != ' ' && rest[5] != '\t' {
+		return 0, false, "", false
+	}
+	return indent, done, strings.TrimSpace(rest[5:]), true
+}
@@ -0,0 +1,116 @@
+package transfer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"listy-api/models"
+)
+
+// todoTxtPriorities maps todo priorities to todo.txt's (A) to (C)
+var todoTxtPriorities = map[string]string{"high": "A", "medium": "B", "low": "C"}
+
+// encodeTodoTxt writes one todo.txt line per todo. The list becomes a
+// +project and the category a @context, with spaces turned into _ since
+// neither may contain one. Completed todos keep their priority as pri:,
+// as the format suggests, and a recurrence is written as rrule: without
+// its DTSTART.
+func encodeTodoTxt(todos []models.Todo, loc *time.Location) []byte {
+	var b strings.Builder
+	for _, todo := range todos {
+		priority := todoTxtPriorities[todo.Priority]
+		if todo.Done {
+			b.WriteString("x ")
+		} else if priority != "" {
+			b.WriteString("(" + priority + ") ")
+		}
+		b.WriteString(todo.Item)
+		if todo.ListId != nil {
+			b.WriteString(" +" + strings.Join(strings.Fields(*todo.ListId), "_"))
+		}
+		if todo.Category != "" {
+			b.WriteString(" @" + strings.Join(strings.Fields(todo.Category), "_"))
+		}
+		b.WriteString(inlineSuffix(todo, loc))
+		if todo.Done && priority != "" {
+			b.WriteString(" pri:" + priority)
+		}
+		if todo.Recurrence != "" {
+			rule := todo.Recurrence[strings.LastIndex(todo.Recurrence, "\n")+1:]
+			b.WriteString(" rrule:" + strings.TrimPrefix(rule, "RRULE:"))
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String())
+}
+
+// decodeTodoTxt reads todo.txt lines: "x" for done, then the optional
+// completion and creation dates, which are skipped, and an (A) to (Z)
+// priority, where A is high, B medium and the rest low. The first +project
+// names the list and the first @context the category; #tags, due:,
+// pri: and rrule: are read as well. Any other key:value stays in the text.
+func decodeTodoTxt(data []byte, loc *time.Location) ([]models.Todo, error) {
+	var todos []models.Todo
+	for n, line := range strings.Split(string(data), "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		fail := func(err error) ([]models.Todo, error) {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalid, n+1, err)
+		}
+
+		var todo models.Todo
+		if words[0] == "x" {
+			todo.Done = true
+			words = words[1:]
+		}
+		if len(words) > 0 && len(words[0]) == 3 && words[0][0] == '(' && words[0][2] == ')' && words[0][1] >= 'A' && words[0][1] <= 'Z' {
+			todo.Priority = todoTxtPriority(words[0][1])
+			words = words[1:]
+		}
+		for i := 0; i < 2 && len(words) > 0; i++ {
+			if _, err := time.Parse(dateLayout, words[0]); err != nil {
+				break
+			}
+			words = words[1:]
+		}
+
+		var rest []string
+		for _, word := range words {
+			switch {
+			case len(word) > 1 && word[0] == '+' && todo.ListId == nil:
+				list := word[1:]
+				todo.ListId = &list
+			case len(word) > 1 && word[0] == '@' && todo.Category == "":
+				todo.Category = word[1:]
+			case strings.HasPrefix(word, "pri:") && len(word) == 5 && word[4] >= 'A' && word[4] <= 'Z':
+				todo.Priority = todoTxtPriority(word[4])
+			case strings.HasPrefix(word, "rrule:") && len(word) > 6:
+				todo.Recurrence = word[6:]
+			default:
+				rest = append(rest, word)
+			}
+		}
+
+		text, tags, due, err := splitInline(strings.Join(rest, " "), loc)
+		if err != nil {
+			return fail(err)
+		}
+		todo.Item, todo.Tags, todo.DueAt = strings.Join(text, " "), tags, due
+		todos = append(todos, todo)
+	}
+	return todos, nil
+}
+
+// todoTxtPriority maps a todo.txt priority letter to a todo priority
+func todoTxtPriority(letter byte) string {
+	switch letter {
+	case 'A':
+		return "high"
+	case 'B':
+		return "medium"
+	}
+	return "low"
+}
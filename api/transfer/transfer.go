@@ -0,0 +1,253 @@
+// Package transfer converts todos to and from the files listy exports and
+// imports: JSON, CSV, Markdown checklists and todo.txt.
+//
+// JSON and CSV keep every field that can be imported. Markdown keeps the
+// text, done state, list, subtask nesting, tags and due date; todo.txt
+// keeps the same apart from nesting, plus priority, category and
+// recurrence.
+package transfer
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"listy-api/core"
+	"listy-api/models"
+)
+
+// ErrInvalid is wrapped by every error Decode returns
+var ErrInvalid = core.NewError(core.ErrValidation, "invalid import")
+
+// Format is a file format todos can be exported to and imported from
+type Format string
+
+// Supported formats
+const (
+	JSON     Format = "json"
+	CSV      Format = "csv"
+	Markdown Format = "markdown"
+	TodoTxt  Format = "todotxt"
+)
+
+// Formats lists every supported format
+var Formats = []Format{JSON, CSV, Markdown, TodoTxt}
+
+// ContentType is the media type of files in the format
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case Markdown:
+		return "text/markdown; charset=utf-8"
+	case TodoTxt:
+		return "text/plain; charset=utf-8"
+	}
+	return "application/json; charset=utf-8"
+}
+
+// Extension is the usual file name extension of the format, without the dot
+func (f Format) Extension() string {
+	switch f {
+	case CSV:
+		return "csv"
+	case Markdown:
+		return "md"
+	case TodoTxt:
+		return "txt"
+	}
+	return "json"
+}
+
+// Detect guesses the format of data: JSON when it starts with [ or {, a
+// Markdown checklist when a line is a "- [ ]" item, CSV when the first line
+// is a header with an item column, and todo.txt otherwise
+func Detect(data []byte) Format {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return JSON
+	}
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		if _, _, _, ok := checklistItem(line); ok {
+			return Markdown
+		}
+	}
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		for _, column := range strings.Split(line, ",") {
+			if strings.EqualFold(strings.Trim(strings.TrimSpace(column), `"`), "item") {
+				return CSV
+			}
+		}
+		break
+	}
+	return TodoTxt
+}
+
+// Encode writes todos in the format. Due dates at 23:59 in loc, which is
+// what a date alone is imported as, are written as that date in formats
+// meant to be edited by hand.
+func Encode(f Format, todos []models.Todo, loc *time.Location) ([]byte, error) {
+	switch f {
+	case JSON:
+		return encodeJSON(todos)
+	case CSV:
+		return encodeCSV(todos)
+	case Markdown:
+		return encodeMarkdown(todos, loc), nil
+	case TodoTxt:
+		return encodeTodoTxt(todos, loc), nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", f)
+}
+
+// Decode reads todos in the format. Each todo's Id and ParentId link
+// subtasks to their parent within the file and are 0 and nil when the
+// format has no IDs. A due date without a time means 23:59 that day in loc.
+func Decode(f Format, data []byte, loc *time.Location) ([]models.Todo, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	var todos []models.Todo
+	var err error
+	switch f {
+	case JSON:
+		todos, err = decodeJSON(data)
+	case CSV:
+		todos, err = decodeCSV(data, loc)
+	case Markdown:
+		todos, err = decodeMarkdown(data, loc)
+	case TodoTxt:
+		todos, err = decodeTodoTxt(data, loc)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalid, f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[int]bool)
+	for i, todo := range todos {
+		if strings.TrimSpace(todo.Item) == "" {
+			return nil, fmt.Errorf("%w: todo %d has no text", ErrInvalid, i+1)
+		}
+		if todo.Id != 0 {
+			if ids[todo.Id] {
+				return nil, fmt.Errorf("%w: ID %d is used by more than one todo", ErrInvalid, todo.Id)
+			}
+			ids[todo.Id] = true
+		}
+	}
+	return todos, nil
+}
+
+// dateLayout is how due dates without a time are written
+const dateLayout = "2006-01-02"
+
+// parseTime reads an RFC 3339 timestamp, or a date meaning 23:59 that day in loc
+func parseTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(dateLayout, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date (YYYY-MM-DD) nor an RFC 3339 timestamp", value)
+	}
+	return day.Add(23*time.Hour + 59*time.Minute), nil
+}
+
+// formatDue writes a due date as a date when it is 23:59 in loc, which is
+// how a date alone is read, and as an RFC 3339 timestamp otherwise
+func formatDue(due time.Time, loc *time.Location) string {
+	local := due.In(loc)
+	if local.Hour() == 23 && local.Minute() == 59 && local.Second() == 0 && local.Nanosecond() == 0 {
+		return local.Format(dateLayout)
+	}
+	return due.Format(time.RFC3339)
+}
+
+// splitInline takes #tags and due:date out of checklist and todo.txt text,
+// returning the rest of the words. A #tag needs at least one letter, so
+// "#1" stays part of the text.
+func splitInline(text string, loc *time.Location) (rest []string, tags []string, due *time.Time, err error) {
+	for _, word := range strings.Fields(text) {
+		if tag, ok := inlineTag(word); ok {
+			tags = append(tags, tag)
+			continue
+		}
+		if value, ok := strings.CutPrefix(word, "due:"); ok && value != "" {
+			t, err := parseTime(value, loc)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			due = &t
+			continue
+		}
+		rest = append(rest, word)
+	}
+	return rest, tags, due, nil
+}
+
+// inlineTag returns the tag a "#word" names
+func inlineTag(word string) (string, bool) {
+	name, ok := strings.CutPrefix(word, "#")
+	if !ok || name == "" || !strings.ContainsFunc(name, unicode.IsLetter) {
+		return "", false
+	}
+	for _, r := range name {
+		if r != '-' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return "", false
+		}
+	}
+	return strings.ToLower(name), true
+}
+
+// inlineSuffix writes a todo's tags and due date the way splitInline reads them
+func inlineSuffix(todo models.Todo, loc *time.Location) string {
+	var b strings.Builder
+	for _, tag := range todo.Tags {
+		b.WriteString(" #" + tag)
+	}
+	if todo.DueAt != nil {
+		b.WriteString(" due:" + formatDue(*todo.DueAt, loc))
+	}
+	return b.String()
+}
+
+// byList groups todos by list, main list first and then in order of first
+// appearance, keeping their order within each list
+func byList(todos []models.Todo) [][]models.Todo {
+	var keys []string
+	groups := make(map[string][]models.Todo)
+	for _, todo := range todos {
+		key := ""
+		if todo.ListId != nil {
+			key = *todo.ListId
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], todo)
+	}
+	slices.SortStableFunc(keys, func(a, b string) int {
+		switch {
+		case a == b:
+			return 0
+		case a == "":
+			return -1
+		case b == "":
+			return 1
+		}
+		return 0
+	})
+
+	lists := make([][]models.Todo, 0, len(keys))
+	for _, key := range keys {
+		lists = append(lists, groups[key])
+	}
+	return lists
+}
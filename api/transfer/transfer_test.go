@@ -0,0 +1,180 @@
+package transfer
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"listy-api/models"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+// sample is a small tree of todos using every field the formats can keep
+func sample() []models.Todo {
+	created := time.Date(2026, time.March, 1, 8, 0, 0, 0, time.UTC)
+	return []models.Todo{
+		{Id: 1, Item: "Buy milk", CreatedAt: created, DueAt: ptr(time.Date(2026, time.March, 2, 23, 59, 0, 0, time.UTC)), Priority: "high", Category: "errands", Tags: []string{"home", "shop"}},
+		{Id: 2, Item: "Oat milk", Done: true, ParentId: ptr(1), CreatedAt: created},
+		{Id: 3, Item: "Ship release", ListId: ptr("work"), CreatedAt: created, DueAt: ptr(time.Date(2026, time.March, 3, 9, 30, 0, 0, time.UTC)), Priority: "low", Recurrence: "FREQ=WEEKLY;BYDAY=MO"},
+		{Id: 4, Item: "Write notes", Done: true, ListId: ptr("work"), CreatedAt: created, Priority: "medium", EstimatedTime: "1 hour", AutoComplete: true, RemindAt: ptr(time.Date(2026, time.March, 3, 8, 0, 0, 0, time.UTC))},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{Markdown, "- [ ] Buy milk #home #shop due:2026-03-02\n" +
+			"  - [x] Oat milk\n" +
+			"\n## work\n\n" +
+			"- [ ] Ship release due:2026-03-03T09:30:00Z\n" +
+			"- [x] Write notes\n"},
+		{TodoTxt, "(A) Buy milk @errands #home #shop due:2026-03-02\n" +
+			"x Oat milk\n" +
+			"(C) Ship release +work due:2026-03-03T09:30:00Z rrule:FREQ=WEEKLY;BYDAY=MO\n" +
+			"x Write notes +work pri:B\n"},
+		{CSV, "id,item,done,list_id,parent_id,created_at,due_at,remind_at,priority,estimated_time,category,auto_complete,recurrence,tags\n" +
+			"1,Buy milk,false,,,2026-03-01T08:00:00Z,2026-03-02T23:59:00Z,,high,,errands,false,,home shop\n" +
+			"2,Oat milk,true,,1,2026-03-01T08:00:00Z,,,,,,false,,\n" +
+			"3,Ship release,false,work,,2026-03-01T08:00:00Z,2026-03-03T09:30:00Z,,low,,,false,FREQ=WEEKLY;BYDAY=MO,\n" +
+			"4,Write notes,true,work,,2026-03-01T08:00:00Z,,2026-03-03T08:00:00Z,medium,1 hour,,true,,\n"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			got, err := Encode(tt.format, sample(), time.UTC)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Encode() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []Format{JSON, CSV} {
+		t.Run(string(format), func(t *testing.T) {
+			data, err := Encode(format, sample(), time.UTC)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			got, err := Decode(format, data, time.UTC)
+			if err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, sample()) {
+				t.Errorf("Decode(Encode()) = %+v, want %+v", got, sample())
+			}
+			if detected := Detect(data); detected != format {
+				t.Errorf("Detect() = %q, want %q", detected, format)
+			}
+		})
+	}
+}
+
+func TestDecode_Markdown(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := "# Weekend\n\nSome notes\n\n" +
+		"- [ ] Clean the flat #home\n" +
+		"  - [X] Kitchen\n" +
+		"    * [ ] Oven due:2026-03-07\n" +
+		"  - [ ] Bathroom\n" +
+		"- not a todo\n" +
+		"## main\n" +
+		"+ [x] Call #1 bank\n"
+	if Detect([]byte(data)) != Markdown {
+		t.Fatalf("Detect() = %q, want markdown", Detect([]byte(data)))
+	}
+
+	got, err := Decode(Markdown, []byte(data), berlin)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	oven := time.Date(2026, time.March, 7, 23, 59, 0, 0, berlin)
+	want := []models.Todo{
+		{Id: 1, Item: "Clean the flat", ListId: ptr("Weekend"), Tags: []string{"home"}},
+		{Id: 2, Item: "Kitchen", Done: true, ListId: ptr("Weekend"), ParentId: ptr(1)},
+		{Id: 3, Item: "Oven", ListId: ptr("Weekend"), ParentId: ptr(2), DueAt: &oven},
+		{Id: 4, Item: "Bathroom", ListId: ptr("Weekend"), ParentId: ptr(1)},
+		{Id: 5, Item: "Call #1 bank", Done: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecode_TodoTxt(t *testing.T) {
+	data := "(A) 2026-03-01 Call mom +family @phone #weekly due:2026-03-02 http://example.com\n" +
+		"\n" +
+		"x 2026-03-02 2026-03-01 Pay rent +home pri:B rrule:FREQ=MONTHLY\n" +
+		"(D) xylophone lessons\n"
+	if Detect([]byte(data)) != TodoTxt {
+		t.Fatalf("Detect() = %q, want todotxt", Detect([]byte(data)))
+	}
+
+	got, err := Decode(TodoTxt, []byte(data), time.UTC)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	want := []models.Todo{
+		{Item: "Call mom http://example.com", ListId: ptr("family"), Category: "phone", Priority: "high", Tags: []string{"weekly"}, DueAt: ptr(time.Date(2026, time.March, 2, 23, 59, 0, 0, time.UTC))},
+		{Item: "Pay rent", Done: true, ListId: ptr("home"), Priority: "medium", Recurrence: "FREQ=MONTHLY"},
+		{Item: "xylophone lessons", Priority: "low"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecode_LegacyJSON(t *testing.T) {
+	data, err := os.ReadFile("../../todos.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Detect(data) != JSON {
+		t.Fatalf("Detect() = %q, want json", Detect(data))
+	}
+
+	todos, err := Decode(JSON, data, time.UTC)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if len(todos) == 0 || todos[0].Id != 1 || todos[0].Item != "Buy Ramen" || todos[0].Done {
+		t.Errorf("Decode() = %+v, want the legacy todos starting with 1 Buy Ramen", todos)
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		data   string
+		want   string
+	}{
+		{"json syntax", JSON, `[{"item": }]`, "invalid character"},
+		{"json without text", JSON, `[{"item": "a"}, {"done": true}]`, "todo 2 has no text"},
+		{"json duplicate id", JSON, `[{"id": 1, "item": "a"}, {"id": 1, "item": "b"}]`, "ID 1 is used by more than one todo"},
+		{"csv without item", CSV, "id,done\n1,true\n", "no item column"},
+		{"csv bad done", CSV, "item,done\nmilk,maybe\n", "line 2, done"},
+		{"csv bad due", CSV, "item,due_at\nmilk,tomorrow\n", "line 2, due_at"},
+		{"markdown bad due", Markdown, "- [ ] a\n- [ ] b due:soon\n", "line 2"},
+		{"todotxt empty text", TodoTxt, "call\n(A) #home\n", "todo 2 has no text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.format, []byte(tt.data), time.UTC)
+			if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Decode() error = %v, want ErrInvalid mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+	"github.com/supabase-community/supabase-go"
+
+	"listy-api/core"
+	"listy-api/models"
+)
+
+// Backend is where todolist keeps todos: Supabase itself, or the API
+type Backend interface {
+	Load() ([]Todo, error)
+	Add(item string) (Todo, error)
+	Update(todo Todo) error // Saves the todo's text and done state
+	Delete(todo Todo) error
+}
+
+// NewBackend picks the backend named by LISTY_BACKEND: "direct" (the
+// default) talks to Supabase, "api" to the API at LISTY_API_URL
+func NewBackend() (Backend, error) {
+	switch backend := os.Getenv("LISTY_BACKEND"); backend {
+	case "", "direct":
+		return InitSupabase()
+	case "api":
+		apiURL := os.Getenv("LISTY_API_URL")
+		if apiURL == "" {
+			apiURL = "http://localhost:8080"
+		}
+		return &apiBackend{baseURL: apiURL, token: os.Getenv("LISTY_TOKEN"), httpClient: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown LISTY_BACKEND %q (expected direct or api)", backend)
+	}
+}
+
+// supabaseBackend reads and writes the todos table directly
+type supabaseBackend struct {
+	client *supabase.Client
+	nextId int // Todos added here get IDs counting up from the highest loaded
+}
+
+// todoRow is the part of a todo this binary writes; the other columns keep
+// their defaults
+type todoRow struct {
+	Id   int    `json:"id"`
+	Item string `json:"item"`
+	Done bool   `json:"done"`
+}
+
+// InitSupabase initializes the Supabase client
+func InitSupabase() (Backend, error) {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		// .env file is optional, continue without it
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+
+	supabaseURL := os.Getenv("SUPABASE_URL")
+	supabaseKey := os.Getenv("SUPABASE_KEY")
+
+	if supabaseURL == "" || supabaseKey == "" {
+		return nil, fmt.Errorf("SUPABASE_URL and SUPABASE_KEY must be set in environment variables or .env file")
+	}
+
+	client, err := supabase.NewClient(supabaseURL, supabaseKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Supabase client: %v", err)
+	}
+	return &supabaseBackend{client: client, nextId: 1}, nil
+}
+
+// Load loads todos from Supabase
+func (b *supabaseBackend) Load() ([]Todo, error) {
+	var todos []Todo
+	data, _, err := b.client.From("todos").Select("*", "", false).Execute()
+	if err != nil {
+		return nil, fmt.Errorf("error loading todos from Supabase: %v", err)
+	}
+
+	// Parse the JSON response
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &todos)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing todos: %v", err)
+		}
+	}
+
+	// If no todos found, return empty slice
+	if todos == nil {
+		todos = []Todo{}
+	}
+	b.nextId = max(b.nextId, core.GetNextID(todos))
+	return todos, nil
+}
+
+// Add inserts a todo with the next ID into Supabase
+func (b *supabaseBackend) Add(item string) (Todo, error) {
+	todo := Todo{Id: b.nextId, Item: item}
+	row := todoRow{Id: todo.Id, Item: todo.Item, Done: todo.Done}
+	if _, _, err := b.client.From("todos").Insert(row, false, "", "", "").Execute(); err != nil {
+		return Todo{}, fmt.Errorf("error inserting todo to Supabase: %v", err)
+	}
+	b.nextId++
+	return todo, nil
+}
+
+// Update updates a single todo in Supabase by ID
+func (b *supabaseBackend) Update(todo Todo) error {
+	row := todoRow{Id: todo.Id, Item: todo.Item, Done: todo.Done}
+	_, _, err := b.client.From("todos").Update(row, "", "").Eq("id", strconv.Itoa(todo.Id)).Execute()
+	if err != nil {
+		return fmt.Errorf("error updating todo in Supabase: %v", err)
+	}
+	return nil
+}
+
+// Delete deletes a single todo from Supabase by ID
+func (b *supabaseBackend) Delete(todo Todo) error {
+	_, _, err := b.client.From("todos").Delete("", "").Eq("id", strconv.Itoa(todo.Id)).Execute()
+	if err != nil {
+		return fmt.Errorf("error deleting todo from Supabase: %v", err)
+	}
+	return nil
+}
+
+// apiBackend goes through the API, so IDs, versions and ownership are the
+// API's. Changes carry the version the todo was loaded at, and fail if
+// someone else changed it since.
+type apiBackend struct {
+	baseURL    string
+	token      string // Bearer token; empty when the API runs without authentication
+	httpClient *http.Client
+}
+
+// Load fetches every todo, following next_cursor page by page
+func (b *apiBackend) Load() ([]Todo, error) {
+	todos := []Todo{}
+	query := url.Values{"limit": {"100"}}
+	for {
+		var page []Todo
+		cursor, err := b.do("GET", "/api/todos?"+query.Encode(), 0, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, page...)
+		if cursor == "" {
+			return todos, nil
+		}
+		query.Set("cursor", cursor)
+	}
+}
+
+func (b *apiBackend) Add(item string) (Todo, error) {
+	var todo Todo
+	_, err := b.do("POST", "/api/todos", 0, map[string]string{"item": item}, &todo)
+	return todo, err
+}
+
+func (b *apiBackend) Update(todo Todo) error {
+	_, err := b.do("PUT", "/api/todos/"+strconv.Itoa(todo.Id), todo.Version, map[string]any{"item": todo.Item, "done": todo.Done}, nil)
+	return err
+}
+
+func (b *apiBackend) Delete(todo Todo) error {
+	_, err := b.do("DELETE", "/api/todos/"+strconv.Itoa(todo.Id), todo.Version, nil, nil)
+	return err
+}
+
+// do sends a request, sending If-Match when version is set, and decodes
+// the data of the response into out, returning its next_cursor
+func (b *apiBackend) do(method, path string, version int, body, out any) (string, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return "", fmt.Errorf("failed to marshal request: %v", err)
+		}
+	}
+	req, err := http.NewRequest(method, b.baseURL+path, &reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	if version != 0 {
+		req.Header.Set("If-Match", strconv.Quote(strconv.Itoa(version)))
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var apiResp struct {
+		Data       json.RawMessage `json:"data"`
+		Error      models.Error    `json:"error"`
+		NextCursor string          `json:"next_cursor"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return "", fmt.Errorf("failed to parse response (status %d): %v", resp.StatusCode, err)
+	}
+	switch {
+	case apiResp.Error.Code == models.CodeVersionConflict:
+		return "", fmt.Errorf("the todo was changed by someone else; run the command again")
+	case resp.StatusCode >= 300:
+		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, apiResp.Error.Message)
+	}
+	if out != nil && len(apiResp.Data) > 0 {
+		if err := json.Unmarshal(apiResp.Data, out); err != nil {
+			return "", fmt.Errorf("failed to parse response data: %v", err)
+		}
+	}
+	return apiResp.NextCursor, nil
+}
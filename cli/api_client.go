@@ -1,310 +1,380 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
+	"iter"
 	"net/http"
-	"strconv"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
+
+	"listy-api/core"
+	"listy-api/models"
+	"listy-api/sdk"
 )
 
-// APIClient handles all API communication
+// APIClient handles all API communication, through the API's Go SDK
 type APIClient struct {
-	baseURL    string
-	httpClient *http.Client
+	api   *sdk.Client
+	probe *sdk.Client // Without retries, so an unreachable API is noticed at once
+	// Set while the API cannot be reached: todos are read from and changed
+	// in the offline copy, and the changes are queued
+	offline *OfflineState
 }
 
-// NewAPIClient creates a new API client
-func NewAPIClient(baseURL string) *APIClient {
+// NewAPIClient creates a new API client; a non-empty token is sent as a
+// bearer token with every request
+func NewAPIClient(baseURL, token string) *APIClient {
 	if baseURL == "" {
 		baseURL = "http://localhost:8080"
 	}
+	httpClient := &http.Client{Timeout: 10 * time.Second}
 	return &APIClient{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		api:   sdk.New(baseURL, sdk.WithToken(token), sdk.WithHTTPClient(httpClient)),
+		probe: sdk.New(baseURL, sdk.WithHTTPClient(httpClient), sdk.WithRetries(0, 0)),
 	}
 }
 
-// APIResponse represents the standard API response format
-type APIResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data"`
-	Error   string      `json:"error,omitempty"`
-	Message string      `json:"message,omitempty"`
+// ErrConflict is returned when a conditional change fails because the todo
+// was changed by someone else since it was read
+var ErrConflict = errors.New("the todo was changed by someone else in the meantime")
+
+// apiError adapts an error from the SDK for the commands: a failed
+// conditional change becomes ErrConflict, and a challenge for a (new)
+// token points at "listy login"
+func apiError(err error) error {
+	var apiErr *sdk.Error
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	if apiErr.Code == models.CodeVersionConflict {
+		return ErrConflict
+	}
+	if apiErr.Code == models.CodeUnauthorized && apiErr.Header.Get("WWW-Authenticate") != "" {
+		return fmt.Errorf("%w (run \"listy login\" to sign in)", err)
+	}
+	return err
 }
 
-// Todo represents a todo item (matches API model)
-type Todo struct {
-	Id   int    `json:"id"`
-	Item string `json:"item"`
-	Done bool   `json:"done"`
+// conflicted reports whether a batch operation failed because the todo was
+// changed by someone else since it was read
+func conflicted(result BatchResult) bool {
+	return result.Code == models.CodeVersionConflict
 }
 
-// CreateTodoRequest represents the request for creating a todo
-type CreateTodoRequest struct {
-	Item string `json:"item"`
+// result returns a call's value with its error adapted by apiError
+func result[T any](value T, err error) (T, error) {
+	return value, apiError(err)
 }
 
-// UpdateTodoRequest represents the request for updating a todo
-type UpdateTodoRequest struct {
-	Item *string `json:"item,omitempty"`
-	Done *bool   `json:"done,omitempty"`
+// The models shared with the API
+type (
+	Todo                 = core.Todo
+	CreateTodoRequest    = models.CreateTodoRequest
+	UpdateTodoRequest    = models.UpdateTodoRequest
+	TodoFilter           = models.TodoFilter
+	BatchOperation       = models.BatchOperation
+	BatchResult          = models.BatchResult
+	Activity             = models.Activity
+	Tag                  = models.Tag
+	CalendarSubscription = models.CalendarSubscription
+	ImportOptions        = models.ImportOptions
+	ImportResult         = models.ImportResult
+	List                 = models.List
+	UpdateListRequest    = models.UpdateListRequest
+	Member               = models.ListMember
+	AuthResponse         = models.AuthResponse
+	TodoEvent            = models.TodoEvent
+)
+
+// localZone is the local UTC offset, sent so "today" and dates without a
+// time mean the caller's calendar day
+func localZone() string {
+	return time.Now().Format("-07:00")
 }
 
 // GetTodos fetches all todos from the API
 func (c *APIClient) GetTodos() ([]Todo, error) {
-	resp, err := c.httpClient.Get(c.baseURL + "/api/todos")
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to API: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(body))
-	}
-
-	var apiResp APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
-	}
-
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %s", apiResp.Error)
-	}
-
-	// Convert data to []Todo
-	dataBytes, _ := json.Marshal(apiResp.Data)
-	var todos []Todo
-	if err := json.Unmarshal(dataBytes, &todos); err != nil {
-		return nil, fmt.Errorf("failed to parse todos: %v", err)
-	}
-
-	return todos, nil
+	return sdk.Collect(c.SearchTodos(TodoFilter{}))
 }
 
-// GetPendingTodos fetches pending todos from the API
-func (c *APIClient) GetPendingTodos() ([]Todo, error) {
-	resp, err := c.httpClient.Get(c.baseURL + "/api/todos/pending")
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to API: %v", err)
+// SearchTodos streams the todos matching filter
+func (c *APIClient) SearchTodos(filter TodoFilter) iter.Seq2[Todo, error] {
+	if c.offline != nil {
+		return c.offline.todos("/api/todos", sdk.Query(filter))
 	}
-	defer resp.Body.Close()
+	return todos(c.api.Todos(context.Background(), filter))
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(body))
+// PendingTodos streams the todos not yet done
+func (c *APIClient) PendingTodos() iter.Seq2[Todo, error] {
+	if c.offline != nil {
+		return c.offline.todos("/api/todos/pending", nil)
 	}
+	return todos(c.api.PendingTodos(context.Background(), models.PageRequest{}))
+}
 
-	var apiResp APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
+// CompletedTodos streams the done todos
+func (c *APIClient) CompletedTodos() iter.Seq2[Todo, error] {
+	if c.offline != nil {
+		return c.offline.todos("/api/todos/completed", nil)
 	}
+	return todos(c.api.CompletedTodos(context.Background(), models.PageRequest{}))
+}
 
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %s", apiResp.Error)
+// DueTodos streams one of the due-date views: "overdue", "today" or "upcoming".
+// The local UTC offset is sent so "today" means the caller's calendar day.
+func (c *APIClient) DueTodos(view string, days int) iter.Seq2[Todo, error] {
+	if c.offline != nil {
+		return c.offline.todos("/api/todos/"+view, nil)
+	}
+	ctx, filter := context.Background(), models.DueFilter{TZ: localZone(), Days: days}
+	switch view {
+	case "overdue":
+		return todos(c.api.OverdueTodos(ctx, filter))
+	case "today":
+		return todos(c.api.TodayTodos(ctx, filter))
+	default:
+		return todos(c.api.UpcomingTodos(ctx, filter))
 	}
+}
 
-	dataBytes, _ := json.Marshal(apiResp.Data)
-	var todos []Todo
-	if err := json.Unmarshal(dataBytes, &todos); err != nil {
-		return nil, fmt.Errorf("failed to parse todos: %v", err)
+// GetTrash iterates over the deleted todos, most recently deleted first
+func (c *APIClient) GetTrash() iter.Seq2[Todo, error] {
+	if c.offline != nil {
+		return c.offline.todos("/api/trash", nil)
 	}
-
-	return todos, nil
+	return todos(c.api.Trash(context.Background(), models.PageRequest{}))
 }
 
-// GetCompletedTodos fetches completed todos from the API
-func (c *APIClient) GetCompletedTodos() ([]Todo, error) {
-	resp, err := c.httpClient.Get(c.baseURL + "/api/todos/completed")
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to API: %v", err)
+// todos adapts the errors of an SDK listing with apiError
+func todos(seq iter.Seq2[Todo, error]) iter.Seq2[Todo, error] {
+	return func(yield func(Todo, error) bool) {
+		for todo, err := range seq {
+			if !yield(todo, apiError(err)) {
+				return
+			}
+		}
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(body))
+// CreateTodo creates a new todo via the API
+func (c *APIClient) CreateTodo(req CreateTodoRequest) (*Todo, error) {
+	if c.offline != nil {
+		return c.offlineChange(BatchOperation{Op: "create", Todo: &req})
 	}
+	return result(c.api.CreateTodo(context.Background(), req))
+}
 
-	var apiResp APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
+// UpdateTodo updates a todo via the API. A non-zero version makes the update
+// conditional: it fails with ErrConflict if the todo has changed since.
+func (c *APIClient) UpdateTodo(id, version int, req UpdateTodoRequest) (*Todo, error) {
+	if c.offline != nil {
+		return c.offlineChange(BatchOperation{Op: "update", Id: id, Version: version, Changes: &req})
 	}
+	return result(c.api.UpdateTodo(context.Background(), id, version, req))
+}
 
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %s", apiResp.Error)
-	}
+// BatchTodos applies several changes in one request per models.MaxBatchSize
+// operations and returns each one's result. Each operation succeeds or fails on its own.
+func (c *APIClient) BatchTodos(ops []BatchOperation) ([]BatchResult, error) {
+	if c.offline != nil {
+		results := make([]BatchResult, len(ops))
+		for i, op := range ops {
+			results[i] = c.offline.apply(op)
+		}
+		return results, nil
+	}
+	var results []BatchResult
+	for chunk := range slices.Chunk(ops, models.MaxBatchSize) {
+		chunkResults, err := c.api.BatchTodos(context.Background(), models.BatchRequest{Operations: chunk})
+		if err != nil {
+			return results, apiError(err)
+		}
+		results = append(results, chunkResults...)
+	}
+	return results, nil
+}
 
-	dataBytes, _ := json.Marshal(apiResp.Data)
-	var todos []Todo
-	if err := json.Unmarshal(dataBytes, &todos); err != nil {
-		return nil, fmt.Errorf("failed to parse todos: %v", err)
+// GetTodo fetches a single todo, including its current version
+func (c *APIClient) GetTodo(id int) (*Todo, error) {
+	if c.offline != nil {
+		return c.offline.get(id)
 	}
-
-	return todos, nil
+	return result(c.api.Todo(context.Background(), id))
 }
 
-// CreateTodo creates a new todo via the API
-func (c *APIClient) CreateTodo(item string) (*Todo, error) {
-	reqBody := CreateTodoRequest{Item: item}
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
+// GetTodoTree fetches a todo with its subtasks nested under Children
+func (c *APIClient) GetTodoTree(id int) (*Todo, error) {
+	if c.offline != nil {
+		return c.offline.tree(id)
 	}
+	return result(c.api.TodoTree(context.Background(), id))
+}
 
-	resp, err := c.httpClient.Post(c.baseURL+"/api/todos", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to API: %v", err)
+// DeleteTodo moves a todo to the trash via the API, only if it is still at
+// version unless version is 0
+func (c *APIClient) DeleteTodo(id, version int) error {
+	if c.offline != nil {
+		_, err := c.offlineChange(BatchOperation{Op: "delete", Id: id, Version: version})
+		return err
 	}
-	defer resp.Body.Close()
+	return apiError(c.api.DeleteTodo(context.Background(), id, version))
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(body))
-	}
+// TodoHistory fetches every recorded change to a todo, oldest first
+func (c *APIClient) TodoHistory(id int) ([]Activity, error) {
+	return result(c.api.TodoHistory(context.Background(), id))
+}
 
-	var apiResp APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
+// ToggleTodo toggles a todo's done status via the API, only if it is still
+// at version unless version is 0
+func (c *APIClient) ToggleTodo(id, version int) (*Todo, error) {
+	if c.offline != nil {
+		return c.offlineChange(BatchOperation{Op: "toggle", Id: id, Version: version})
 	}
+	return result(c.api.ToggleTodo(context.Background(), id, version))
+}
 
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %s", apiResp.Error)
-	}
+// GetTags fetches every tag on the user's todos, ordered by name
+func (c *APIClient) GetTags() ([]Tag, error) {
+	return result(c.api.Tags(context.Background()))
+}
 
-	dataBytes, _ := json.Marshal(apiResp.Data)
-	var todo Todo
-	if err := json.Unmarshal(dataBytes, &todo); err != nil {
-		return nil, fmt.Errorf("failed to parse todo: %v", err)
+// TagTodo adds tags to a todo via the API, or removes them when remove is
+// set, only if it is still at version unless version is 0
+func (c *APIClient) TagTodo(id, version int, tags []string, remove bool) (*Todo, error) {
+	if remove {
+		return result(c.api.RemoveTags(context.Background(), id, version, tags))
 	}
-
-	return &todo, nil
+	return result(c.api.AddTags(context.Background(), id, version, tags))
 }
 
-// UpdateTodo updates a todo via the API
-func (c *APIClient) UpdateTodo(id int, req UpdateTodoRequest) (*Todo, error) {
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
+// Calendar fetches the iCalendar feed of every todo, or of one list, with
+// pending todos as events or, when as is "todo", every todo as a VTODO
+func (c *APIClient) Calendar(list, as string) ([]byte, error) {
+	filter := models.CalendarFilter{As: as}
+	if list != "" {
+		return result(c.api.ListCalendar(context.Background(), list, filter))
 	}
+	return result(c.api.Calendar(context.Background(), filter))
+}
 
-	reqHTTP, err := http.NewRequest("PUT", c.baseURL+"/api/todos/"+strconv.Itoa(id), bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-	reqHTTP.Header.Set("Content-Type", "application/json")
+// IssueCalendarToken issues a calendar token, replacing the previous one
+func (c *APIClient) IssueCalendarToken() (*CalendarSubscription, error) {
+	return result(c.api.IssueCalendarToken(context.Background()))
+}
 
-	resp, err := c.httpClient.Do(reqHTTP)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to API: %v", err)
-	}
-	defer resp.Body.Close()
+// Export fetches every todo, or those in one list, as a json, csv,
+// markdown or todotxt file. Due dates at 23:59 local time are written as
+// dates, so the local UTC offset is sent.
+func (c *APIClient) Export(format, list string) ([]byte, error) {
+	filter := models.ExportFilter{Format: format, List: list, TZ: localZone()}
+	return result(c.api.Export(context.Background(), filter))
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(body))
-	}
+// Import adds the todos in a file. Dates without a time mean 23:59 local time.
+func (c *APIClient) Import(data []byte, opts ImportOptions) (*ImportResult, error) {
+	opts.TZ = localZone()
+	return result(c.api.Import(context.Background(), data, opts))
+}
 
-	var apiResp APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
-	}
+// GetList fetches a single list with its todo counts
+func (c *APIClient) GetList(id string) (*List, error) {
+	return result(c.api.List(context.Background(), id))
+}
 
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %s", apiResp.Error)
-	}
+// GetLists fetches the lists with their todo counts, optionally including archived ones
+func (c *APIClient) GetLists(includeArchived bool) ([]List, error) {
+	return result(c.api.Lists(context.Background(), models.ListFilter{Archived: includeArchived}))
+}
 
-	dataBytes, _ := json.Marshal(apiResp.Data)
-	var todo Todo
-	if err := json.Unmarshal(dataBytes, &todo); err != nil {
-		return nil, fmt.Errorf("failed to parse todo: %v", err)
-	}
+// CreateList creates a list; the API derives its ID from the name
+func (c *APIClient) CreateList(name, color string) (*List, error) {
+	return result(c.api.CreateList(context.Background(), models.CreateListRequest{Name: name, Color: color}))
+}
 
-	return &todo, nil
+// UpdateList updates a list via the API
+func (c *APIClient) UpdateList(id string, req UpdateListRequest) (*List, error) {
+	return result(c.api.UpdateList(context.Background(), id, req))
 }
 
-// DeleteTodo deletes a todo via the API
-func (c *APIClient) DeleteTodo(id int) error {
-	reqHTTP, err := http.NewRequest("DELETE", c.baseURL+"/api/todos/"+strconv.Itoa(id), nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+// DeleteList deletes a list, moving its todos to the trash or to the main list
+func (c *APIClient) DeleteList(id string, deleteTodos bool) error {
+	var req models.DeleteListRequest
+	if deleteTodos {
+		req.Todos = "delete"
 	}
+	return apiError(c.api.DeleteList(context.Background(), id, req))
+}
 
-	resp, err := c.httpClient.Do(reqHTTP)
-	if err != nil {
-		return fmt.Errorf("failed to connect to API: %v", err)
-	}
-	defer resp.Body.Close()
+// GetMembers fetches the users a list is shared with
+func (c *APIClient) GetMembers(listId string) ([]Member, error) {
+	return result(c.api.Members(context.Background(), listId))
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error: %s", string(body))
+// AddMember shares a list with a user, given by email or, without an @, by user ID
+func (c *APIClient) AddMember(listId, user, role string) (*Member, error) {
+	req := models.AddMemberRequest{Role: role, UserId: user}
+	if strings.Contains(user, "@") {
+		req = models.AddMemberRequest{Role: role, Email: user}
 	}
+	return result(c.api.AddMember(context.Background(), listId, req))
+}
 
-	var apiResp APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return fmt.Errorf("failed to parse response: %v", err)
-	}
+// UpdateMember changes a member's role
+func (c *APIClient) UpdateMember(listId, userId, role string) (*Member, error) {
+	return result(c.api.UpdateMember(context.Background(), listId, userId, models.UpdateMemberRequest{Role: role}))
+}
 
-	if !apiResp.Success {
-		return fmt.Errorf("API error: %s", apiResp.Error)
-	}
+// RemoveMember stops sharing a list with a user
+func (c *APIClient) RemoveMember(listId, userId string) error {
+	return apiError(c.api.RemoveMember(context.Background(), listId, userId))
+}
 
-	return nil
+// Login exchanges an email and password for a token
+func (c *APIClient) Login(email, password string) (*AuthResponse, error) {
+	return result(c.api.Login(context.Background(), models.Credentials{Email: email, Password: password}))
 }
 
-// ToggleTodo toggles a todo's done status via the API
-func (c *APIClient) ToggleTodo(id int) (*Todo, error) {
-	reqHTTP, err := http.NewRequest("PATCH", c.baseURL+"/api/todos/"+strconv.Itoa(id)+"/toggle", nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
+// Register creates an account and returns a token for it
+func (c *APIClient) Register(email, password string) (*AuthResponse, error) {
+	return result(c.api.Register(context.Background(), models.Credentials{Email: email, Password: password}))
+}
 
-	resp, err := c.httpClient.Do(reqHTTP)
+// Me returns the ID and email of the user the token belongs to
+func (c *APIClient) Me() (id, email string, err error) {
+	me, err := c.api.Me(context.Background())
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to API: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error: %s", string(body))
-	}
-
-	var apiResp APIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v", err)
-	}
-
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %s", apiResp.Error)
+		return "", "", apiError(err)
 	}
+	return me.Id, me.Email, nil
+}
 
-	dataBytes, _ := json.Marshal(apiResp.Data)
-	var todo Todo
-	if err := json.Unmarshal(dataBytes, &todo); err != nil {
-		return nil, fmt.Errorf("failed to parse todo: %v", err)
+// StreamTodos connects to the todo change stream, optionally for one list
+// ("main" for the main list), and yields events until the connection ends or
+// ctx is cancelled. Passing the last event ID seen resumes after it.
+func (c *APIClient) StreamTodos(ctx context.Context, list, lastEventId string) iter.Seq2[TodoEvent, error] {
+	return func(yield func(TodoEvent, error) bool) {
+		for event, err := range c.api.StreamTodos(ctx, models.StreamFilter{List: list, LastEventId: lastEventId}) {
+			if !yield(event, apiError(err)) {
+				return
+			}
+		}
 	}
-
-	return &todo, nil
 }
 
 // CheckHealth checks if the API is available
 func (c *APIClient) CheckHealth() error {
-	resp, err := c.httpClient.Get(c.baseURL + "/api/health")
-	if err != nil {
-		return fmt.Errorf("API server is not running: %v", err)
+	if err := c.probe.Health(context.Background()); err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("API server is not running: %w", err)
+		}
+		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API server returned status: %d", resp.StatusCode)
-	}
-
 	return nil
 }
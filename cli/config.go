@@ -1,9 +1,19 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 )
 
+// Config is the CLI state kept between runs in the config file
+type Config struct {
+	Token string `json:"token,omitempty"` // Bearer token from "listy login"
+	Email string `json:"email,omitempty"` // Who the token belongs to, for display
+}
+
 // GetAPIURL returns the API URL from environment or default
 func GetAPIURL() string {
 	apiURL := os.Getenv("LISTY_API_URL")
@@ -12,3 +22,65 @@ func GetAPIURL() string {
 	}
 	return apiURL
 }
+
+// ConfigPath returns LISTY_CONFIG, or config.json in the user's config directory
+// (e.g. ~/.config/listy/config.json)
+func ConfigPath() (string, error) {
+	if path := os.Getenv("LISTY_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate config directory: %v", err)
+	}
+	return filepath.Join(dir, "listy", "config.json"), nil
+}
+
+// LoadConfig reads the config file; a missing file is an empty config
+func LoadConfig() (Config, error) {
+	var config Config
+	path, err := ConfigPath()
+	if err != nil {
+		return config, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// SaveConfig writes the config file, readable only by the current user since it holds the token
+func SaveConfig(config Config) error {
+	path, err := ConfigPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// GetToken returns LISTY_TOKEN if set, otherwise the token saved by "listy login"
+func GetToken(config Config) string {
+	if token := os.Getenv("LISTY_TOKEN"); token != "" {
+		return token
+	}
+	return config.Token
+}
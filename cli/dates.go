@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date-only phrases resolve to this time of day, so "due friday" stays
+// on time until the day is over
+const defaultDueHour, defaultDueMinute = 23, 59
+
+var (
+	inDurationPattern = regexp.MustCompile(`^in (\d+|an?|one) (minute|hour|day|week|month)s?$`)
+	clockPattern      = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))? ?(am|pm)?$`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// ParseDate turns a natural-language or ISO date into a time relative to now.
+//
+// Supported forms (case-insensitive, optionally followed by "at <time>"):
+//
+//	today, tonight, tomorrow
+//	friday, this friday   - the next Friday, today included
+//	next friday           - the next Friday after today
+//	next week, next month - same weekday/day one week/month from now
+//	in 3 days, in 2 hours, in a week
+//	2026-03-06, 2026-03-06 17:00, RFC 3339
+//
+// Times may be written "17:00", "5pm" or "5:30pm". Dates without a time
+// resolve to 23:59 local time.
+func ParseDate(input string, now time.Time) (time.Time, error) {
+	text := strings.ToLower(strings.Join(strings.Fields(input), " "))
+	if text == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	if t, err := time.Parse(time.RFC3339, input); err == nil {
+		return t, nil
+	}
+	// The input is lowercased, so the ISO separator is matched as "t"
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02t15:04"} {
+		if t, err := time.ParseInLocation(layout, text, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	if m := inDurationPattern.FindStringSubmatch(text); m != nil {
+		n := 1
+		if m[1] != "a" && m[1] != "an" && m[1] != "one" {
+			n, _ = strconv.Atoi(m[1])
+		}
+		switch m[2] {
+		case "minute":
+			return now.Add(time.Duration(n) * time.Minute), nil
+		case "hour":
+			return now.Add(time.Duration(n) * time.Hour), nil
+		case "day":
+			return atTime(now.AddDate(0, 0, n), defaultDueHour, defaultDueMinute), nil
+		case "week":
+			return atTime(now.AddDate(0, 0, 7*n), defaultDueHour, defaultDueMinute), nil
+		case "month":
+			return atTime(now.AddDate(0, n, 0), defaultDueHour, defaultDueMinute), nil
+		}
+	}
+
+	datePart, clockPart, hasClock := strings.Cut(text, " at ")
+	hour, minute := defaultDueHour, defaultDueMinute
+	if hasClock {
+		var err error
+		if hour, minute, err = parseClock(clockPart); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	day, err := parseDay(datePart, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if datePart == "tonight" && !hasClock {
+		hour, minute = 20, 0
+	}
+	return atTime(day, hour, minute), nil
+}
+
+// parseDay resolves the date portion of a phrase to a day (time of day is ignored)
+func parseDay(text string, now time.Time) (time.Time, error) {
+	switch text {
+	case "today", "tonight":
+		return now, nil
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), nil
+	case "next week":
+		return now.AddDate(0, 0, 7), nil
+	case "next month":
+		return now.AddDate(0, 1, 0), nil
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", text, now.Location()); err == nil {
+		return t, nil
+	}
+
+	name, next := strings.CutPrefix(text, "next ")
+	if !next {
+		name = strings.TrimPrefix(text, "this ")
+	}
+	if weekday, ok := weekdays[name]; ok {
+		days := (int(weekday) - int(now.Weekday()) + 7) % 7
+		if next && days == 0 {
+			days = 7
+		}
+		return now.AddDate(0, 0, days), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised date %q (try \"tomorrow\", \"next friday\", \"in 3 days\" or 2006-01-02)", text)
+}
+
+// parseClock parses "17:00", "5pm" or "5:30 pm"
+func parseClock(text string) (int, int, error) {
+	m := clockPattern.FindStringSubmatch(strings.TrimSpace(text))
+	if m == nil {
+		return 0, 0, fmt.Errorf("unrecognised time %q (try 17:00 or 5pm)", text)
+	}
+
+	hour, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if minute > 59 || hour > 23 || (m[3] != "" && (hour < 1 || hour > 12)) {
+		return 0, 0, fmt.Errorf("invalid time %q", text)
+	}
+
+	// 12am is midnight and 12pm is noon
+	switch {
+	case m[3] == "am" && hour == 12:
+		hour = 0
+	case m[3] == "pm" && hour != 12:
+		hour += 12
+	}
+	return hour, minute, nil
+}
+
+// atTime returns day at hour:minute in day's location
+func atTime(day time.Time, hour, minute int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+}
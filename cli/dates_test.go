@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	// Wednesday 4 March 2026, 10:15 local time
+	loc := time.FixedZone("CET", 3600)
+	now := time.Date(2026, time.March, 4, 10, 15, 0, 0, loc)
+	on := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"today", on(4, 23, 59)},
+		{"tonight", on(4, 20, 0)},
+		{"Tomorrow", on(5, 23, 59)},
+		{"tomorrow at 9am", on(5, 9, 0)},
+		{"tomorrow at 12am", on(5, 0, 0)},
+		{"tomorrow at 12pm", on(5, 12, 0)},
+		{"friday", on(6, 23, 59)},
+		{"this friday at 5:30pm", on(6, 17, 30)},
+		{"wednesday", on(4, 23, 59)},
+		{"next wednesday", on(11, 23, 59)},
+		{"next friday", on(6, 23, 59)},
+		{"next fri at 17:00", on(6, 17, 0)},
+		{"next week", on(11, 23, 59)},
+		{"in 3 days", on(7, 23, 59)},
+		{"in a week", on(11, 23, 59)},
+		{"in 2 hours", on(4, 12, 15)},
+		{"in 1 month", time.Date(2026, time.April, 4, 23, 59, 0, 0, loc)},
+		{"2026-03-20", on(20, 23, 59)},
+		{"2026-03-20 08:45", on(20, 8, 45)},
+		{"2026-03-20T08:45", on(20, 8, 45)},
+		{"2026-03-20 at 8am", on(20, 8, 0)},
+		{"2026-03-20T08:45:00Z", time.Date(2026, time.March, 20, 8, 45, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDate(tt.input, now)
+			if err != nil {
+				t.Fatalf("ParseDate(%q) error = %v", tt.input, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseDate(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDate_Invalid(t *testing.T) {
+	now := time.Date(2026, time.March, 4, 10, 15, 0, 0, time.UTC)
+
+	for _, input := range []string{"", "someday", "next blursday", "tomorrow at 25:00", "friday at 13pm", "in many days"} {
+		if got, err := ParseDate(input, now); err == nil {
+			t.Errorf("ParseDate(%q) = %v, want error", input, got)
+		}
+	}
+}
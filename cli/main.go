@@ -1,33 +1,81 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"net/url"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+
+	"listy-api/core"
+	"listy-api/sdk"
 )
 
 func main() {
-	// Get API URL from environment or use default
+	// Get API URL from environment or use default, and the token saved by "login"
 	apiURL := GetAPIURL()
-	client := NewAPIClient(apiURL)
+	config, err := LoadConfig()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	client := NewAPIClient(apiURL, GetToken(config))
 
-	// Check if API is available
-	if err := client.CheckHealth(); err != nil {
+	command := ""
+	if len(os.Args) >= 2 {
+		command = os.Args[1]
+	}
+	offline, err := LoadOffline()
+	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		fmt.Println("\nMake sure the API server is running:")
-		fmt.Println("  cd api && go run main.go")
-		fmt.Println("\nOr set LISTY_API_URL environment variable to point to your API server.")
 		os.Exit(1)
 	}
 
+	// Check if API is available; without it, some commands work from the
+	// offline copy and queue their changes for "listy sync"
+	if err := client.CheckHealth(); err != nil {
+		if !offlineCommands[command] {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Println("\nMake sure the API server is running:")
+			fmt.Println("  cd api && go run main.go")
+			fmt.Println("\nOr set LISTY_API_URL environment variable to point to your API server.")
+			os.Exit(1)
+		}
+		if offline.User != config.Email {
+			fmt.Printf("Error: %v\n", err)
+			fmt.Printf("The offline copy belongs to %s; log in as them to work offline.\n", offline.User)
+			os.Exit(1)
+		}
+		if offline.SyncedAt.IsZero() {
+			fmt.Println("Working offline (the API cannot be reached); changes are queued for \"listy sync\"")
+		} else {
+			fmt.Printf("Working offline with your todos as of %s; changes are queued for \"listy sync\"\n",
+				offline.SyncedAt.Local().Format("Mon Jan 2 15:04"))
+		}
+		client.GoOffline(offline)
+	} else if command != "sync" && command != "login" && command != "logout" {
+		flushQueue(client, offline, config)
+	}
+
 	// Check if user provided a command
 	if len(os.Args) < 2 {
 		printHelp()
 		return
 	}
 
-	command := os.Args[1]
-
 	switch command {
 	case "add":
 		handleAdd(client)
@@ -41,11 +89,26 @@ func main() {
 	case "completed":
 		handleCompleted(client)
 
+	case "overdue":
+		printTodos(client.DueTodos("overdue", 0), "No overdue todos")
+
+	case "today":
+		printTodos(client.DueTodos("today", 0), "Nothing due today")
+
+	case "upcoming":
+		handleUpcoming(client)
+
+	case "show":
+		handleShow(client)
+
+	case "lists":
+		handleLists(client)
+
 	case "complete":
-		handleComplete(client)
+		handleSetDone(client, true)
 
 	case "incomplete":
-		handleIncomplete(client)
+		handleSetDone(client, false)
 
 	case "toggle":
 		handleToggle(client)
@@ -56,6 +119,51 @@ func main() {
 	case "remove":
 		handleRemove(client)
 
+	case "trash":
+		printTodos(client.GetTrash(), "The trash is empty")
+
+	case "restore":
+		handleRestore(client)
+
+	case "undo":
+		handleUndo(client)
+
+	case "history":
+		handleHistory(client)
+
+	case "tags":
+		handleTags(client)
+
+	case "tag":
+		handleTag(client, false)
+
+	case "untag":
+		handleTag(client, true)
+
+	case "watch":
+		handleWatch(client)
+
+	case "export":
+		handleExport(client)
+
+	case "import":
+		handleImport(client)
+
+	case "login":
+		handleLogin(client, apiURL, false)
+
+	case "register":
+		handleLogin(client, apiURL, true)
+
+	case "logout":
+		handleLogout(config)
+
+	case "whoami":
+		handleWhoami(client)
+
+	case "sync":
+		handleSync(client, offline, config)
+
 	case "help":
 		printHelp()
 
@@ -63,87 +171,310 @@ func main() {
 		fmt.Printf("Unknown command: %s\n", command)
 		printHelp()
 	}
+
+	// Keep the offline copy current for the next time the API cannot be reached
+	if client.offline == nil && command != "sync" && offlineCommands[command] && len(offline.Queue) == 0 {
+		if err := client.Refresh(offline, config.Email); err != nil {
+			fmt.Printf("Warning: could not update the offline copy: %v\n", err)
+		}
+	}
 }
 
 func printHelp() {
 	fmt.Println("Usage: go run main.go <command>")
 	fmt.Println("\nCommands:")
-	fmt.Println("  add <item>           - Add a new todo item")
-	fmt.Println("  list                 - List all todos")
+	fmt.Println("  add <item> [flags]   - Add a new todo item; #words in it become tags (\"Buy milk #errands\");")
+	fmt.Println("                         flags: --due <date>, --remind <date>, --list <id>,")
+	fmt.Println("                         --priority high|medium|low, --estimate <time>, --category <name>,")
+	fmt.Println("                         --parent <id> (add as a subtask), --auto-complete,")
+	fmt.Println("                         --every <rule> (e.g. \"weekly on mon\", \"month on the 1st\", \"weekdays\", or an RRULE)")
+	fmt.Println("                         dates: today, tomorrow at 9am, next friday, in 3 days, 2006-01-02 15:04")
+	fmt.Println("  list [flags]         - List todos; flags: --done true|false, --list <id|main>,")
+	fmt.Println("                         --q <text>, --sort <keys> (e.g. -created_at,item),")
+	fmt.Println("                         --priority high|medium|low, --category <name>, --parent none|<id>,")
+	fmt.Println("                         --tag <name> (repeatable; todos with every tag), --any-tag (with any of them)")
+	fmt.Println("  show <id>            - Show a todo with its subtasks")
 	fmt.Println("  pending              - List only pending todos")
 	fmt.Println("  completed            - List only completed todos")
-	fmt.Println("  complete <id>        - Mark a todo as complete")
-	fmt.Println("  incomplete <id>      - Mark a todo as incomplete")
+	fmt.Println("  overdue              - List pending todos past their due date")
+	fmt.Println("  today                - List pending todos due today")
+	fmt.Println("  upcoming [--days N]  - List pending todos due in the next N days (default 7)")
+	fmt.Println("  lists [--archived]   - Show lists with pending/done counts")
+	fmt.Println("  lists add <name> [--color #hex]      - Create a list")
+	fmt.Println("  lists rename <id> <name>             - Rename a list")
+	fmt.Println("  lists archive|unarchive <id>         - Hide or show a list")
+	fmt.Println("  lists delete <id> [--delete-todos]   - Delete a list; its todos move to the main list (or the trash)")
+	fmt.Println("  lists members <id>                   - Show who a list is shared with")
+	fmt.Println("  lists share <id> <email|user-id> [--role viewer|editor] - Share a list (default: viewer)")
+	fmt.Println("  lists role <id> <email|user-id> viewer|editor           - Change a member's role")
+	fmt.Println("  lists unshare <id> <email|user-id>   - Stop sharing a list; members can unshare themselves")
+	fmt.Println("  complete <id>...     - Mark one or more todos as complete")
+	fmt.Println("  incomplete <id>...   - Mark one or more todos as incomplete")
 	fmt.Println("  toggle <id>          - Toggle todo status")
-	fmt.Println("  update <id> <text>   - Update todo item text")
-	fmt.Println("  remove <id>          - Remove a todo")
+	fmt.Println("  update <id> [text]   - Update todo item text; flags: --priority, --estimate, --category, --every (\"\" clears)")
+	fmt.Println("  remove <id>...       - Move one or more todos to the trash")
+	fmt.Println("  remove --done [--list <id|main>]     - Move every completed todo to the trash")
+	fmt.Println("  trash                - List deleted todos; they are purged after a while (30 days by default)")
+	fmt.Println("  restore <id>...      - Take todos out of the trash")
+	fmt.Println("  undo                 - Reverse the last command that changed todos or lists")
+	fmt.Println("  history <id>         - Show who changed a todo, when, and what changed")
+	fmt.Println("  tags                 - Show tags with pending/done counts")
+	fmt.Println("  tag <id> <tag>...    - Add tags to a todo")
+	fmt.Println("  untag <id> <tag>...  - Remove tags from a todo")
+	fmt.Println("  watch [--list <id|main>] - Print changes to todos as they happen, until Ctrl+C")
+	fmt.Println("  export [--format json|csv|markdown|todotxt|ics] [--list <id|main>] [--output <file>]")
+	fmt.Println("                       - Write todos to a file (default: stdout, as json or as --output's extension);")
+	fmt.Println("                         ics writes todos with due dates as an iCalendar file, --as event|todo picks")
+	fmt.Println("                         the entries, and --subscribe prints a feed URL for calendar apps instead")
+	fmt.Println("  import <file|-> [flags] - Add the todos in a json (also the old todos.json), csv, markdown")
+	fmt.Println("                         (\"- [ ]\" checklist) or todo.txt file; flags: --format, --list <id|main>,")
+	fmt.Println("                         --dry-run (only show what would be added), --dedupe (skip todos already there)")
+	fmt.Println("  sync [--discard]     - Show the changes made while the API could not be reached and send them;")
+	fmt.Println("                         --discard drops them instead. add, list, pending, completed, show,")
+	fmt.Println("                         complete, incomplete, toggle, update and remove work offline from the")
+	fmt.Println("                         todos last seen, and their changes are sent the next time the API answers")
+	fmt.Println("  login [email]        - Sign in and save the token; --token <jwt> saves a token issued elsewhere")
+	fmt.Println("  register [email]     - Create an account and sign in")
+	fmt.Println("  logout               - Forget the saved token")
+	fmt.Println("  whoami               - Show who the saved token belongs to")
 	fmt.Println("  help                 - Show this help message")
 	fmt.Println("\nEnvironment Variables:")
 	fmt.Println("  LISTY_API_URL        - API server URL (default: http://localhost:8080)")
+	fmt.Println("  LISTY_TOKEN          - Bearer token to use instead of the one saved by login")
+	fmt.Println("  LISTY_CONFIG         - Config file path (default: <user config dir>/listy/config.json)")
 }
 
 func handleAdd(client *APIClient) {
-	if len(os.Args) < 3 {
+	flags := flag.NewFlagSet("add", flag.ExitOnError)
+	due := flags.String("due", "", "due date, e.g. \"next friday\" or \"tomorrow at 9am\"")
+	remind := flags.String("remind", "", "reminder time, same formats as --due")
+	list := flags.String("list", "", "add to this list instead of the main list")
+	priority := flags.String("priority", "", "high, medium or low")
+	estimate := flags.String("estimate", "", "estimated time, e.g. \"30 minutes\"")
+	category := flags.String("category", "", "category, e.g. errands")
+	parent := flags.Int("parent", 0, "add as a subtask of this todo ID")
+	autoComplete := flags.Bool("auto-complete", false, "mark done automatically once all subtasks are done")
+	every := flags.String("every", "", "repeat when completed, e.g. \"weekly on mon\" or an RRULE")
+	args := parseInterspersed(flags, os.Args[2:])
+
+	if len(args) < 1 {
 		fmt.Println("Error: Please provide an item to add")
 		return
 	}
-	itemName := os.Args[2]
-	todo, err := client.CreateTodo(itemName)
+	itemName, tags := ExtractTags(args[0])
+	if itemName == "" {
+		fmt.Println("Error: Please provide an item besides its tags")
+		return
+	}
+	req := CreateTodoRequest{Item: itemName, Priority: *priority, EstimatedTime: *estimate, Category: *category, Tags: tags}
+	if *list != "" {
+		req.ListId = list
+	}
+	if *parent != 0 {
+		req.ParentId = parent
+	}
+	req.AutoComplete = *autoComplete
+
+	now := time.Now()
+	if *due != "" {
+		dueAt, err := ParseDate(*due, now)
+		if err != nil {
+			fmt.Printf("Error: --due: %v\n", err)
+			return
+		}
+		req.DueAt = &dueAt
+	}
+	if *remind != "" {
+		remindAt, err := ParseDate(*remind, now)
+		if err != nil {
+			fmt.Printf("Error: --remind: %v\n", err)
+			return
+		}
+		req.RemindAt = &remindAt
+	}
+	if *every != "" {
+		rule, err := ParseEvery(*every)
+		if err != nil {
+			fmt.Printf("Error: --every: %v\n", err)
+			return
+		}
+		// Without a due date, occurrences fall at the end of the day like date-only due dates
+		start := atTime(now, defaultDueHour, defaultDueMinute)
+		if req.DueAt != nil {
+			start = *req.DueAt
+		}
+		req.Recurrence = withTimeZone(rule, start)
+	}
+
+	todo, err := client.CreateTodo(req)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
-	fmt.Printf("Added %s (Id: %d)\n", itemName, todo.Id)
+	recordUndo([]BatchOperation{{Op: "delete", Id: todo.Id, Version: todo.Version}}, nil)
+	added := fmt.Sprintf("Added %s (Id: %d)", itemName, todo.Id)
+	if todo.DueAt != nil {
+		added += ", due " + todo.DueAt.Local().Format("Mon Jan 2 2006 15:04")
+	}
+	if todo.Recurrence != "" {
+		added += ", repeating " + core.DescribeRecurrence(todo.Recurrence)
+	}
+	if len(todo.Tags) > 0 {
+		added += ", tagged #" + strings.Join(todo.Tags, " #")
+	}
+	fmt.Println(added)
+}
+
+// parseInterspersed parses flags that may appear before or after positional
+// arguments (flag.Parse stops at the first positional) and returns the positionals
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
 }
 
 func handleList(client *APIClient) {
-	todos, err := client.GetTodos()
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	done := flags.String("done", "", "only pending (false) or completed (true) todos")
+	list := flags.String("list", "", "only todos in this list (\"main\" for the main list)")
+	search := flags.String("q", "", "only todos whose text contains this (case-insensitive)")
+	sort := flags.String("sort", "", "comma-separated sort keys, \"-\" prefix for descending (e.g. -created_at)")
+	priority := flags.String("priority", "", "only todos with this priority (high, medium or low)")
+	category := flags.String("category", "", "only todos in this category")
+	parent := flags.String("parent", "", "\"none\" for top-level todos, or a todo ID for its subtasks")
+	var tags stringList
+	flags.Var(&tags, "tag", "only todos with this tag; repeat for todos with every tag")
+	anyTag := flags.Bool("any-tag", false, "with several --tag, todos with any of them")
+	flags.Parse(os.Args[2:])
+
+	filter := TodoFilter{
+		List: *list, Search: *search, Sort: *sort,
+		Priority: *priority, Category: *category, Parent: *parent,
+		Tags: tags,
+	}
+	if *done != "" {
+		value, err := strconv.ParseBool(*done)
+		if err != nil {
+			fmt.Println("Error: --done must be true or false")
+			return
+		}
+		filter.Done = &value
+	}
+	if *anyTag {
+		filter.TagMode = "any"
+	}
+
+	printTodos(client.SearchTodos(filter), "No Todos found")
+}
+
+func handlePending(client *APIClient) {
+	printTodos(client.PendingTodos(), "No pending todos found")
+}
+
+func handleCompleted(client *APIClient) {
+	printTodos(client.CompletedTodos(), "No completed todos found")
+}
+
+func handleUpcoming(client *APIClient) {
+	flags := flag.NewFlagSet("upcoming", flag.ExitOnError)
+	days := flags.Int("days", 7, "how many days ahead to look")
+	flags.Parse(os.Args[2:])
+
+	printTodos(client.DueTodos("upcoming", *days), fmt.Sprintf("Nothing due in the next %d days", *days))
+}
+
+func handleShow(client *APIClient) {
+	if len(os.Args) < 3 {
+		fmt.Println("Error: Please provide a todo ID")
+		return
+	}
+	id, err := strconv.Atoi(os.Args[2])
+	if err != nil {
+		fmt.Println("Error: Invalid ID. Please provide a number")
+		return
+	}
+
+	todo, err := client.GetTodoTree(id)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
-	if len(todos) == 0 {
-		fmt.Println("No Todos found")
+	printTree(*todo, "")
+}
+
+// handleHistory prints the recorded changes to a todo, oldest first
+func handleHistory(client *APIClient) {
+	if len(os.Args) < 3 {
+		fmt.Println("Error: Please provide a todo ID")
 		return
 	}
-	for _, todo := range todos {
-		fmt.Println(todo)
+	id, err := strconv.Atoi(os.Args[2])
+	if err != nil {
+		fmt.Println("Error: Invalid ID. Please provide a number")
+		return
 	}
-}
 
-func handlePending(client *APIClient) {
-	todos, err := client.GetPendingTodos()
+	history, err := client.TodoHistory(id)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
-	if len(todos) == 0 {
-		fmt.Println("No pending todos found")
+	if len(history) == 0 {
+		fmt.Printf("No recorded changes to todo %d\n", id)
 		return
 	}
-	for _, todo := range todos {
-		fmt.Println(todo)
+	me, _, _ := client.Me()
+	for _, entry := range history {
+		line := entry.At.Local().Format("Mon Jan 2 2006 15:04") + "  " + entry.Action
+		switch entry.ActorId {
+		case "":
+		case me:
+			line += " by you"
+		default:
+			line += " by " + entry.ActorId
+		}
+		fmt.Println(line)
+
+		fields := slices.Sorted(maps.Keys(entry.Changes))
+		for _, field := range fields {
+			change := entry.Changes[field]
+			if entry.Action == "created" {
+				fmt.Printf("    %s: %s\n", field, formatChange(change.After))
+			} else {
+				fmt.Printf("    %s: %s -> %s\n", field, formatChange(change.Before), formatChange(change.After))
+			}
+		}
 	}
 }
 
-func handleCompleted(client *APIClient) {
-	todos, err := client.GetCompletedTodos()
+// handleTags prints every tag with how many todos carry it
+func handleTags(client *APIClient) {
+	tags, err := client.GetTags()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
-	if len(todos) == 0 {
-		fmt.Println("No completed todos found")
+	if len(tags) == 0 {
+		fmt.Println("No tags yet")
 		return
 	}
-	for _, todo := range todos {
-		fmt.Println(todo)
+	for _, tag := range tags {
+		fmt.Printf("#%s: %d pending, %d done\n", tag.Name, tag.PendingCount, tag.DoneCount)
 	}
 }
 
-func handleComplete(client *APIClient) {
-	if len(os.Args) < 3 {
-		fmt.Println("Error: Please provide a todo ID")
+// handleTag adds tags to a todo, or removes them
+func handleTag(client *APIClient, remove bool) {
+	if len(os.Args) < 4 {
+		fmt.Println("Error: Please provide a todo ID and at least one tag")
+		fmt.Printf("Usage: go run main.go %s <id> <tag>...\n", os.Args[1])
 		return
 	}
 	id, err := strconv.Atoi(os.Args[2])
@@ -151,32 +482,331 @@ func handleComplete(client *APIClient) {
 		fmt.Println("Error: Invalid ID. Please provide a number")
 		return
 	}
-	done := true
-	todo, err := client.UpdateTodo(id, UpdateTodoRequest{Done: &done})
+	before, err := client.GetTodo(id)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
-	fmt.Printf("Todo %d marked as complete\n", todo.Id)
+	todo, err := client.TagTodo(id, before.Version, os.Args[3:], remove)
+	if err != nil {
+		printTodoError(id, err)
+		return
+	}
+
+	if todo.Version == before.Version {
+		recordUndo(nil, nil)
+	} else {
+		tags := append([]string{}, before.Tags...)
+		recordUndo([]BatchOperation{{Op: "update", Id: id, Version: todo.Version, Changes: &UpdateTodoRequest{Tags: &tags}}}, nil)
+	}
+	if len(todo.Tags) == 0 {
+		fmt.Printf("Todo %d has no tags\n", id)
+		return
+	}
+	fmt.Printf("Todo %d tagged #%s\n", id, strings.Join(todo.Tags, " #"))
 }
 
-func handleIncomplete(client *APIClient) {
-	if len(os.Args) < 3 {
-		fmt.Println("Error: Please provide a todo ID")
+// formatChange prints a field value from the history, showing times in local time
+func formatChange(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "(none)"
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t.Local().Format("Mon Jan 2 2006 15:04")
+		}
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// printTree prints a todo and its subtasks, indenting each level
+func printTree(todo Todo, indent string) {
+	fmt.Println(indent + todo.String())
+	for _, child := range todo.Children {
+		printTree(child, indent+"  ")
+	}
+}
+
+func handleLists(client *APIClient) {
+	args := os.Args[2:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		flags := flag.NewFlagSet("lists", flag.ExitOnError)
+		archived := flags.Bool("archived", false, "include archived lists")
+		flags.Parse(args)
+
+		lists, err := client.GetLists(*archived)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if len(lists) == 0 {
+			fmt.Println("No lists yet")
+			return
+		}
+		for _, list := range lists {
+			line := fmt.Sprintf("%s (%s): %d pending, %d done", list.Name, list.Id, list.PendingCount, list.DoneCount)
+			if list.Role != "" && list.Role != "owner" {
+				line += " [shared, " + list.Role + "]"
+			}
+			if list.Archived {
+				line += " [archived]"
+			}
+			fmt.Println(line)
+		}
 		return
 	}
-	id, err := strconv.Atoi(os.Args[2])
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "add":
+		flags := flag.NewFlagSet("lists add", flag.ExitOnError)
+		color := flags.String("color", "", "hex colour, e.g. #4f46e5")
+		args = parseInterspersed(flags, args)
+		if len(args) < 1 {
+			fmt.Println("Error: Please provide a list name")
+			return
+		}
+		list, err := client.CreateList(args[0], *color)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		recordUndo(nil, &ListUndo{Id: list.Id, Delete: true})
+		fmt.Printf("Created list %s (Id: %s)\n", list.Name, list.Id)
+
+	case "rename":
+		if len(args) < 2 {
+			fmt.Println("Error: Please provide a list ID and new name")
+			return
+		}
+		name := args[1]
+		before, err := client.GetList(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if _, err := client.UpdateList(args[0], UpdateListRequest{Name: &name}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		recordUndo(nil, &ListUndo{Id: args[0], Changes: &UpdateListRequest{Name: &before.Name}})
+		fmt.Printf("List %s renamed to %s\n", args[0], name)
+
+	case "archive", "unarchive":
+		if len(args) < 1 {
+			fmt.Println("Error: Please provide a list ID")
+			return
+		}
+		archived := command == "archive"
+		before, err := client.GetList(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if _, err := client.UpdateList(args[0], UpdateListRequest{Archived: &archived}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		recordUndo(nil, &ListUndo{Id: args[0], Changes: &UpdateListRequest{Archived: &before.Archived}})
+		fmt.Printf("List %s %sd\n", args[0], command)
+
+	case "delete":
+		flags := flag.NewFlagSet("lists delete", flag.ExitOnError)
+		deleteTodos := flags.Bool("delete-todos", false, "move the list's todos to the trash instead of the main list")
+		args = parseInterspersed(flags, args)
+		if len(args) < 1 {
+			fmt.Println("Error: Please provide a list ID")
+			return
+		}
+		if err := client.DeleteList(args[0], *deleteTodos); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		recordUndo(nil, nil)
+		fmt.Printf("List %s deleted\n", args[0])
+
+	case "members":
+		if len(args) < 1 {
+			fmt.Println("Error: Please provide a list ID")
+			return
+		}
+		members, err := client.GetMembers(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if len(members) == 0 {
+			fmt.Printf("List %s is not shared\n", args[0])
+			return
+		}
+		for _, member := range members {
+			name := member.Email
+			if name == "" {
+				name = member.UserId
+			}
+			fmt.Printf("%s (%s)\n", name, member.Role)
+		}
+
+	case "share":
+		flags := flag.NewFlagSet("lists share", flag.ExitOnError)
+		role := flags.String("role", "viewer", "viewer or editor")
+		args = parseInterspersed(flags, args)
+		if len(args) < 2 {
+			fmt.Println("Error: Please provide a list ID and an email or user ID")
+			return
+		}
+		if _, err := client.AddMember(args[0], args[1], *role); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		recordUndo(nil, nil)
+		fmt.Printf("List %s shared with %s as %s\n", args[0], args[1], *role)
+
+	case "role":
+		if len(args) < 3 {
+			fmt.Println("Error: Please provide a list ID, an email or user ID, and viewer or editor")
+			return
+		}
+		userId, err := findMember(client, args[0], args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if _, err := client.UpdateMember(args[0], userId, args[2]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		recordUndo(nil, nil)
+		fmt.Printf("%s is now %s on list %s\n", args[1], args[2], args[0])
+
+	case "unshare":
+		if len(args) < 2 {
+			fmt.Println("Error: Please provide a list ID and an email or user ID")
+			return
+		}
+		userId, err := findMember(client, args[0], args[1])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if err := client.RemoveMember(args[0], userId); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		recordUndo(nil, nil)
+		fmt.Printf("List %s is no longer shared with %s\n", args[0], args[1])
+
+	default:
+		fmt.Printf("Unknown lists command: %s\n", command)
+		printHelp()
+	}
+}
+
+// findMember resolves a member given by email to their user ID; anything
+// without an @ is taken to be a user ID already
+func findMember(client *APIClient, listId, user string) (string, error) {
+	if !strings.Contains(user, "@") {
+		return user, nil
+	}
+	members, err := client.GetMembers(listId)
 	if err != nil {
-		fmt.Println("Error: Invalid ID. Please provide a number")
-		return
+		return "", err
+	}
+	for _, member := range members {
+		if strings.EqualFold(member.Email, user) {
+			return member.UserId, nil
+		}
 	}
-	done := false
-	todo, err := client.UpdateTodo(id, UpdateTodoRequest{Done: &done})
+	return "", fmt.Errorf("%s is not a member of list %s", user, listId)
+}
+
+// printTodos prints todos as pages arrive so large lists start showing immediately
+func printTodos(todos iter.Seq2[Todo, error], emptyMessage string) {
+	found := false
+	for todo, err := range todos {
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Println(todo)
+		found = true
+	}
+	if !found {
+		fmt.Println(emptyMessage)
+	}
+}
+
+// handleSetDone marks one or more todos as complete or incomplete
+func handleSetDone(client *APIClient, done bool) {
+	ids, err := parseIDs(os.Args[2:])
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
-	fmt.Printf("Todo %d marked as incomplete\n", todo.Id)
+	state := "incomplete"
+	if done {
+		state = "complete"
+	}
+
+	// Remember which todos change state, so "listy undo" only reverts those
+	before := make(map[int]Todo)
+	for _, id := range ids {
+		if todo, err := client.GetTodo(id); err == nil {
+			before[id] = *todo
+		}
+	}
+
+	ops := make([]BatchOperation, len(ids))
+	for i, id := range ids {
+		ops[i] = BatchOperation{Op: "update", Id: id, Changes: &UpdateTodoRequest{Done: &done}}
+	}
+	results, err := client.BatchTodos(ops)
+	printBatch(results, err, "marked as "+state)
+
+	var undo []BatchOperation
+	for _, result := range results {
+		if result.OK() {
+			printNext(result.Data)
+		}
+		if old, ok := before[result.Id]; ok && old.Done != done && result.OK() {
+			undo = append(undo, undoCompletion(old, *result.Data)...)
+		}
+	}
+	if len(undo) > 0 {
+		recordUndo(undo, nil)
+	}
+}
+
+// parseIDs parses one or more todo IDs
+func parseIDs(args []string) ([]int, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("please provide a todo ID")
+	}
+	ids := make([]int, len(args))
+	for i, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q, please provide numbers", arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// printBatch prints a line per batch operation, saying what happened to the todo
+func printBatch(results []BatchResult, err error, action string) {
+	for _, result := range results {
+		if result.OK() {
+			fmt.Printf("Todo %d %s\n", result.Id, action)
+		} else {
+			fmt.Printf("Error: todo %d: %s\n", result.Id, result.Error)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
 }
 
 func handleToggle(client *APIClient) {
@@ -189,49 +819,577 @@ func handleToggle(client *APIClient) {
 		fmt.Println("Error: Invalid ID. Please provide a number")
 		return
 	}
-	todo, err := client.ToggleTodo(id)
+	// Toggle the state we read, not whatever someone else changed it to since
+	todo, err := client.GetTodo(id)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
+	before := *todo
+	todo, err = client.ToggleTodo(id, todo.Version)
+	if err != nil {
+		printTodoError(id, err)
+		return
+	}
+	recordUndo(undoCompletion(before, *todo), nil)
 	fmt.Printf("Todo %d status toggled\n", todo.Id)
+	printNext(todo)
+}
+
+// undoCompletion returns how to put back a todo whose done state changed
+// from before to after. Completing a recurring todo also added its next
+// occurrence and moved the rule there, so undoing removes that occurrence
+// and gives the rule back.
+func undoCompletion(before, after Todo) []BatchOperation {
+	revert := &UpdateTodoRequest{Done: &before.Done}
+	undo := []BatchOperation{{Op: "update", Id: after.Id, Version: after.Version, Changes: revert}}
+	if after.Next != nil {
+		revert.Recurrence = &before.Recurrence
+		undo = append(undo, BatchOperation{Op: "delete", Id: after.Next.Id, Version: after.Next.Version})
+	}
+	return undo
+}
+
+// printNext reports the occurrence added by completing a recurring todo
+func printNext(todo *Todo) {
+	if todo == nil || todo.Next == nil {
+		return
+	}
+	next := fmt.Sprintf("Next occurrence: todo %d", todo.Next.Id)
+	if todo.Next.DueAt != nil {
+		next += ", due " + todo.Next.DueAt.Local().Format("Mon Jan 2 2006 15:04")
+	}
+	fmt.Println(next)
 }
 
 func handleUpdate(client *APIClient) {
-	if len(os.Args) < 4 {
-		fmt.Println("Error: Please provide a todo ID and new text")
-		fmt.Println("Usage: go run main.go update <id> \"New text\"")
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	flags.String("priority", "", "high, medium or low; empty to clear")
+	flags.String("estimate", "", "estimated time; empty to clear")
+	flags.String("category", "", "category; empty to clear")
+	flags.String("every", "", "repeat when completed, e.g. \"weekly on mon\"; empty to stop repeating")
+	args := parseInterspersed(flags, os.Args[2:])
+
+	// Only flags given on the command line are sent, so --category "" clears the category
+	var req UpdateTodoRequest
+	flags.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "priority":
+			req.Priority = &value
+		case "estimate":
+			req.EstimatedTime = &value
+		case "category":
+			req.Category = &value
+		case "every":
+			req.Recurrence = &value
+		}
+	})
+
+	if len(args) < 1 || (len(args) < 2 && req == (UpdateTodoRequest{})) {
+		fmt.Println("Error: Please provide a todo ID and new text or flags")
+		fmt.Println("Usage: go run main.go update <id> [\"New text\"] [--priority p] [--estimate e] [--category c] [--every rule]")
 		return
 	}
-	id, err := strconv.Atoi(os.Args[2])
+	id, err := strconv.Atoi(args[0])
 	if err != nil {
 		fmt.Println("Error: Invalid ID. Please provide a number")
 		return
 	}
-	newText := os.Args[3]
-	item := newText
-	todo, err := client.UpdateTodo(id, UpdateTodoRequest{Item: &item})
+	if len(args) > 1 {
+		item := args[1]
+		req.Item = &item
+	}
+	before, err := client.GetTodo(id)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
+	if req.Recurrence != nil && *req.Recurrence != "" {
+		rule, err := ParseEvery(*req.Recurrence)
+		if err != nil {
+			fmt.Printf("Error: --every: %v\n", err)
+			return
+		}
+		start := atTime(time.Now(), defaultDueHour, defaultDueMinute)
+		if before.DueAt != nil {
+			start = *before.DueAt
+		}
+		rule = withTimeZone(rule, start)
+		req.Recurrence = &rule
+	}
+	todo, err := client.UpdateTodo(id, before.Version, req)
+	if err != nil {
+		printTodoError(id, err)
+		return
+	}
+
+	// Undoing puts back the fields this update changed
+	var revert UpdateTodoRequest
+	if req.Item != nil {
+		revert.Item = &before.Item
+	}
+	if req.Priority != nil {
+		revert.Priority = &before.Priority
+	}
+	if req.EstimatedTime != nil {
+		revert.EstimatedTime = &before.EstimatedTime
+	}
+	if req.Category != nil {
+		revert.Category = &before.Category
+	}
+	if req.Recurrence != nil {
+		revert.Recurrence = &before.Recurrence
+	}
+	recordUndo([]BatchOperation{{Op: "update", Id: id, Version: todo.Version, Changes: &revert}}, nil)
 	fmt.Printf("Todo %d updated successfully\n", todo.Id)
 }
 
 func handleRemove(client *APIClient) {
-	if len(os.Args) < 3 {
-		fmt.Println("Error: Please provide a todo ID")
+	flags := flag.NewFlagSet("remove", flag.ExitOnError)
+	done := flags.Bool("done", false, "remove every completed todo")
+	list := flags.String("list", "", "with --done, only completed todos in this list (\"main\" for the main list)")
+	args := parseInterspersed(flags, os.Args[2:])
+
+	if *done {
+		removeCompleted(client, *list)
 		return
 	}
-	id, err := strconv.Atoi(os.Args[2])
+	ids, err := parseIDs(args)
 	if err != nil {
-		fmt.Println("Error: Invalid ID. Please provide a number")
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(ids) > 1 {
+		ops := make([]BatchOperation, len(ids))
+		for i, id := range ids {
+			ops[i] = BatchOperation{Op: "delete", Id: id}
+		}
+		results, err := client.BatchTodos(ops)
+		printBatch(results, err, "moved to the trash")
+		recordRestore(results)
+		return
+	}
+
+	id := ids[0]
+	todo, err := client.GetTodo(id)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if err := client.DeleteTodo(id, todo.Version); err != nil {
+		printTodoError(id, err)
+		return
+	}
+	recordUndo([]BatchOperation{{Op: "restore", Id: id}}, nil)
+	fmt.Printf("Todo %d moved to the trash (\"listy undo\" brings it back)\n", id)
+}
+
+// recordRestore lets "listy undo" bring back the todos a batch moved to the
+// trash, latest first so parents come back before their subtasks
+func recordRestore(results []BatchResult) {
+	var undo []BatchOperation
+	for _, result := range results {
+		if result.OK() {
+			undo = append(undo, BatchOperation{Op: "restore", Id: result.Id})
+		}
+	}
+	slices.Reverse(undo)
+	if len(undo) > 0 {
+		recordUndo(undo, nil)
+		fmt.Println("Run \"listy undo\" to bring them back")
+	}
+}
+
+// handleRestore takes one or more todos out of the trash
+func handleRestore(client *APIClient) {
+	ids, err := parseIDs(os.Args[2:])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	ops := make([]BatchOperation, len(ids))
+	for i, id := range ids {
+		ops[i] = BatchOperation{Op: "restore", Id: id}
+	}
+	results, err := client.BatchTodos(ops)
+	printBatch(results, err, "restored")
+
+	var undo []BatchOperation
+	for _, result := range results {
+		if result.OK() {
+			undo = append(undo, BatchOperation{Op: "delete", Id: result.Id, Version: result.Data.Version})
+		}
+	}
+	if len(undo) > 0 {
+		recordUndo(undo, nil)
+	}
+}
+
+// removeCompleted moves every completed todo to the trash, optionally in one
+// list. Each is only deleted while still at the version listed, so a todo
+// reopened in the meantime is kept.
+func removeCompleted(client *APIClient, list string) {
+	done := true
+	todos, err := sdk.Collect(client.SearchTodos(TodoFilter{Done: &done, List: list}))
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	// Deleting a todo deletes its subtasks too, and restoring it restores them
+	removing := make(map[int]bool)
+	for _, todo := range todos {
+		removing[todo.Id] = true
+	}
+	var ops []BatchOperation
+	for _, todo := range todos {
+		if todo.ParentId == nil || !removing[*todo.ParentId] {
+			ops = append(ops, BatchOperation{Op: "delete", Id: todo.Id, Version: todo.Version})
+		}
+	}
+	if len(ops) == 0 {
+		fmt.Println("No completed todos to remove")
+		return
+	}
+
+	results, err := client.BatchTodos(ops)
+	removed := 0
+	for _, result := range results {
+		if result.OK() {
+			removed++
+		} else if conflicted(result) {
+			fmt.Printf("Kept todo %d: it was changed by someone else in the meantime\n", result.Id)
+		} else {
+			fmt.Printf("Error: todo %d: %s\n", result.Id, result.Error)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+	fmt.Printf("Moved %d completed todo(s) to the trash\n", removed)
+	recordRestore(results)
+}
+
+// printTodoError prints why changing a todo failed, explaining what to do
+// when someone else changed it first
+func printTodoError(id int, err error) {
+	if errors.Is(err, ErrConflict) {
+		fmt.Printf("Error: todo %d was changed by someone else in the meantime, so nothing was done.\n", id)
+		fmt.Printf("Run \"listy show %d\" to see the latest version and try again.\n", id)
+		return
+	}
+	fmt.Printf("Error: %v\n", err)
+}
+
+func handleWatch(client *APIClient) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	list := flags.String("list", "", "only changes in this list (\"main\" for the main list)")
+	flags.Parse(os.Args[2:])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Println("Watching for changes (Ctrl+C to stop)...")
+	lastEventId := ""
+	backoff := time.Second
+	for {
+		for event, err := range client.StreamTodos(ctx, *list, lastEventId) {
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				if errors.As(err, new(*sdk.Error)) {
+					return // Not found, forbidden or signed out: retrying won't help
+				}
+				break
+			}
+			backoff = time.Second
+			lastEventId = event.Id
+			printEvent(event)
+		}
+
+		// The stream ended: reconnect, resuming after the last event seen
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// printEvent prints a change from the todo stream
+func printEvent(event TodoEvent) {
+	at := event.At.Local().Format("15:04:05")
+	if event.Todo == nil {
+		fmt.Printf("%s  some changes were missed; run \"listy list\" to see the current todos\n", at)
 		return
 	}
-	err = client.DeleteTodo(id)
+	fmt.Printf("%s  %-8s %s\n", at, event.Type, event.Todo)
+}
+
+// handleLogin signs in (or registers) with an email and password, or checks a
+// token passed with --token, and saves the token to the config file
+// handleExport writes the user's todos in another format
+func handleExport(client *APIClient) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "", "output format: json, csv, markdown, todotxt or ics (default: from --output, else json)")
+	list := flags.String("list", "", "only todos in this list (\"main\" for the main list)")
+	as := flags.String("as", "", "ics: pending todos as events (default), or every todo as a VTODO with \"todo\"")
+	output := flags.String("output", "", "write to this file instead of stdout")
+	subscribe := flags.Bool("subscribe", false, "ics: print a feed URL to subscribe calendar apps to, replacing the previous one")
+	flags.Parse(os.Args[2:])
+
+	if *format == "" {
+		*format = formatFromPath(*output)
+	}
+	if *format == "" {
+		*format = "json"
+	}
+	if *format == "md" {
+		*format = "markdown"
+	}
+	if !slices.Contains(fileFormats, *format) && *format != "ics" {
+		fmt.Printf("Error: unsupported --format %q (supported: %s, ics)\n", *format, strings.Join(fileFormats, ", "))
+		return
+	}
+
+	if *format == "ics" && *subscribe {
+		subscription, err := client.IssueCalendarToken()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		feed, err := url.Parse(subscription.URL)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if *list != "" {
+			feed.Path = "/api/lists/" + url.PathEscape(*list) + "/calendar.ics"
+		}
+		if *as != "" {
+			query := feed.Query()
+			query.Set("as", *as)
+			feed.RawQuery = query.Encode()
+		}
+		fmt.Println("Subscribe your calendar app to:")
+		fmt.Println(feed.String())
+		fmt.Println("Anyone with this URL can read these todos; URLs issued before no longer work.")
+		return
+	}
+
+	var data []byte
+	var err error
+	if *format == "ics" {
+		data, err = client.Calendar(*list, *as)
+	} else {
+		data, err = client.Export(*format, *list)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if *output == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if *format == "ics" {
+		entries := bytes.Count(data, []byte("BEGIN:VEVENT")) + bytes.Count(data, []byte("BEGIN:VTODO"))
+		fmt.Printf("Exported %d todos to %s\n", entries, *output)
+	} else {
+		fmt.Printf("Exported todos to %s\n", *output)
+	}
+}
+
+// fileFormats are the formats export and import share
+var fileFormats = []string{"json", "csv", "markdown", "todotxt"}
+
+// formatFromPath picks a format from a file name's extension; "" when it has no known one
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".csv":
+		return "csv"
+	case ".md", ".markdown":
+		return "markdown"
+	case ".txt":
+		return "todotxt"
+	case ".ics":
+		return "ics"
+	}
+	return ""
+}
+
+// handleImport adds the todos in a JSON, CSV, Markdown checklist or todo.txt file
+func handleImport(client *APIClient) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	format := flags.String("format", "", "input format: json, csv, markdown or todotxt (default: from the file name, else detected)")
+	list := flags.String("list", "", "put every todo in this list (\"main\" for the main list)")
+	dryRun := flags.Bool("dry-run", false, "show what would be imported without adding anything")
+	dedupe := flags.Bool("dedupe", false, "skip todos whose text is already in the same list")
+	args := parseInterspersed(flags, os.Args[2:])
+
+	if len(args) != 1 {
+		fmt.Println("Usage: import <file|-> [--format json|csv|markdown|todotxt] [--list <id|main>] [--dry-run] [--dedupe]")
+		return
+	}
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if *format == "" {
+		*format = formatFromPath(args[0])
+	}
+	if *format == "md" {
+		*format = "markdown"
+	}
+	if *format != "" && !slices.Contains(fileFormats, *format) {
+		fmt.Printf("Error: unsupported --format %q (supported: %s)\n", *format, strings.Join(fileFormats, ", "))
+		return
+	}
+
+	result, err := client.Import(data, ImportOptions{Format: *format, List: *list, DryRun: *dryRun, Dedupe: *dedupe})
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		return
 	}
-	fmt.Printf("Todo %d removed successfully\n", id)
+
+	skipped := ""
+	if result.Skipped > 0 {
+		skipped = fmt.Sprintf(", skipped %d duplicates", result.Skipped)
+	}
+	if result.DryRun {
+		fmt.Printf("Would import %d todos from %s%s:\n", result.Created, result.Format, skipped)
+		for _, todo := range result.Todos {
+			fmt.Println("  " + todo.String())
+		}
+		return
+	}
+	fmt.Printf("Imported %d todos from %s%s\n", result.Created, result.Format, skipped)
+
+	if len(result.Todos) == 0 {
+		recordUndo(nil, nil)
+		return
+	}
+	// Later todos in the import may have changed earlier ones, such as an
+	// auto-completing parent, so undo does not check versions; subtasks go
+	// to the trash before their parents
+	undo := make([]BatchOperation, len(result.Todos))
+	for i, todo := range result.Todos {
+		undo[len(undo)-1-i] = BatchOperation{Op: "delete", Id: todo.Id}
+	}
+	recordUndo(undo, nil)
+}
+
+func handleLogin(client *APIClient, apiURL string, register bool) {
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	token := flags.String("token", "", "save this token (e.g. a Supabase access token) instead of signing in")
+	args := parseInterspersed(flags, os.Args[2:])
+
+	config := Config{Token: *token}
+	if *token != "" {
+		_, email, err := NewAPIClient(apiURL, *token).Me()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		config.Email = email
+	} else {
+		input := bufio.NewReader(os.Stdin)
+		email := ""
+		if len(args) > 0 {
+			email = args[0]
+		} else {
+			email = prompt(input, "Email: ")
+		}
+		password := readPassword(input, "Password: ")
+
+		signIn := client.Login
+		if register {
+			signIn = client.Register
+		}
+		auth, err := signIn(email, password)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		config = Config{Token: auth.Token, Email: auth.User.Email}
+	}
+
+	if err := SaveConfig(config); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	path, _ := ConfigPath()
+	if config.Email != "" {
+		fmt.Printf("Logged in as %s (token saved to %s)\n", config.Email, path)
+		return
+	}
+	fmt.Printf("Logged in (token saved to %s)\n", path)
+}
+
+func handleLogout(config Config) {
+	if config.Token == "" {
+		fmt.Println("Not logged in")
+		return
+	}
+	if err := SaveConfig(Config{}); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Println("Logged out")
+}
+
+func handleWhoami(client *APIClient) {
+	id, email, err := client.Me()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	switch {
+	case id == "":
+		fmt.Println("Authentication is disabled on this server; all todos are shared")
+	case email != "":
+		fmt.Printf("%s (%s)\n", email, id)
+	default:
+		fmt.Println(id)
+	}
+}
+
+// prompt prints label and reads one line from input
+func prompt(input *bufio.Reader, label string) string {
+	fmt.Print(label)
+	line, _ := input.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// readPassword prompts for a password without echoing it when stdin is a
+// terminal; piped input is read as a plain line
+func readPassword(input *bufio.Reader, label string) string {
+	if err := stty("-echo"); err == nil {
+		defer func() {
+			stty("echo")
+			fmt.Println()
+		}()
+	}
+	return prompt(input, label)
+}
+
+// stty changes the terminal settings of stdin; it fails when stdin is not a terminal
+func stty(setting string) error {
+	cmd := exec.Command("stty", setting)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
 }
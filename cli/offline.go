@@ -0,0 +1,637 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"listy-api/core"
+	"listy-api/models"
+	"listy-api/sdk"
+)
+
+// OfflineState is what lets the CLI work while the API is unreachable: the
+// todos as last fetched, changed by the commands run offline, and the queue
+// of those changes waiting to be sent. It is kept next to the config file.
+type OfflineState struct {
+	User     string    `json:"user,omitempty"` // Email of the account the todos belong to
+	SyncedAt time.Time `json:"synced_at"`      // When the todos were last fetched
+	Todos    []Todo    `json:"todos"`
+
+	Queue []QueuedChange `json:"queue,omitempty"`
+	// Todos added offline get IDs counting down from -1 until they are synced
+	LastLocalId int `json:"last_local_id,omitempty"`
+}
+
+// QueuedChange is a change made offline, sent as a batch operation when the
+// API can be reached again. Version is the todo's version when the change
+// was made, so changes to todos someone else changed since are detected.
+type QueuedChange struct {
+	BatchOperation
+	LocalId  int       `json:"local_id,omitempty"` // For creates, the ID the todo has until synced
+	Command  string    `json:"command"`            // The command that made the change, e.g. "complete 3"
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+// OfflinePath returns offline.json in the same directory as the config file
+func OfflinePath() (string, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "offline.json"), nil
+}
+
+// LoadOffline reads the offline state; a missing file is an empty state
+func LoadOffline() (*OfflineState, error) {
+	path, err := OfflinePath()
+	if err != nil {
+		return nil, err
+	}
+	state := &OfflineState{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// SaveOffline writes the offline state, readable only by the current user
+func SaveOffline(state *OfflineState) error {
+	path, err := OfflinePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode offline state: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// offlineCommands are the commands that work from the offline state when
+// the API cannot be reached
+var offlineCommands = map[string]bool{
+	"add": true, "list": true, "pending": true, "completed": true, "show": true,
+	"complete": true, "incomplete": true, "toggle": true, "update": true, "remove": true,
+	"undo": true, "sync": true,
+}
+
+// isUnreachable reports whether err means the API could not be reached,
+// rather than that it answered with an error
+func isUnreachable(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// index returns the position of the todo with id, or -1
+func (s *OfflineState) index(id int) int {
+	i, _ := core.FindTodosById(s.Todos, id)
+	return i
+}
+
+// apply makes a change to the offline todos and queues it, answering like
+// the batch endpoint would. The change is saved before apply returns.
+func (s *OfflineState) apply(op BatchOperation) BatchResult {
+	result := BatchResult{Op: op.Op, Id: op.Id, Status: http.StatusOK}
+	fail := func(status int, code, format string, args ...any) BatchResult {
+		result.Status, result.Code, result.Error = status, code, fmt.Sprintf(format, args...)
+		return result
+	}
+	change := QueuedChange{BatchOperation: op, Command: commandLine(), QueuedAt: time.Now().UTC()}
+
+	switch op.Op {
+	case "create":
+		if op.Todo == nil || strings.TrimSpace(op.Todo.Item) == "" {
+			return fail(http.StatusBadRequest, models.CodeValidation, "a todo needs text")
+		}
+		req := *op.Todo
+		if req.ParentId != nil && req.ListId == nil {
+			if parent := s.index(*req.ParentId); parent >= 0 {
+				req.ListId = s.Todos[parent].ListId
+			}
+		}
+		s.LastLocalId--
+		todo := Todo{
+			Id: s.LastLocalId, Version: 1, Item: req.Item, ListId: req.ListId,
+			DueAt: req.DueAt, RemindAt: req.RemindAt,
+			Priority: req.Priority, EstimatedTime: req.EstimatedTime, Category: req.Category,
+			ParentId: req.ParentId, AutoComplete: req.AutoComplete, Recurrence: req.Recurrence, Tags: req.Tags,
+		}
+		s.Todos = append(s.Todos, todo)
+		change.LocalId = todo.Id
+		result.Id, result.Status, result.Data = todo.Id, http.StatusCreated, &todo
+
+	case "update", "toggle", "delete":
+		i := s.index(op.Id)
+		if i < 0 {
+			return fail(http.StatusNotFound, models.CodeNotFound, "todo with ID %d is not in the offline copy", op.Id)
+		}
+		todo := s.Todos[i]
+		if op.Version != 0 && op.Version != todo.Version {
+			return fail(http.StatusPreconditionFailed, models.CodeVersionConflict, "todo %d was changed in the meantime", op.Id)
+		}
+		// Checked against the version the API had, so a change someone
+		// else made while this one waited is a conflict
+		change.Version = todo.Version
+
+		if op.Op == "delete" {
+			var removed []int
+			for _, t := range s.Todos {
+				if s.within(t, op.Id) {
+					removed = append(removed, t.Id)
+				}
+			}
+			s.Todos = slices.DeleteFunc(s.Todos, func(t Todo) bool { return slices.Contains(removed, t.Id) })
+			break
+		}
+		if op.Op == "toggle" {
+			todo.ToggleDone()
+		} else if op.Changes != nil {
+			applyChanges(&todo, *op.Changes)
+		}
+		todo.Version++
+		s.Todos[i] = todo
+		result.Data = &todo
+
+	default:
+		return fail(http.StatusServiceUnavailable, models.CodeUnavailable, "%s is not available offline", op.Op)
+	}
+
+	s.Queue = append(s.Queue, change)
+	if err := SaveOffline(s); err != nil {
+		return fail(http.StatusInternalServerError, models.CodeInternal, "%v", err)
+	}
+	return result
+}
+
+// within reports whether todo is the one with id or one of its subtasks, at any depth
+func (s *OfflineState) within(todo Todo, id int) bool {
+	for seen := 0; seen <= len(s.Todos); seen++ {
+		if todo.Id == id {
+			return true
+		}
+		if todo.ParentId == nil {
+			return false
+		}
+		parent := s.index(*todo.ParentId)
+		if parent < 0 {
+			return false
+		}
+		todo = s.Todos[parent]
+	}
+	return false
+}
+
+// applyChanges sets the fields an update changes, as the API does
+func applyChanges(todo *Todo, req UpdateTodoRequest) {
+	if req.Item != nil {
+		todo.Item = *req.Item
+	}
+	if req.Done != nil {
+		todo.Done = *req.Done
+	}
+	if req.DueAt != nil {
+		todo.DueAt = req.DueAt
+	}
+	if req.ClearDueAt {
+		todo.DueAt = nil
+	}
+	if req.RemindAt != nil {
+		todo.RemindAt = req.RemindAt
+	}
+	if req.ClearRemindAt {
+		todo.RemindAt = nil
+	}
+	if req.Priority != nil {
+		todo.Priority = *req.Priority
+	}
+	if req.EstimatedTime != nil {
+		todo.EstimatedTime = *req.EstimatedTime
+	}
+	if req.Category != nil {
+		todo.Category = *req.Category
+	}
+	if req.ParentId != nil {
+		todo.ParentId = req.ParentId
+	}
+	if req.ClearParentId {
+		todo.ParentId = nil
+	}
+	if req.AutoComplete != nil {
+		todo.AutoComplete = *req.AutoComplete
+	}
+	if req.Recurrence != nil {
+		todo.Recurrence = *req.Recurrence
+	}
+	if req.Tags != nil {
+		todo.Tags = *req.Tags
+	}
+}
+
+// view returns a copy of a todo with its subtask progress counted from the offline todos
+func (s *OfflineState) view(todo Todo) Todo {
+	todo.Subtasks = nil
+	for _, child := range s.Todos {
+		if child.ParentId == nil || *child.ParentId != todo.Id {
+			continue
+		}
+		if todo.Subtasks == nil {
+			todo.Subtasks = &core.SubtaskProgress{}
+		}
+		todo.Subtasks.Total++
+		if child.Done {
+			todo.Subtasks.Done++
+		}
+	}
+	return todo
+}
+
+// get returns a todo from the offline copy
+func (s *OfflineState) get(id int) (*Todo, error) {
+	i := s.index(id)
+	if i < 0 {
+		return nil, fmt.Errorf("todo with ID %d is not in the offline copy", id)
+	}
+	todo := s.view(s.Todos[i])
+	return &todo, nil
+}
+
+// tree returns a todo with its subtasks nested under Children
+func (s *OfflineState) tree(id int) (*Todo, error) {
+	todo, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range s.Todos {
+		if child.ParentId != nil && *child.ParentId == id {
+			subtree, err := s.tree(child.Id)
+			if err != nil {
+				return nil, err
+			}
+			todo.Children = append(todo.Children, *subtree)
+		}
+	}
+	return todo, nil
+}
+
+// todos iterates over the offline todos a listing endpoint would return.
+// Only the plain listings and their filters work offline; sorting does not.
+func (s *OfflineState) todos(path string, params url.Values) iter.Seq2[Todo, error] {
+	return func(yield func(Todo, error) bool) {
+		query := url.Values{}
+		for key, values := range params {
+			query[key] = values
+		}
+		switch path {
+		case "/api/todos":
+		case "/api/todos/pending":
+			query.Set("done", "false")
+		case "/api/todos/completed":
+			query.Set("done", "true")
+		default:
+			yield(Todo{}, fmt.Errorf("%s is not available offline", path))
+			return
+		}
+		if query.Has("sort") {
+			yield(Todo{}, fmt.Errorf("sorting is not available offline"))
+			return
+		}
+
+		for _, todo := range s.Todos {
+			match, err := matchesQuery(todo, query)
+			if err != nil {
+				yield(Todo{}, err)
+				return
+			}
+			if match && !yield(s.view(todo), nil) {
+				return
+			}
+		}
+	}
+}
+
+// matchesQuery reports whether a todo passes the filters of GET /api/todos
+func matchesQuery(todo Todo, query url.Values) (bool, error) {
+	if value := query.Get("done"); value != "" {
+		done, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("done must be true or false")
+		}
+		if todo.Done != done {
+			return false, nil
+		}
+	}
+	if list := query.Get("list"); list != "" {
+		if list == "main" && todo.ListId != nil || list != "main" && (todo.ListId == nil || *todo.ListId != list) {
+			return false, nil
+		}
+	}
+	if search := query.Get("q"); search != "" && !strings.Contains(strings.ToLower(todo.Item), strings.ToLower(search)) {
+		return false, nil
+	}
+	if priority := query.Get("priority"); priority != "" && todo.Priority != priority {
+		return false, nil
+	}
+	if category := query.Get("category"); category != "" && !strings.EqualFold(todo.Category, category) {
+		return false, nil
+	}
+	switch parent := query.Get("parent"); parent {
+	case "":
+	case "none":
+		if todo.ParentId != nil {
+			return false, nil
+		}
+	default:
+		id, err := strconv.Atoi(parent)
+		if err != nil {
+			return false, fmt.Errorf("parent must be \"none\" or a todo ID")
+		}
+		if todo.ParentId == nil || *todo.ParentId != id {
+			return false, nil
+		}
+	}
+	if tags := query["tag"]; len(tags) > 0 {
+		matched := 0
+		for _, tag := range tags {
+			if slices.Contains(todo.Tags, strings.ToLower(strings.TrimPrefix(tag, "#"))) {
+				matched++
+			}
+		}
+		if matched == 0 || query.Get("tag_mode") != "any" && matched < len(tags) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// SyncOutcome is what happened to a queued change when it was sent
+type SyncOutcome struct {
+	Change QueuedChange
+	Result BatchResult
+}
+
+// Sync sends the queued changes to the API in order, one at a time, so
+// todos added offline get their real IDs before later changes name them.
+// A change to a todo someone else changed in the meantime fails with 412
+// and is dropped, and so is every later change to that todo. When the API
+// cannot be reached or the request fails as a whole, e.g. with 401, 429 or
+// 503, the changes not yet sent stay queued, with the IDs they need filled
+// in, and the error is returned.
+func (c *APIClient) Sync(state *OfflineState) ([]SyncOutcome, error) {
+	ids := make(map[int]int)        // Local IDs of todos added offline to their real ones
+	conflicts := make(map[int]bool) // Todos whose changes were dropped as conflicts
+	var outcomes []SyncOutcome
+	for len(state.Queue) > 0 {
+		change := state.Queue[0]
+		op, missing := resolveIds(change.BatchOperation, ids)
+		result := BatchResult{Op: op.Op, Id: op.Id}
+		if missing != 0 {
+			result.Status, result.Code, result.Error = http.StatusNotFound, models.CodeNotFound, fmt.Sprintf("todo %d was never added", missing)
+		} else if op.Op != "create" && conflicts[op.Id] {
+			// Its version would match the conflicting change's, not this one's
+			result.Status, result.Code, result.Error = http.StatusPreconditionFailed, models.CodeVersionConflict, fmt.Sprintf("todo %d was changed in the meantime", op.Id)
+		} else {
+			results, err := c.BatchTodos([]BatchOperation{op})
+			var apiErr *sdk.Error
+			if err != nil && (!errors.As(err, &apiErr) || !refused(apiErr.StatusCode)) {
+				return outcomes, err
+			}
+			if err != nil {
+				result.Status, result.Code, result.Error = apiErr.StatusCode, apiErr.Code, err.Error()
+			} else if len(results) == 1 {
+				result = results[0]
+			}
+			if op.Op == "create" && result.OK() {
+				ids[change.LocalId] = result.Id
+			}
+			if conflicted(result) {
+				conflicts[op.Id] = true
+			}
+		}
+		outcomes = append(outcomes, SyncOutcome{Change: change, Result: result})
+
+		// Fill in the real IDs now, so the rest of the queue still works
+		// if the next change cannot be sent
+		state.Queue = state.Queue[1:]
+		for i := range state.Queue {
+			state.Queue[i].BatchOperation, _ = resolveIds(state.Queue[i].BatchOperation, ids)
+		}
+		if err := SaveOffline(state); err != nil {
+			return outcomes, err
+		}
+	}
+	return outcomes, nil
+}
+
+// refused reports whether a batch request failed with a status that
+// refuses the change it carried, so sending it again cannot succeed, rather
+// than one that says nothing about the change, such as 401 or 503
+func refused(status int) bool {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		return false
+	}
+	return status >= 400 && status < 500
+}
+
+// resolveIds replaces the local IDs an operation names with the real IDs in
+// ids, returning the first local ID that has none, or 0
+func resolveIds(op BatchOperation, ids map[int]int) (BatchOperation, int) {
+	missing := 0
+	resolve := func(id *int) *int {
+		if id == nil || *id >= 0 {
+			return id
+		}
+		if real, ok := ids[*id]; ok {
+			return &real
+		}
+		if missing == 0 {
+			missing = *id
+		}
+		return id
+	}
+
+	if op.Op != "create" {
+		op.Id = *resolve(&op.Id)
+	}
+	if op.Todo != nil {
+		todo := *op.Todo
+		todo.ParentId = resolve(todo.ParentId)
+		op.Todo = &todo
+	}
+	if op.Changes != nil {
+		changes := *op.Changes
+		changes.ParentId = resolve(changes.ParentId)
+		op.Changes = &changes
+	}
+	return op, missing
+}
+
+// Refresh replaces the offline todos with the user's todos as the API has
+// them now. It must only run with an empty queue, or changes made offline
+// would vanish from the offline copy.
+func (c *APIClient) Refresh(state *OfflineState, user string) error {
+	todos, err := c.GetTodos()
+	if err != nil {
+		return err
+	}
+	state.User, state.SyncedAt, state.Todos, state.LastLocalId = user, time.Now().UTC(), todos, 0
+	return SaveOffline(state)
+}
+
+// GoOffline makes the client read and change the offline copy instead of calling the API
+func (c *APIClient) GoOffline(state *OfflineState) {
+	c.offline = state
+}
+
+// offlineChange applies one change to the offline copy, returning the todo
+// or the error the API call would
+func (c *APIClient) offlineChange(op BatchOperation) (*Todo, error) {
+	result := c.offline.apply(op)
+	if !result.OK() {
+		return nil, resultError(result)
+	}
+	return result.Data, nil
+}
+
+// resultError turns a failed offline change into the error the API call would return
+func resultError(result BatchResult) error {
+	if conflicted(result) {
+		return ErrConflict
+	}
+	return errors.New(result.Error)
+}
+
+// commandLine is the command being run, quoted where needed, as undo and the sync queue show it
+func commandLine() string {
+	args := make([]string, len(os.Args)-1)
+	for i, arg := range os.Args[1:] {
+		if arg == "" || strings.ContainsAny(arg, " \t\"'") {
+			arg = strconv.Quote(arg)
+		}
+		args[i] = arg
+	}
+	return strings.Join(args, " ")
+}
+
+// handleSync shows the changes made offline and sends them when the API can
+// be reached; --discard drops them instead
+func handleSync(client *APIClient, state *OfflineState, config Config) {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	discard := flags.Bool("discard", false, "drop the queued changes without sending them")
+	flags.Parse(os.Args[2:])
+
+	if *discard {
+		dropped := len(state.Queue)
+		state.Queue = nil
+		if client.offline != nil {
+			// The offline copy still shows the dropped changes until it is refreshed
+			state.SyncedAt = time.Time{}
+			if err := SaveOffline(state); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			fmt.Printf("Dropped %d change(s); the offline copy is refreshed once the API can be reached\n", dropped)
+			return
+		}
+		if err := client.Refresh(state, config.Email); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Dropped %d change(s)\n", dropped)
+		return
+	}
+
+	if len(state.Queue) == 0 {
+		fmt.Println("Nothing to sync")
+	} else {
+		fmt.Printf("%d change(s) made offline:\n", len(state.Queue))
+		for _, change := range state.Queue {
+			fmt.Printf("  %s (listy %s, %s)\n", describeChange(change), change.Command, change.QueuedAt.Local().Format("Mon Jan 2 15:04"))
+		}
+	}
+	if client.offline != nil {
+		if len(state.Queue) > 0 {
+			fmt.Println("The API cannot be reached; they are sent once it can.")
+		}
+		return
+	}
+
+	if !flushQueue(client, state, config) {
+		return
+	}
+	if err := client.Refresh(state, config.Email); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Offline copy updated: %d todo(s)\n", len(state.Todos))
+}
+
+// flushQueue sends the changes made offline, printing what happened to
+// each, and reports whether the queue is now empty. Changes made with
+// another account are left for that account.
+func flushQueue(client *APIClient, state *OfflineState, config Config) bool {
+	if len(state.Queue) == 0 {
+		return true
+	}
+	if state.User != config.Email {
+		fmt.Printf("Warning: %d change(s) made offline as %s were not sent; log in as %s and run \"listy sync\", or drop them with \"listy sync --discard\"\n",
+			len(state.Queue), state.User, state.User)
+		return false
+	}
+
+	outcomes, err := client.Sync(state)
+	for _, outcome := range outcomes {
+		change, result := outcome.Change, outcome.Result
+		switch {
+		case result.OK() && change.Op == "create":
+			fmt.Printf("Synced: %s, now todo %d\n", describeChange(change), result.Id)
+		case result.OK():
+			fmt.Printf("Synced: %s\n", describeChange(change))
+		case conflicted(result):
+			fmt.Printf("Conflict: %s was dropped, the todo was changed by someone else in the meantime\n", describeChange(change))
+		default:
+			fmt.Printf("Error: %s was dropped: %s\n", describeChange(change), result.Error)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error: %v; %d change(s) stay queued\n", err, len(state.Queue))
+		return false
+	}
+	return true
+}
+
+// describeChange says what a queued change does, e.g. "toggle todo 3"
+func describeChange(change QueuedChange) string {
+	switch change.Op {
+	case "create":
+		return fmt.Sprintf("add %q", change.Todo.Item)
+	case "delete":
+		return fmt.Sprintf("remove todo %d", change.Id)
+	}
+	return fmt.Sprintf("%s todo %d", change.Op, change.Id)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"listy-api/models"
+	"listy-api/sdk"
+)
+
+func offlineState(t *testing.T) *OfflineState {
+	t.Helper()
+	t.Setenv("LISTY_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	parent := 1
+	return &OfflineState{Todos: []Todo{
+		{Id: 1, Version: 3, Item: "Plan trip", Tags: []string{"travel"}},
+		{Id: 2, Version: 1, Item: "Book flights", ParentId: &parent, Done: true},
+		{Id: 3, Version: 2, Item: "Buy milk", Priority: "high"},
+	}}
+}
+
+func TestOfflineApply(t *testing.T) {
+	state := offlineState(t)
+
+	created := state.apply(BatchOperation{Op: "create", Todo: &CreateTodoRequest{Item: "Pack bags", ParentId: intPtr(1)}})
+	if created.Status != http.StatusCreated || created.Id != -1 || created.Data.Version != 1 {
+		t.Fatalf("create = %+v, want local ID -1 at version 1", created)
+	}
+	if result := state.apply(BatchOperation{Op: "toggle", Id: 3, Version: 2}); !result.OK() || !result.Data.Done || result.Data.Version != 3 {
+		t.Errorf("toggle = %+v, want done at version 3", result)
+	}
+	if result := state.apply(BatchOperation{Op: "toggle", Id: 3, Version: 2}); result.Status != http.StatusPreconditionFailed {
+		t.Errorf("toggle with a stale version: status = %d, want 412", result.Status)
+	}
+	if result := state.apply(BatchOperation{Op: "restore", Id: 3}); result.Status != http.StatusServiceUnavailable {
+		t.Errorf("restore: status = %d, want 503", result.Status)
+	}
+
+	todo, err := state.get(1)
+	if err != nil || todo.Subtasks == nil || todo.Subtasks.Total != 2 || todo.Subtasks.Done != 1 {
+		t.Fatalf("get(1) = %+v, %v; want 1 of 2 subtasks done", todo, err)
+	}
+
+	// Removing a todo removes its subtasks, as moving it to the trash does
+	if result := state.apply(BatchOperation{Op: "delete", Id: 1}); !result.OK() {
+		t.Fatalf("delete = %+v", result)
+	}
+	if len(state.Todos) != 1 || state.Todos[0].Id != 3 {
+		t.Errorf("todos after delete = %v, want only todo 3", state.Todos)
+	}
+
+	// Queued with the version the API had, and saved
+	if len(state.Queue) != 3 {
+		t.Fatalf("queue has %d changes, want 3", len(state.Queue))
+	}
+	if change := state.Queue[1]; change.Op != "toggle" || change.Version != 2 {
+		t.Errorf("queued toggle = %+v, want version 2", change)
+	}
+	saved, err := LoadOffline()
+	if err != nil || len(saved.Queue) != 3 || saved.LastLocalId != -1 {
+		t.Errorf("LoadOffline() = %+v, %v; want the queue saved", saved, err)
+	}
+}
+
+func TestMatchesQuery(t *testing.T) {
+	state := offlineState(t)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"", []int{1, 2, 3}},
+		{"done=true", []int{2}},
+		{"q=MILK", []int{3}},
+		{"priority=high", []int{3}},
+		{"parent=none", []int{1, 3}},
+		{"parent=1", []int{2}},
+		{"tag=%23Travel", []int{1}},
+		{"tag=travel&tag=food", nil},
+		{"tag=travel&tag=food&tag_mode=any", []int{1}},
+		{"list=main", []int{1, 2, 3}},
+		{"list=abc", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			var got []int
+			for todo, err := range state.todos("/api/todos", query) {
+				if err != nil {
+					t.Fatalf("todos(%q) error = %v", tt.query, err)
+				}
+				got = append(got, todo.Id)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("todos(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	for todo, err := range state.todos("/api/todos", url.Values{"sort": {"item"}}) {
+		if err == nil {
+			t.Errorf("sorting offline returned %v, want an error", todo)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	state := offlineState(t)
+	state.User = "ada@example.com"
+	state.apply(BatchOperation{Op: "create", Todo: &CreateTodoRequest{Item: "Pack bags"}})
+	state.apply(BatchOperation{Op: "create", Todo: &CreateTodoRequest{Item: "Socks", ParentId: intPtr(-1)}})
+	state.apply(BatchOperation{Op: "toggle", Id: -2})
+	state.apply(BatchOperation{Op: "toggle", Id: 3})
+	state.apply(BatchOperation{Op: "update", Id: 3, Changes: &UpdateTodoRequest{Item: strPtr("Buy oat milk")}})
+
+	// The API has todo 3 at version 5, changed by someone else
+	var sent []BatchOperation
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Operations []BatchOperation `json:"operations"`
+		}
+		if r.URL.Path != "/api/todos/batch" || json.NewDecoder(r.Body).Decode(&body) != nil || len(body.Operations) != 1 {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		op := body.Operations[0]
+		sent = append(sent, op)
+		result := BatchResult{Op: op.Op, Id: op.Id, Status: http.StatusOK}
+		switch {
+		case op.Op == "create":
+			result.Id, result.Status = 100+len(sent), http.StatusCreated
+		case op.Id == 3 && op.Version != 5:
+			result.Status, result.Code, result.Error = http.StatusPreconditionFailed, "version_conflict", "version mismatch"
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "data": []BatchResult{result}})
+	}))
+	defer server.Close()
+
+	outcomes, err := NewAPIClient(server.URL, "").Sync(state)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	if len(outcomes) != 5 || len(state.Queue) != 0 {
+		t.Fatalf("Sync() = %d outcomes with %d changes left, want 5 and none", len(outcomes), len(state.Queue))
+	}
+
+	// The subtask is added under the parent's real ID and toggled by its own
+	if parent := sent[1].Todo.ParentId; parent == nil || *parent != 101 {
+		t.Errorf("subtask sent with parent %v, want 101", parent)
+	}
+	if sent[2].Id != 102 {
+		t.Errorf("toggle sent for todo %d, want 102", sent[2].Id)
+	}
+	// The update after the conflicting toggle is dropped without being sent
+	if len(sent) != 4 {
+		t.Errorf("sent %d operations, want 4", len(sent))
+	}
+	for i, want := range []int{http.StatusCreated, http.StatusCreated, http.StatusOK, http.StatusPreconditionFailed, http.StatusPreconditionFailed} {
+		if outcomes[i].Result.Status != want {
+			t.Errorf("outcome %d status = %d, want %d", i, outcomes[i].Result.Status, want)
+		}
+	}
+}
+
+func TestSync_Unreachable(t *testing.T) {
+	state := offlineState(t)
+	state.apply(BatchOperation{Op: "toggle", Id: 3})
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	_, err := NewAPIClient(server.URL, "").Sync(state)
+	if err == nil || !isUnreachable(err) {
+		t.Fatalf("Sync() error = %v, want the API to be unreachable", err)
+	}
+	if len(state.Queue) != 1 {
+		t.Errorf("queue has %d changes, want the change kept", len(state.Queue))
+	}
+}
+
+func TestSync_RequestFails(t *testing.T) {
+	for status, code := range map[int]string{http.StatusUnauthorized: models.CodeUnauthorized, http.StatusServiceUnavailable: models.CodeUnavailable} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			state := offlineState(t)
+			state.apply(BatchOperation{Op: "toggle", Id: 3})
+			state.apply(BatchOperation{Op: "toggle", Id: 1})
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+				json.NewEncoder(w).Encode(models.ErrorResponse{Error: models.Error{Code: code, Message: http.StatusText(status)}})
+			}))
+			defer server.Close()
+
+			outcomes, err := NewAPIClient(server.URL, "").Sync(state)
+			var apiErr *sdk.Error
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != status {
+				t.Fatalf("Sync() error = %v, want the %d response", err, status)
+			}
+			if len(outcomes) != 0 || len(state.Queue) != 2 {
+				t.Errorf("Sync() = %d outcomes with %d changes left, want none and both kept", len(outcomes), len(state.Queue))
+			}
+		})
+	}
+}
+
+func intPtr(n int) *int { return &n }
+
+func strPtr(s string) *string { return &s }
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	everyIntervalPattern = regexp.MustCompile(`^(\d+|other) (day|week|month|year)s?$`)
+	monthDayPattern      = regexp.MustCompile(`^(?:the )?(\d{1,2})(?:st|nd|rd|th)?(?: day)?$`)
+	nthWeekdayPattern    = regexp.MustCompile(`^(?:the )?(first|1st|second|2nd|third|3rd|fourth|4th|last) (\w+)$`)
+)
+
+var frequencies = map[string]string{
+	"day": "DAILY", "daily": "DAILY",
+	"week": "WEEKLY", "weekly": "WEEKLY",
+	"month": "MONTHLY", "monthly": "MONTHLY",
+	"year": "YEARLY", "yearly": "YEARLY", "annually": "YEARLY",
+}
+
+var ordinals = map[string]int{
+	"first": 1, "1st": 1, "second": 2, "2nd": 2, "third": 3, "3rd": 3, "fourth": 4, "4th": 4, "last": -1,
+}
+
+// dayCodes are the RRULE weekday names, indexed by time.Weekday
+var dayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// ParseEvery turns an --every shorthand into an iCalendar RRULE. Text that
+// already contains FREQ= is passed through for the API to check.
+//
+// Supported forms (case-insensitive, optionally starting with "every"):
+//
+//	day, week, month, year (or daily, weekly, monthly, yearly)
+//	weekday, weekend
+//	2 weeks, other month  - every second week or month
+//	mon, monday and thursday, mon,wed,fri
+//	week on mon,thu       - "on" narrows weeks to weekdays
+//	month on the 1st, month on the last day, month on the last fri
+func ParseEvery(input string) (string, error) {
+	if strings.Contains(strings.ToUpper(input), "FREQ=") {
+		return strings.TrimSpace(input), nil
+	}
+	text := strings.Join(strings.Fields(strings.ToLower(input)), " ")
+	text = strings.TrimPrefix(text, "every ")
+	period, on, _ := strings.Cut(text, " on ")
+
+	switch period {
+	case "weekday", "weekdays":
+		return "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", nil
+	case "weekend", "weekends":
+		return "FREQ=WEEKLY;BYDAY=SA,SU", nil
+	}
+	if days, err := parseWeekdayList(period); err == nil && on == "" {
+		return "FREQ=WEEKLY;BYDAY=" + days, nil
+	}
+	if _, err := parseMonthDay(period); err == nil && on == "" {
+		on, period = period, "month"
+	}
+
+	rule, ok := frequencies[period]
+	if ok {
+		rule = "FREQ=" + rule
+	} else if m := everyIntervalPattern.FindStringSubmatch(period); m != nil {
+		interval := 2
+		if m[1] != "other" {
+			interval, _ = strconv.Atoi(m[1])
+		}
+		if interval < 1 {
+			return "", fmt.Errorf("cannot repeat every %s", period)
+		}
+		rule = "FREQ=" + frequencies[m[2]]
+		if interval > 1 {
+			rule += ";INTERVAL=" + strconv.Itoa(interval)
+		}
+	} else {
+		return "", fmt.Errorf("cannot understand %q (try \"weekly on mon\", \"month on the 1st\" or an RRULE such as FREQ=WEEKLY;BYDAY=MO)", input)
+	}
+	if on == "" {
+		return rule, nil
+	}
+
+	switch {
+	case strings.HasPrefix(rule, "FREQ=WEEKLY"), strings.HasPrefix(rule, "FREQ=DAILY"):
+		days, err := parseWeekdayList(on)
+		if err != nil {
+			return "", err
+		}
+		return rule + ";BYDAY=" + days, nil
+	case strings.HasPrefix(rule, "FREQ=MONTHLY"):
+		if day, err := parseMonthDay(on); err == nil {
+			return rule + ";BYMONTHDAY=" + strconv.Itoa(day), nil
+		}
+		if m := nthWeekdayPattern.FindStringSubmatch(on); m != nil {
+			weekday, ok := weekdays[strings.TrimSuffix(m[2], "s")]
+			if !ok {
+				return "", fmt.Errorf("unknown weekday %q", m[2])
+			}
+			return rule + ";BYDAY=" + strconv.Itoa(ordinals[m[1]]) + dayCodes[weekday], nil
+		}
+		return "", fmt.Errorf("cannot understand %q (try \"the 1st\", \"the last day\" or \"the first mon\")", on)
+	default:
+		return "", fmt.Errorf("%q cannot be narrowed with \"on\"; use an RRULE such as FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=1", period)
+	}
+}
+
+// parseWeekdayList reads weekdays separated by commas or "and", e.g. "mon, wed and fri"
+func parseWeekdayList(text string) (string, error) {
+	var days []string
+	for _, name := range strings.FieldsFunc(strings.ReplaceAll(text, " and ", ","), func(r rune) bool { return r == ',' || r == ' ' }) {
+		weekday, ok := weekdays[name]
+		if !ok {
+			weekday, ok = weekdays[strings.TrimSuffix(name, "s")]
+		}
+		if !ok {
+			return "", fmt.Errorf("unknown weekday %q", name)
+		}
+		days = append(days, dayCodes[weekday])
+	}
+	if len(days) == 0 {
+		return "", fmt.Errorf("missing weekday")
+	}
+	return strings.Join(days, ","), nil
+}
+
+// parseMonthDay reads a day of the month such as "the 15th", or "the last day" as -1
+func parseMonthDay(text string) (int, error) {
+	if text == "the last day" || text == "last day" {
+		return -1, nil
+	}
+	m := monthDayPattern.FindStringSubmatch(text)
+	if m == nil {
+		return 0, fmt.Errorf("%q is not a day of the month", text)
+	}
+	day, _ := strconv.Atoi(m[1])
+	if day < 1 || day > 31 {
+		return 0, fmt.Errorf("%q is not a day of the month", text)
+	}
+	return day, nil
+}
+
+// withTimeZone prefixes a rule with a DTSTART in the local time zone, so
+// the API works out weekdays and dates the way the user sees them. The rule
+// is returned as is when the zone has no IANA name the API could load.
+func withTimeZone(rule string, start time.Time) string {
+	if strings.Contains(strings.ToUpper(rule), "DTSTART") {
+		return rule
+	}
+	zone := localZoneName()
+	if zone == "" {
+		return rule
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return rule
+	}
+	return "DTSTART;TZID=" + zone + ":" + start.In(loc).Format("20060102T150405") + "\nRRULE:" + strings.TrimPrefix(rule, "RRULE:")
+}
+
+// localZoneName returns the IANA name of the local time zone from $TZ or
+// the /etc/localtime link, or "" when it cannot be told
+func localZoneName() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+		return tz
+	}
+	target, err := filepath.EvalSymlinks("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	if _, zone, ok := strings.Cut(target, "zoneinfo/"); ok {
+		return zone
+	}
+	return ""
+}
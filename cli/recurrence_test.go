@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"listy-api/core"
+)
+
+func TestParseEvery(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"day", "FREQ=DAILY"},
+		{"every weekday", "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"},
+		{"weekly", "FREQ=WEEKLY"},
+		{"weekly on mon", "FREQ=WEEKLY;BYDAY=MO"},
+		{"Week on Monday and Thursday", "FREQ=WEEKLY;BYDAY=MO,TH"},
+		{"mon, wed, fri", "FREQ=WEEKLY;BYDAY=MO,WE,FR"},
+		{"sundays", "FREQ=WEEKLY;BYDAY=SU"},
+		{"2 weeks on tue", "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU"},
+		{"other month", "FREQ=MONTHLY;INTERVAL=2"},
+		{"3 days", "FREQ=DAILY;INTERVAL=3"},
+		{"month on the 1st", "FREQ=MONTHLY;BYMONTHDAY=1"},
+		{"the 15th", "FREQ=MONTHLY;BYMONTHDAY=15"},
+		{"monthly on the last day", "FREQ=MONTHLY;BYMONTHDAY=-1"},
+		{"month on the last fri", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"month on the second tuesday", "FREQ=MONTHLY;BYDAY=2TU"},
+		{"annually", "FREQ=YEARLY"},
+		{"FREQ=YEARLY;BYMONTH=3", "FREQ=YEARLY;BYMONTH=3"},
+	}
+	for _, tt := range tests {
+		got, err := ParseEvery(tt.input)
+		if err != nil {
+			t.Errorf("ParseEvery(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseEvery(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "fortnightly", "week on the 1st", "month on blursday", "year on mon", "0 days", "the 32nd"} {
+		if got, err := ParseEvery(input); err == nil {
+			t.Errorf("ParseEvery(%q) = %q, want an error", input, got)
+		}
+	}
+}
+
+func TestWithTimeZone(t *testing.T) {
+	start := time.Date(2026, time.March, 2, 8, 0, 0, 0, time.UTC)
+
+	t.Setenv("TZ", "Europe/Berlin")
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	want := "DTSTART;TZID=Europe/Berlin:20260302T090000\nRRULE:FREQ=WEEKLY;BYDAY=MO"
+	if got := withTimeZone("FREQ=WEEKLY;BYDAY=MO", start); got != want {
+		t.Errorf("withTimeZone() = %q, want %q", got, want)
+	}
+	if got := core.DescribeRecurrence(want); got != "FREQ=WEEKLY;BYDAY=MO" {
+		t.Errorf("DescribeRecurrence() = %q, want the rule without DTSTART", got)
+	}
+
+	t.Setenv("TZ", "Nowhere/Special")
+	if got := withTimeZone("FREQ=DAILY", start); got != "FREQ=DAILY" {
+		t.Errorf("withTimeZone(unknown zone) = %q, want the rule unchanged", got)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ExtractTags takes the #tags out of a todo's text, so "Buy milk #errands
+// #Urgent" becomes "Buy milk" tagged errands and urgent. Only words made of
+// letters, digits, - and _ with at least one letter count, so "issue #42"
+// keeps its number.
+func ExtractTags(text string) (string, []string) {
+	var words, tags []string
+	for _, word := range strings.Fields(text) {
+		if tag, ok := inlineTag(word); ok {
+			tags = append(tags, tag)
+		} else {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " "), tags
+}
+
+// inlineTag returns the lower-case tag a #word names
+func inlineTag(word string) (string, bool) {
+	name, ok := strings.CutPrefix(word, "#")
+	if !ok || name == "" {
+		return "", false
+	}
+	hasLetter := false
+	for _, r := range name {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r), r == '-', r == '_':
+		default:
+			return "", false
+		}
+	}
+	return strings.ToLower(name), hasLetter
+}
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractTags(t *testing.T) {
+	tests := []struct {
+		in       string
+		wantItem string
+		wantTags []string
+	}{
+		{"Buy milk", "Buy milk", nil},
+		{"Buy milk #errands #Urgent", "Buy milk", []string{"errands", "urgent"}},
+		{"#home  Fix the  sink", "Fix the sink", []string{"home"}},
+		{"Close issue #42", "Close issue #42", nil},
+		{"Plan #q3-launch", "Plan", []string{"q3-launch"}},
+		{"Reply to #", "Reply to #", nil},
+		{"Read C# in depth", "Read C# in depth", nil},
+		{"Email #bob's team", "Email #bob's team", nil},
+	}
+	for _, tt := range tests {
+		item, tags := ExtractTags(tt.in)
+		if item != tt.wantItem || !slices.Equal(tags, tt.wantTags) {
+			t.Errorf("ExtractTags(%q) = %q, %v, want %q, %v", tt.in, item, tags, tt.wantItem, tt.wantTags)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Undo is how to reverse the last command that changed todos or lists,
+// kept next to the config file so "listy undo" works in a later run
+type Undo struct {
+	Command string           `json:"command"`         // The command being undone, e.g. "remove 3"
+	Todos   []BatchOperation `json:"todos,omitempty"` // Sent as one batch
+	List    *ListUndo        `json:"list,omitempty"`
+}
+
+// ListUndo reverses a change to a list: deleting one that was created, or
+// applying Changes that put back what was changed
+type ListUndo struct {
+	Id      string             `json:"id"`
+	Delete  bool               `json:"delete,omitempty"`
+	Changes *UpdateListRequest `json:"changes,omitempty"`
+}
+
+// UndoPath returns undo.json in the same directory as the config file
+func UndoPath() (string, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(path), "undo.json"), nil
+}
+
+// LoadUndo reads what the last command saved; nil means there is nothing to undo
+func LoadUndo() (*Undo, error) {
+	path, err := UndoPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	var undo Undo
+	if err := json.Unmarshal(data, &undo); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &undo, nil
+}
+
+// SaveUndo replaces the saved undo; nil forgets it
+func SaveUndo(undo *Undo) error {
+	path, err := UndoPath()
+	if err != nil {
+		return err
+	}
+	if undo == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %v", path, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	data, err := json.MarshalIndent(undo, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode undo: %v", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// recordUndo saves how to reverse the command being run. A command with
+// nothing to reverse still replaces the saved undo, so "listy undo" never
+// skips back past it.
+func recordUndo(todos []BatchOperation, list *ListUndo) {
+	undo := &Undo{Command: commandLine(), Todos: todos, List: list}
+	if err := SaveUndo(undo); err != nil {
+		fmt.Printf("Warning: %v; this command cannot be undone\n", err)
+	}
+}
+
+// handleUndo reverses the last command that changed todos or lists. Todos
+// changed by someone else since are left alone.
+func handleUndo(client *APIClient) {
+	undo, err := LoadUndo()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if undo == nil {
+		fmt.Println("Nothing to undo")
+		return
+	}
+	if len(undo.Todos) == 0 && undo.List == nil {
+		fmt.Printf("\"listy %s\" cannot be undone\n", undo.Command)
+		return
+	}
+
+	if list := undo.List; list != nil {
+		if list.Delete {
+			err = client.DeleteList(list.Id, false)
+		} else {
+			_, err = client.UpdateList(list.Id, *list.Changes)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+	if len(undo.Todos) > 0 {
+		results, err := client.BatchTodos(undo.Todos)
+		for _, result := range results {
+			if conflicted(result) {
+				fmt.Printf("Left todo %d alone: it was changed by someone else in the meantime\n", result.Id)
+			} else if !result.OK() {
+				fmt.Printf("Error: todo %d: %s\n", result.Id, result.Error)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+	}
+
+	if err := SaveUndo(nil); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	fmt.Printf("Undid \"listy %s\"\n", undo.Command)
+}
@@ -2,13 +2,19 @@ module listy
 
 go 1.25.5
 
+require (
+	github.com/joho/godotenv v1.5.1
+	github.com/supabase-community/supabase-go v0.0.4
+	listy-api v0.0.0-00010101000000-000000000000
+)
+
 require (
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/supabase-community/functions-go v0.0.0-20220927045802-22373e6cb51d // indirect
 	github.com/supabase-community/gotrue-go v1.2.0 // indirect
 	github.com/supabase-community/postgrest-go v0.0.11 // indirect
 	github.com/supabase-community/storage-go v0.7.0 // indirect
-	github.com/supabase-community/supabase-go v0.0.4 // indirect
 	github.com/tomnomnom/linkheader v0.0.0-20180905144013-02ca5825eb80 // indirect
 )
+
+replace listy-api => ./api
@@ -1,7 +1,7 @@
 'use client';
 
 import { useState, useEffect } from 'react';
-import { Todo, getTodos, getPendingTodos, getCompletedTodos, getTodosByList, createTodo, updateTodo, deleteTodo, toggleTodo } from '@/lib/api';
+import { Todo, getTodos, getPendingTodos, getCompletedTodos, getTodosByList, createTodo, updateTodo, deleteTodo, restoreTodo, toggleTodo, ConflictError } from '@/lib/api';
 import AddTodoForm from '@/components/AddTodoForm';
 import TodoList from '@/components/TodoList';
 import AITaskGenerator from '@/components/AITaskGenerator';
@@ -14,6 +14,7 @@ export default function Home() {
   const [filter, setFilter] = useState<Filter>('all');
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
+  const [deleted, setDeleted] = useState<Todo | null>(null); // Last todo moved to the trash, offered for undo
   const [selectedListId, setSelectedListId] = useState<string | null>(null);
   const [listsChanged, setListsChanged] = useState(0); // Counter to trigger refresh
 
@@ -77,30 +78,55 @@ export default function Home() {
     setListsChanged(prev => prev + 1);
   };
 
+  // Changes apply to the version on screen; if someone else changed the todo
+  // first, show the latest version instead of overwriting it
+  const versionOf = (id: number) => todos.find(todo => todo.id === id)?.version;
+  const handleChangeError = async (err: unknown, fallback: string) => {
+    if (err instanceof ConflictError) {
+      await fetchTodos();
+      setError('That todo was changed by someone else, showing the latest version. Please try again.');
+      return;
+    }
+    setError(err instanceof Error ? err.message : fallback);
+  };
+
   const handleToggle = async (id: number) => {
     try {
-      await toggleTodo(id);
+      await toggleTodo(id, versionOf(id));
       await fetchTodos(); // Refresh list
     } catch (err) {
-      setError(err instanceof Error ? err.message : 'Failed to toggle todo');
+      await handleChangeError(err, 'Failed to toggle todo');
     }
   };
 
   const handleDelete = async (id: number) => {
     try {
-      await deleteTodo(id);
+      await deleteTodo(id, versionOf(id));
+      setDeleted(todos.find(todo => todo.id === id) ?? null);
+      await fetchTodos(); // Refresh list
+    } catch (err) {
+      await handleChangeError(err, 'Failed to delete todo');
+    }
+  };
+
+  const handleUndoDelete = async () => {
+    if (!deleted) return;
+    try {
+      await restoreTodo(deleted.id);
+      setDeleted(null);
       await fetchTodos(); // Refresh list
     } catch (err) {
-      setError(err instanceof Error ? err.message : 'Failed to delete todo');
+      setDeleted(null);
+      setError(err instanceof Error ? err.message : 'Failed to restore todo');
     }
   };
 
   const handleUpdate = async (id: number, item: string) => {
     try {
-      await updateTodo(id, { item });
+      await updateTodo(id, { item }, versionOf(id));
       await fetchTodos(); // Refresh list
     } catch (err) {
-      setError(err instanceof Error ? err.message : 'Failed to update todo');
+      await handleChangeError(err, 'Failed to update todo');
     }
   };
 
@@ -215,6 +241,29 @@ export default function Home() {
                 </div>
               )}
 
+              {/* Undo Delete */}
+              {deleted && (
+                <div className="mb-6 p-4 bg-gray-50 border-l-4 border-gray-400 rounded-lg flex items-center justify-between">
+                  <span className="text-gray-700 font-medium">&ldquo;{deleted.item}&rdquo; moved to the trash</span>
+                  <div className="flex items-center gap-3">
+                    <button
+                      onClick={handleUndoDelete}
+                      className="text-blue-600 hover:text-blue-800 font-semibold"
+                    >
+                      Undo
+                    </button>
+                    <button
+                      onClick={() => setDeleted(null)}
+                      className="text-gray-500 hover:text-gray-700 hover:bg-gray-100 rounded-full p-1 transition-colors"
+                    >
+                      <svg className="w-5 h-5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                        <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M6 18L18 6M6 6l12 12" />
+                      </svg>
+                    </button>
+                  </div>
+                </div>
+              )}
+
               {/* Loading State */}
               {loading ? (
                 <div className="text-center py-16">
@@ -1,7 +1,7 @@
 'use client';
 
 import { useState, useEffect } from 'react';
-import { getAllLists, getTodosByList } from '@/lib/api';
+import { getAllLists, TodoList } from '@/lib/api';
 
 interface ListsSidebarProps {
   selectedListId: string | null;
@@ -10,29 +10,17 @@ interface ListsSidebarProps {
 }
 
 export default function ListsSidebar({ selectedListId, onSelectList, onListsChanged }: ListsSidebarProps) {
-  const [lists, setLists] = useState<string[]>([]);
+  const [lists, setLists] = useState<TodoList[]>([]);
   const [loading, setLoading] = useState(true);
-  const [listCounts, setListCounts] = useState<Record<string, number>>({});
 
   const fetchLists = async (showLoading = false) => {
     try {
       if (showLoading) {
         setLoading(true);
       }
+      // Lists come with their pending/done counts
       const fetchedLists = await getAllLists();
       setLists(fetchedLists);
-
-      // Fetch counts for each list
-      const counts: Record<string, number> = {};
-      for (const listId of fetchedLists) {
-        try {
-          const todos = await getTodosByList(listId);
-          counts[listId] = todos.length;
-        } catch (err) {
-          counts[listId] = 0;
-        }
-      }
-      setListCounts(counts);
     } catch (err) {
       console.error('Error fetching lists:', err);
       setLists([]);
@@ -55,14 +43,6 @@ export default function ListsSidebar({ selectedListId, onSelectList, onListsChan
     }
   }, [onListsChanged]);
 
-  const getListDisplayName = (listId: string) => {
-    // Capitalize first letter and replace underscores with spaces
-    return listId
-      .split('_')
-      .map((word) => word.charAt(0).toUpperCase() + word.slice(1))
-      .join(' ');
-  };
-
   return (
     <div className="w-64 bg-white border-r border-gray-200 h-full overflow-y-auto">
       <div className="p-4 border-b border-gray-200">
@@ -106,32 +86,32 @@ export default function ListsSidebar({ selectedListId, onSelectList, onListsChan
           </div>
         ) : (
           <div className="space-y-1">
-            {lists.map((listId) => (
+            {lists.map((list) => (
               <button
-                key={listId}
-                onClick={() => onSelectList(listId)}
+                key={list.id}
+                onClick={() => onSelectList(list.id)}
                 className={`w-full text-left px-4 py-3 rounded-lg transition-all ${
-                  selectedListId === listId
+                  selectedListId === list.id
                     ? 'bg-gradient-to-r from-purple-500 to-indigo-600 text-white shadow-md'
                     : 'hover:bg-gray-100 text-gray-700'
                 }`}
               >
                 <div className="flex items-center justify-between">
                   <div className="flex items-center gap-2 flex-1 min-w-0">
-                    <svg className="w-4 h-4 flex-shrink-0" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                    <svg className="w-4 h-4 flex-shrink-0" style={list.color ? { color: list.color } : undefined} fill="none" stroke="currentColor" viewBox="0 0 24 24">
                       <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M7 7h.01M7 3h5c.512 0 1.024.195 1.414.586l7 7a2 2 0 010 2.828l-7 7a2 2 0 01-2.828 0l-7-7A1.994 1.994 0 013 12V7a4 4 0 014-4z" />
                     </svg>
-                    <span className="font-medium truncate">{getListDisplayName(listId)}</span>
+                    <span className="font-medium truncate">{list.name}</span>
                   </div>
-                  {listCounts[listId] !== undefined && (
+                  {list.pending_count > 0 && (
                     <span
                       className={`ml-2 px-2 py-0.5 rounded-full text-xs font-semibold flex-shrink-0 ${
-                        selectedListId === listId
+                        selectedListId === list.id
                           ? 'bg-white/20 text-white'
                           : 'bg-gray-200 text-gray-600'
                       }`}
                     >
-                      {listCounts[listId]}
+                      {list.pending_count}
                     </span>
                   )}
                 </div>
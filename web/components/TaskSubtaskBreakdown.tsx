@@ -58,8 +58,8 @@ export default function TaskSubtaskBreakdown({ task, onClose, onTasksCreated }:
         category: '',
       }));
 
-      // Create subtasks in the same list as the parent task
-      await createAITasks(aiTasks, task.list_id || null);
+      // Create the subtasks under the parent task, in the same list
+      await createAITasks(aiTasks, task.list_id || null, task.id);
       onTasksCreated(); // This will trigger a refresh in the parent
       onClose();
     } catch (err) {
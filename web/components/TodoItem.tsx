@@ -76,6 +76,12 @@ export default function TodoItem({ todo, onToggle, onDelete, onUpdate, showAIBre
         </span>
       )}
 
+      {todo.subtasks && (
+        <span className="px-2 py-0.5 rounded-full text-xs font-semibold bg-gray-100 text-gray-600" title="Subtasks done">
+          {todo.subtasks.done}/{todo.subtasks.total}
+        </span>
+      )}
+
       <div className="flex gap-2 opacity-0 group-hover:opacity-100 transition-opacity">
         {showAIBreakdown && (
           <button
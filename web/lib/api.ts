@@ -12,17 +12,186 @@ const getApiUrl = () => {
 
 const API_BASE_URL = getApiUrl();
 
+const TOKEN_KEY = 'listy_token';
+
+// The bearer token sent with every request: from login()/register(), or a
+// Supabase Auth session's access_token passed to setAuthToken()
+export function getAuthToken(): string | null {
+  return typeof window === 'undefined' ? null : window.localStorage.getItem(TOKEN_KEY);
+}
+
+export function setAuthToken(token: string | null) {
+  if (token) {
+    window.localStorage.setItem(TOKEN_KEY, token);
+  } else {
+    window.localStorage.removeItem(TOKEN_KEY);
+  }
+}
+
+// The body of every error response: code names the kind of error, e.g.
+// 'not_found', 'validation' or 'version_conflict', for switching on
+export interface ApiErrorBody {
+  code: string;
+  message: string;
+  details?: string[];
+}
+
+// Thrown for error responses, with the response's status, code and details
+export class ApiError extends Error {
+  status: number;
+  code: string;
+  details: string[];
+
+  constructor(message: string, status: number, code: string, details: string[] = []) {
+    super(message);
+    this.status = status;
+    this.code = code;
+    this.details = details;
+  }
+}
+
+// Thrown when a change made against a todo version fails because someone else changed it first
+export class ConflictError extends ApiError {}
+
+// ifMatch builds the If-Match header making a change conditional on a todo version
+function ifMatch(version?: number): HeadersInit {
+  return version ? { 'If-Match': `"${version}"` } : {};
+}
+
+// apiError turns an error response into an ApiError, or a ConflictError for
+// version conflicts; fallback is the message when the body has none
+async function apiError(response: Response, fallback: string): Promise<ApiError> {
+  const body: { error?: Partial<ApiErrorBody> } = await response.json().catch(() => ({}));
+  const { code = '', message = fallback, details = [] } = body.error ?? {};
+  if (code === 'version_conflict') {
+    return new ConflictError(message, response.status, code, details);
+  }
+  return new ApiError(message, response.status, code, details);
+}
+
+// fetch with the Authorization header added when a token is stored
+async function authFetch(url: string, init: RequestInit = {}): Promise<Response> {
+  const token = getAuthToken();
+  if (!token) {
+    return fetch(url, init);
+  }
+  const headers = new Headers(init.headers);
+  headers.set('Authorization', `Bearer ${token}`);
+  return fetch(url, { ...init, headers });
+}
+
 export interface Todo {
   id: number;
+  version: number; // goes up with every change; pass it back to only change this version
   item: string;
   done: boolean;
   list_id?: string | null; // null means main list
+  owner_id?: string; // user the todo belongs to; absent when auth is disabled
+  parent_id?: number | null; // set on subtasks
+  auto_complete?: boolean; // parent is marked done once every subtask is done
+  deleted_at?: string; // set while the todo is in the trash
+  recurrence?: string; // iCalendar RRULE; completing the todo adds its next occurrence
+  tags?: string[]; // lower case without the #, sorted
+  subtasks?: { done: number; total: number }; // roll-up of direct subtasks
+  children?: Todo[]; // only with ?include=children
+  next?: Todo; // the occurrence added by completing a recurring todo
+}
+
+export interface TodoList {
+  id: string; // Matches Todo.list_id
+  name: string;
+  color?: string;
+  position: number;
+  archived: boolean;
+  created_at: string;
+  owner_id?: string;
+  role?: ListRole; // The signed-in user's role in the list
+  pending_count: number;
+  done_count: number;
+}
+
+// A tag with how many of the caller's todos carry it
+export interface Tag {
+  name: string;
+  pending_count: number;
+  done_count: number;
+}
+
+// Returned when a calendar token is issued; url is the feed of every todo
+export interface CalendarSubscription {
+  token: string;
+  url: string;
+  created_at: string;
+}
+
+export type TransferFormat = 'json' | 'csv' | 'markdown' | 'todotxt';
+
+// What POST /api/import added; with dry_run, the todos that would be added, without IDs
+export interface ImportResult {
+  format: TransferFormat;
+  dry_run: boolean;
+  created: number;
+  skipped: number; // Duplicates left out with dedupe
+  todos: Todo[];
+}
+
+export type ListRole = 'owner' | 'editor' | 'viewer';
+
+export interface ListMember {
+  list_id: string;
+  user_id: string;
+  email?: string;
+  role: Exclude<ListRole, 'owner'>;
+  created_at: string;
+}
+
+export type TodoEventType = 'created' | 'updated' | 'toggled' | 'deleted' | 'restored' | 'reset';
+
+// A change pushed by GET /api/todos/stream; reset events carry no todo and
+// mean changes were missed, so todos should be re-fetched
+export interface TodoEvent {
+  id: string;
+  type: TodoEventType;
+  todo?: Todo;
+  at: string;
+}
+
+// A recorded change to a todo; changes maps field names to their values
+// before and after, null when unset
+export interface Activity {
+  id: number;
+  todo_id: number;
+  actor_id?: string; // absent when auth is disabled
+  action: Exclude<TodoEventType, 'reset'>;
+  changes?: Record<string, { before: unknown; after: unknown }>;
+  at: string;
+}
+
+// One change in POST /api/todos/batch; version makes it conditional like If-Match
+export type BatchOperation =
+  | { op: 'create'; todo: { item: string; list_id?: string | null; parent_id?: number | null } }
+  | { op: 'update'; id: number; version?: number; changes: { item?: string; done?: boolean } }
+  | { op: 'toggle' | 'delete' | 'restore'; id: number; version?: number };
+
+// The outcome of one batch operation, with the status it would have had as its own request
+export interface BatchResult {
+  op: BatchOperation['op'];
+  id?: number;
+  status: number;
+  data?: Todo;
+  code?: string; // As in ApiErrorBody, plus 'not_applied' for operations skipped in a failed atomic batch
+  error?: string;
+}
+
+export interface AuthResponse {
+  token: string;
+  expires_at: string;
+  user: { id: string; email: string; created_at: string };
 }
 
 export interface ApiResponse<T> {
   success: boolean;
   data: T;
-  error?: string;
   message?: string;
 }
 
@@ -43,46 +212,46 @@ export interface AITaskBreakdownResponse {
 
 // Get all todos
 export async function getTodos(): Promise<Todo[]> {
-  const response = await fetch(`${API_BASE_URL}/api/todos`);
+  const response = await authFetch(`${API_BASE_URL}/api/todos`);
   if (!response.ok) {
-    throw new Error('Failed to fetch todos');
+    throw await apiError(response, 'Failed to fetch todos');
   }
   const result: ApiResponse<Todo[]> = await response.json();
   if (!result.success) {
-    throw new Error(result.error || 'Failed to fetch todos');
+    throw new Error('Failed to fetch todos');
   }
   return result.data;
 }
 
 // Get pending todos
 export async function getPendingTodos(): Promise<Todo[]> {
-  const response = await fetch(`${API_BASE_URL}/api/todos/pending`);
+  const response = await authFetch(`${API_BASE_URL}/api/todos/pending`);
   if (!response.ok) {
-    throw new Error('Failed to fetch pending todos');
+    throw await apiError(response, 'Failed to fetch pending todos');
   }
   const result: ApiResponse<Todo[]> = await response.json();
   if (!result.success) {
-    throw new Error(result.error || 'Failed to fetch pending todos');
+    throw new Error('Failed to fetch pending todos');
   }
   return result.data;
 }
 
 // Get completed todos
 export async function getCompletedTodos(): Promise<Todo[]> {
-  const response = await fetch(`${API_BASE_URL}/api/todos/completed`);
+  const response = await authFetch(`${API_BASE_URL}/api/todos/completed`);
   if (!response.ok) {
-    throw new Error('Failed to fetch completed todos');
+    throw await apiError(response, 'Failed to fetch completed todos');
   }
   const result: ApiResponse<Todo[]> = await response.json();
   if (!result.success) {
-    throw new Error(result.error || 'Failed to fetch completed todos');
+    throw new Error('Failed to fetch completed todos');
   }
   return result.data;
 }
 
 // Create a new todo
 export async function createTodo(item: string, listId?: string | null): Promise<Todo> {
-  const response = await fetch(`${API_BASE_URL}/api/todos`, {
+  const response = await authFetch(`${API_BASE_URL}/api/todos`, {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
@@ -90,66 +259,222 @@ export async function createTodo(item: string, listId?: string | null): Promise<
     body: JSON.stringify({ item, list_id: listId || null }),
   });
   if (!response.ok) {
-    const error = await response.json();
-    throw new Error(error.error || 'Failed to create todo');
+    throw await apiError(response, 'Failed to create todo');
   }
   const result: ApiResponse<Todo> = await response.json();
   if (!result.success) {
-    throw new Error(result.error || 'Failed to create todo');
+    throw new Error('Failed to create todo');
   }
   return result.data;
 }
 
 // Update a todo
-export async function updateTodo(id: number, updates: { item?: string; done?: boolean }): Promise<Todo> {
-  const response = await fetch(`${API_BASE_URL}/api/todos/${id}`, {
+// Update a todo; with a version, fails with a ConflictError if the todo has changed since
+export async function updateTodo(id: number, updates: { item?: string; done?: boolean }, version?: number): Promise<Todo> {
+  const response = await authFetch(`${API_BASE_URL}/api/todos/${id}`, {
     method: 'PUT',
     headers: {
       'Content-Type': 'application/json',
+      ...ifMatch(version),
     },
     body: JSON.stringify(updates),
   });
   if (!response.ok) {
-    const error = await response.json();
-    throw new Error(error.error || 'Failed to update todo');
+    throw await apiError(response, 'Failed to update todo');
   }
   const result: ApiResponse<Todo> = await response.json();
   if (!result.success) {
-    throw new Error(result.error || 'Failed to update todo');
+    throw new Error('Failed to update todo');
   }
   return result.data;
 }
 
-// Delete a todo
-export async function deleteTodo(id: number): Promise<void> {
-  const response = await fetch(`${API_BASE_URL}/api/todos/${id}`, {
+// Delete a todo; it moves to the trash, from which restoreTodo brings it back
+export async function deleteTodo(id: number, version?: number): Promise<void> {
+  const response = await authFetch(`${API_BASE_URL}/api/todos/${id}`, {
     method: 'DELETE',
+    headers: ifMatch(version),
+  });
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to delete todo');
+  }
+}
+
+// Get deleted todos, most recently deleted first
+export async function getTrash(): Promise<Todo[]> {
+  const response = await authFetch(`${API_BASE_URL}/api/trash`);
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to fetch the trash');
+  }
+  const result: ApiResponse<Todo[]> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to fetch the trash');
+  }
+  return result.data;
+}
+
+// Take a todo, and the subtasks deleted with it, out of the trash
+export async function restoreTodo(id: number, version?: number): Promise<Todo> {
+  const response = await authFetch(`${API_BASE_URL}/api/todos/${id}/restore`, {
+    method: 'POST',
+    headers: ifMatch(version),
   });
   if (!response.ok) {
-    const error = await response.json();
-    throw new Error(error.error || 'Failed to delete todo');
+    throw await apiError(response, 'Failed to restore todo');
   }
+  const result: ApiResponse<Todo> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to restore todo');
+  }
+  return result.data;
+}
+
+// Get every recorded change to a todo, oldest first
+export async function getTodoHistory(id: number): Promise<Activity[]> {
+  const response = await authFetch(`${API_BASE_URL}/api/todos/${id}/history`);
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to fetch the history');
+  }
+  const result: ApiResponse<Activity[]> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to fetch the history');
+  }
+  return result.data;
+}
+
+// Get the changes to the caller's todos, oldest first, optionally only those after since
+export async function getActivity(since?: string): Promise<Activity[]> {
+  const query = since ? `?since=${encodeURIComponent(since)}` : '';
+  const response = await authFetch(`${API_BASE_URL}/api/activity${query}`);
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to fetch activity');
+  }
+  const result: ApiResponse<Activity[]> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to fetch activity');
+  }
+  return result.data;
+}
+
+// Get every tag on the caller's todos with their counts
+export async function getTags(): Promise<Tag[]> {
+  const response = await authFetch(`${API_BASE_URL}/api/tags`);
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to fetch tags');
+  }
+  const result: ApiResponse<Tag[]> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to fetch tags');
+  }
+  return result.data;
+}
+
+// Get the todos with every one of tags, or any of them when any is set
+export async function getTodosByTags(tags: string[], any = false): Promise<Todo[]> {
+  const query = new URLSearchParams(tags.map((tag) => ['tag', tag]));
+  if (any) {
+    query.set('tag_mode', 'any');
+  }
+  const response = await authFetch(`${API_BASE_URL}/api/todos?${query}`);
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to fetch todos');
+  }
+  const result: ApiResponse<Todo[]> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to fetch todos');
+  }
+  return result.data;
+}
+
+// Add tags to a todo, or remove them when remove is set
+export async function tagTodo(id: number, tags: string[], remove = false, version?: number): Promise<Todo> {
+  const response = await authFetch(`${API_BASE_URL}/api/todos/${id}/tags`, {
+    method: remove ? 'DELETE' : 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+      ...ifMatch(version),
+    },
+    body: JSON.stringify({ tags }),
+  });
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to change tags');
+  }
+  const result: ApiResponse<Todo> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to change tags');
+  }
+  return result.data;
+}
+
+// Issue a calendar token, replacing the previous one, for subscribing calendar apps to the todos
+export async function issueCalendarToken(): Promise<CalendarSubscription> {
+  const response = await authFetch(`${API_BASE_URL}/api/calendar/token`, { method: 'POST' });
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to issue a calendar token');
+  }
+  const result: ApiResponse<CalendarSubscription> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to issue a calendar token');
+  }
+  return result.data;
+}
+
+// The calendar feed of one list for a token from issueCalendarToken
+export function listCalendarUrl(listId: string, token: string): string {
+  return `${API_BASE_URL}/api/lists/${encodeURIComponent(listId)}/calendar.ics?token=${encodeURIComponent(token)}`;
+}
+
+// Download the todos, or one list's ("main" for the main list), as a file
+export async function exportTodos(format: TransferFormat, listId?: string): Promise<Blob> {
+  const params = new URLSearchParams({ format });
+  if (listId) params.set('list', listId);
+  const response = await authFetch(`${API_BASE_URL}/api/export?${params}`);
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to export todos');
+  }
+  return response.blob();
+}
+
+// Add the todos in a JSON, CSV, Markdown checklist or todo.txt file; the format is detected unless given
+export async function importTodos(
+  file: Blob | string,
+  options: { format?: TransferFormat; listId?: string; dryRun?: boolean; dedupe?: boolean } = {}
+): Promise<ImportResult> {
+  const params = new URLSearchParams({ tz: Intl.DateTimeFormat().resolvedOptions().timeZone });
+  if (options.format) params.set('format', options.format);
+  if (options.listId) params.set('list', options.listId);
+  if (options.dryRun) params.set('dry_run', 'true');
+  if (options.dedupe) params.set('dedupe', 'true');
+  const response = await authFetch(`${API_BASE_URL}/api/import?${params}`, { method: 'POST', body: file });
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to import todos');
+  }
+  const result: ApiResponse<ImportResult> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to import todos');
+  }
+  return result.data;
 }
 
 // Toggle todo status
-export async function toggleTodo(id: number): Promise<Todo> {
-  const response = await fetch(`${API_BASE_URL}/api/todos/${id}/toggle`, {
+export async function toggleTodo(id: number, version?: number): Promise<Todo> {
+  const response = await authFetch(`${API_BASE_URL}/api/todos/${id}/toggle`, {
     method: 'PATCH',
+    headers: ifMatch(version),
   });
   if (!response.ok) {
-    const error = await response.json();
-    throw new Error(error.error || 'Failed to toggle todo');
+    throw await apiError(response, 'Failed to toggle todo');
   }
   const result: ApiResponse<Todo> = await response.json();
   if (!result.success) {
-    throw new Error(result.error || 'Failed to toggle todo');
+    throw new Error('Failed to toggle todo');
   }
   return result.data;
 }
 
 // AI: Generate task breakdown (for main list)
 export async function generateTaskBreakdown(goal: string): Promise<AITaskBreakdownResponse> {
-  const response = await fetch(`${API_BASE_URL}/api/todos/ai/breakdown`, {
+  const response = await authFetch(`${API_BASE_URL}/api/todos/ai/breakdown`, {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
@@ -157,8 +482,7 @@ export async function generateTaskBreakdown(goal: string): Promise<AITaskBreakdo
     body: JSON.stringify({ goal }),
   });
   if (!response.ok) {
-    const error = await response.json();
-    throw new Error(error.error || 'Failed to generate task breakdown');
+    throw await apiError(response, 'Failed to generate task breakdown');
   }
   const result: AITaskBreakdownResponse = await response.json();
   if (!result.success) {
@@ -169,7 +493,7 @@ export async function generateTaskBreakdown(goal: string): Promise<AITaskBreakdo
 
 // AI: Generate subtask breakdown (for individual tasks - smart breakdown)
 export async function generateSubtaskBreakdown(task: string): Promise<AITaskBreakdownResponse> {
-  const response = await fetch(`${API_BASE_URL}/api/todos/ai/subtasks`, {
+  const response = await authFetch(`${API_BASE_URL}/api/todos/ai/subtasks`, {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
@@ -177,15 +501,7 @@ export async function generateSubtaskBreakdown(task: string): Promise<AITaskBrea
     body: JSON.stringify({ goal: task }),
   });
   if (!response.ok) {
-    let errorMessage = 'Failed to generate subtask breakdown';
-    try {
-      const error = await response.json();
-      errorMessage = error.error || errorMessage;
-    } catch (e) {
-      // If response is not JSON (e.g., 404 HTML page), use status text
-      errorMessage = `Server error: ${response.status} ${response.statusText}`;
-    }
-    throw new Error(errorMessage);
+    throw await apiError(response, `Server error: ${response.status} ${response.statusText}`);
   }
   const result: AITaskBreakdownResponse = await response.json();
   if (!result.success) {
@@ -195,21 +511,20 @@ export async function generateSubtaskBreakdown(task: string): Promise<AITaskBrea
 }
 
 // AI: Create multiple todos from AI tasks
-export async function createAITasks(tasks: AITask[], listId?: string | null): Promise<Todo[]> {
-  const response = await fetch(`${API_BASE_URL}/api/todos/ai/create`, {
+export async function createAITasks(tasks: AITask[], listId?: string | null, parentId?: number | null): Promise<Todo[]> {
+  const response = await authFetch(`${API_BASE_URL}/api/todos/ai/create`, {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
     },
-    body: JSON.stringify({ tasks, list_id: listId || null }),
+    body: JSON.stringify({ tasks, list_id: listId || null, parent_id: parentId || null }),
   });
   if (!response.ok) {
-    const error = await response.json();
-    throw new Error(error.error || 'Failed to create AI tasks');
+    throw await apiError(response, 'Failed to create AI tasks');
   }
   const result: ApiResponse<Todo[]> = await response.json();
   if (!result.success) {
-    throw new Error(result.error || 'Failed to create AI tasks');
+    throw new Error('Failed to create AI tasks');
   }
   return result.data;
 }
@@ -217,27 +532,197 @@ export async function createAITasks(tasks: AITask[], listId?: string | null): Pr
 // Get todos by list ID (null or "main" for main list)
 export async function getTodosByList(listId: string | null): Promise<Todo[]> {
   const listParam = listId === null || listId === 'main' ? 'main' : listId;
-  const response = await fetch(`${API_BASE_URL}/api/todos/list/${listParam}`);
+  const response = await authFetch(`${API_BASE_URL}/api/todos/list/${listParam}`);
   if (!response.ok) {
-    throw new Error('Failed to fetch todos for list');
+    throw await apiError(response, 'Failed to fetch todos for list');
   }
   const result: ApiResponse<Todo[]> = await response.json();
   if (!result.success) {
-    throw new Error(result.error || 'Failed to fetch todos for list');
+    throw new Error('Failed to fetch todos for list');
   }
   return result.data;
 }
 
 // Get all lists
-export async function getAllLists(): Promise<string[]> {
-  const response = await fetch(`${API_BASE_URL}/api/lists`);
+export async function getAllLists(): Promise<TodoList[]> {
+  const response = await authFetch(`${API_BASE_URL}/api/lists`);
   if (!response.ok) {
+    throw await apiError(response, 'Failed to fetch lists');
+  }
+  const result: ApiResponse<TodoList[]> = await response.json();
+  if (!result.success) {
     throw new Error('Failed to fetch lists');
   }
-  const result: ApiResponse<string[]> = await response.json();
+  return result.data;
+}
+
+// Fetch the users a list is shared with
+export async function getListMembers(listId: string): Promise<ListMember[]> {
+  const response = await authFetch(`${API_BASE_URL}/api/lists/${encodeURIComponent(listId)}/members`);
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to fetch list members');
+  }
+  const result: ApiResponse<ListMember[]> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to fetch list members');
+  }
+  return result.data;
+}
+
+// Share a list with a registered email address (or a user ID) as viewer or editor
+export async function addListMember(listId: string, user: { email?: string; user_id?: string }, role: ListMember['role']): Promise<ListMember> {
+  const response = await authFetch(`${API_BASE_URL}/api/lists/${encodeURIComponent(listId)}/members`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ ...user, role }),
+  });
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to share list');
+  }
+  const result: ApiResponse<ListMember> = await response.json();
   if (!result.success) {
-    throw new Error(result.error || 'Failed to fetch lists');
+    throw new Error('Failed to share list');
   }
   return result.data;
 }
 
+// Change a member's role
+export async function updateListMember(listId: string, userId: string, role: ListMember['role']): Promise<ListMember> {
+  const response = await authFetch(`${API_BASE_URL}/api/lists/${encodeURIComponent(listId)}/members/${encodeURIComponent(userId)}`, {
+    method: 'PUT',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ role }),
+  });
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to change member role');
+  }
+  const result: ApiResponse<ListMember> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to change member role');
+  }
+  return result.data;
+}
+
+// Stop sharing a list with a member; members may remove themselves to leave
+export async function removeListMember(listId: string, userId: string): Promise<void> {
+  const response = await authFetch(`${API_BASE_URL}/api/lists/${encodeURIComponent(listId)}/members/${encodeURIComponent(userId)}`, {
+    method: 'DELETE',
+  });
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to remove member');
+  }
+}
+
+// Apply several todo changes in one request. With atomic, either all of them
+// apply or none do, and the request fails with the first error.
+export async function batchTodos(operations: BatchOperation[], atomic = false): Promise<BatchResult[]> {
+  const response = await authFetch(`${API_BASE_URL}/api/todos/batch`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ operations, atomic }),
+  });
+  if (!response.ok) {
+    throw await apiError(response, 'Failed to apply batch');
+  }
+  const result: ApiResponse<BatchResult[]> = await response.json();
+  if (!result.success) {
+    throw new Error('Failed to apply batch');
+  }
+  return result.data;
+}
+
+// Watch todo changes, optionally for one list (null for the main list), until
+// the returned function is called. Reconnects automatically, resuming after the
+// last event received. Uses fetch rather than EventSource so the token travels
+// in a header instead of the URL.
+export function watchTodos(onEvent: (event: TodoEvent) => void, listId?: string | null): () => void {
+  const controller = new AbortController();
+  const query = listId === undefined ? '' : `?list=${encodeURIComponent(listId ?? 'main')}`;
+  let lastEventId = '';
+
+  const connect = async () => {
+    const headers: HeadersInit = lastEventId ? { 'Last-Event-ID': lastEventId } : {};
+    const response = await authFetch(`${API_BASE_URL}/api/todos/stream${query}`, { headers, signal: controller.signal });
+    if (!response.ok || !response.body) {
+      throw await apiError(response, 'Failed to watch todos');
+    }
+
+    // Events are "field: value" lines separated by a blank line; ":" lines are keep-alives
+    const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+    let buffer = '';
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) return;
+      buffer += value;
+      let end;
+      while ((end = buffer.indexOf('\n\n')) >= 0) {
+        const data = buffer.slice(0, end).split('\n')
+          .filter((line) => line.startsWith('data: '))
+          .map((line) => line.slice(6))
+          .join('');
+        buffer = buffer.slice(end + 2);
+        if (data) {
+          const event: TodoEvent = JSON.parse(data);
+          lastEventId = event.id;
+          onEvent(event);
+        }
+      }
+    }
+  };
+
+  (async () => {
+    let delay = 1000;
+    while (!controller.signal.aborted) {
+      try {
+        await connect();
+        delay = 1000;
+      } catch (error) {
+        if (controller.signal.aborted) return;
+        console.error('Todo stream interrupted:', error);
+      }
+      await new Promise((resolve) => setTimeout(resolve, delay));
+      delay = Math.min(delay * 2, 30000);
+    }
+  })();
+
+  return () => controller.abort();
+}
+
+// Sign in with an email and password; the token is stored for later requests
+export async function login(email: string, password: string): Promise<AuthResponse> {
+  return authenticate('login', email, password);
+}
+
+// Create an account and sign in
+export async function register(email: string, password: string): Promise<AuthResponse> {
+  return authenticate('register', email, password);
+}
+
+export function logout() {
+  setAuthToken(null);
+}
+
+async function authenticate(action: 'login' | 'register', email: string, password: string): Promise<AuthResponse> {
+  const response = await fetch(`${API_BASE_URL}/api/auth/${action}`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ email, password }),
+  });
+  if (!response.ok) {
+    throw await apiError(response, `Failed to ${action}`);
+  }
+  const result: ApiResponse<AuthResponse> = await response.json();
+  if (!result.success) {
+    throw new Error(`Failed to ${action}`);
+  }
+  setAuthToken(result.data.token);
+  return result.data;
+}